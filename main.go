@@ -0,0 +1,12 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package main
+
+import (
+	"github.com/freightdog/kustomize-sopssecretgenerator/v2/pkg/sopssecretgenerator"
+)
+
+func main() {
+	sopssecretgenerator.Main()
+}
@@ -0,0 +1,1206 @@
+// Copyright 2019-2020 Go About B.V. and contributors
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Parts adapted from kustomize, Copyright 2019 The Kubernetes Authors.
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/cmd/sops/common"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/getsops/sops/v3/config"
+	"github.com/getsops/sops/v3/keyservice"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+const apiVersion = "kustomize.freightdog.com/v1"
+const kind = "SopsSecretGenerator"
+
+var utf8bom = []byte{0xEF, 0xBB, 0xBF}
+var stripAnnotations = map[string]bool{
+	"config.kubernetes.io/local-config": true,
+	"config.kubernetes.io/function":     true,
+	dryRunAnnotation:                    true,
+}
+
+type kvMap map[string]string
+
+// TypeMeta defines the resource type
+type TypeMeta struct {
+	APIVersion string `json:"apiVersion" yaml:"apiVersion"`
+	Kind       string `json:"kind" yaml:"kind"`
+}
+
+// ObjectMeta contains Kubernetes resource metadata such as the name
+type ObjectMeta struct {
+	Name        string `json:"name" yaml:"name"`
+	Namespace   string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Labels      kvMap  `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Annotations kvMap  `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+}
+
+// SopsSecretGenerator is a generator for Secrets
+type SopsSecretGenerator struct {
+	TypeMeta              `json:",inline" yaml:",inline"`
+	ObjectMeta            `json:"metadata" yaml:"metadata"`
+	EnvSources            []string                  `json:"envs" yaml:"envs"`
+	FileSources           []string                  `json:"files" yaml:"files"`
+	Behavior              string                    `json:"behavior,omitempty" yaml:"behavior,omitempty"`
+	DisableNameSuffixHash bool                      `json:"disableNameSuffixHash,omitempty" yaml:"disableNameSuffixHash,omitempty"`
+	Type                  string                    `json:"type,omitempty" yaml:"type,omitempty"`
+	Policy                Policy                    `json:"policy,omitempty" yaml:"policy,omitempty"`
+	Timeout               string                    `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Offline               bool                      `json:"offline,omitempty" yaml:"offline,omitempty"`
+	KMS                   KMSOptions                `json:"kms,omitempty" yaml:"kms,omitempty"`
+	MaxFileSize           string                    `json:"maxFileSize,omitempty" yaml:"maxFileSize,omitempty"`
+	MaxFileSizes          kvMap                     `json:"maxFileSizes,omitempty" yaml:"maxFileSizes,omitempty"`
+	Reloader              bool                      `json:"reloader,omitempty" yaml:"reloader,omitempty"`
+	ReplicateTo           []string                  `json:"replicateTo,omitempty" yaml:"replicateTo,omitempty"`
+	AnnotationPresets     []string                  `json:"annotationPresets,omitempty" yaml:"annotationPresets,omitempty"`
+	Provenance            bool                      `json:"provenance,omitempty" yaml:"provenance,omitempty"`
+	RotateAfter           string                    `json:"rotateAfter,omitempty" yaml:"rotateAfter,omitempty"`
+	RotateStrict          bool                      `json:"rotateStrict,omitempty" yaml:"rotateStrict,omitempty"`
+	Extends               string                    `json:"extends,omitempty" yaml:"extends,omitempty"`
+	Variant               string                    `json:"variant,omitempty" yaml:"variant,omitempty"`
+	Variants              map[string]Variant        `json:"variants,omitempty" yaml:"variants,omitempty"`
+	AllowEmpty            bool                      `json:"allowEmpty,omitempty" yaml:"allowEmpty,omitempty"`
+	When                  Condition                 `json:"when,omitempty" yaml:"when,omitempty"`
+	NameSuffixHash        NameSuffixHash            `json:"nameSuffixHash,omitempty" yaml:"nameSuffixHash,omitempty"`
+	BaseDir               string                    `json:"baseDir,omitempty" yaml:"baseDir,omitempty"`
+	ClusterSources        []ClusterSource           `json:"clusterSources,omitempty" yaml:"clusterSources,omitempty"`
+	VaultSources          []VaultSource             `json:"vaultSources,omitempty" yaml:"vaultSources,omitempty"`
+	AWSSecretsManager     []AWSSecretsManagerSource `json:"awsSecretsManager,omitempty" yaml:"awsSecretsManager,omitempty"`
+	SSMParameters         []SSMParameter            `json:"ssmParameters,omitempty" yaml:"ssmParameters,omitempty"`
+	GCPSecretSources      []GCPSecretSource         `json:"gcpSecretSources,omitempty" yaml:"gcpSecretSources,omitempty"`
+	AzureKeyVaultSources  []AzureKeyVaultSource     `json:"azureKeyVaultSources,omitempty" yaml:"azureKeyVaultSources,omitempty"`
+	OnePasswordSources    []string                  `json:"onePasswordSources,omitempty" yaml:"onePasswordSources,omitempty"`
+	HelmValues            HelmValues                `json:"helmValues,omitempty" yaml:"helmValues,omitempty"`
+	DockerConfigs         []string                  `json:"dockerConfigs,omitempty" yaml:"dockerConfigs,omitempty"`
+	OutputKind            string                    `json:"outputKind,omitempty" yaml:"outputKind,omitempty"`
+	StringData            StringData                `json:"stringData,omitempty" yaml:"stringData,omitempty"`
+	CaseInsensitiveKeys   bool                      `json:"caseInsensitiveKeys,omitempty" yaml:"caseInsensitiveKeys,omitempty"`
+}
+
+// Secret is a Kubernetes Secret
+type Secret struct {
+	TypeMeta   `json:",inline" yaml:",inline"`
+	ObjectMeta `json:"metadata" yaml:"metadata"`
+	Data       kvMap  `json:"data" yaml:"data"`
+	StringData kvMap  `json:"stringData,omitempty" yaml:"stringData,omitempty"`
+	Type       string `json:"type,omitempty" yaml:"type,omitempty"`
+}
+
+func usage() {
+	usage := `
+		SopsSecretGenerator is a Kustomize generator plugin that generates Secrets from sops-encrypted files.
+
+		Note:
+		  The usage examples here are for standalone execution. If the plugin is used via Kustomize,
+		  then Kustomize will handle passing data to the plugin.
+
+		Usage:
+		  cat ResourceList.yaml | SopsSecretGenerator [FLAGS]
+		  SopsSecretGenerator [FLAGS] COMMAND [ARGS]
+
+		Flags:
+		  --no-cache    Do not use the decryption cache
+		  --parallel N  Process at most N generators at a time (default 8)
+		  --max-concurrency N  Decrypt files and fetch remote sources at most N at a time (default 8)
+		  --timings     Report decryption durations and KMS calls on stderr
+		  --no-color    Do not color warnings and errors when stderr is a terminal
+		  --no-progress Do not show the progress of decryption when stderr is a terminal
+		  --events ndjson  Emit progress events as JSON lines on stderr
+		  --events-file F  Write events to file F, such as /dev/fd/3, instead of stderr
+		  --dry-run     Replace the values of Secrets with a hash of the value
+		  --output FMT  Write a ResourceList (yaml, the default) or a List of the Secrets (json)
+		  --variant V   Generate variant V of generators that define variants
+		  --namespace N Generate the Secrets in namespace N
+		  --error-format FMT  Report errors as text (the default) or as a JSON object (json)
+		  --annotations FMT  Also report problems as GitHub Actions (github) or GitLab (gitlab) annotations
+		  --version     Print the version and exit
+
+		Commands:
+`
+
+	_, _ = fmt.Fprintf(os.Stderr, "%s", strings.ReplaceAll(usage, "		", ""))
+	commandsUsage(os.Stderr)
+}
+
+// exitWithError reports the error that failed the command on stderr, as text
+// or as a JSON error envelope, and exits with the exit code of its class.
+// Text errors are logged with the message, if any, and followed by the usage
+// if asked for.
+func exitWithError(err error, message string, showUsage bool) {
+	_, code := classifyError(err)
+	invocationProgress.finish()
+	switch {
+	case runtimeSettings.ErrorFormat == "json":
+		invocationEvents.emit(event{Type: eventError, Message: err.Error()})
+		_ = writeErrorJSON(os.Stderr, err)
+	case message != "":
+		// The logger emits the error event
+		runtimeSettings.logger().Error(message, "error", err)
+	default:
+		invocationEvents.emit(event{Type: eventError, Message: err.Error()})
+		_, _ = fmt.Fprintln(os.Stderr, colorize(colorRed, err.Error()))
+	}
+	if showUsage && runtimeSettings.ErrorFormat != "json" {
+		usage()
+	}
+	stopProfiles()
+	os.Exit(code)
+}
+
+// Main runs the SopsSecretGenerator command: a subcommand, if one is given,
+// and otherwise the KRM function on the ResourceList on stdin. It exits the
+// process when done.
+func Main() {
+	configFiles, err := applyConfigFiles()
+	if err != nil {
+		runtimeSettings.ErrorFormat = os.Getenv(envPrefix + "ERROR_FORMAT")
+		exitWithError(withCause(errFlags, err), "", false)
+	}
+	runtimeSettings, err = OptionsFromEnv()
+	if err != nil {
+		runtimeSettings.ErrorFormat = os.Getenv(envPrefix + "ERROR_FORMAT")
+		exitWithError(withCause(errFlags, err), "", false)
+	}
+
+	args, err := parseGlobalFlags(&runtimeSettings, os.Args[1:])
+	if err != nil {
+		exitWithError(withCause(errFlags, err), "", true)
+	}
+	stderrColor = colorEnabled(os.Stderr, runtimeSettings.NoColor)
+	invocationEvents, err = openEvents(runtimeSettings)
+	if err != nil {
+		exitWithError(withCause(errFlags, err), "", false)
+	}
+	runtimeSettings.Logger = withEvents(runtimeSettings.Logger, invocationEvents)
+	for _, configFile := range configFiles {
+		runtimeSettings.logger().Debug("read configuration file", "file", configFile)
+	}
+
+	if runtimeSettings.Timings {
+		invocationTimings = newTimings()
+	}
+	invocationProfiles, err = startProfiles(runtimeSettings.CPUProfile, runtimeSettings.MemProfile)
+	if err != nil {
+		runtimeSettings.logger().Warn("profiling disabled", "error", err)
+	}
+	invocationContext = notifyInterrupt(context.Background())
+	gpgTTYPassthrough = true
+	shutdownTracing, err := setupTracing()
+	if err != nil {
+		runtimeSettings.logger().Warn("tracing disabled", "error", err)
+	}
+	if _, ok := runtimeSettings.Decryptor.(FakeDecryptor); ok {
+		runtimeSettings.logger().Warn("fake decryption is enabled, only fixtures created with `encrypt --fake` are decrypted")
+	}
+
+	// Legacy exec plugins are passed the path of the generator manifest, so
+	// anything that is not a subcommand is left to the KRM function.
+	if len(args) > 0 {
+		if c, ok := findCommand(args[0]); ok {
+			code := runCommand(c, args[1:])
+			stopProfiles()
+			os.Exit(code)
+		}
+	}
+
+	stdinStat, _ := os.Stdin.Stat()
+
+	// Check the StdIn content.
+	if (stdinStat.Mode() & os.ModeCharDevice) != 0 {
+		usage()
+		stopProfiles()
+		os.Exit(exitConfig)
+	}
+
+	// The progress line would garble events on stderr
+	if !runtimeSettings.NoProgress && !invocationEvents.toStderr() && term.IsTerminal(int(os.Stderr.Fd())) {
+		invocationProgress = newProgress(os.Stderr, progressInterval)
+	}
+	if runtimeSettings.Output == "json" {
+		err = generateJSON(os.Stdin, os.Stdout)
+	} else {
+		err = fn.AsMain(fn.ResourceListProcessorFunc(generateKRMManifest))
+	}
+	invocationProgress.finish()
+	invocationTimings.report(os.Stderr)
+	invocationDataKeys.wipe()
+	if shutdownTracing != nil {
+		flushErr := flushTracing(shutdownTracing)
+		if flushErr != nil {
+			runtimeSettings.logger().Warn("could not export spans", "error", flushErr)
+		}
+	}
+	if err != nil {
+		exitWithError(err, "could not generate Secrets", true)
+	}
+	stopProfiles()
+}
+
+// generateKRMManifest reads ResourceList with SopsSecretGenerator items
+// and returns ResourceList with Secret items.
+func generateKRMManifest(rl *fn.ResourceList) (bool, error) {
+	ctx, span := tracer().Start(invocationContext, "ResourceList", trace.WithAttributes(attribute.Int("items", len(rl.Items))))
+	defer applyFunctionConfig(rl.FunctionConfig)()
+	invocationProvenance.reset()
+	state := openState()
+	var generatedSecrets fn.KubeObjects
+	items, err := expandLists(rl.Items)
+	if err == nil {
+		items, err = extendItems(items)
+	}
+	if err == nil {
+		items, err = applyDefaults(items)
+	}
+	if err == nil {
+		generatedSecrets, err = generateSecretObjects(ctx, items, runtimeSettings.Parallel, state)
+	}
+	if err == nil {
+		generatedSecrets, err = mergeSecrets(generatedSecrets)
+	}
+	endSpan(span, err)
+	rl.Results = append(rl.Results, staleWarnings.take()...)
+	if err != nil {
+		rl.LogResult(err)
+		return false, err
+	}
+	err = state.save()
+	if err != nil {
+		runtimeSettings.logger().Warn("could not save state file", "file", runtimeSettings.StateFile, "error", err)
+	}
+
+	rl.Items = generatedSecrets
+
+	return true, nil
+}
+
+// generateSecretObject generates the Secret for a SopsSecretGenerator item.
+// If the state file has a Secret for the same generator manifest and source
+// files, it is reused without decrypting anything.
+func generateSecretObject(ctx context.Context, item *fn.KubeObject, state *stateFile) (*fn.KubeObject, error) {
+	manifest := []byte(item.String())
+	input, err := readInput(manifest)
+	if err != nil {
+		return nil, err
+	}
+	input, err = applyVariant(input, activeVariant(input, runtimeSettings))
+	if err != nil {
+		return nil, err
+	}
+	input, err = renderName(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	input = applyNamespace(input, runtimeSettings)
+	if !input.When.holds() {
+		runtimeSettings.logger().Info("skipped generator, its condition does not hold", "generator", stateKey(input))
+		return nil, nil
+	}
+	input = skipMissingSources(input, runtimeSettings.logger())
+
+	// A digest error, such as a missing file, is reported by generating
+	// the Secret instead. Redacted Secrets neither come from nor go to the
+	// state file. Secrets from remote sources, which the digest does not
+	// cover, are always generated again.
+	if isDryRun(input, runtimeSettings) {
+		state = nil
+	}
+	var digest string
+	if state != nil && !hasRemoteSources(input) {
+		digest, _ = generatorDigest(ctx, manifest, input)
+		if previous, ok := state.get(stateKey(input), digest); ok {
+			// The recorded Secret has the same sources, but they may have
+			// become due for rotation since
+			err := checkReusedRotation(input)
+			if err != nil {
+				return nil, err
+			}
+			runtimeSettings.logger().Info("reused Secret from state file", "generator", stateKey(input))
+			return fn.ParseKubeObject([]byte(previous))
+		}
+	}
+
+	secret, err := generateSecret(ctx, input, runtimeSettings)
+	if err != nil {
+		return nil, err
+	}
+	if isEncryptedOutput(input) {
+		return encryptedSecretObject(secret, input, state, digest)
+	}
+	obj, err := newSecretKubeObject(secret)
+	if err != nil {
+		return nil, err
+	}
+	if digest != "" {
+		state.put(stateKey(input), digest, obj.String())
+	}
+	return obj, nil
+}
+
+func processSopsSecretGenerator(manifestContent []byte) (string, error) {
+	manifestContent, err := resolveExtends(manifestContent, nil, "", ".")
+	if err != nil {
+		return "", err
+	}
+	input, err := readInput(manifestContent)
+	if err != nil {
+		return "", err
+	}
+	if !input.When.holds() {
+		return "", nil
+	}
+	invocationProvenance.reset()
+	secret, err := generateSecret(invocationContext, input, runtimeSettings)
+	if err != nil {
+		return "", err
+	}
+	if isEncryptedOutput(input) {
+		obj, err := encryptedSecretObject(secret, input, nil, "")
+		if err != nil {
+			return "", err
+		}
+		return obj.String(), nil
+	}
+	output, err := yaml.Marshal(secret)
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+func generateSecret(ctx context.Context, sopsSecret SopsSecretGenerator, opts Options) (Secret, error) {
+	ctx, span := tracer().Start(ctx, "generate", trace.WithAttributes(
+		attribute.String("generator", sopsSecret.Name),
+		attribute.String("namespace", sopsSecret.Namespace),
+	))
+	sopsSecret = applyBaseDir(sopsSecret)
+	sopsSecret, err := applyVariant(sopsSecret, activeVariant(sopsSecret, opts))
+	if err != nil {
+		endSpan(span, err)
+		return Secret{}, err
+	}
+	sopsSecret, err = renderName(ctx, sopsSecret)
+	if err != nil {
+		endSpan(span, err)
+		return Secret{}, err
+	}
+	sopsSecret = applyNamespace(sopsSecret, opts)
+	sopsSecret = skipMissingSources(sopsSecret, opts.logger())
+	data, strs, err := parseInput(ctx, sopsSecret, opts)
+	endSpan(span, err)
+	if err != nil {
+		return Secret{}, err
+	}
+	if sopsSecret.CaseInsensitiveKeys {
+		err = checkKeyCase(data)
+		if err != nil {
+			return Secret{}, withCause(ErrInvalidGenerator, err)
+		}
+	}
+	if isDryRun(sopsSecret, opts) {
+		redactData(data)
+	}
+	stringData, err := splitStringData(data, strs)
+	if err != nil {
+		return Secret{}, err
+	}
+
+	annotations := make(kvMap)
+	for k, v := range sopsSecret.Annotations {
+		if _, skip := stripAnnotations[k]; skip {
+			continue
+		}
+		annotations[k] = v
+	}
+	presets, err := presetAnnotations(sopsSecret)
+	if err != nil {
+		return Secret{}, withCause(ErrInvalidGenerator, err)
+	}
+	for k, v := range presets {
+		annotations[k] = v
+	}
+	provenance, err := provenanceAnnotations(ctx, sopsSecret)
+	if err != nil {
+		return Secret{}, err
+	}
+	for k, v := range provenance {
+		annotations[k] = v
+	}
+	rotation, err := checkRotation(sopsSecret, time.Now(), opts.logger())
+	if err != nil {
+		return Secret{}, err
+	}
+	for k, v := range rotation {
+		annotations[k] = v
+	}
+	if !sopsSecret.DisableNameSuffixHash && sopsSecret.NameSuffixHash.Algorithm == "" {
+		annotations["kustomize.config.k8s.io/needs-hash"] = "true"
+	}
+	if sopsSecret.Behavior != "" {
+		annotations["kustomize.config.k8s.io/behavior"] = sopsSecret.Behavior
+	}
+
+	secret := Secret{
+		TypeMeta: TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: ObjectMeta{
+			Name:        sopsSecret.Name,
+			Namespace:   sopsSecret.Namespace,
+			Labels:      sopsSecret.Labels,
+			Annotations: annotations,
+		},
+		Data:       data,
+		StringData: stringData,
+		Type:       secretType(sopsSecret),
+	}
+	if sopsSecret.NameSuffixHash.Algorithm != "" {
+		hash, err := secretNameHash(secret, sopsSecret.NameSuffixHash)
+		if err != nil {
+			return Secret{}, err
+		}
+		secret.Name += "-" + hash
+	}
+	err = validateSecret(secret, !isDryRun(sopsSecret, opts))
+	if err != nil {
+		return Secret{}, err
+	}
+	opts.logger().Info("generated Secret", "generator", sopsSecret.Name, "namespace", sopsSecret.Namespace, "keys", len(data)+len(stringData))
+	return secret, nil
+}
+
+// newSecretKubeObject builds the KubeObject for a Secret field by field, in
+// the order that the Secret would be marshalled in. Maps are set key by key,
+// because the KubeObject setters convert maps through JSON.
+func newSecretKubeObject(secret Secret) (*fn.KubeObject, error) {
+	obj := fn.NewEmptyKubeObject()
+	var err error
+	set := func(value string, fields ...string) {
+		if err == nil {
+			err = obj.SetNestedString(value, fields...)
+		}
+	}
+	setMap := func(m kvMap, fields ...string) {
+		for _, k := range slices.Sorted(maps.Keys(m)) {
+			set(m[k], append(fields, k)...)
+		}
+	}
+
+	set(secret.APIVersion, "apiVersion")
+	set(secret.Kind, "kind")
+	set(secret.Name, "metadata", "name")
+	if secret.Namespace != "" {
+		set(secret.Namespace, "metadata", "namespace")
+	}
+	setMap(secret.Labels, "metadata", "labels")
+	setMap(secret.Annotations, "metadata", "annotations")
+	if len(secret.Data) == 0 && err == nil {
+		err = obj.SetNestedStringMap(map[string]string{}, "data")
+	}
+	setMap(secret.Data, "data")
+	setMap(secret.StringData, "stringData")
+	if secret.Type != "" {
+		set(secret.Type, "type")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func readFile(fileName string) ([]byte, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return []byte{}, err
+	}
+
+	return content, nil
+}
+
+func readInput(manifestContent []byte) (SopsSecretGenerator, error) {
+	input := SopsSecretGenerator{
+		TypeMeta: TypeMeta{},
+		ObjectMeta: ObjectMeta{
+			Annotations: make(kvMap),
+		},
+	}
+
+	err := yaml.Unmarshal(manifestContent, &input)
+	if err != nil {
+		return SopsSecretGenerator{}, withCause(ErrInvalidGenerator, err)
+	}
+	err = validateInput(input)
+	if err != nil {
+		return SopsSecretGenerator{}, err
+	}
+	return applyBaseDir(input), nil
+}
+
+// validateInput checks the type, name and annotation presets of a generator.
+func validateInput(input SopsSecretGenerator) error {
+	if input.APIVersion != apiVersion || input.Kind != kind {
+		return withCause(ErrInvalidGenerator, errors.Errorf("input must be apiVersion %s, kind %s", apiVersion, kind))
+	}
+	if input.Name == "" {
+		return withCause(ErrInvalidGenerator, errors.New("input must contain metadata.name value"))
+	}
+	_, err := presetAnnotations(input)
+	if err != nil {
+		return withCause(ErrInvalidGenerator, err)
+	}
+	err = validateVariant(input)
+	if err != nil {
+		return withCause(ErrInvalidGenerator, err)
+	}
+	err = validateNameSuffixHash(input)
+	if err != nil {
+		return withCause(ErrInvalidGenerator, err)
+	}
+	err = validateOutputKind(input)
+	if err != nil {
+		return withCause(ErrInvalidGenerator, err)
+	}
+	err = validateStringData(input)
+	if err != nil {
+		return withCause(ErrInvalidGenerator, err)
+	}
+	err = validateDockerConfigs(input)
+	if err != nil {
+		return withCause(ErrInvalidGenerator, err)
+	}
+	err = validateRotateAfter(input)
+	if err != nil {
+		return withCause(ErrInvalidGenerator, err)
+	}
+	for _, source := range input.ClusterSources {
+		err = source.validate()
+		if err != nil {
+			return withCause(ErrInvalidGenerator, err)
+		}
+	}
+	for _, source := range input.VaultSources {
+		err = source.validate()
+		if err != nil {
+			return withCause(ErrInvalidGenerator, err)
+		}
+	}
+	for _, source := range input.AWSSecretsManager {
+		err = source.validate()
+		if err != nil {
+			return withCause(ErrInvalidGenerator, err)
+		}
+	}
+	for _, parameter := range input.SSMParameters {
+		err = parameter.validate()
+		if err != nil {
+			return withCause(ErrInvalidGenerator, err)
+		}
+	}
+	for _, source := range input.GCPSecretSources {
+		err = source.validate()
+		if err != nil {
+			return withCause(ErrInvalidGenerator, err)
+		}
+	}
+	for _, source := range input.AzureKeyVaultSources {
+		err = source.validate()
+		if err != nil {
+			return withCause(ErrInvalidGenerator, err)
+		}
+	}
+	for _, source := range input.OnePasswordSources {
+		_, err = parseOnePasswordRef(source)
+		if err != nil {
+			return withCause(ErrInvalidGenerator, errors.Wrap(err, "onePasswordSources"))
+		}
+	}
+	if isNameTemplate(input.Name) {
+		_, err = parseNameTemplate(context.Background(), input.Name)
+		if err != nil {
+			return withCause(ErrInvalidGenerator, err)
+		}
+	}
+	return nil
+}
+
+// decryptOptions holds the generator settings that apply to decrypting its sources
+type decryptOptions struct {
+	Generator         string
+	Policy            Policy
+	Timeout           time.Duration
+	Offline           bool
+	KMSRegions        []string
+	KMSAttemptTimeout time.Duration
+	Retry             retryPolicy
+	MaxFileSize       int64
+	MaxFileSizes      map[string]int64
+	AuditLog          string
+	Logger            *slog.Logger
+	Cache             *decryptCache
+	Decryptor         Decryptor
+	Prefetched        prefetchedFiles
+	Strings           *stringKeys
+	Workers           chan struct{}
+	Context           context.Context
+}
+
+// workers returns the worker pool that bounds decryptions and fetches, or
+// the default pool if none is set.
+func (o decryptOptions) workers() chan struct{} {
+	if o.Workers == nil {
+		return openWorkers(0)
+	}
+	return o.Workers
+}
+
+// context returns the context that cancels decryptions, or the background
+// context if none is set.
+func (o decryptOptions) context() context.Context {
+	if o.Context == nil {
+		return context.Background()
+	}
+	return o.Context
+}
+
+func parseInput(ctx context.Context, input SopsSecretGenerator, options Options) (kvMap, *stringKeys, error) {
+	data := make(kvMap)
+	opts, err := newDecryptOptions(input, options)
+	if err != nil {
+		return nil, nil, withCause(ErrInvalidGenerator, err)
+	}
+	opts.Context = ctx
+	opts.Strings = newStringKeys(input)
+	files := inputFiles(input)
+	invocationProgress.add(len(files) + len(input.HelmValues.Files) + len(input.DockerConfigs))
+	opts.Prefetched = prefetchFiles(files, opts)
+	defer opts.Prefetched.wipe()
+
+	// Every source is parsed even if one fails, so that all failing sources
+	// are reported at once
+	var errs []error
+	for _, parse := range []func() error{
+		func() error { return parseEnvSources(input.EnvSources, opts, data) },
+		func() error { return parseClusterSources(input.ClusterSources, opts, data) },
+		func() error { return parseVaultSources(input.VaultSources, opts, data) },
+		func() error { return parseAWSSecretsManagerSources(input.AWSSecretsManager, opts, data) },
+		func() error { return parseSSMParameters(input.SSMParameters, opts, data) },
+		func() error { return parseGCPSecretSources(input.GCPSecretSources, opts, data) },
+		func() error { return parseAzureKeyVaultSources(input.AzureKeyVaultSources, opts, data) },
+		func() error { return parseOnePasswordSources(input.OnePasswordSources, opts, data) },
+		func() error { return parseFileSources(input.FileSources, opts, data) },
+		func() error { return parseHelmValues(input.HelmValues, opts, data) },
+		func() error { return parseDockerConfigs(input.DockerConfigs, opts, data) },
+	} {
+		if err := parse(); err != nil {
+			errs = append(errs, err)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if err := joinSourceErrors(errs); err != nil {
+		return nil, nil, err
+	}
+	return data, opts.Strings, nil
+}
+
+// hasRemoteSources reports whether a generator has sources that are not
+// files, whose contents cannot be digested without reading them.
+func hasRemoteSources(input SopsSecretGenerator) bool {
+	return len(input.ClusterSources)+len(input.VaultSources)+len(input.AWSSecretsManager)+len(input.SSMParameters)+
+		len(input.GCPSecretSources)+len(input.AzureKeyVaultSources)+len(input.OnePasswordSources) > 0
+}
+
+// inputFiles returns the files to decrypt for a generator. Invalid sources
+// are skipped, they are reported when the sources are parsed.
+func inputFiles(input SopsSecretGenerator) []string {
+	files, fileSources := allVariantSources(input)
+	for _, source := range fileSources {
+		_, fileName, err := parseFileName(source)
+		if err == nil {
+			files = append(files, fileName)
+		}
+	}
+	return files
+}
+
+// newDecryptOptions returns the decryption options for a generator. Fields of
+// the generator take precedence over the options.
+func newDecryptOptions(input SopsSecretGenerator, options Options) (decryptOptions, error) {
+	opts := decryptOptions{
+		Generator:         input.Name,
+		Policy:            input.Policy,
+		Timeout:           options.Timeout,
+		Offline:           input.Offline || options.Offline,
+		KMSRegions:        options.KMSRegions,
+		KMSAttemptTimeout: options.KMSAttemptTimeout,
+		Retry:             retryPolicy{retries: options.KMSRetries, backoff: options.KMSRetryBackoff},
+		MaxFileSize:       options.MaxFileSize,
+		AuditLog:          options.AuditLog,
+		Cache:             openCache(options),
+		Decryptor:         options.Decryptor,
+		Workers:           openWorkers(options.MaxConcurrency),
+		Logger:            options.logger(),
+	}
+	if input.Timeout != "" {
+		timeout, err := time.ParseDuration(input.Timeout)
+		if err != nil {
+			return decryptOptions{}, errors.Wrap(err, "invalid timeout")
+		}
+		opts.Timeout = timeout
+	}
+	if len(input.KMS.Regions) > 0 {
+		opts.KMSRegions = input.KMS.Regions
+	}
+	if input.KMS.AttemptTimeout != "" {
+		timeout, err := time.ParseDuration(input.KMS.AttemptTimeout)
+		if err != nil {
+			return decryptOptions{}, errors.Wrap(err, "invalid kms attemptTimeout")
+		}
+		opts.KMSAttemptTimeout = timeout
+	}
+	if input.KMS.Retries < 0 {
+		return decryptOptions{}, errors.New("invalid kms retries, must not be negative")
+	}
+	if input.KMS.Retries > 0 {
+		opts.Retry.retries = input.KMS.Retries
+	}
+	if input.KMS.RetryBackoff != "" {
+		backoff, err := time.ParseDuration(input.KMS.RetryBackoff)
+		if err != nil {
+			return decryptOptions{}, errors.Wrap(err, "invalid kms retryBackoff")
+		}
+		opts.Retry.backoff = backoff
+	}
+	if input.MaxFileSize != "" {
+		limit, err := parseSize(input.MaxFileSize)
+		if err != nil {
+			return decryptOptions{}, errors.Wrap(err, "invalid maxFileSize")
+		}
+		opts.MaxFileSize = limit
+	}
+	limits, err := parseMaxFileSizes(input)
+	if err != nil {
+		return decryptOptions{}, err
+	}
+	opts.MaxFileSizes = limits
+	return opts, nil
+}
+
+func parseEnvSources(sources []string, opts decryptOptions, data kvMap) error {
+	var errs []error
+	for _, source := range sources {
+		parsed := make(kvMap)
+		err := parseEnvSource(source, opts, parsed)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "env source \"%s\"", source))
+			if opts.Context != nil && opts.Context.Err() != nil {
+				break
+			}
+			continue
+		}
+		opts.Strings.record(source, parsed)
+		maps.Copy(data, parsed)
+	}
+	return joinSourceErrors(errs)
+}
+
+func parseEnvSource(source string, opts decryptOptions, data kvMap) error {
+	decrypted, err := decryptFile(source, opts)
+	if err != nil {
+		return err
+	}
+	defer wipe(decrypted)
+
+	filePath, _, err := splitExtract(source)
+	if err != nil {
+		return err
+	}
+
+	return parseEnvFileContent(decrypted, filePath, data)
+}
+
+// parseEnvContent parses the decrypted content of an env source.
+func parseEnvContent(content []byte, format formats.Format, data kvMap) error {
+	switch format {
+	case formats.Dotenv:
+		return parseDotEnvContent(content, data)
+	case formats.Yaml:
+		return parseYAMLContent(content, data)
+	case formats.Json:
+		return parseJSONContent(content, data)
+	default:
+		return withCause(ErrUnknownFormat, errors.New("unknown file format, use dotenv, yaml, json, jsonc or csv"))
+	}
+}
+
+func parseDotEnvContent(content []byte, data kvMap) error {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineNum := 0
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		lineNum++
+		// Strip UTF-8 byte order mark from first line
+		if lineNum == 1 {
+			line = bytes.TrimPrefix(line, utf8bom)
+		}
+		err := parseDotEnvLine(line, data)
+		var parseErr parseError
+		if errors.As(err, &parseErr) {
+			parseErr.Line = lineNum
+			return parseErr
+		}
+		if err != nil {
+			return errors.Wrapf(err, "line %d", lineNum)
+		}
+	}
+	return scanner.Err()
+}
+
+func parseDotEnvLine(line []byte, data kvMap) error {
+	if column := invalidUTF8Column(line); column > 0 {
+		return parseError{Column: column, Message: "invalid UTF-8"}
+	}
+
+	trimmed := bytes.TrimLeftFunc(line, unicode.IsSpace)
+
+	if len(trimmed) == 0 || trimmed[0] == '#' {
+		return nil
+	}
+
+	key, value, found := bytes.Cut(trimmed, []byte("="))
+	if !found {
+		return parseError{Column: utf8.RuneCount(line) + 1, Message: "expected KEY=value, the line has no '='"}
+	}
+
+	data[string(key)] = encodeBase64(value)
+	return nil
+}
+
+func parseJSONContent(content []byte, data kvMap) error {
+	d := make(kvMap)
+	err := json.Unmarshal(content, &d)
+	if err != nil {
+		return jsonParseError(content, err)
+	}
+	for k, v := range d {
+		data[k] = encodeBase64([]byte(v))
+	}
+	return nil
+}
+
+func parseFileSources(sources []string, opts decryptOptions, data kvMap) error {
+	var errs []error
+	for _, source := range sources {
+		parsed := make(kvMap)
+		err := parseFileSource(source, opts, parsed)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "file source \"%s\"", source))
+			if opts.Context != nil && opts.Context.Err() != nil {
+				break
+			}
+			continue
+		}
+		opts.Strings.record(source, parsed)
+		maps.Copy(data, parsed)
+	}
+	return joinSourceErrors(errs)
+}
+
+func decryptFile(source string, opts decryptOptions) ([]byte, error) {
+	if result, ok := opts.Prefetched.take(source); ok {
+		return result.decrypted, result.err
+	}
+
+	filePath, treePath, err := splitExtract(source)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkFileSize(filePath, opts.maxFileSize(filePath))
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read file")
+	}
+
+	format := formats.FormatForPath(filePath)
+	ctx, span := tracer().Start(opts.context(), "decrypt", trace.WithAttributes(
+		attribute.String("generator", opts.Generator),
+		attribute.String("file", filePath),
+		attribute.Int("size", len(content)),
+	))
+	opts.Context = ctx
+	decrypted, err := decryptData(filePath, content, format, opts)
+	endSpan(span, err)
+	invocationProgress.fileDone(filePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "sops could not decrypt")
+	}
+	if treePath != nil {
+		defer wipe(decrypted)
+		return extractValue(decrypted, format, treePath)
+	}
+	return decrypted, nil
+}
+
+// decryptData checks the sops metadata against the generator policy before
+// handing the content to sops for decryption. In offline mode, network key
+// services are removed from the metadata so that sops only tries local keys.
+// Every decryption attempt is recorded in the audit log, if enabled.
+func decryptData(filePath string, content []byte, format formats.Format, opts decryptOptions) ([]byte, error) {
+	store := common.StoreForFormat(format, config.NewStoresConfig())
+	tree, err := store.LoadEncryptedFile(content)
+	if err != nil {
+		return nil, loadError(err, content, format)
+	}
+	err = opts.Policy.check(tree.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Policy.MatchCreationRules {
+		err = checkCreationRule(filePath, tree.Metadata)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.Offline || len(opts.KMSRegions) > 0 {
+		if opts.Offline {
+			err = localKeysOnly(&tree.Metadata)
+			if err != nil {
+				return nil, err
+			}
+		}
+		orderKMSKeys(&tree.Metadata, opts.KMSRegions)
+		content, err = store.EmitEncryptedFile(tree)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Only the metadata of the parsed tree is needed from here on. Dropping
+	// the encrypted values lets large files be collected while sops decrypts
+	// its own copy.
+	tree.Branches = nil
+	span := trace.SpanFromContext(opts.context())
+	span.SetAttributes(keyAttributes(tree.Metadata)...)
+
+	var kmsCalls atomic.Int64
+	passGPGTTY(tree.Metadata)
+	decryptor, err := fileDecryptor(tree.Metadata, opts, &kmsCalls)
+	if err != nil {
+		return nil, err
+	}
+	decryptFn := func() ([]byte, error) {
+		decrypted, err := decryptor.Decrypt(content, format)
+		return decrypted, gpgAgentError(decryptError(err))
+	}
+
+	start := time.Now()
+	decrypted, cached, err := decryptCached(opts.context(), opts.Cache, content, decryptFn, opts.Timeout)
+	duration := time.Since(start)
+	span.SetAttributes(attribute.Bool("cached", cached))
+	invocationTimings.addFile(fileTiming{
+		generator: opts.Generator,
+		file:      filePath,
+		duration:  duration,
+		kmsCalls:  kmsCalls.Load(),
+		cached:    cached,
+	})
+	if err != nil {
+		opts.logger().Debug("could not decrypt file", "generator", opts.Generator, "file", filePath, "duration", duration, "error", err)
+	} else {
+		opts.logger().Debug("decrypted file", "generator", opts.Generator, "file", filePath, "duration", duration, "cached", cached)
+		invocationEvents.emit(event{
+			Type:       eventFileDecrypted,
+			Generator:  opts.Generator,
+			File:       filePath,
+			DurationMS: duration.Milliseconds(),
+			Cached:     cached,
+		})
+	}
+	record := newAuditRecord(filePath, tree.Metadata, opts.Generator, err)
+	record.Cached = cached
+	auditErr := writeAuditRecord(opts.AuditLog, record)
+	if auditErr != nil {
+		wipe(decrypted)
+		return nil, auditErr
+	}
+	return decrypted, err
+}
+
+// decryptKeyServer returns the local key service for decrypting a file, or nil
+// if sops can decrypt it by itself.
+func decryptKeyServer(metadata sops.Metadata, opts decryptOptions) (keyservice.KeyServiceServer, error) {
+	var server keyservice.KeyServiceServer
+	if hasAgeKeys(metadata) {
+		identities, err := agePluginIdentities()
+		if err != nil {
+			return nil, err
+		}
+		if identities != nil {
+			server = ageKeyServer{identities: identities}
+		}
+	}
+	if hasSharedClientKeys(metadata) {
+		if server == nil {
+			server = keyservice.Server{}
+		}
+		server = sharedClientServer{next: server, credentials: invocationCredentials}
+	}
+	if opts.KMSAttemptTimeout > 0 && hasKMSKeys(metadata) {
+		if server == nil {
+			server = keyservice.Server{}
+		}
+		server = kmsFailoverServer{next: server, timeout: opts.KMSAttemptTimeout}
+	}
+	if opts.Retry.retries > 0 && hasNetworkKeys(metadata) {
+		if server == nil {
+			server = keyservice.Server{}
+		}
+		server = retryServer{next: server, policy: opts.Retry, ctx: opts.context()}
+	}
+	return server, nil
+}
+
+// decryptCached returns the plaintext of encrypted content from the cache, or
+// decrypts it and stores it in the cache. It reports whether the plaintext
+// came from the cache.
+func decryptCached(ctx context.Context, c *decryptCache, content []byte, decryptFn func() ([]byte, error), timeout time.Duration) ([]byte, bool, error) {
+	if c != nil {
+		if plaintext, ok := c.get(content); ok {
+			return plaintext, true, nil
+		}
+	}
+	decrypted, err := decryptWithTimeout(ctx, decryptFn, timeout)
+	if err == nil && c != nil {
+		_ = c.put(content, decrypted)
+	}
+	return decrypted, false, err
+}
+
+// decryptWithTimeout runs the decryption, giving up after the timeout or when
+// the context is canceled. A zero timeout waits indefinitely. sops does not
+// support cancellation, so an abandoned decryption keeps running in the
+// background until the process exits.
+func decryptWithTimeout(ctx context.Context, decryptFn func() ([]byte, error), timeout time.Duration) ([]byte, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, errors.Wrap(err, "decryption canceled")
+	}
+	if timeout <= 0 && ctx.Done() == nil {
+		return decryptFn()
+	}
+
+	type result struct {
+		decrypted []byte
+		err       error
+	}
+	done := make(chan result, 1)
+	go func() {
+		decrypted, err := decryptFn()
+		done <- result{decrypted, err}
+	}()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case r := <-done:
+		return r.decrypted, r.err
+	case <-expired:
+		return nil, errors.Errorf("decryption timed out after %s, check that the key services are reachable", timeout)
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err(), "decryption canceled")
+	}
+}
+
+func parseFileSource(source string, opts decryptOptions, data kvMap) error {
+	key, fname, err := parseFileName(source)
+	if err != nil {
+		return err
+	}
+
+	decrypted, err := decryptFile(fname, opts)
+	if err != nil {
+		return err
+	}
+	defer wipe(decrypted)
+
+	data[key] = encodeBase64(decrypted)
+	return nil
+}
+
+// parseFileName returns the data key and the file path of a file source,
+// "path" or "key=path". An '=' in the key or the path is escaped as '\='.
+func parseFileName(source string) (key string, fn string, err error) {
+	key, fn, found := cutFileSource(source)
+	if !found {
+		fn = unescapeFileSource(source)
+		filePath, treePath, err := splitExtract(fn)
+		if err != nil {
+			return "", "", err
+		}
+		if treePath != nil {
+			return extractKey(filePath, treePath), fn, nil
+		}
+		return path.Base(fn), fn, nil
+	}
+	if _, _, found := cutFileSource(fn); found {
+		return "", "", errors.New("key names or file paths cannot contain '=', escape it as '\\='")
+	}
+	key, fn = unescapeFileSource(key), unescapeFileSource(fn)
+	if key == "" {
+		return "", "", fmt.Errorf("key name for file path \"%s\" missing", fn)
+	} else if fn == "" {
+		return "", "", fmt.Errorf("file path for key name \"%s\" missing", key)
+	}
+	return key, fn, nil
+}
+
+// cutFileSource splits a file source around its first '=' that is not
+// escaped as '\='. Both parts are returned escaped.
+func cutFileSource(source string) (key string, filePath string, found bool) {
+	for i := 0; i < len(source); i++ {
+		if source[i] == '=' && (i == 0 || source[i-1] != '\\') {
+			return source[:i], source[i+1:], true
+		}
+	}
+	return "", source, false
+}
+
+// unescapeFileSource replaces the escaped '\=' of a file source with '='.
+func unescapeFileSource(source string) string {
+	return strings.ReplaceAll(source, `\=`, "=")
+}
@@ -2,12 +2,15 @@
 // Copyright 2024-2025 Freightdog B.V. and contributors
 // Licensed under the Apache License, Version 2.0.
 
-package main
+package sopssecretgenerator
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
+	"io/fs"
 	"os"
+	"os/exec"
 	"reflect"
 	"strings"
 	"testing"
@@ -16,6 +19,7 @@ import (
 	"github.com/getsops/sops/v3/pgp"
 	"github.com/lithammer/dedent"
 	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
 )
 
 const testkeyFingerprint = "2D2483DF73A3A0FAEE3C2A695BDC395360CE8FF4"
@@ -36,6 +40,11 @@ sDRQLEMprueQOkhMr/JzgWRCV8JYxRFOqXjl7PRBjmgNPMu2GzRIB8D/+SU=
 // Test suite setup
 
 func TestMain(m *testing.M) {
+	// Test_Main runs the test binary as the command
+	if os.Getenv("SOPS_SECRETGEN_TEST_MAIN") != "" {
+		Main()
+		os.Exit(0)
+	}
 	err := setupGnuPG()
 	if err != nil {
 		_, _ = fmt.Fprintln(os.Stderr, err)
@@ -151,6 +160,54 @@ func Test_GenerateKRMManifest(t *testing.T) {
 	}
 }
 
+// Test_Main checks that failures of the command only write diagnostics to
+// stderr, leaving stdout to the ResourceList, or nothing.
+func Test_Main(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		rlFile   string
+		wantCode int
+		wantRL   bool
+	}{
+		{"ResourceList", nil, "testdata/krm-error.yaml", 0, true},
+		{"JSON", []string{"--output=json"}, "testdata/krm-error.yaml", 0, false},
+		{"Flags", []string{"--parallel", "many"}, "testdata/krm-function-input.yaml", exitConfig, false},
+		{"ErrorFormat", []string{"--error-format=xml"}, "testdata/krm-function-input.yaml", exitConfig, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, err := os.Open(tt.rlFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = in.Close() }()
+			var stdout, stderr strings.Builder
+			cmd := exec.Command(os.Args[0], tt.args...)
+			cmd.Env = append(os.Environ(), "SOPS_SECRETGEN_TEST_MAIN=1", "GNUPGHOME=testdata")
+			cmd.Stdin, cmd.Stdout, cmd.Stderr = in, &stdout, &stderr
+			err = cmd.Run()
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				t.Fatalf("command error = %v, want it to fail", err)
+			}
+			if tt.wantCode != 0 && exitErr.ExitCode() != tt.wantCode {
+				t.Errorf("command exit code = %d, want %d", exitErr.ExitCode(), tt.wantCode)
+			}
+			if stderr.Len() == 0 {
+				t.Error("command wrote nothing to stderr")
+			}
+			if tt.wantRL {
+				if _, err := fn.ParseResourceList([]byte(stdout.String())); err != nil {
+					t.Errorf("command stdout is not a ResourceList: %v\n%s", err, stdout.String())
+				}
+			} else if stdout.Len() != 0 {
+				t.Errorf("command stdout = %q, want nothing", stdout.String())
+			}
+		})
+	}
+}
+
 func Test_ProcessSopsSecretGenerator(t *testing.T) {
 	type args struct {
 		fn string
@@ -262,7 +319,7 @@ func Test_generateSecret(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Run(tt.name, func(t *testing.T) {
-				got, err := generateSecret(tt.args.sopsSecret)
+				got, err := generateSecret(context.Background(), tt.args.sopsSecret, Options{})
 				if (err != nil) != tt.wantErr {
 					t.Errorf("generateSecret() error = %v, wantErr %v", err, tt.wantErr)
 					return
@@ -275,6 +332,45 @@ func Test_generateSecret(t *testing.T) {
 	}
 }
 
+func Test_newSecretKubeObject(t *testing.T) {
+	secret := func(meta ObjectMeta, data kvMap, secretType string) Secret {
+		return Secret{TypeMeta: TypeMeta{APIVersion: "v1", Kind: "Secret"}, ObjectMeta: meta, Data: data, Type: secretType}
+	}
+	tests := []struct {
+		name   string
+		secret Secret
+	}{
+		{"Minimal", secret(ObjectMeta{Name: "minimal"}, kvMap{"KEY": b64("value")}, "")},
+		{"EmptyData", secret(ObjectMeta{Name: "empty"}, kvMap{}, "")},
+		{"AllFields", secret(ObjectMeta{
+			Name:        "all",
+			Namespace:   "ns",
+			Labels:      kvMap{"app": "my-app", "tier": "backend"},
+			Annotations: kvMap{"kustomize.config.k8s.io/needs-hash": "true", "a.example.com/owner": "me"},
+		}, kvMap{"file.txt": b64("secret\n"), "B": b64("2"), "A": b64("1")}, "kubernetes.io/tls")},
+		{"Quoting", secret(ObjectMeta{Name: "quoting", Labels: kvMap{"number": "1", "bool": "true"}}, kvMap{"KEY": ""}, "")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newSecretKubeObject(tt.secret)
+			if err != nil {
+				t.Fatalf("newSecretKubeObject() error = %v", err)
+			}
+			manifest, err := yaml.Marshal(tt.secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := fn.ParseKubeObject(manifest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != want.String() {
+				t.Errorf("newSecretKubeObject()\ngot = %v\nwant = %v", got, want)
+			}
+		})
+	}
+}
+
 func Test_readFile(t *testing.T) {
 	type args struct {
 		fn string
@@ -342,10 +438,16 @@ func Test_parseInput(t *testing.T) {
 		{"Input", args{ssg([]string{"testdata/vars.env"}, []string{"testdata/file.txt"})}, kvMap{"VAR_ENV": b64("val_env"), "file.txt": b64("secret\n")}, false},
 		{"EnvsError", args{ssg([]string{"testdata/file.txt"}, []string{"testdata/file.txt"})}, nil, true},
 		{"FilesError", args{ssg([]string{"testdata/vars.env"}, []string{"testdata/missing.txt"})}, nil, true},
+		{"Timeout", args{withTimeout(ssg(nil, []string{"testdata/file.txt"}), "1m")}, kvMap{"file.txt": b64("secret\n")}, false},
+		{"InvalidTimeout", args{withTimeout(ssg(nil, []string{"testdata/file.txt"}), "soon")}, nil, true},
+		{"InvalidKMSAttemptTimeout", args{withKMS(ssg(nil, []string{"testdata/file.txt"}), KMSOptions{AttemptTimeout: "soon"})}, nil, true},
+		{"InvalidKMSRetryBackoff", args{withKMS(ssg(nil, []string{"testdata/file.txt"}), KMSOptions{Retries: 2, RetryBackoff: "soon"})}, nil, true},
+		{"NegativeKMSRetries", args{withKMS(ssg(nil, []string{"testdata/file.txt"}), KMSOptions{Retries: -1})}, nil, true},
+		{"KMSOptions", args{withKMS(ssg(nil, []string{"testdata/file.txt"}), KMSOptions{Regions: []string{"eu-west-1"}, AttemptTimeout: "5s"})}, kvMap{"file.txt": b64("secret\n")}, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseInput(tt.args.input)
+			got, _, err := parseInput(context.Background(), tt.args.input, Options{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseInput() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -357,6 +459,22 @@ func Test_parseInput(t *testing.T) {
 	}
 }
 
+func Test_parseInput_allErrors(t *testing.T) {
+	input := ssg([]string{"testdata/missing.env", "testdata/vars.env", "testdata/file.txt"}, []string{"testdata/missing.txt", "testdata/file.txt"})
+	_, _, err := parseInput(context.Background(), input, Options{})
+	if err == nil {
+		t.Fatal("parseInput() succeeded")
+	}
+	for _, source := range []string{"env source \"testdata/missing.env\"", "env source \"testdata/file.txt\"", "file source \"testdata/missing.txt\""} {
+		if !strings.Contains(err.Error(), source) {
+			t.Errorf("parseInput() error = %v, want %s", err, source)
+		}
+	}
+	if !errors.Is(err, fs.ErrNotExist) || !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("parseInput() error = %v, want both causes", err)
+	}
+}
+
 func Test_parseEnvSources(t *testing.T) {
 	type args struct {
 		sources []string
@@ -374,7 +492,7 @@ func Test_parseEnvSources(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			got := make(kvMap)
-			err := parseEnvSources(tt.args.sources, got)
+			err := parseEnvSources(tt.args.sources, decryptOptions{}, got)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseEnvSources() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -399,6 +517,7 @@ func Test_parseEnvSource(t *testing.T) {
 		{"DotEnv", args{"testdata/vars.env"}, kvMap{"VAR_ENV": b64("val_env")}, false},
 		{"YAML", args{"testdata/vars.yaml"}, kvMap{"VAR_YAML": b64("val_yaml")}, false},
 		{"JSON", args{"testdata/vars.json"}, kvMap{"VAR_JSON": b64("val_json")}, false},
+		{"ExtractScalar", args{`testdata/vars.yaml["VAR_YAML"]`}, kvMap{}, true},
 		{"Binary", args{"testdata/file.txt"}, kvMap{}, true},
 		{"Missing", args{"testdata/missing.txt"}, kvMap{}, true},
 		{"NotSops", args{"testdata/empty.txt"}, kvMap{}, true},
@@ -406,7 +525,7 @@ func Test_parseEnvSource(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			got := make(kvMap)
-			err := parseEnvSource(tt.args.source, got)
+			err := parseEnvSource(tt.args.source, decryptOptions{}, got)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseEnvSource() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -557,7 +676,7 @@ func Test_parseFileSources(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			got := make(kvMap)
-			err := parseFileSources(tt.args.sources, got)
+			err := parseFileSources(tt.args.sources, decryptOptions{}, got)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseFileSources() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -585,6 +704,9 @@ func Test_parseFileSource(t *testing.T) {
 		{"Ini", args{"testdata/file.ini"}, kvMap{"file.ini": b64("[section]\nvar = secret\n")}, false},
 		{"Binary", args{"testdata/file.txt"}, kvMap{"file.txt": b64("secret\n")}, false},
 		{"BinaryRenamed", args{"renamed.txt=testdata/file.txt"}, kvMap{"renamed.txt": b64("secret\n")}, false},
+		{"Extract", args{`testdata/file.json["var"]`}, kvMap{"var": b64("secret")}, false},
+		{"ExtractRenamed", args{`password=testdata/vars.yaml["VAR_YAML"]`}, kvMap{"password": b64("val_yaml")}, false},
+		{"ExtractMissing", args{`testdata/vars.yaml["MISSING"]`}, kvMap{}, true},
 		{"MissingFile", args{"testdata/missing.txt"}, kvMap{}, true},
 		{"InvalidName", args{"=testdata/file.txt"}, kvMap{}, true},
 		{"NotSopsFile", args{"testdata/empty.txt"}, kvMap{}, true},
@@ -592,7 +714,7 @@ func Test_parseFileSource(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			got := make(kvMap)
-			err := parseFileSource(tt.args.source, got)
+			err := parseFileSource(tt.args.source, decryptOptions{}, got)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseFileSource() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -618,6 +740,9 @@ func Test_parseFileName(t *testing.T) {
 		{"WithoutDirectory", args{"filename"}, "filename", "filename", false},
 		{"WithDirectory", args{"directory/filename"}, "filename", "directory/filename", false},
 		{"ExplicitKey", args{"key=filename"}, "key", "filename", false},
+		{"Extract", args{`directory/filename.yaml["db"]["password"]`}, "password", `directory/filename.yaml["db"]["password"]`, false},
+		{"EscapedPath", args{`directory/build\=1.txt`}, "build=1.txt", "directory/build=1.txt", false},
+		{"EscapedKeyAndPath", args{`key\=1=build\=1.txt`}, "key=1", "build=1.txt", false},
 		{"MissingKey", args{"=filename"}, "", "", true},
 		{"MissingFilename", args{"key="}, "", "", true},
 		{"TooManyEqualSigns", args{"key=filename=extra"}, "", "", true},
@@ -664,3 +789,13 @@ func ssg(envSources []string, fileSources []string) SopsSecretGenerator {
 		FileSources:           fileSources,
 	}
 }
+
+func withTimeout(input SopsSecretGenerator, timeout string) SopsSecretGenerator {
+	input.Timeout = timeout
+	return input
+}
+
+func withKMS(input SopsSecretGenerator, options KMSOptions) SopsSecretGenerator {
+	input.KMS = options
+	return input
+}
@@ -0,0 +1,223 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"filippo.io/age/plugin"
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/aes"
+	sopsage "github.com/getsops/sops/v3/age"
+	"github.com/getsops/sops/v3/cmd/sops/common"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/getsops/sops/v3/config"
+	"github.com/getsops/sops/v3/keyservice"
+	"github.com/pkg/errors"
+	"golang.org/x/term"
+)
+
+// agePluginPrefix starts identities that are handled by an age plugin binary,
+// such as age-plugin-yubikey.
+const agePluginPrefix = "AGE-PLUGIN-"
+
+// ageIdentitySources returns the age identities configured for sops, by the
+// name of their source. They are found in the same places sops looks for them.
+func ageIdentitySources() (map[string][]byte, error) {
+	sources := make(map[string][]byte)
+	if key, ok := os.LookupEnv(sopsage.SopsAgeKeyEnv); ok {
+		sources[sopsage.SopsAgeKeyEnv] = []byte(key)
+	}
+	if keyFile, ok := os.LookupEnv(sopsage.SopsAgeKeyFileEnv); ok {
+		content, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not read %s", sopsage.SopsAgeKeyFileEnv)
+		}
+		sources[keyFile] = content
+	}
+	if keyFile, err := userAgeKeyFile(); err == nil {
+		content, err := os.ReadFile(keyFile)
+		if err == nil {
+			sources[keyFile] = content
+		} else if !os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "could not read %s", keyFile)
+		}
+	}
+	return sources, nil
+}
+
+// userAgeKeyFile returns the path of the age key file in the user
+// configuration directory, where sops looks for identities by default.
+func userAgeKeyFile() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); runtime.GOOS == "darwin" && xdg != "" {
+		configDir, err = xdg, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, filepath.FromSlash(sopsage.SopsAgeKeyUserConfigPath)), nil
+}
+
+// agePluginIdentities returns the configured age identities if any of them
+// is a plugin identity, which sops cannot use by itself. It returns nil if
+// there are no plugin identities, so that decryption is left to sops.
+func agePluginIdentities() ([]age.Identity, error) {
+	sources, err := ageIdentitySources()
+	if err != nil {
+		return nil, err
+	}
+	var identities []age.Identity
+	plugins := false
+	for name, content := range sources {
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for n := 1; scanner.Scan(); n++ {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			var identity age.Identity
+			if strings.HasPrefix(line, agePluginPrefix) {
+				plugins = true
+				identity, err = plugin.NewIdentity(line, agePluginUI)
+			} else {
+				identity, err = age.ParseX25519Identity(line)
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid age identity at %s line %d", name, n)
+			}
+			identities = append(identities, identity)
+		}
+		wipe(content)
+	}
+	if !plugins {
+		return nil, nil
+	}
+	return identities, nil
+}
+
+// agePluginUI lets age plugins talk to the user. Kustomize connects stdin and
+// stdout to the plugin protocol, so prompts go to the terminal instead.
+var agePluginUI = &plugin.ClientUI{
+	DisplayMessage: func(name, message string) error {
+		_, err := fmt.Fprintf(os.Stderr, "age-plugin-%s: %s\n", name, message)
+		return err
+	},
+	RequestValue: func(name, prompt string, secret bool) (string, error) {
+		return promptTerminal(fmt.Sprintf("age-plugin-%s: %s ", name, prompt), secret)
+	},
+	Confirm: func(name, prompt, yes, no string) (bool, error) {
+		choices := yes
+		if no != "" {
+			choices += "/" + no
+		}
+		answer, err := promptTerminal(fmt.Sprintf("age-plugin-%s: %s [%s] ", name, prompt, choices), false)
+		if err != nil {
+			return false, err
+		}
+		return no == "" || strings.EqualFold(answer, yes), nil
+	},
+	WaitTimer: func(name string) {
+		_, _ = fmt.Fprintf(os.Stderr, "age-plugin-%s: waiting on plugin, touch your hardware key if needed\n", name)
+	},
+}
+
+// promptTerminal asks the user for a value on the controlling terminal.
+func promptTerminal(prompt string, secret bool) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", errors.Wrap(err, "age plugin needs a terminal to prompt for input")
+	}
+	defer func() { _ = tty.Close() }()
+
+	_, _ = fmt.Fprint(tty, prompt)
+	if secret {
+		value, err := term.ReadPassword(int(tty.Fd()))
+		_, _ = fmt.Fprintln(tty)
+		return string(value), err
+	}
+	value, err := bufio.NewReader(tty).ReadString('\n')
+	return strings.TrimSpace(value), err
+}
+
+// ageKeyServer is a local key service that decrypts age keys with the given
+// identities, and leaves other keys to sops.
+type ageKeyServer struct {
+	keyservice.Server
+	identities []age.Identity
+}
+
+// Decrypt decrypts a data key.
+func (s ageKeyServer) Decrypt(ctx context.Context, req *keyservice.DecryptRequest) (*keyservice.DecryptResponse, error) {
+	if _, ok := req.Key.KeyType.(*keyservice.Key_AgeKey); !ok {
+		return s.Server.Decrypt(ctx, req)
+	}
+	r, err := age.Decrypt(armor.NewReader(bytes.NewReader(req.Ciphertext)), s.identities...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decrypt sops data key with age")
+	}
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decrypt sops data key with age")
+	}
+	return &keyservice.DecryptResponse{Plaintext: plaintext}, nil
+}
+
+// localKeyServices returns the key services to decrypt data keys with. Age
+// plugin identities are used if they are configured, and cloud credentials
+// are shared between files.
+func localKeyServices() ([]keyservice.KeyServiceClient, error) {
+	identities, err := agePluginIdentities()
+	if err != nil {
+		return nil, err
+	}
+	var server keyservice.KeyServiceServer = keyservice.Server{}
+	if identities != nil {
+		server = ageKeyServer{identities: identities}
+	}
+	server = sharedClientServer{next: server, credentials: invocationCredentials}
+	return []keyservice.KeyServiceClient{keyservice.NewCustomLocalClient(server)}, nil
+}
+
+// hasAgeKeys reports whether a file is encrypted to any age recipients.
+func hasAgeKeys(metadata sops.Metadata) bool {
+	for _, group := range metadata.KeyGroups {
+		for _, key := range group {
+			if key.TypeToIdentifier() == sopsage.KeyTypeIdentifier {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decryptWithKeyServices decrypts a sops file like decrypt.DataWithFormat,
+// but with the given key services.
+func decryptWithKeyServices(content []byte, format formats.Format, keyServices []keyservice.KeyServiceClient) ([]byte, error) {
+	store := common.StoreForFormat(format, config.NewStoresConfig())
+	tree, err := store.LoadEncryptedFile(content)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := common.DecryptTree(common.DecryptTreeOpts{
+		Tree:        &tree,
+		KeyServices: keyServices,
+		Cipher:      aes.NewCipher(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(dataKey)
+	return store.EmitPlainFile(tree.Branches)
+}
@@ -0,0 +1,148 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"filippo.io/age/plugin"
+	"github.com/getsops/sops/v3"
+	sopsage "github.com/getsops/sops/v3/age"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/getsops/sops/v3/keyservice"
+)
+
+func Test_agePluginIdentities(t *testing.T) {
+	x25519, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pluginIdentity := plugin.EncodeIdentity("yubikey", []byte("slot"))
+
+	tests := []struct {
+		name    string
+		keys    string
+		want    int
+		wantErr bool
+	}{
+		{"None", "", 0, false},
+		{"X25519Only", x25519.String(), 0, false},
+		{"Plugin", "# hardware key\n" + pluginIdentity + "\n\n" + x25519.String() + "\n", 2, false},
+		{"Invalid", pluginIdentity + "\nnot-a-key\n", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			t.Setenv(sopsage.SopsAgeKeyEnv, tt.keys)
+			got, err := agePluginIdentities()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("agePluginIdentities() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("agePluginIdentities() returned %d identities, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func Test_ageKeyServer_Decrypt(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataKey := []byte("0123456789abcdef0123456789abcdef")
+	ciphertext := ageEncrypt(t, dataKey, identity.Recipient())
+	request := &keyservice.DecryptRequest{
+		Key:        &keyservice.Key{KeyType: &keyservice.Key_AgeKey{AgeKey: &keyservice.AgeKey{Recipient: identity.Recipient().String()}}},
+		Ciphertext: ciphertext,
+	}
+
+	tests := []struct {
+		name       string
+		identities []age.Identity
+		wantErr    bool
+	}{
+		{"Match", []age.Identity{other, identity}, false},
+		{"NoMatch", []age.Identity{other}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ageKeyServer{identities: tt.identities}.Decrypt(context.Background(), request)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decrypt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !bytes.Equal(got.Plaintext, dataKey) {
+				t.Errorf("Decrypt() = %q, want %q", got.Plaintext, dataKey)
+			}
+		})
+	}
+}
+
+func Test_decryptWithKeyServices(t *testing.T) {
+	// PGP keys are passed through to sops.
+	keyServices := []keyservice.KeyServiceClient{keyservice.NewCustomLocalClient(ageKeyServer{})}
+	content, err := os.ReadFile("testdata/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decryptWithKeyServices(content, formats.Binary, keyServices)
+	if err != nil {
+		t.Fatalf("decryptWithKeyServices() error = %v", err)
+	}
+	if string(got) != "secret\n" {
+		t.Errorf("decryptWithKeyServices() = %q, want %q", got, "secret\n")
+	}
+}
+
+func Test_hasAgeKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata sops.Metadata
+		want     bool
+	}{
+		{"PGP", pgpMetadata(testkeyFingerprint), false},
+		{"Age", keyGroupsMetadata(sops.KeyGroup{&sopsage.MasterKey{Recipient: testAgeRecipient}}), true},
+		{"None", sops.Metadata{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasAgeKeys(tt.metadata); got != tt.want {
+				t.Errorf("hasAgeKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test util functions
+
+func ageEncrypt(t *testing.T, plaintext []byte, recipient age.Recipient) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	armored := armor.NewWriter(&buf)
+	w, err := age.Encrypt(armored, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.Copy(w, bytes.NewReader(plaintext))
+	if err == nil {
+		err = w.Close()
+	}
+	if err == nil {
+		err = armored.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,177 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+)
+
+// Formats of CI annotations
+const (
+	annotationsGitHub = "github"
+	annotationsGitLab = "gitlab"
+)
+
+// Severities of CI annotations
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// pathAnnotations are the annotations that kpt and kustomize record the file
+// of a resource in, newest first
+var pathAnnotations = []string{"internal.config.kubernetes.io/path", "config.kubernetes.io/path"}
+
+// annotation is a diagnostic about a file, which CI systems show inline on
+// the diff of a pull or merge request. Line is zero if the diagnostic is about
+// the file as a whole.
+type annotation struct {
+	Severity string
+	File     string
+	Line     int
+	Title    string
+	Message  string
+}
+
+// problemLine returns the line that a validation error is about: the line it
+// names, or else the line of the generator document.
+func problemLine(err error, documentLine int) int {
+	if m := fieldErrorLine.FindStringSubmatch(err.Error()); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	return documentLine
+}
+
+// writeAnnotations writes diagnostics in a CI format: GitHub Actions
+// workflow commands, one per line, or a GitLab code quality report, which is
+// a JSON array. Nothing is written in the GitHub format if there are no
+// diagnostics, but an empty report is, since GitLab expects the file.
+func writeAnnotations(w io.Writer, format string, annotations []annotation) error {
+	switch format {
+	case annotationsGitHub:
+		for _, a := range annotations {
+			_, err := io.WriteString(w, githubAnnotation(a)+"\n")
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case annotationsGitLab:
+		return writeCodeQuality(w, annotations)
+	}
+	return nil
+}
+
+// githubAnnotation returns a diagnostic as a GitHub Actions workflow command,
+// such as ::error file=secrets.yaml,line=3,title=...::message.
+func githubAnnotation(a annotation) string {
+	var properties []string
+	if a.File != "" {
+		properties = append(properties, "file="+escapeGitHubProperty(filepath.ToSlash(a.File)))
+	}
+	if a.Line > 0 {
+		properties = append(properties, "line="+strconv.Itoa(a.Line))
+	}
+	if a.Title != "" {
+		properties = append(properties, "title="+escapeGitHubProperty(a.Title))
+	}
+	command := "::" + a.Severity
+	if len(properties) > 0 {
+		command += " " + strings.Join(properties, ",")
+	}
+	return command + "::" + escapeGitHubData(a.Message)
+}
+
+// escapeGitHubData escapes the message of a workflow command, which ends at
+// the end of the line.
+func escapeGitHubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeGitHubProperty escapes a property of a workflow command, which is
+// also delimited by commas and colons.
+func escapeGitHubProperty(s string) string {
+	return strings.NewReplacer(":", "%3A", ",", "%2C").Replace(escapeGitHubData(s))
+}
+
+// codeQualityIssue is an entry of a GitLab code quality report
+type codeQualityIssue struct {
+	Description string              `json:"description"`
+	CheckName   string              `json:"check_name"`
+	Fingerprint string              `json:"fingerprint"`
+	Severity    string              `json:"severity"`
+	Location    codeQualityLocation `json:"location"`
+}
+
+// codeQualityLocation is the file and line of a code quality issue
+type codeQualityLocation struct {
+	Path  string `json:"path"`
+	Lines struct {
+		Begin int `json:"begin"`
+	} `json:"lines"`
+}
+
+// writeCodeQuality writes diagnostics as a GitLab code quality report.
+// Errors are major issues and warnings minor ones. The fingerprint identifies
+// an issue across pipelines, so it leaves out the line, which shifts with
+// unrelated edits.
+func writeCodeQuality(w io.Writer, annotations []annotation) error {
+	issues := make([]codeQualityIssue, 0, len(annotations))
+	for _, a := range annotations {
+		issue := codeQualityIssue{
+			Description: a.Message,
+			CheckName:   a.Title,
+			Severity:    "major",
+		}
+		if a.Severity == severityWarning {
+			issue.Severity = "minor"
+		}
+		issue.Location.Path = filepath.ToSlash(a.File)
+		issue.Location.Lines.Begin = max(a.Line, 1)
+		sum := sha256.Sum256([]byte(a.Title + "\x00" + issue.Location.Path + "\x00" + a.Message))
+		issue.Fingerprint = hex.EncodeToString(sum[:16])
+		issues = append(issues, issue)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(issues)
+}
+
+// itemPath returns the file that kpt or kustomize read a ResourceList item
+// from, if it recorded it.
+func itemPath(item *fn.KubeObject) string {
+	for _, name := range pathAnnotations {
+		if p := item.GetAnnotation(name); p != "" {
+			return p
+		}
+	}
+	return ""
+}
+
+// annotateGeneratorError reports the error of a generator in a run of the
+// function as a GitHub Actions annotation on stderr, since stdout carries the
+// ResourceList. The annotation is on the file of the generator if kpt or
+// kustomize recorded it. GitLab reports are only written by commands.
+func annotateGeneratorError(w io.Writer, format string, item *fn.KubeObject, err error) {
+	if format != annotationsGitHub {
+		return
+	}
+	a := annotation{
+		Severity: severityError,
+		File:     itemPath(item),
+		Title:    fmt.Sprintf("SopsSecretGenerator %s", item.GetName()),
+		Message:  err.Error(),
+	}
+	_ = writeAnnotations(w, format, []annotation{a})
+}
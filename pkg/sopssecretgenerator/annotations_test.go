@@ -0,0 +1,119 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/pkg/errors"
+)
+
+func Test_githubAnnotation(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation annotation
+		want       string
+	}{
+		{"Full", annotation{severityError, "overlays/prod/secrets.yaml", 7, "SopsSecretGenerator db", "source \"db.env\": no key could decrypt the file"},
+			`::error file=overlays/prod/secrets.yaml,line=7,title=SopsSecretGenerator db::source "db.env": no key could decrypt the file`},
+		{"WithoutLine", annotation{severityWarning, "unused.enc.yaml", 0, "lint UNUSED", "not referenced"},
+			"::warning file=unused.enc.yaml,title=lint UNUSED::not referenced"},
+		{"WithoutFile", annotation{Severity: severityError, Message: "failed"}, "::error::failed"},
+		{"Escaped", annotation{severityError, "a,b:c.yaml", 1, "", "100%\nsure"},
+			"::error file=a%2Cb%3Ac.yaml,line=1::100%25%0Asure"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := githubAnnotation(tt.annotation); got != tt.want {
+				t.Errorf("githubAnnotation() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_writeAnnotations_gitlab(t *testing.T) {
+	var out bytes.Buffer
+	err := writeAnnotations(&out, annotationsGitLab, []annotation{
+		{severityError, "secrets.yaml", 3, "SopsSecretGenerator db", "invalid"},
+		{severityWarning, "unused.enc.yaml", 0, "lint UNUSED", "not referenced"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var issues []codeQualityIssue
+	err = json.Unmarshal(out.Bytes(), &issues)
+	if err != nil {
+		t.Fatalf("invalid report %s: %v", out.String(), err)
+	}
+	if len(issues) != 2 {
+		t.Fatalf("report has %d issues, want 2", len(issues))
+	}
+	if issues[0].Severity != "major" || issues[0].Location.Path != "secrets.yaml" || issues[0].Location.Lines.Begin != 3 || issues[0].CheckName != "SopsSecretGenerator db" {
+		t.Errorf("issue 0 = %+v", issues[0])
+	}
+	if issues[1].Severity != "minor" || issues[1].Location.Lines.Begin != 1 {
+		t.Errorf("issue 1 = %+v", issues[1])
+	}
+	if len(issues[0].Fingerprint) != 32 || issues[0].Fingerprint == issues[1].Fingerprint {
+		t.Errorf("fingerprints = %s, %s", issues[0].Fingerprint, issues[1].Fingerprint)
+	}
+
+	out.Reset()
+	_ = writeAnnotations(&out, annotationsGitLab, nil)
+	if out.String() != "[]\n" {
+		t.Errorf("empty report = %q, want []", out.String())
+	}
+	out.Reset()
+	_ = writeAnnotations(&out, "", []annotation{{Severity: severityError, Message: "failed"}})
+	if out.Len() != 0 {
+		t.Errorf("writeAnnotations() without a format wrote %q", out.String())
+	}
+}
+
+func Test_validation_annotations(t *testing.T) {
+	v := validation{Path: "secrets.yaml", Name: "db", Line: 5, Problems: []error{
+		errors.New("line 9: field envs not found in type SopsSecretGenerator"),
+		errors.New("source \"db.env\": file is not encrypted with sops"),
+	}}
+	got := v.annotations()
+	if len(got) != 2 || got[0].Line != 9 || got[1].Line != 5 || got[0].File != "secrets.yaml" || got[0].Title != "SopsSecretGenerator db" {
+		t.Errorf("annotations() = %+v", got)
+	}
+}
+
+func Test_lintProblem_annotation(t *testing.T) {
+	missing := lintProblem{lintMissing, "prod/db.env", "source of generator db in prod/secrets.yaml does not exist", "prod/secrets.yaml"}
+	if got := missing.annotation(); got.Severity != severityError || got.File != "prod/secrets.yaml" || got.Message != "prod/db.env: "+missing.Detail {
+		t.Errorf("annotation() of a missing source = %+v", got)
+	}
+	unused := lintProblem{lintUnused, "old.enc.env", "encrypted with sops, but not referenced by any generator", ""}
+	if got := unused.annotation(); got.Severity != severityWarning || got.File != "old.enc.env" {
+		t.Errorf("annotation() of an unused file = %+v", got)
+	}
+}
+
+func Test_annotateGeneratorError(t *testing.T) {
+	item, err := fn.ParseKubeObject([]byte(`apiVersion: kustomize.freightdog.com/v1
+kind: SopsSecretGenerator
+metadata:
+  name: db
+  annotations:
+    internal.config.kubernetes.io/path: overlays/prod/secrets.yaml
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	annotateGeneratorError(&out, annotationsGitLab, item, errors.New("failed"))
+	if out.Len() != 0 {
+		t.Errorf("annotateGeneratorError() wrote %q for gitlab", out.String())
+	}
+	annotateGeneratorError(&out, annotationsGitHub, item, errors.New("failed"))
+	if want := "::error file=overlays/prod/secrets.yaml,title=SopsSecretGenerator db::failed\n"; out.String() != want {
+		t.Errorf("annotateGeneratorError() = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"encoding/json"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/getsops/sops/v3"
+	"github.com/pkg/errors"
+)
+
+// auditSyslog is the audit log destination that sends records to syslog
+const auditSyslog = "syslog"
+
+// auditRecord describes a single decryption for the audit log
+type auditRecord struct {
+	Time      time.Time `json:"time"`
+	Generator string    `json:"generator"`
+	Command   string    `json:"command,omitempty"`
+	File      string    `json:"file"`
+	Keys      []string  `json:"keys"`
+	User      string    `json:"user"`
+	Host      string    `json:"host"`
+	Success   bool      `json:"success"`
+	Cached    bool      `json:"cached,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// newAuditRecord describes the decryption of a file. The keys are the sops
+// keys the file is encrypted to; sops does not report which of them was used.
+func newAuditRecord(file string, metadata sops.Metadata, generator string, decryptErr error) auditRecord {
+	record := auditRecord{
+		Time:      time.Now().UTC(),
+		Generator: generator,
+		File:      file,
+		Keys:      []string{},
+		Success:   decryptErr == nil,
+	}
+	if abs, err := filepath.Abs(file); err == nil {
+		record.File = abs
+	}
+	for _, group := range metadata.KeyGroups {
+		for _, key := range group {
+			record.Keys = append(record.Keys, key.TypeToIdentifier()+":"+key.ToString())
+		}
+	}
+	if u, err := user.Current(); err == nil {
+		record.User = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		record.Host = host
+	}
+	if decryptErr != nil {
+		record.Error = decryptErr.Error()
+	}
+	return record
+}
+
+// auditCommand records the decryption of a file by a command, such as edit
+// or rotate, rather than by a generator, in the audit log.
+func auditCommand(command string, file string, metadata sops.Metadata, decryptErr error) error {
+	record := newAuditRecord(file, metadata, "", decryptErr)
+	record.Command = command
+	return writeAuditRecord(runtimeSettings.AuditLog, record)
+}
+
+// auditMutex serializes writes to the audit log by concurrent decryptions
+var auditMutex sync.Mutex
+
+// writeAuditRecord appends a record to the audit log, as a line of JSON. The
+// destination is either a file path or "syslog", and an empty destination
+// disables the audit log. Failing to write the audit log fails the build, so
+// that no decryption goes unrecorded.
+func writeAuditRecord(destination string, record auditRecord) error {
+	if destination == "" {
+		return nil
+	}
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "could not write audit log")
+	}
+
+	if destination == auditSyslog {
+		err = writeSyslog(string(line))
+	} else {
+		err = appendLine(destination, line)
+	}
+	if err != nil {
+		return errors.Wrap(err, "could not write audit log")
+	}
+	return nil
+}
+
+// appendLine appends a line to a file, creating it if necessary. The file is
+// only readable by the current user.
+func appendLine(fileName string, line []byte) error {
+	f, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,20 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+//go:build !windows
+
+package sopssecretgenerator
+
+import (
+	"log/syslog"
+)
+
+// writeSyslog sends an audit message to the local syslog daemon.
+func writeSyslog(message string) error {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTH, "SopsSecretGenerator")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = w.Close() }()
+	return w.Info(message)
+}
@@ -0,0 +1,15 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+//go:build windows
+
+package sopssecretgenerator
+
+import (
+	"github.com/pkg/errors"
+)
+
+// writeSyslog is not supported on Windows, which has no syslog daemon.
+func writeSyslog(string) error {
+	return errors.New("syslog is not supported on Windows, use a file path")
+}
@@ -0,0 +1,70 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func Test_newAuditRecord(t *testing.T) {
+	record := newAuditRecord("testdata/file.txt", pgpMetadata(testkeyFingerprint), "secret", errors.New("failed"))
+	if record.Generator != "secret" {
+		t.Errorf("newAuditRecord() Generator = %v, want secret", record.Generator)
+	}
+	if !filepath.IsAbs(record.File) || !strings.HasSuffix(record.File, "file.txt") {
+		t.Errorf("newAuditRecord() File = %v, want absolute path", record.File)
+	}
+	if len(record.Keys) != 1 || record.Keys[0] != "pgp:"+testkeyFingerprint {
+		t.Errorf("newAuditRecord() Keys = %v", record.Keys)
+	}
+	if record.Success || record.Error != "failed" {
+		t.Errorf("newAuditRecord() Success = %v, Error = %v", record.Success, record.Error)
+	}
+}
+
+func Test_writeAuditRecord(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "audit.log")
+	opts := decryptOptions{Generator: "secret", AuditLog: logFile}
+
+	_, err := decryptFile("testdata/file.txt", opts)
+	if err != nil {
+		t.Fatalf("decryptFile() error = %v", err)
+	}
+	_, err = decryptFile("testdata/file2.txt", opts)
+	if err != nil {
+		t.Fatalf("decryptFile() error = %v", err)
+	}
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("could not read audit log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("audit log has %d lines, want 2", len(lines))
+	}
+	var record auditRecord
+	err = json.Unmarshal([]byte(lines[1]), &record)
+	if err != nil {
+		t.Fatalf("could not parse audit record: %v", err)
+	}
+	if !record.Success || !strings.HasSuffix(record.File, "file2.txt") {
+		t.Errorf("audit record = %+v", record)
+	}
+}
+
+func Test_writeAuditRecord_Unwritable(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "missing", "audit.log")
+
+	_, err := decryptFile("testdata/file.txt", decryptOptions{AuditLog: logFile})
+	if err == nil || !strings.Contains(err.Error(), "could not write audit log") {
+		t.Errorf("decryptFile() error = %v, want audit log error", err)
+	}
+}
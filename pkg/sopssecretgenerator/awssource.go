@@ -0,0 +1,262 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/pkg/errors"
+)
+
+// AWSSecretsManagerSource is a secret in AWS Secrets Manager. Its value is
+// added under Key, or, if Key is empty, it must be a JSON object whose
+// key/value pairs are added.
+type AWSSecretsManagerSource struct {
+	// SecretID is the name or ARN of the secret
+	SecretID string `json:"secretId" yaml:"secretId"`
+	Key      string `json:"key,omitempty" yaml:"key,omitempty"`
+	// VersionStage is the staging label of the version, AWSCURRENT if empty
+	VersionStage string `json:"versionStage,omitempty" yaml:"versionStage,omitempty"`
+	// Region is that of the ARN or the environment if empty
+	Region  string `json:"region,omitempty" yaml:"region,omitempty"`
+	Profile string `json:"profile,omitempty" yaml:"profile,omitempty"`
+}
+
+// SSMParameter is a parameter in AWS Systems Manager Parameter Store.
+// SecureString parameters are decrypted.
+type SSMParameter struct {
+	// Name is the name or ARN of the parameter
+	Name string `json:"name" yaml:"name"`
+	// Key is the last element of the name if empty
+	Key string `json:"key,omitempty" yaml:"key,omitempty"`
+	// Region is that of the ARN or the environment if empty
+	Region  string `json:"region,omitempty" yaml:"region,omitempty"`
+	Profile string `json:"profile,omitempty" yaml:"profile,omitempty"`
+}
+
+// String returns the source as aws-secretsmanager:secretId.
+func (s AWSSecretsManagerSource) String() string {
+	return "aws-secretsmanager:" + s.SecretID
+}
+
+// String returns the source as aws-ssm:name.
+func (s SSMParameter) String() string {
+	return "aws-ssm:" + s.Name
+}
+
+// validate checks the fields of a Secrets Manager source.
+func (s AWSSecretsManagerSource) validate() error {
+	if s.SecretID == "" {
+		return errors.New("awsSecretsManager: secretId is required")
+	}
+	return nil
+}
+
+// validate checks the fields of a Parameter Store source.
+func (s SSMParameter) validate() error {
+	if s.Name == "" {
+		return errors.New("ssmParameters: name is required")
+	}
+	if strings.Trim(s.key(), "/") == "" {
+		return errors.Errorf("ssmParameters: %s: no key in the name, set key", s)
+	}
+	return nil
+}
+
+// key returns the data key of the parameter.
+func (s SSMParameter) key() string {
+	if s.Key != "" {
+		return s.Key
+	}
+	return path.Base(s.Name)
+}
+
+// awsRegion returns the region for a secret or parameter: the given region,
+// that of its ARN, or that of the environment.
+func awsRegion(region string, id string, service string) string {
+	if region != "" {
+		return region
+	}
+	parts := strings.SplitN(id, ":", 5)
+	if len(parts) == 5 && parts[0] == "arn" && parts[2] == service {
+		return parts[3]
+	}
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// awsEndpoint returns the endpoint of a service in a region, which can be
+// overridden with AWS_ENDPOINT_URL_<SERVICE> or AWS_ENDPOINT_URL, as in the
+// AWS SDKs.
+func awsEndpoint(service string, envName string, region string) string {
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL_" + envName); endpoint != "" {
+		return endpoint
+	}
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL"); endpoint != "" {
+		return endpoint
+	}
+	return "https://" + service + "." + region + ".amazonaws.com"
+}
+
+// awsError is the error response of the AWS JSON protocol
+type awsError struct {
+	Type         string `json:"__type"`
+	Message      string `json:"message"`
+	MessageUpper string `json:"Message"`
+}
+
+// callAWS calls an action of an AWS JSON 1.1 API with the shared
+// credentials of a profile, and decodes the response into out.
+func callAWS(opts decryptOptions, service string, envName string, target string, profile string, region string, in interface{}, out interface{}) error {
+	if region == "" {
+		return errors.New("no region, set region or AWS_REGION")
+	}
+	provider, err := invocationCredentials.awsCredentials(profile, "", region)
+	if err != nil {
+		return err
+	}
+	ctx := opts.context()
+	credentials, err := provider.Retrieve(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not load AWS credentials")
+	}
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, awsEndpoint(service, envName, region), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+	hash := sha256.Sum256(body)
+	err = v4.NewSigner().SignHTTP(ctx, credentials, req, hex.EncodeToString(hash[:]), service, region, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "could not sign request")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e awsError
+		_ = json.Unmarshal(respBody, &e)
+		message := e.Message
+		if message == "" {
+			message = e.MessageUpper
+		}
+		if i := strings.LastIndex(e.Type, "#"); i >= 0 {
+			e.Type = e.Type[i+1:]
+		}
+		if e.Type == "" {
+			return errors.Errorf("%s returned %s", service, resp.Status)
+		}
+		return errors.Errorf("%s returned %s: %s", service, e.Type, message)
+	}
+	return errors.Wrapf(json.Unmarshal(respBody, out), "invalid %s response", service)
+}
+
+// parseAWSSecretsManagerSources reads the Secrets Manager sources of a
+// generator into the Secret data.
+func parseAWSSecretsManagerSources(sources []AWSSecretsManagerSource, opts decryptOptions, data kvMap) error {
+	if len(sources) > 0 && opts.Offline {
+		return errors.New("AWS Secrets Manager sources cannot be read in offline mode")
+	}
+	var errs []error
+	for _, source := range sources {
+		err := withWorker(opts, func() error { return parseAWSSecretsManagerSource(source, opts, data) })
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "source %s", source))
+			if opts.context().Err() != nil {
+				break
+			}
+			continue
+		}
+	}
+	return joinSourceErrors(errs)
+}
+
+func parseAWSSecretsManagerSource(source AWSSecretsManagerSource, opts decryptOptions, data kvMap) error {
+	in := map[string]string{"SecretId": source.SecretID}
+	if source.VersionStage != "" {
+		in["VersionStage"] = source.VersionStage
+	}
+	var out struct {
+		SecretString *string
+		SecretBinary []byte
+	}
+	region := awsRegion(source.Region, source.SecretID, "secretsmanager")
+	err := callAWS(opts, "secretsmanager", "SECRETS_MANAGER", "secretsmanager.GetSecretValue", source.Profile, region, in, &out)
+	if err != nil {
+		return err
+	}
+	value := out.SecretBinary
+	if out.SecretString != nil {
+		value = []byte(*out.SecretString)
+	}
+	defer wipe(value)
+	if source.Key != "" {
+		data[source.Key] = encodeBase64(value)
+		return nil
+	}
+	err = parseJSONContent(value, data)
+	if err != nil {
+		return errors.Wrap(err, "secret is not a JSON object of strings, set key")
+	}
+	return nil
+}
+
+// parseSSMParameters reads the Parameter Store sources of a generator into
+// the Secret data.
+func parseSSMParameters(parameters []SSMParameter, opts decryptOptions, data kvMap) error {
+	if len(parameters) > 0 && opts.Offline {
+		return errors.New("SSM parameters cannot be read in offline mode")
+	}
+	var errs []error
+	for _, parameter := range parameters {
+		err := withWorker(opts, func() error { return parseSSMParameter(parameter, opts, data) })
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "source %s", parameter))
+			if opts.context().Err() != nil {
+				break
+			}
+			continue
+		}
+	}
+	return joinSourceErrors(errs)
+}
+
+func parseSSMParameter(parameter SSMParameter, opts decryptOptions, data kvMap) error {
+	in := map[string]interface{}{"Name": parameter.Name, "WithDecryption": true}
+	var out struct {
+		Parameter struct {
+			Value string
+		}
+	}
+	region := awsRegion(parameter.Region, parameter.Name, "ssm")
+	err := callAWS(opts, "ssm", "SSM", "AmazonSSM.GetParameter", parameter.Profile, region, in, &out)
+	if err != nil {
+		return err
+	}
+	data[parameter.key()] = encodeBase64([]byte(out.Parameter.Value))
+	return nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/pkg/errors"
+)
+
+// azureKeyVaultAPIVersion is the version of the Key Vault REST API
+const azureKeyVaultAPIVersion = "7.4"
+
+// AzureKeyVaultSource is a set of secrets in an Azure Key Vault. Secrets are
+// given as name, name/version or key=name[/version], and added under the
+// key, or their name.
+type AzureKeyVaultSource struct {
+	// VaultURI is the URI of the vault, such as https://myvault.vault.azure.net
+	VaultURI string   `json:"vaultUri" yaml:"vaultUri"`
+	Secrets  []string `json:"secrets" yaml:"secrets"`
+}
+
+// azureSecret is a secret reference of an Azure Key Vault source
+type azureSecret struct {
+	key     string
+	name    string
+	version string
+}
+
+// String returns the source as the vault URI.
+func (s AzureKeyVaultSource) String() string {
+	return strings.TrimRight(s.VaultURI, "/")
+}
+
+// validate checks the fields of an Azure Key Vault source.
+func (s AzureKeyVaultSource) validate() error {
+	u, err := url.Parse(s.VaultURI)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return errors.Errorf("azureKeyVaultSources: vaultUri %s must be an https URI", s.VaultURI)
+	}
+	if len(s.Secrets) == 0 {
+		return errors.Errorf("azureKeyVaultSources: %s: secrets are required", s)
+	}
+	for _, secret := range s.Secrets {
+		_, err := parseAzureSecret(secret)
+		if err != nil {
+			return errors.Wrapf(err, "azureKeyVaultSources: %s", s)
+		}
+	}
+	return nil
+}
+
+// parseAzureSecret parses a secret reference.
+func parseAzureSecret(secret string) (azureSecret, error) {
+	key, ref, found := strings.Cut(secret, "=")
+	if !found {
+		ref = secret
+	}
+	name, version, _ := strings.Cut(ref, "/")
+	if !found {
+		key = name
+	}
+	if key == "" || name == "" || strings.Contains(version, "/") {
+		return azureSecret{}, errors.Errorf("invalid secret %s, use [key=]name[/version]", secret)
+	}
+	return azureSecret{key: key, name: name, version: version}, nil
+}
+
+// scope returns the OAuth scope of the vault, which differs per cloud.
+func (s AzureKeyVaultSource) scope() string {
+	u, _ := url.Parse(s.VaultURI)
+	_, domain, _ := strings.Cut(u.Hostname(), ".")
+	return "https://" + domain + "/.default"
+}
+
+// get returns the value of a secret.
+func (s AzureKeyVaultSource) get(secret azureSecret, token string, opts decryptOptions) (string, error) {
+	u := s.String() + "/secrets/" + url.PathEscape(secret.name)
+	if secret.version != "" {
+		u += "/" + url.PathEscape(secret.version)
+	}
+	req, err := http.NewRequestWithContext(opts.context(), http.MethodGet, u+"?api-version="+azureKeyVaultAPIVersion, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	var response struct {
+		Value string `json:"value"`
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	err = json.Unmarshal(body, &response)
+	if resp.StatusCode != http.StatusOK {
+		if err == nil && response.Error.Code != "" {
+			return "", errors.Errorf("key vault returned %s: %s", response.Error.Code, response.Error.Message)
+		}
+		return "", errors.Errorf("key vault returned %s", resp.Status)
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "invalid key vault response")
+	}
+	return response.Value, nil
+}
+
+// parseAzureKeyVaultSources reads the Azure Key Vault sources of a generator
+// into the Secret data, with the credentials shared with Azure Key Vault
+// decryption.
+func parseAzureKeyVaultSources(sources []AzureKeyVaultSource, opts decryptOptions, data kvMap) error {
+	if len(sources) == 0 {
+		return nil
+	}
+	if opts.Offline {
+		return errors.New("Azure Key Vault sources cannot be read in offline mode")
+	}
+	credential, err := invocationCredentials.azureCredential()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, source := range sources {
+		if opts.context().Err() != nil {
+			break
+		}
+		token, err := credential.GetToken(opts.context(), policy.TokenRequestOptions{Scopes: []string{source.scope()}})
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "source %s: could not get a token", source))
+			continue
+		}
+		for _, ref := range source.Secrets {
+			secret, err := parseAzureSecret(ref)
+			if err != nil {
+				errs = append(errs, errors.Wrapf(err, "source %s", source))
+				continue
+			}
+			var value string
+			err = withWorker(opts, func() error {
+				var err error
+				value, err = source.get(secret, token.Token, opts)
+				return err
+			})
+			if err != nil {
+				errs = append(errs, errors.Wrapf(err, "source %s: secret %s", source, secret.name))
+				if opts.context().Err() != nil {
+					break
+				}
+				continue
+			}
+			data[secret.key] = encodeBase64([]byte(value))
+		}
+	}
+	return joinSourceErrors(errs)
+}
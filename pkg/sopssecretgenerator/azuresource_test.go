@@ -0,0 +1,133 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// staticAzureCredential returns a fixed token
+type staticAzureCredential struct{}
+
+func (staticAzureCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token"}, nil
+}
+
+func Test_parseAzureSecret(t *testing.T) {
+	tests := []struct {
+		secret  string
+		want    azureSecret
+		wantErr bool
+	}{
+		{"db-password", azureSecret{key: "db-password", name: "db-password"}, false},
+		{"db-password/0123abcd", azureSecret{key: "db-password", name: "db-password", version: "0123abcd"}, false},
+		{"DB_PASSWORD=db-password", azureSecret{key: "DB_PASSWORD", name: "db-password"}, false},
+		{"DB_PASSWORD=db-password/0123abcd", azureSecret{key: "DB_PASSWORD", name: "db-password", version: "0123abcd"}, false},
+		{"=db-password", azureSecret{}, true},
+		{"DB_PASSWORD=", azureSecret{}, true},
+		{"db-password/0123abcd/more", azureSecret{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.secret, func(t *testing.T) {
+			got, err := parseAzureSecret(tt.secret)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAzureSecret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseAzureSecret() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_AzureKeyVaultSource_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  AzureKeyVaultSource
+		wantErr bool
+	}{
+		{"Valid", AzureKeyVaultSource{VaultURI: "https://myvault.vault.azure.net", Secrets: []string{"db-password"}}, false},
+		{"NoURI", AzureKeyVaultSource{Secrets: []string{"db-password"}}, true},
+		{"NoSecrets", AzureKeyVaultSource{VaultURI: "https://myvault.vault.azure.net"}, true},
+		{"InvalidSecret", AzureKeyVaultSource{VaultURI: "https://myvault.vault.azure.net", Secrets: []string{"=db-password"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.source.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_parseAzureKeyVaultSources(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.URL.Query().Get("api-version") != azureKeyVaultAPIVersion {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/secrets/db-password":
+			_, _ = w.Write([]byte(`{"value":"secret"}`))
+		case "/secrets/db-password/0123abcd":
+			_, _ = w.Write([]byte(`{"value":"old"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"code":"SecretNotFound","message":"not found"}}`))
+		}
+	}))
+	defer server.Close()
+	previous := invocationCredentials
+	invocationCredentials = &sharedCredentials{azure: staticAzureCredential{}}
+	defer func() { invocationCredentials = previous }()
+
+	tests := []struct {
+		name    string
+		secrets []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"Name", []string{"db-password"}, map[string]string{"db-password": "secret"}, false},
+		{"KeyVersion", []string{"DB_PASSWORD=db-password/0123abcd"}, map[string]string{"DB_PASSWORD": "old"}, false},
+		{"NotFound", []string{"missing"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make(kvMap)
+			source := AzureKeyVaultSource{VaultURI: server.URL + "/", Secrets: tt.secrets}
+			err := parseAzureKeyVaultSources([]AzureKeyVaultSource{source}, decryptOptions{Context: context.Background()}, data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAzureKeyVaultSources() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(decodeData(data), tt.want) {
+				t.Errorf("parseAzureKeyVaultSources() = %v, want %v", decodeData(data), tt.want)
+			}
+		})
+	}
+}
+
+func Test_AzureKeyVaultSource_scope(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{"https://myvault.vault.azure.net", "https://vault.azure.net/.default"},
+		{"https://myvault.vault.azure.cn/", "https://vault.azure.cn/.default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			if got := (AzureKeyVaultSource{VaultURI: tt.uri}).scope(); got != tt.want {
+				t.Errorf("scope() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
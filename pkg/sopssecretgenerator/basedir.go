@@ -0,0 +1,56 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+// applyBaseDir resolves the relative sources of a generator, including those
+// of its variants, against its baseDir. The baseDir itself is relative to
+// where the sources would be resolved without it.
+func applyBaseDir(input SopsSecretGenerator) SopsSecretGenerator {
+	if input.BaseDir == "" {
+		return input
+	}
+	input.EnvSources = rebaseEnvSources(input.EnvSources, input.BaseDir)
+	input.FileSources = rebaseFileSources(input.FileSources, input.BaseDir)
+	input.HelmValues.Files = rebaseEnvSources(input.HelmValues.Files, input.BaseDir)
+	input.DockerConfigs = rebaseEnvSources(input.DockerConfigs, input.BaseDir)
+	input.StringData.Sources = rebaseFileSources(input.StringData.Sources, input.BaseDir)
+	if input.Variants != nil {
+		variants := make(map[string]Variant, len(input.Variants))
+		for name, variant := range input.Variants {
+			variants[name] = Variant{
+				EnvSources:  rebaseEnvSources(variant.EnvSources, input.BaseDir),
+				FileSources: rebaseFileSources(variant.FileSources, input.BaseDir),
+			}
+		}
+		input.Variants = variants
+	}
+	input.BaseDir = ""
+	return input
+}
+
+// rebaseEnvSources prefixes the relative paths of env sources with a
+// directory.
+func rebaseEnvSources(sources []string, prefix string) []string {
+	if sources == nil {
+		return nil
+	}
+	rebased := make([]string, len(sources))
+	for i, source := range sources {
+		rebased[i] = rebaseSource(source, prefix)
+	}
+	return rebased
+}
+
+// rebaseFileSources prefixes the relative paths of file sources with a
+// directory.
+func rebaseFileSources(sources []string, prefix string) []string {
+	if sources == nil {
+		return nil
+	}
+	rebased := make([]string, len(sources))
+	for i, source := range sources {
+		rebased[i] = rebaseFileSource(source, prefix)
+	}
+	return rebased
+}
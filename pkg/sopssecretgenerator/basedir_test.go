@@ -0,0 +1,82 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_applyBaseDir(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     SopsSecretGenerator
+		wantEnvs  []string
+		wantFiles []string
+	}{
+		{"None", SopsSecretGenerator{EnvSources: []string{"a.env"}, FileSources: []string{"b.txt"}},
+			[]string{"a.env"}, []string{"b.txt"}},
+		{"Relative", SopsSecretGenerator{BaseDir: "../../secrets", EnvSources: []string{"a.env", `c.yaml["app"]`}, FileSources: []string{"b.txt", "key=d/e.txt"}},
+			[]string{"../../secrets/a.env", `../../secrets/c.yaml["app"]`}, []string{"../../secrets/b.txt", "key=../../secrets/d/e.txt"}},
+		{"Escaped", SopsSecretGenerator{BaseDir: "secrets", FileSources: []string{`build\=1.txt`, `k\=v=build\=2.txt`}},
+			nil, []string{`secrets/build\=1.txt`, `k\=v=secrets/build\=2.txt`}},
+		{"AbsoluteSource", SopsSecretGenerator{BaseDir: "secrets", EnvSources: []string{"/etc/a.env"}},
+			[]string{"/etc/a.env"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := applyBaseDir(tt.input)
+			if !reflect.DeepEqual(got.EnvSources, tt.wantEnvs) || !reflect.DeepEqual(got.FileSources, tt.wantFiles) {
+				t.Errorf("applyBaseDir() = %v %v, want %v %v", got.EnvSources, got.FileSources, tt.wantEnvs, tt.wantFiles)
+			}
+			if got.BaseDir != "" {
+				t.Errorf("applyBaseDir() kept baseDir %s", got.BaseDir)
+			}
+		})
+	}
+}
+
+func Test_applyBaseDir_variants(t *testing.T) {
+	input := SopsSecretGenerator{BaseDir: "secrets", Variants: map[string]Variant{"prod": {EnvSources: []string{"prod.env"}}}}
+	got := applyBaseDir(input)
+	if want := []string{"secrets/prod.env"}; !reflect.DeepEqual(got.Variants["prod"].EnvSources, want) {
+		t.Errorf("applyBaseDir() variant = %v, want %v", got.Variants["prod"].EnvSources, want)
+	}
+	if input.Variants["prod"].EnvSources[0] != "prod.env" {
+		t.Errorf("applyBaseDir() changed the variants of its input")
+	}
+}
+
+func Test_generateSecret_baseDir(t *testing.T) {
+	input, err := readInput([]byte("apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: secret\nbaseDir: testdata\nfiles:\n  - file.txt\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := generateSecret(context.Background(), input, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := secret.Data["file.txt"]; !ok {
+		t.Errorf("generateSecret() = %v, want file.txt from testdata", secret.Data)
+	}
+}
+
+func Test_readGenerators_baseDir(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "generator.yaml")
+	writeTestFile(t, fileName, "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: secret\nbaseDir: ../secrets\nenvs:\n  - app.env\n")
+	generators, err := readGenerators(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := generators[0].sourceFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(filepath.Dir(dir), "secrets", "app.env")}; !reflect.DeepEqual(files, want) {
+		t.Errorf("sourceFiles() = %v, want %v", files, want)
+	}
+}
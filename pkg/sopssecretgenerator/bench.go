@@ -0,0 +1,133 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// defaultBenchIterations is the number of runs of each generator and cache
+// state if --iterations is not given
+const defaultBenchIterations = 10
+
+// benchResult are the runs of a generator in a cache state
+type benchResult struct {
+	durations []time.Duration
+	kmsCalls  int64
+}
+
+// runBench implements the bench subcommand.
+func runBench(args []string) error {
+	flags := newFlagSet("bench")
+	iterations := flags.Int("iterations", defaultBenchIterations, "`number` of runs of each generator, cold and warm")
+	name := flags.String("name", "", "only benchmark the generator with this `name`")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected a generator manifest")
+	}
+	if *iterations < 1 {
+		return errors.Errorf("invalid --iterations %d, expected at least 1", *iterations)
+	}
+
+	generators, err := readGenerators(flags.Arg(0))
+	if err != nil {
+		return errors.Wrapf(err, "could not read generator %s", flags.Arg(0))
+	}
+	if *name != "" {
+		g, err := selectGenerator(flags.Arg(0), *name)
+		if err != nil {
+			return err
+		}
+		generators = []generatorFile{g}
+	}
+	if len(generators) == 0 {
+		return errors.Errorf("%s contains no generators", flags.Arg(0))
+	}
+	for _, g := range generators {
+		err = benchGenerator(os.Stdout, g, *iterations, runtimeSettings)
+		if err != nil {
+			return errors.Wrap(err, g.Generator.Name)
+		}
+	}
+	return nil
+}
+
+// benchGenerator generates the Secret of a generator a number of times
+// without the decryption cache, and as many times with a warm cache if the
+// cache is enabled, and reports the durations and KMS calls of the runs.
+func benchGenerator(w io.Writer, g generatorFile, iterations int, opts Options) error {
+	generator := g.withBaseDir()
+	files, err := g.sourceFiles()
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(w, "%s: %d files, %d iterations\n", generator.Name, len(files), iterations)
+
+	cold := opts
+	cold.NoCache = true
+	result, err := benchRuns(generator, iterations, cold)
+	if err != nil {
+		return err
+	}
+	printBenchResult(w, "cold", result)
+
+	if openCache(opts) == nil {
+		_, _ = fmt.Fprintln(w, "  warm: the decryption cache is disabled")
+		return nil
+	}
+	// The first run fills the cache
+	_, err = benchRuns(generator, 1, opts)
+	if err != nil {
+		return err
+	}
+	result, err = benchRuns(generator, iterations, opts)
+	if err != nil {
+		return err
+	}
+	printBenchResult(w, "warm", result)
+	return nil
+}
+
+// benchRuns generates the Secret of a generator a number of times, and
+// counts the KMS calls of the runs.
+func benchRuns(generator SopsSecretGenerator, iterations int, opts Options) (benchResult, error) {
+	saved := invocationTimings
+	defer func() { invocationTimings = saved }()
+
+	var result benchResult
+	for i := 0; i < iterations; i++ {
+		invocationTimings = newTimings()
+		start := time.Now()
+		_, err := Generate(invocationContext, generator, opts)
+		if err != nil {
+			return benchResult{}, err
+		}
+		result.durations = append(result.durations, time.Since(start))
+		result.kmsCalls += invocationTimings.kmsCalls()
+	}
+	return result, nil
+}
+
+// printBenchResult writes the statistics of runs on a line.
+func printBenchResult(w io.Writer, label string, result benchResult) {
+	var total time.Duration
+	for _, d := range result.durations {
+		total += d
+	}
+	runs := len(result.durations)
+	mean := total / time.Duration(runs)
+	_, _ = fmt.Fprintf(w, "  %s: min %s, mean %s, max %s, %.1f runs/s, %.1f KMS calls per run\n", label,
+		formatDuration(slices.Min(result.durations)), formatDuration(mean), formatDuration(slices.Max(result.durations)),
+		float64(runs)/total.Seconds(), float64(result.kmsCalls)/float64(runs))
+}
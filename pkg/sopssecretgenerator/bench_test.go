@@ -0,0 +1,52 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_benchGenerator(t *testing.T) {
+	g := generatorFile{
+		Path: "testdata/generator.yaml",
+		Generator: SopsSecretGenerator{
+			TypeMeta:   TypeMeta{APIVersion: apiVersion, Kind: kind},
+			ObjectMeta: ObjectMeta{Name: "secret"},
+			EnvSources: []string{"vars.env"}, FileSources: []string{"file.txt"},
+		},
+	}
+	tests := []struct {
+		name  string
+		opts  Options
+		lines []string
+	}{
+		{"NoCache", Options{}, []string{"secret: 2 files, 2 iterations", "  cold: min ", "  warm: the decryption cache is disabled"}},
+		{"Cache", Options{CacheDir: t.TempDir()}, []string{"secret: 2 files, 2 iterations", "  cold: min ", "  warm: min "}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := benchGenerator(&out, g, 2, tt.opts)
+			if err != nil {
+				t.Fatalf("benchGenerator() error = %v", err)
+			}
+			lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			if len(lines) != len(tt.lines) {
+				t.Fatalf("benchGenerator() = %q, want %d lines", out.String(), len(tt.lines))
+			}
+			for i, prefix := range tt.lines {
+				if !strings.HasPrefix(lines[i], prefix) {
+					t.Errorf("benchGenerator() line %d = %q, want prefix %q", i+1, lines[i], prefix)
+				}
+			}
+		})
+	}
+
+	g.Generator.EnvSources = []string{"missing.env"}
+	if err := benchGenerator(&bytes.Buffer{}, g, 1, Options{}); err == nil {
+		t.Error("benchGenerator() of a missing source succeeded")
+	}
+}
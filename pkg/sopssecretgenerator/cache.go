@@ -0,0 +1,204 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"filippo.io/age"
+	"github.com/pkg/errors"
+)
+
+// defaultCacheTTL is how long decrypted files are cached if no TTL is set
+const defaultCacheTTL = time.Hour
+
+// cacheIdentityFile is the name of the generated age identity in the cache
+// directory
+const cacheIdentityFile = "identity.txt"
+
+// decryptCache is an on-disk cache of decrypted files, keyed by a hash of the
+// encrypted content. Entries are encrypted with a local age identity, so the
+// cache is no easier to read than the key files on the same machine.
+type decryptCache struct {
+	dir      string
+	ttl      time.Duration
+	identity *age.X25519Identity
+}
+
+// cacheConfig identifies an opened cache
+type cacheConfig struct {
+	dir      string
+	ttl      time.Duration
+	identity string
+}
+
+var (
+	cachesMutex sync.Mutex
+	caches      = make(map[cacheConfig]*decryptCache)
+)
+
+// openCache returns the decryption cache for the options, or nil if the cache
+// is not enabled. A cache is opened once per process. The cache is an
+// optimization, so if it cannot be opened, a warning is printed and
+// decryption continues without it.
+func openCache(opts Options) *decryptCache {
+	if opts.CacheDir == "" || opts.NoCache {
+		return nil
+	}
+	cachesMutex.Lock()
+	defer cachesMutex.Unlock()
+
+	config := cacheConfig{opts.CacheDir, opts.CacheTTL, opts.CacheIdentity}
+	c, ok := caches[config]
+	if !ok {
+		var err error
+		c, err = newDecryptCache(config.dir, config.ttl, config.identity)
+		if err != nil {
+			opts.logger().Warn("decryption cache disabled", "dir", config.dir, "error", err)
+		}
+		caches[config] = c
+	}
+	return c
+}
+
+// newDecryptCache opens a cache directory. If no identity file is given, an
+// identity is generated in the cache directory on first use.
+func newDecryptCache(dir string, ttl time.Duration, identityFile string) (*decryptCache, error) {
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create cache directory")
+	}
+	if identityFile == "" {
+		identityFile = filepath.Join(dir, cacheIdentityFile)
+		err = generateCacheIdentity(identityFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	identity, err := readCacheIdentity(identityFile)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptCache{dir: dir, ttl: ttl, identity: identity}, nil
+}
+
+// generateCacheIdentity writes a new age identity, unless the file exists.
+func generateCacheIdentity(fileName string) error {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if os.IsExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "could not create cache identity")
+	}
+	_, err = fmt.Fprintf(f, "# sops-secretgen cache identity\n%s\n", identity)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// readCacheIdentity reads the first age identity in a file.
+func readCacheIdentity(fileName string) (*age.X25519Identity, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read cache identity")
+	}
+	defer wipe(content)
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		identity, err := age.ParseX25519Identity(line)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid cache identity in %s", fileName)
+		}
+		return identity, nil
+	}
+	return nil, errors.Errorf("no age identity in %s", fileName)
+}
+
+// entryPath returns the path of the cache entry for encrypted content.
+func (c *decryptCache) entryPath(content []byte) string {
+	sum := sha256.Sum256(content)
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".age")
+}
+
+// get returns the cached plaintext for encrypted content. Expired and
+// unreadable entries are removed and reported as missing.
+func (c *decryptCache) get(content []byte) ([]byte, bool) {
+	entry := c.entryPath(content)
+	info, err := os.Stat(entry)
+	if err != nil {
+		return nil, false
+	}
+	if time.Since(info.ModTime()) > c.ttl {
+		_ = os.Remove(entry)
+		return nil, false
+	}
+	ciphertext, err := os.ReadFile(entry)
+	if err != nil {
+		return nil, false
+	}
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), c.identity)
+	if err != nil {
+		_ = os.Remove(entry)
+		return nil, false
+	}
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		wipe(plaintext)
+		_ = os.Remove(entry)
+		return nil, false
+	}
+	return plaintext, true
+}
+
+// put stores the plaintext for encrypted content.
+func (c *decryptCache) put(content []byte, plaintext []byte) error {
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, c.identity.Recipient())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(plaintext)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	entry := c.entryPath(content)
+	tmp, err := os.CreateTemp(c.dir, ".entry-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	_, err = tmp.Write(buf.Bytes())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), entry)
+}
@@ -0,0 +1,146 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func Test_newDecryptCache(t *testing.T) {
+	dir := t.TempDir()
+	first, err := newDecryptCache(dir, 0, "")
+	if err != nil {
+		t.Fatalf("newDecryptCache() error = %v", err)
+	}
+	if first.ttl != defaultCacheTTL {
+		t.Errorf("newDecryptCache() ttl = %v, want %v", first.ttl, defaultCacheTTL)
+	}
+	info, err := os.Stat(filepath.Join(dir, cacheIdentityFile))
+	if err != nil {
+		t.Fatalf("identity not generated: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("identity mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	second, err := newDecryptCache(dir, time.Minute, "")
+	if err != nil {
+		t.Fatalf("newDecryptCache() error = %v", err)
+	}
+	if second.identity.String() != first.identity.String() {
+		t.Errorf("newDecryptCache() generated a new identity for an existing cache")
+	}
+
+	invalid := filepath.Join(dir, "invalid.txt")
+	writeTestFile(t, invalid, "# no identity\n")
+	_, err = newDecryptCache(dir, 0, invalid)
+	if err == nil {
+		t.Errorf("newDecryptCache() with invalid identity file, want error")
+	}
+}
+
+func Test_decryptCache(t *testing.T) {
+	c, err := newDecryptCache(t.TempDir(), time.Hour, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted := []byte("encrypted content")
+
+	_, ok := c.get(encrypted)
+	if ok {
+		t.Fatalf("get() on empty cache, want miss")
+	}
+	err = c.put(encrypted, []byte("plaintext"))
+	if err != nil {
+		t.Fatalf("put() error = %v", err)
+	}
+	got, ok := c.get(encrypted)
+	if !ok || string(got) != "plaintext" {
+		t.Errorf("get() = %q, %v, want %q", got, ok, "plaintext")
+	}
+	_, ok = c.get([]byte("other content"))
+	if ok {
+		t.Errorf("get() for other content, want miss")
+	}
+
+	ciphertext, err := os.ReadFile(c.entryPath(encrypted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(ciphertext) == "plaintext" {
+		t.Errorf("cache entry is not encrypted")
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	err = os.Chtimes(c.entryPath(encrypted), old, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ok = c.get(encrypted)
+	if ok {
+		t.Errorf("get() for expired entry, want miss")
+	}
+	if _, err := os.Stat(c.entryPath(encrypted)); !os.IsNotExist(err) {
+		t.Errorf("expired entry was not removed")
+	}
+
+	writeTestFile(t, c.entryPath(encrypted), "corrupt")
+	_, ok = c.get(encrypted)
+	if ok {
+		t.Errorf("get() for corrupt entry, want miss")
+	}
+}
+
+func Test_decryptCached(t *testing.T) {
+	c, err := newDecryptCache(t.TempDir(), time.Hour, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	decryptFn := func() ([]byte, error) {
+		calls++
+		return []byte("plaintext"), nil
+	}
+	failFn := func() ([]byte, error) {
+		calls++
+		return nil, errors.New("failed")
+	}
+
+	tests := []struct {
+		name       string
+		cache      *decryptCache
+		content    string
+		decryptFn  func() ([]byte, error)
+		wantCached bool
+		wantCalls  int
+		wantErr    bool
+	}{
+		{"NoCache", nil, "a", decryptFn, false, 1, false},
+		{"Miss", c, "a", decryptFn, false, 1, false},
+		{"Hit", c, "a", decryptFn, true, 0, false},
+		{"FailureNotCached", c, "b", failFn, false, 1, true},
+		{"FailureRetried", c, "b", failFn, false, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			got, cached, err := decryptCached(context.Background(), tt.cache, []byte(tt.content), tt.decryptFn, 0)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decryptCached() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if cached != tt.wantCached || calls != tt.wantCalls {
+				t.Errorf("decryptCached() cached = %v with %d calls, want %v with %d", cached, calls, tt.wantCached, tt.wantCalls)
+			}
+			if !tt.wantErr && string(got) != "plaintext" {
+				t.Errorf("decryptCached() = %q, want %q", got, "plaintext")
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/pgp"
+	"github.com/pkg/errors"
+)
+
+// deniedKind is how check-access reports a file that cannot be decrypted
+const deniedKind = "DENIED"
+
+// accessProblem is a source file that the identity or recipients that are
+// checked cannot decrypt
+type accessProblem struct {
+	Path   string
+	Detail string
+}
+
+// runCheckAccess implements the check-access subcommand.
+func runCheckAccess(args []string) error {
+	flags := newFlagSet("check-access")
+	var recipients []string
+	flags.Func("recipient", "`key` that must be able to decrypt every file, checked without decrypting, as sops records it: an age recipient, PGP fingerprint or KMS ARN (repeatable)", func(s string) error {
+		recipients = append(recipients, s)
+		return nil
+	})
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	root := "."
+	if flags.NArg() > 0 {
+		root = flags.Arg(0)
+	}
+
+	files, err := referencedFiles(root)
+	if err != nil {
+		return err
+	}
+	problems, checked, err := checkAccess(files, recipients)
+	if err != nil {
+		return err
+	}
+	var annotations []annotation
+	for _, problem := range problems {
+		_, _ = fmt.Fprintf(os.Stderr, "%s %s: %s\n", colorize(colorRed, fmt.Sprintf("%-7s", deniedKind)), problem.Path, problem.Detail)
+		annotations = append(annotations, annotation{Severity: severityError, File: problem.Path, Title: "check-access", Message: problem.Detail})
+	}
+	err = writeAnnotations(os.Stdout, runtimeSettings.Annotations, annotations)
+	if err != nil {
+		return err
+	}
+	who := "the current identity"
+	if len(recipients) > 0 {
+		who = strings.Join(recipients, ", ")
+	}
+	if len(problems) > 0 {
+		return withCause(ErrKeyDenied, errors.Errorf("%d of %d source files cannot be decrypted by %s", len(problems), checked, who))
+	}
+	// A GitLab report is the only output on stdout
+	if runtimeSettings.Annotations != annotationsGitLab {
+		fmt.Printf("ok      %d source files can be decrypted by %s\n", checked, who)
+	}
+	return nil
+}
+
+// checkAccess checks that source files can be decrypted. Without recipients,
+// it decrypts the data key of every file with the keys of the current
+// identity, which proves access without decrypting any value. With
+// recipients, it checks the sops metadata of every file instead, so that CI
+// can check the key of another identity, such as a deploy robot, without
+// having it. Files that do not exist are skipped, lint reports them. It
+// returns the problems and the number of files checked.
+func checkAccess(files []string, recipients []string) ([]accessProblem, int, error) {
+	keyServices, err := localKeyServices()
+	if err != nil {
+		return nil, 0, err
+	}
+	var problems []accessProblem
+	checked := 0
+	for _, file := range files {
+		tree, _, err := loadEncryptedTree(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		checked++
+		if err != nil {
+			problems = append(problems, accessProblem{Path: file, Detail: fmt.Sprintf("could not be read as an encrypted file: %v", err)})
+			continue
+		}
+		if len(recipients) > 0 {
+			for _, recipient := range recipients {
+				err = recipientAccess(tree.Metadata, recipient)
+				if err != nil {
+					problems = append(problems, accessProblem{Path: file, Detail: err.Error()})
+				}
+			}
+			continue
+		}
+		passGPGTTY(tree.Metadata)
+		dataKey, err := tree.Metadata.GetDataKeyWithKeyServices(keyServices, nil)
+		wipe(dataKey)
+		if err != nil {
+			err = gpgAgentError(decryptError(err))
+			problems = append(problems, accessProblem{Path: file, Detail: err.Error()})
+		}
+		auditErr := auditCommand("check-access", tree.FilePath, tree.Metadata, err)
+		if auditErr != nil {
+			return nil, checked, auditErr
+		}
+	}
+	return problems, checked, nil
+}
+
+// recipientAccess checks that a recipient can decrypt a file on its own: it
+// must be a key of as many key groups as the Shamir threshold requires.
+func recipientAccess(metadata sops.Metadata, recipient string) error {
+	groups := 0
+	for _, group := range metadata.KeyGroups {
+		for _, key := range group {
+			if isRecipient(key.TypeToIdentifier(), key.ToString(), recipient) {
+				groups++
+				break
+			}
+		}
+	}
+	threshold := max(shamirThreshold(metadata), 1)
+	switch {
+	case groups == 0:
+		return errors.Errorf("%s is not a recipient of the file", recipient)
+	case groups < threshold:
+		return errors.Errorf("%s is a recipient in %d key groups, but the file needs %d", recipient, groups, threshold)
+	}
+	return nil
+}
+
+// isRecipient reports whether a key of a file is a recipient. PGP
+// fingerprints are compared regardless of case and whitespace.
+func isRecipient(keyType string, key string, recipient string) bool {
+	if keyType == pgp.KeyTypeIdentifier {
+		return normalizeFingerprint(key) == normalizeFingerprint(recipient)
+	}
+	return key == recipient
+}
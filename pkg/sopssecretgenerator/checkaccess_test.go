@@ -0,0 +1,67 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/age"
+	"github.com/getsops/sops/v3/pgp"
+)
+
+func Test_checkAccess(t *testing.T) {
+	files := []string{"testdata/file.txt", "testdata/vars.yaml", "testdata/notyaml.txt", "testdata/missing.env"}
+	tests := []struct {
+		name        string
+		recipients  []string
+		want        []string
+		wantChecked int
+	}{
+		{"Identity", nil, []string{"testdata/notyaml.txt"}, 3},
+		{"Recipient", []string{"2d24 83df 73a3 a0fa ee3c  2a69 5bdc 3953 60ce 8ff4"}, []string{"testdata/notyaml.txt"}, 3},
+		{"NotRecipient", []string{testAgeRecipient}, []string{"testdata/file.txt", "testdata/vars.yaml", "testdata/notyaml.txt"}, 3},
+	}
+	setupEncryptionKeyring(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems, checked, err := checkAccess(files, tt.recipients)
+			if err != nil {
+				t.Fatalf("checkAccess() error = %v", err)
+			}
+			var got []string
+			for _, problem := range problems {
+				got = append(got, problem.Path)
+			}
+			if !reflect.DeepEqual(got, tt.want) || checked != tt.wantChecked {
+				t.Errorf("checkAccess() = %v, %d, want %v, %d", problems, checked, tt.want, tt.wantChecked)
+			}
+		})
+	}
+}
+
+func Test_recipientAccess(t *testing.T) {
+	pgpKey := pgp.NewMasterKeyFromFingerprint(testkeyFingerprint)
+	ageKey := &age.MasterKey{Recipient: testAgeRecipient}
+	tests := []struct {
+		name     string
+		metadata sops.Metadata
+		wantErr  bool
+	}{
+		{"SingleGroup", sops.Metadata{KeyGroups: []sops.KeyGroup{{pgpKey, ageKey}}}, false},
+		{"NotRecipient", sops.Metadata{KeyGroups: []sops.KeyGroup{{pgpKey}}}, true},
+		{"AllGroups", sops.Metadata{KeyGroups: []sops.KeyGroup{{ageKey}, {ageKey, pgpKey}}}, false},
+		{"BelowThreshold", sops.Metadata{KeyGroups: []sops.KeyGroup{{ageKey}, {pgpKey}}}, true},
+		{"Threshold", sops.Metadata{KeyGroups: []sops.KeyGroup{{ageKey}, {pgpKey}, {ageKey}}, ShamirThreshold: 2}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := recipientAccess(tt.metadata, testAgeRecipient)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("recipientAccess() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/cmd/sops/common"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/getsops/sops/v3/config"
+	"github.com/pkg/errors"
+)
+
+// plaintextKind is how check-encrypted reports a file with plaintext
+const plaintextKind = "PLAIN"
+
+// plaintextProblem is a source file that is not, or not fully, encrypted
+type plaintextProblem struct {
+	Path   string
+	Detail string
+}
+
+// runCheckEncrypted implements the check-encrypted subcommand.
+func runCheckEncrypted(args []string) error {
+	flags := newFlagSet("check-encrypted")
+	dir := flags.String("dir", ".", "`directory` to find generators in")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	problems, checked, err := checkEncrypted(*dir, flags.Args())
+	if err != nil {
+		return err
+	}
+	var annotations []annotation
+	for _, problem := range problems {
+		_, _ = fmt.Fprintf(os.Stderr, "%s %s: %s\n", colorize(colorRed, fmt.Sprintf("%-7s", plaintextKind)), problem.Path, problem.Detail)
+		annotations = append(annotations, annotation{Severity: severityError, File: problem.Path, Title: "check-encrypted", Message: problem.Detail})
+	}
+	err = writeAnnotations(os.Stdout, runtimeSettings.Annotations, annotations)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("%d of %d source files are not encrypted", len(problems), checked)
+	}
+	// A GitLab report is the only output on stdout
+	if runtimeSettings.Annotations != annotationsGitLab {
+		fmt.Printf("ok      %d source files are encrypted\n", checked)
+	}
+	return nil
+}
+
+// checkEncrypted checks that the source files of the generators under a
+// directory are encrypted with sops, without decrypting them. If files are
+// given, as a pre-commit hook passes the staged files, only those of them
+// that generators reference are checked. Files that do not exist are
+// skipped, lint reports them. It returns the problems and the number of
+// files checked.
+func checkEncrypted(dir string, only []string) ([]plaintextProblem, int, error) {
+	referenced, err := referencedFiles(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	files := referenced
+	if len(only) > 0 {
+		files, err = referencedAmong(referenced, only)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	var problems []plaintextProblem
+	checked := 0
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		checked++
+		for _, detail := range plaintextDetails(file, content) {
+			problems = append(problems, plaintextProblem{Path: file, Detail: detail})
+		}
+	}
+	return problems, checked, nil
+}
+
+// referencedAmong returns the files that are referenced by generators, in
+// the order of the given files.
+func referencedAmong(referenced []string, files []string) ([]string, error) {
+	isReferenced := make(map[string]bool)
+	for _, file := range referenced {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		isReferenced[abs] = true
+	}
+	var among []string
+	for _, file := range files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		if isReferenced[abs] {
+			among = append(among, file)
+		}
+	}
+	return among, nil
+}
+
+// plaintextDetails returns what is not encrypted in the content of a source
+// file: the whole file if it has no sops metadata, or otherwise the keys of
+// values that should be encrypted but are not, such as values added by hand
+// after the file was encrypted. Values that the unencrypted and encrypted
+// suffix and regex of the file leave in plaintext are not reported.
+func plaintextDetails(fileName string, content []byte) []string {
+	format := formats.FormatForPath(fileName)
+	store := common.StoreForFormat(format, config.NewStoresConfig())
+	tree, err := store.LoadEncryptedFile(content)
+	if err != nil {
+		err = loadError(err, content, format)
+		if errors.Is(err, ErrNotEncrypted) {
+			return []string{"has no sops metadata, the file is not encrypted"}
+		}
+		return []string{fmt.Sprintf("could not be read as an encrypted file: %v", err)}
+	}
+
+	var details []string
+	for _, branch := range tree.Branches {
+		walkPlaintext(branch, nil, tree.Metadata, &details)
+	}
+	return details
+}
+
+// walkPlaintext adds the keys of plaintext values under a value of a sops
+// tree to details. Comments are not values, so they are skipped.
+func walkPlaintext(value interface{}, path []string, metadata sops.Metadata, details *[]string) {
+	switch v := value.(type) {
+	case sops.TreeBranch:
+		for _, item := range v {
+			if _, ok := item.Key.(sops.Comment); ok {
+				continue
+			}
+			walkPlaintext(item.Value, append(path[:len(path):len(path)], fmt.Sprint(item.Key)), metadata, details)
+		}
+	case []interface{}:
+		for i, element := range v {
+			if _, ok := element.(sops.Comment); ok {
+				continue
+			}
+			walkPlaintext(element, append(path[:len(path):len(path)], fmt.Sprint(i)), metadata, details)
+		}
+	case nil:
+	case string:
+		if v != "" && !strings.HasPrefix(v, "ENC[") && shouldBeEncrypted(path, metadata) {
+			*details = append(*details, fmt.Sprintf("value of key %s is not encrypted", strings.Join(path, ".")))
+		}
+	default:
+		if shouldBeEncrypted(path, metadata) {
+			*details = append(*details, fmt.Sprintf("value of key %s is not encrypted", strings.Join(path, ".")))
+		}
+	}
+}
+
+// shouldBeEncrypted reports whether sops encrypts the value at a path, by
+// the suffix and regex settings that the file was encrypted with, like sops
+// does: a key anywhere on the path can match.
+func shouldBeEncrypted(path []string, metadata sops.Metadata) bool {
+	anyKey := func(match func(string) bool) bool {
+		for _, key := range path {
+			if match(key) {
+				return true
+			}
+		}
+		return false
+	}
+	matchRegex := func(expr string) func(string) bool {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return func(string) bool { return false }
+		}
+		return re.MatchString
+	}
+	switch {
+	case metadata.UnencryptedSuffix != "":
+		return !anyKey(func(key string) bool { return strings.HasSuffix(key, metadata.UnencryptedSuffix) })
+	case metadata.EncryptedSuffix != "":
+		return anyKey(func(key string) bool { return strings.HasSuffix(key, metadata.EncryptedSuffix) })
+	case metadata.UnencryptedRegex != "":
+		return !anyKey(matchRegex(metadata.UnencryptedRegex))
+	case metadata.EncryptedRegex != "":
+		return anyKey(matchRegex(metadata.EncryptedRegex))
+	}
+	return true
+}
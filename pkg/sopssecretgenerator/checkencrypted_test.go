@@ -0,0 +1,129 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/getsops/sops/v3"
+)
+
+func Test_checkEncrypted(t *testing.T) {
+	encrypted, err := os.ReadFile("testdata/file.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := "password: hunter2\nnote_unencrypted: not a secret\n" + string(encrypted)
+	manifest := "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: app\nenvs:\n  - vars.env\n  - config.yaml\n  - missing.env\nfiles:\n  - file.txt\n"
+
+	tests := []struct {
+		name        string
+		files       map[string]string
+		only        []string
+		want        []string
+		wantChecked int
+	}{
+		{"Encrypted", map[string]string{"vars.env": "testdata/vars.env", "config.yaml": "testdata/file.yaml", "file.txt": "testdata/file.txt"}, nil, nil, 3},
+		{"PlaintextFile", map[string]string{"vars.env": "testdata/vars.env", "config.yaml": "testdata/file.yaml", "file.txt": "testdata/notyaml.txt"}, nil,
+			[]string{"file.txt: has no sops metadata, the file is not encrypted"}, 3},
+		{"PlaintextValue", map[string]string{"vars.env": "testdata/vars.env", "file.txt": "testdata/file.txt"}, nil,
+			[]string{"config.yaml: value of key password is not encrypted"}, 3},
+		{"Only", map[string]string{"vars.env": "testdata/vars.env", "config.yaml": "testdata/file.yaml", "file.txt": "testdata/notyaml.txt"},
+			[]string{"vars.env", "unrelated.txt"}, nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFile(t, filepath.Join(dir, "generator.yaml"), manifest)
+			if _, ok := tt.files["config.yaml"]; !ok {
+				writeTestFile(t, filepath.Join(dir, "config.yaml"), tampered)
+			}
+			for dst, src := range tt.files {
+				copyTestFile(t, src, filepath.Join(dir, dst))
+			}
+			var only []string
+			for _, file := range tt.only {
+				only = append(only, filepath.Join(dir, file))
+			}
+
+			problems, checked, err := checkEncrypted(dir, only)
+			if err != nil {
+				t.Fatalf("checkEncrypted() error = %v", err)
+			}
+			var got []string
+			for _, problem := range problems {
+				rel, _ := filepath.Rel(dir, problem.Path)
+				got = append(got, filepath.ToSlash(rel)+": "+problem.Detail)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkEncrypted() = %v, want %v", got, tt.want)
+			}
+			if checked != tt.wantChecked {
+				t.Errorf("checkEncrypted() checked = %d, want %d", checked, tt.wantChecked)
+			}
+		})
+	}
+}
+
+func Test_runCheckEncrypted_invalidGenerator(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, dir string)
+	}{
+		{"Malformed", func(t *testing.T, dir string) {
+			writeTestFile(t, filepath.Join(dir, "broken.yaml"), "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata: [\n")
+		}},
+		{"Invalid", func(t *testing.T, dir string) {
+			writeTestFile(t, filepath.Join(dir, "broken.yaml"), "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nenvs:\n  - vars.env\n")
+		}},
+		{"Unreadable", func(t *testing.T, dir string) {
+			err := os.Symlink(filepath.Join(dir, "missing.yaml"), filepath.Join(dir, "broken.yaml"))
+			if err != nil {
+				t.Skipf("could not create symlink: %v", err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFile(t, filepath.Join(dir, "generator.yaml"), "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: app\nenvs:\n  - vars.env\n")
+			copyTestFile(t, "testdata/vars.env", filepath.Join(dir, "vars.env"))
+			tt.setup(t, dir)
+
+			err := runCheckEncrypted([]string{"--dir", dir})
+			if err == nil || !strings.Contains(err.Error(), filepath.Join(dir, "broken.yaml")) {
+				t.Errorf("runCheckEncrypted() error = %v, want it to report broken.yaml", err)
+			}
+		})
+	}
+}
+
+func Test_shouldBeEncrypted(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     []string
+		metadata sops.Metadata
+		want     bool
+	}{
+		{"Default", []string{"password"}, sops.Metadata{}, true},
+		{"UnencryptedSuffix", []string{"db", "host_unencrypted"}, sops.Metadata{UnencryptedSuffix: "_unencrypted"}, false},
+		{"UnencryptedSuffixParent", []string{"public_unencrypted", "host"}, sops.Metadata{UnencryptedSuffix: "_unencrypted"}, false},
+		{"EncryptedSuffix", []string{"password"}, sops.Metadata{EncryptedSuffix: "_secret"}, false},
+		{"EncryptedSuffixMatch", []string{"password_secret"}, sops.Metadata{EncryptedSuffix: "_secret"}, true},
+		{"UnencryptedRegex", []string{"metadata", "name"}, sops.Metadata{UnencryptedRegex: "^(metadata|kind)$"}, false},
+		{"EncryptedRegex", []string{"data", "password"}, sops.Metadata{EncryptedRegex: "^data$"}, true},
+		{"EncryptedRegexNoMatch", []string{"kind"}, sops.Metadata{EncryptedRegex: "^data$"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldBeEncrypted(tt.path, tt.metadata); got != tt.want {
+				t.Errorf("shouldBeEncrypted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
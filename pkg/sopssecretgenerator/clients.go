@@ -0,0 +1,243 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/azkv"
+	"github.com/getsops/sops/v3/hcvault"
+	"github.com/getsops/sops/v3/keyservice"
+	"github.com/getsops/sops/v3/kms"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// awsRoleSessionName identifies the plugin in CloudTrail when it assumes a
+// role for a KMS key
+const awsRoleSessionName = "sops-secretgen"
+
+// sharedCredentials holds cloud credentials for the whole invocation. sops
+// loads credentials, and assumes roles, for every file it decrypts; sharing
+// them means each is loaded and refreshed only once.
+type sharedCredentials struct {
+	mu    sync.Mutex
+	aws   map[awsCredentialsKey]aws.CredentialsProvider
+	azure azcore.TokenCredential
+	gcp   oauth2.TokenSource
+	// vault is the Vault token, once vaultLoaded is set. It is empty if
+	// there is none, and sops then fails with its own error.
+	vault       string
+	vaultLoaded bool
+}
+
+// awsCredentialsKey identifies the AWS credentials for a KMS key
+type awsCredentialsKey struct {
+	profile string
+	role    string
+}
+
+// invocationCredentials are the credentials shared by all decryptions
+var invocationCredentials = &sharedCredentials{}
+
+// awsCredentials returns the credentials for an AWS profile and, if set, a
+// role to assume. The region is only used to reach STS.
+func (c *sharedCredentials) awsCredentials(profile string, role string, region string) (aws.CredentialsProvider, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := awsCredentialsKey{profile, role}
+	if provider, ok := c.aws[key]; ok {
+		return provider, nil
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background(), func(o *awsconfig.LoadOptions) error {
+		o.SharedConfigProfile = profile
+		o.Region = region
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not load AWS config")
+	}
+	provider := cfg.Credentials
+	if role != "" {
+		provider = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), role, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = awsRoleSessionName
+		}))
+	}
+	if c.aws == nil {
+		c.aws = make(map[awsCredentialsKey]aws.CredentialsProvider)
+	}
+	c.aws[key] = provider
+	return provider, nil
+}
+
+// azureCredential returns the default Azure credential, which caches tokens.
+func (c *sharedCredentials) azureCredential() (azcore.TokenCredential, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.azure == nil {
+		credential, err := azidentity.NewDefaultAzureCredential(nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not load Azure credentials")
+		}
+		c.azure = credential
+	}
+	return c.azure, nil
+}
+
+// gcpTokenSource returns the Google credentials in GOOGLE_CREDENTIALS, a
+// path or the JSON itself as sops accepts it, or the default credentials.
+// The token source caches tokens.
+func (c *sharedCredentials) gcpTokenSource() (oauth2.TokenSource, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.gcp == nil {
+		const scope = "https://www.googleapis.com/auth/cloud-platform"
+		var credentials *google.Credentials
+		var err error
+		if value := os.Getenv("GOOGLE_CREDENTIALS"); value != "" {
+			content, readErr := os.ReadFile(value)
+			if readErr != nil {
+				content = []byte(value)
+			}
+			credentials, err = google.CredentialsFromJSON(context.Background(), content, scope)
+		} else {
+			credentials, err = google.FindDefaultCredentials(context.Background(), scope)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "could not load Google credentials")
+		}
+		c.gcp = credentials.TokenSource
+	}
+	return c.gcp, nil
+}
+
+// vaultToken returns the Vault token in VAULT_TOKEN or ~/.vault-token, the
+// places sops looks for one. sops reads the token file for every file it
+// decrypts; it is read once instead.
+func (c *sharedCredentials) vaultToken() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.vaultLoaded {
+		token := os.Getenv("VAULT_TOKEN")
+		if token == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", errors.Wrap(err, "could not find the Vault token file")
+			}
+			content, err := os.ReadFile(filepath.Join(home, ".vault-token"))
+			if err != nil && !os.IsNotExist(err) {
+				return "", errors.Wrap(err, "could not read the Vault token file")
+			}
+			token = string(bytes.TrimSpace(content))
+		}
+		c.vault = token
+		c.vaultLoaded = true
+	}
+	return c.vault, nil
+}
+
+// sharedClientServer is a local key service that decrypts AWS KMS, Azure Key
+// Vault and Vault transit keys with shared credentials. Other keys are passed
+// on unchanged.
+type sharedClientServer struct {
+	next        keyservice.KeyServiceServer
+	credentials *sharedCredentials
+}
+
+// Encrypt encrypts a data key.
+func (s sharedClientServer) Encrypt(ctx context.Context, req *keyservice.EncryptRequest) (*keyservice.EncryptResponse, error) {
+	return s.next.Encrypt(ctx, req)
+}
+
+// Decrypt decrypts a data key.
+func (s sharedClientServer) Decrypt(ctx context.Context, req *keyservice.DecryptRequest) (*keyservice.DecryptResponse, error) {
+	var plaintext []byte
+	var err error
+	switch k := req.Key.KeyType.(type) {
+	case *keyservice.Key_KmsKey:
+		plaintext, err = s.decryptKMS(k.KmsKey, req.Ciphertext)
+	case *keyservice.Key_AzureKeyvaultKey:
+		plaintext, err = s.decryptAzureKeyVault(k.AzureKeyvaultKey, req.Ciphertext)
+	case *keyservice.Key_VaultKey:
+		plaintext, err = s.decryptVault(k.VaultKey, req.Ciphertext)
+	default:
+		return s.next.Decrypt(ctx, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &keyservice.DecryptResponse{Plaintext: plaintext}, nil
+}
+
+// decryptKMS decrypts a data key with AWS KMS. The role is assumed by the
+// shared credentials, so it is not passed on to sops.
+func (s sharedClientServer) decryptKMS(key *keyservice.KmsKey, ciphertext []byte) ([]byte, error) {
+	provider, err := s.credentials.awsCredentials(key.AwsProfile, key.Role, kmsRegion(key.Arn))
+	if err != nil {
+		return nil, err
+	}
+	encryptionContext := make(map[string]*string, len(key.Context))
+	for k, v := range key.Context {
+		encryptionContext[k] = &v
+	}
+	masterKey := kms.NewMasterKeyWithProfile(key.Arn, "", encryptionContext, key.AwsProfile)
+	kms.NewCredentialsProvider(provider).ApplyToMasterKey(masterKey)
+	masterKey.EncryptedKey = string(ciphertext)
+	return masterKey.Decrypt()
+}
+
+// decryptAzureKeyVault decrypts a data key with Azure Key Vault.
+func (s sharedClientServer) decryptAzureKeyVault(key *keyservice.AzureKeyVaultKey, ciphertext []byte) ([]byte, error) {
+	credential, err := s.credentials.azureCredential()
+	if err != nil {
+		return nil, err
+	}
+	masterKey := azkv.NewMasterKey(key.VaultUrl, key.Name, key.Version)
+	azkv.NewTokenCredential(credential).ApplyToMasterKey(masterKey)
+	masterKey.EncryptedKey = string(ciphertext)
+	return masterKey.Decrypt()
+}
+
+// decryptVault decrypts a data key with a Vault transit key. sops creates a
+// Vault client for every key, but only takes a token, so the token is shared.
+func (s sharedClientServer) decryptVault(key *keyservice.VaultKey, ciphertext []byte) ([]byte, error) {
+	token, err := s.credentials.vaultToken()
+	if err != nil {
+		return nil, err
+	}
+	masterKey := hcvault.NewMasterKey(key.VaultAddress, key.EnginePath, key.KeyName)
+	hcvault.Token(token).ApplyToMasterKey(masterKey)
+	masterKey.EncryptedKey = string(ciphertext)
+	return masterKey.Decrypt()
+}
+
+// hasSharedClientKeys reports whether a file is encrypted to any keys that
+// are decrypted with shared credentials.
+func hasSharedClientKeys(metadata sops.Metadata) bool {
+	for _, group := range metadata.KeyGroups {
+		for _, key := range group {
+			switch key.TypeToIdentifier() {
+			case kms.KeyTypeIdentifier, azkv.KeyTypeIdentifier, hcvault.KeyTypeIdentifier:
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os/exec"
+	"strings"
+
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/pkg/errors"
+)
+
+// ClusterSource is a sops-encrypted file stored under a key of a Secret or
+// ConfigMap in the cluster, for clusters where git cannot hold even
+// encrypted secrets. It is parsed like an env source.
+type ClusterSource struct {
+	// Kind is Secret, the default, or ConfigMap
+	Kind string `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Name string `json:"name" yaml:"name"`
+	// Namespace is that of the kubeconfig context if empty
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	// Key holds the encrypted file, and its extension gives the format
+	Key string `json:"key" yaml:"key"`
+	// Context is the kubeconfig context, the current one if empty
+	Context string `json:"context,omitempty" yaml:"context,omitempty"`
+}
+
+// String returns the cluster source as kind/namespace/name[key].
+func (s ClusterSource) String() string {
+	name := s.Name
+	if s.Namespace != "" {
+		name = s.Namespace + "/" + name
+	}
+	return strings.ToLower(s.kind()) + "/" + name + "[" + s.Key + "]"
+}
+
+// kind returns the kind of the object that holds the source.
+func (s ClusterSource) kind() string {
+	if s.Kind == "" {
+		return "Secret"
+	}
+	return s.Kind
+}
+
+// format returns the format of the encrypted file.
+func (s ClusterSource) format() formats.Format {
+	return formats.FormatForPath(s.Key)
+}
+
+// validate checks the fields of a cluster source.
+func (s ClusterSource) validate() error {
+	if s.kind() != "Secret" && s.kind() != "ConfigMap" {
+		return errors.Errorf("clusterSources: kind must be Secret or ConfigMap, not %s", s.Kind)
+	}
+	if s.Name == "" || s.Key == "" {
+		return errors.New("clusterSources: name and key are required")
+	}
+	switch s.format() {
+	case formats.Dotenv, formats.Yaml, formats.Json:
+		return nil
+	}
+	if isCSV(s.Key) || isJSONC(s.Key) {
+		return nil
+	}
+	return errors.Errorf("clusterSources: key %s must have a dotenv, yaml, json, jsonc or csv extension", s.Key)
+}
+
+// clusterObject is the data of a Secret or ConfigMap
+type clusterObject struct {
+	Data       map[string]string `json:"data"`
+	BinaryData map[string]string `json:"binaryData"`
+}
+
+// fetch reads the encrypted file of the source from the cluster with
+// kubectl, which uses the kubeconfig of the environment.
+func (s ClusterSource) fetch(opts decryptOptions) ([]byte, error) {
+	args := []string{"get", s.kind(), s.Name, "--output", "json"}
+	if s.Namespace != "" {
+		args = append(args, "--namespace", s.Namespace)
+	}
+	if s.Context != "" {
+		args = append(args, "--context", s.Context)
+	}
+	cmd := exec.CommandContext(opts.context(), "kubectl", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "kubectl get: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	var object clusterObject
+	err = json.Unmarshal(output, &object)
+	if err != nil {
+		return nil, errors.Wrap(err, "kubectl get")
+	}
+	if value, ok := object.Data[s.Key]; ok {
+		if s.kind() == "ConfigMap" {
+			return []byte(value), nil
+		}
+		return base64.StdEncoding.DecodeString(value)
+	}
+	if value, ok := object.BinaryData[s.Key]; ok {
+		return base64.StdEncoding.DecodeString(value)
+	}
+	return nil, errors.Errorf("%s has no key %s", s.kind(), s.Key)
+}
+
+// parseClusterSources decrypts the cluster sources of a generator into the
+// Secret data. Creation rules apply to files, so they are not checked.
+func parseClusterSources(sources []ClusterSource, opts decryptOptions, data kvMap) error {
+	opts.Policy.MatchCreationRules = false
+	var errs []error
+	for _, source := range sources {
+		err := withWorker(opts, func() error { return parseClusterSource(source, opts, data) })
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "cluster source %s", source))
+			if opts.context().Err() != nil {
+				break
+			}
+			continue
+		}
+	}
+	return joinSourceErrors(errs)
+}
+
+func parseClusterSource(source ClusterSource, opts decryptOptions, data kvMap) error {
+	content, err := source.fetch(opts)
+	if err != nil {
+		return err
+	}
+	decrypted, err := decryptData(source.String(), content, source.format(), opts)
+	if err != nil {
+		return errors.Wrap(err, "sops could not decrypt")
+	}
+	defer wipe(decrypted)
+	return parseEnvFileContent(decrypted, source.Key, data)
+}
@@ -0,0 +1,129 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeKubectlGet records its arguments and prints the object in $TEST_OBJECT
+const fakeKubectlGet = `#!/bin/sh
+echo "$@" > "$TEST_DIR/args"
+if [ ! -f "$TEST_OBJECT" ]; then
+  echo 'Error from server (NotFound)' >&2
+  exit 1
+fi
+cat "$TEST_OBJECT"
+`
+
+func Test_ClusterSource_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  ClusterSource
+		wantErr bool
+	}{
+		{"Secret", ClusterSource{Name: "bootstrap", Key: "app.env"}, false},
+		{"ConfigMap", ClusterSource{Kind: "ConfigMap", Name: "bootstrap", Key: "app.yaml"}, false},
+		{"OtherKind", ClusterSource{Kind: "Pod", Name: "bootstrap", Key: "app.env"}, true},
+		{"NoName", ClusterSource{Key: "app.env"}, true},
+		{"NoKey", ClusterSource{Name: "bootstrap"}, true},
+		{"CSVKey", ClusterSource{Name: "bootstrap", Key: "app.csv"}, false},
+		{"JSONCKey", ClusterSource{Name: "bootstrap", Key: "app.jsonc"}, false},
+		{"BinaryKey", ClusterSource{Name: "bootstrap", Key: "app"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.source.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_parseClusterSources(t *testing.T) {
+	cat, err := exec.LookPath("cat")
+	if err != nil {
+		t.Skip("cat is not installed")
+	}
+	encrypted, err := os.ReadFile("testdata/vars.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		source   ClusterSource
+		object   map[string]interface{}
+		wantArgs string
+		wantErr  bool
+	}{
+		{"Secret", ClusterSource{Name: "bootstrap", Namespace: "infra", Key: "vars.yaml"},
+			map[string]interface{}{"data": map[string]string{"vars.yaml": base64.StdEncoding.EncodeToString(encrypted)}},
+			"get Secret bootstrap --output json --namespace infra", false},
+		{"ConfigMap", ClusterSource{Kind: "ConfigMap", Name: "bootstrap", Key: "vars.yaml", Context: "prod"},
+			map[string]interface{}{"data": map[string]string{"vars.yaml": string(encrypted)}},
+			"get ConfigMap bootstrap --output json --context prod", false},
+		{"BinaryData", ClusterSource{Kind: "ConfigMap", Name: "bootstrap", Key: "vars.yaml"},
+			map[string]interface{}{"binaryData": map[string]string{"vars.yaml": base64.StdEncoding.EncodeToString(encrypted)}},
+			"get ConfigMap bootstrap --output json", false},
+		{"MissingKey", ClusterSource{Name: "bootstrap", Key: "other.yaml"},
+			map[string]interface{}{"data": map[string]string{"vars.yaml": base64.StdEncoding.EncodeToString(encrypted)}},
+			"get Secret bootstrap --output json", true},
+		{"NotEncrypted", ClusterSource{Name: "bootstrap", Key: "vars.yaml"},
+			map[string]interface{}{"data": map[string]string{"vars.yaml": base64.StdEncoding.EncodeToString([]byte("VAR_YAML: plain\n"))}},
+			"get Secret bootstrap --output json", true},
+		{"NotFound", ClusterSource{Name: "missing", Key: "vars.yaml"}, nil,
+			"get Secret missing --output json", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			bin := filepath.Join(dir, "bin")
+			if err := os.Mkdir(bin, 0o700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink(cat, filepath.Join(bin, "cat")); err != nil {
+				t.Fatal(err)
+			}
+			writeTestFile(t, filepath.Join(bin, "kubectl"), fakeKubectlGet)
+			if err := os.Chmod(filepath.Join(bin, "kubectl"), 0o700); err != nil {
+				t.Fatal(err)
+			}
+			if tt.object != nil {
+				object, err := json.Marshal(tt.object)
+				if err != nil {
+					t.Fatal(err)
+				}
+				writeTestFile(t, filepath.Join(dir, "object.json"), string(object))
+			}
+			t.Setenv("PATH", bin)
+			t.Setenv("TEST_DIR", dir)
+			t.Setenv("TEST_OBJECT", filepath.Join(dir, "object.json"))
+
+			data := make(kvMap)
+			opts := decryptOptions{Context: context.Background()}
+			err := parseClusterSources([]ClusterSource{tt.source}, opts, data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseClusterSources() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			args, err := os.ReadFile(filepath.Join(dir, "args"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.TrimSpace(string(args)); got != tt.wantArgs {
+				t.Errorf("kubectl %s, want kubectl %s", got, tt.wantArgs)
+			}
+			if !tt.wantErr && data["VAR_YAML"] == "" {
+				t.Errorf("parseClusterSources() = %v, want VAR_YAML", data)
+			}
+		})
+	}
+}
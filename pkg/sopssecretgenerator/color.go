@@ -0,0 +1,68 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// ANSI escape sequences of the colors of diagnostics
+const (
+	colorRed    = "\033[31m"
+	colorYellow = "\033[33m"
+	colorReset  = "\033[0m"
+)
+
+// stderrColor enables colored diagnostics on stderr. The command enables it
+// if stderr is a terminal, unless NO_COLOR or --no-color is set.
+var stderrColor bool
+
+// colorEnabled reports whether diagnostics on a file are colored: if it is a
+// terminal that supports colors, and they are not disabled.
+func colorEnabled(f *os.File, noColor bool) bool {
+	if noColor || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// colorize returns a diagnostic in a color, if colors are enabled.
+func colorize(color string, s string) string {
+	if !stderrColor {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// colorWriter colors the records of a text logger by their level, errors in
+// red and warnings in yellow, if colors are enabled. The logger writes every
+// record in a single call.
+type colorWriter struct {
+	w io.Writer
+}
+
+// Write writes a record, in the color of its level.
+func (c colorWriter) Write(p []byte) (int, error) {
+	var color string
+	switch {
+	case !stderrColor:
+	case bytes.Contains(p, []byte(" level=ERROR ")):
+		color = colorRed
+	case bytes.Contains(p, []byte(" level=WARN ")):
+		color = colorYellow
+	}
+	if color == "" {
+		return c.w.Write(p)
+	}
+	record := bytes.TrimSuffix(p, []byte("\n"))
+	_, err := c.w.Write([]byte(color + string(record) + colorReset + string(p[len(record):])))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+)
+
+func Test_colorWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		level   slog.Level
+		prefix  string
+	}{
+		{"Disabled", false, slog.LevelError, "time="},
+		{"Error", true, slog.LevelError, colorRed + "time="},
+		{"Warning", true, slog.LevelWarn, colorYellow + "time="},
+		{"Info", true, slog.LevelInfo, "time="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(enabled bool) { stderrColor = enabled }(stderrColor)
+			stderrColor = tt.enabled
+			var out bytes.Buffer
+			newLogger(colorWriter{&out}, slog.LevelDebug).Log(context.Background(), tt.level, "message", "key", "value")
+			got := out.String()
+			if !bytes.HasPrefix([]byte(got), []byte(tt.prefix)) {
+				t.Errorf("colorWriter wrote %q, want prefix %q", got, tt.prefix)
+			}
+			wantSuffix := "\n"
+			if tt.prefix != "time=" {
+				wantSuffix = colorReset + "\n"
+			}
+			if !bytes.HasSuffix([]byte(got), []byte(wantSuffix)) {
+				t.Errorf("colorWriter wrote %q, want suffix %q", got, wantSuffix)
+			}
+		})
+	}
+}
+
+func Test_colorize(t *testing.T) {
+	defer func(enabled bool) { stderrColor = enabled }(stderrColor)
+	stderrColor = false
+	if got := colorize(colorRed, "FAILED"); got != "FAILED" {
+		t.Errorf("colorize() = %q, want it uncolored", got)
+	}
+	stderrColor = true
+	if got := colorize(colorRed, "FAILED"); got != colorRed+"FAILED"+colorReset {
+		t.Errorf("colorize() = %q, want it red", got)
+	}
+}
+
+func Test_colorEnabled(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	if colorEnabled(f, false) {
+		t.Error("colorEnabled() of a file = true, want false")
+	}
+	if colorEnabled(os.Stderr, true) {
+		t.Error("colorEnabled() with noColor = true, want false")
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// command is a subcommand for standalone use of the plugin
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(args []string) error
+}
+
+// commands are the available subcommands, in the order they are listed in
+// the usage message
+var commands []command
+
+func init() {
+	commands = []command{
+		{"rotate", "rotate [--update-keys] [DIR]", "Rotate the data keys of all files referenced by generators", runRotate},
+		{"updatekeys", "updatekeys [DIR]", "Encrypt all files referenced by generators to the keys of their .sops.yaml creation rules", runUpdateKeys},
+		{"encrypt", "encrypt [--config FILE] [--generator FILE] [--force] [--fake] PLAINTEXT OUTPUT", "Encrypt a file for use by a generator", runEncrypt},
+		{"edit", "edit [--dir DIR] FILE", "Edit an encrypted file and check that generators can still use it", runEdit},
+		{"exec-env", "exec-env [--name NAME] GENERATOR -- COMMAND [ARGS]", "Run a command with the env sources of a generator in its environment", runExecEnv},
+		{"export-env", "export-env [--name NAME] [--out FILE] GENERATOR", "Write the env sources of a generator to a .env file for docker compose, in an allowed directory", runExportEnv},
+		{"completion", "completion bash|zsh|fish", "Print a shell completion script for the commands and their flags", runCompletion},
+		{"convert", "convert [--write] PATH...", "Convert ksops and legacy generator manifests to SopsSecretGenerator", runConvert},
+		{"doctor", "doctor [DIR]", "Check that keys, source files and kustomize are set up to run the generators", runDoctor},
+		{"init", "init [--output FILE] [--env FILE]... [--file [KEY=]FILE]... [--age RECIPIENT]... [--pgp FINGERPRINT]... [--exec PATH] [--starter] [--force] NAME", "Create a generator manifest, and optionally a creation rule and encrypted starter files", runInit},
+		{"keygen", "keygen [--output FILE] [--sops-config FILE] [--path-regex REGEX]", "Generate an age identity, and optionally add its recipient to the creation rules of a .sops.yaml", runKeygen},
+		{"lint", "lint [DIR]", "Find missing source files, and encrypted files that no generator uses", runLint},
+		{"check-encrypted", "check-encrypted [--dir DIR] [FILE...]", "Check that the source files of generators are encrypted, as a pre-commit hook or in CI", runCheckEncrypted},
+		{"check-access", "check-access [--recipient KEY]... [DIR]", "Check that the current identity, or a recipient, can decrypt the source files of generators", runCheckAccess},
+		{"keys-report", "keys-report [--json] [DIR]", "Report the sops keys and key groups of the source files of generators, for access reviews", runKeysReport},
+		{"serve-webhook", "serve-webhook --cert-file FILE --key-file FILE [--addr ADDR] [--require-annotation KEY]... [--exempt-type TYPE]...", "Serve a validating admission webhook that rejects Secrets that are sops-encrypted, redacted or lack provenance annotations", runServeWebhook},
+		{"list-keys", "list-keys [--name NAME] GENERATOR", "List the keys of the Secrets of generators and where they come from", runListKeys},
+		{"validate", "validate [PATH...]", "Check generator manifests and their source files without decrypting", runValidate},
+		{"verify", "verify [--kubeconfig FILE] [--context NAME] [DIR]", "Report which Secrets of a kustomization would change in the cluster", runVerify},
+		{"inject", "inject", "Replace the generators in a stream of manifests on stdin with their Secrets, for Skaffold and other tools without kustomize", runInject},
+		{"render", "render --out DIR [--force] [--ttl DURATION] [--name NAME] GENERATOR", "Write the decrypted source files of generators to a tmpfs for debugging, and remove them afterwards", runRender},
+		{"explain", "explain [--recursive] [FIELD]", "Describe the fields of the generator, such as kms.regions, with examples", runExplain},
+		{"bench", "bench [--iterations N] [--name NAME] GENERATOR", "Measure how long generators take to decrypt and generate, with a cold and a warm cache", runBench},
+		{"version", "version [--json]", "Print the version of the plugin and of sops", runVersion},
+	}
+}
+
+// findCommand returns the subcommand with the given name.
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// runCommand runs a subcommand and returns the process exit code.
+func runCommand(c command, args []string) int {
+	err := c.run(args)
+	if err == flag.ErrHelp {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		_, code := classifyError(err)
+		if runtimeSettings.ErrorFormat == "json" {
+			_ = writeErrorJSON(os.Stderr, errors.Wrap(err, c.name))
+		} else {
+			_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", c.name, colorize(colorRed, err.Error()))
+		}
+		return code
+	}
+	return 0
+}
+
+// newFlagSet returns a flag set for a subcommand that reports errors instead
+// of exiting, and prints its usage to stderr.
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	flags.Usage = func() {
+		c, _ := findCommand(name)
+		_, _ = fmt.Fprintf(flags.Output(), "Usage:\n  SopsSecretGenerator %s\n\n%s.\n", c.usage, c.summary)
+		if hasFlags(flags) {
+			_, _ = fmt.Fprintf(flags.Output(), "\nFlags:\n")
+			flags.PrintDefaults()
+		}
+	}
+	return flags
+}
+
+// hasFlags reports whether any flags are defined in the flag set.
+func hasFlags(flags *flag.FlagSet) bool {
+	found := false
+	flags.VisitAll(func(*flag.Flag) { found = true })
+	return found
+}
+
+// commandsUsage lists the subcommands for the usage message.
+func commandsUsage(w io.Writer) {
+	width := 0
+	for _, c := range commands {
+		width = max(width, len(c.name))
+	}
+	for _, c := range commands {
+		_, _ = fmt.Fprintf(w, "  %s%s  %s\n", c.name, strings.Repeat(" ", width-len(c.name)), c.summary)
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_findCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		wantOk bool
+	}{
+		{"rotate", "rotate", true},
+		{"unknown", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := findCommand(tt.name)
+			if ok != tt.wantOk || got.name != tt.want {
+				t.Errorf("findCommand() = %v, %v, want %v, %v", got.name, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_commandsUsage(t *testing.T) {
+	var buf bytes.Buffer
+	commandsUsage(&buf)
+	for _, c := range commands {
+		if !strings.Contains(buf.String(), c.name) || !strings.Contains(buf.String(), c.summary) {
+			t.Errorf("commandsUsage() = %q, missing command %s", buf.String(), c.name)
+		}
+	}
+}
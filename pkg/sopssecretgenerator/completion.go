@@ -0,0 +1,226 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// completionFlag is a flag offered by shell completion
+type completionFlag struct {
+	Name  string
+	Usage string
+	Value bool
+}
+
+// usageFlag matches a flag in the usage of a subcommand, and whether it is
+// followed by a value
+var usageFlag = regexp.MustCompile(`--([a-z][-a-z]*)( [A-Z\[])?`)
+
+// runCompletion implements the completion subcommand.
+func runCompletion(args []string) error {
+	flags := newFlagSet("completion")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected a shell: bash, zsh or fish")
+	}
+	return writeCompletion(os.Stdout, flags.Arg(0))
+}
+
+// writeCompletion writes the completion script for a shell.
+func writeCompletion(w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		bashCompletion(w)
+	case "zsh":
+		zshCompletion(w)
+	case "fish":
+		fishCompletion(w)
+	default:
+		return errors.Errorf("unsupported shell \"%s\", expected bash, zsh or fish", shell)
+	}
+	return nil
+}
+
+// globalFlags returns the flags that come before a subcommand, except the
+// hidden ones.
+func globalFlags() []completionFlag {
+	var flags []completionFlag
+	globalFlagSet(&Options{}, new(bool)).VisitAll(func(f *flag.Flag) {
+		if hiddenFlags[f.Name] {
+			return
+		}
+		boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, completionFlag{Name: f.Name, Usage: f.Usage, Value: !ok || !boolFlag.IsBoolFlag()})
+	})
+	return flags
+}
+
+// commandFlags returns the flags of a subcommand, as listed in its usage.
+func commandFlags(c command) []completionFlag {
+	var flags []completionFlag
+	seen := make(map[string]bool)
+	for _, m := range usageFlag.FindAllStringSubmatch(c.usage, -1) {
+		if seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		flags = append(flags, completionFlag{Name: m[1], Value: m[2] != ""})
+	}
+	return flags
+}
+
+// flagWords returns the flags as they are typed, separated by spaces.
+func flagWords(flags []completionFlag) string {
+	var words []string
+	for _, f := range flags {
+		words = append(words, "--"+f.Name)
+	}
+	return strings.Join(words, " ")
+}
+
+// valueFlagPattern returns a shell case pattern that matches the flags that
+// take a value, so that the value is not mistaken for a subcommand.
+func valueFlagPattern(flags []completionFlag) string {
+	var patterns []string
+	for _, f := range flags {
+		if f.Value {
+			patterns = append(patterns, "--"+f.Name, "-"+f.Name)
+		}
+	}
+	if len(patterns) == 0 {
+		return "--"
+	}
+	return strings.Join(patterns, "|")
+}
+
+// shellQuote quotes a string for sh-like shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// bashCompletion writes the bash completion script. It completes global
+// flags and subcommands, the flags of a subcommand, and otherwise files.
+func bashCompletion(w io.Writer) {
+	var names []string
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	global := globalFlags()
+	_, _ = fmt.Fprintf(w, `# bash completion for SopsSecretGenerator
+_SopsSecretGenerator() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" command="" words i
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        case "${COMP_WORDS[i]}" in
+            %s) ((i++)) ;;
+            -*) ;;
+            *) command="${COMP_WORDS[i]}"; break ;;
+        esac
+    done
+    if [[ -z "$command" && "$cur" == -* ]]; then
+        words=%s
+    elif [[ -z "$command" ]]; then
+        words=%s
+    elif [[ "$cur" == -* ]]; then
+        case "$command" in
+`, valueFlagPattern(global), shellQuote(flagWords(global)), shellQuote(strings.Join(names, " ")))
+	for _, c := range commands {
+		if flags := commandFlags(c); len(flags) > 0 {
+			_, _ = fmt.Fprintf(w, "            %s) words=%s ;;\n", c.name, shellQuote(flagWords(flags)))
+		}
+	}
+	_, _ = fmt.Fprint(w, `        esac
+    else
+        COMPREPLY=($(compgen -f -- "$cur"))
+        return
+    fi
+    COMPREPLY=($(compgen -W "$words" -- "$cur"))
+}
+complete -o filenames -F _SopsSecretGenerator SopsSecretGenerator
+`)
+}
+
+// zshCompletion writes the zsh completion script, which works both from a
+// directory in fpath and when sourced.
+func zshCompletion(w io.Writer) {
+	global := globalFlags()
+	_, _ = fmt.Fprintf(w, `#compdef SopsSecretGenerator
+
+_SopsSecretGenerator() {
+  local command i
+  local -a subcommands
+  for ((i = 2; i < CURRENT; i++)); do
+    case "${words[i]}" in
+      %s) ((i++)) ;;
+      -*) ;;
+      *) command="${words[i]}"; break ;;
+    esac
+  done
+  if [[ -z "$command" && "$PREFIX" == -* ]]; then
+    compadd -- %s
+  elif [[ -z "$command" ]]; then
+    subcommands=(
+`, valueFlagPattern(global), flagWords(global))
+	for _, c := range commands {
+		_, _ = fmt.Fprintf(w, "      %s\n", shellQuote(c.name+":"+c.summary))
+	}
+	_, _ = fmt.Fprint(w, `    )
+    _describe command subcommands
+  elif [[ "$PREFIX" == -* ]]; then
+    case "$command" in
+`)
+	for _, c := range commands {
+		if flags := commandFlags(c); len(flags) > 0 {
+			_, _ = fmt.Fprintf(w, "      %s) compadd -- %s ;;\n", c.name, flagWords(flags))
+		}
+	}
+	_, _ = fmt.Fprint(w, `    esac
+  else
+    _files
+  fi
+}
+
+if [[ "${funcstack[1]}" == "_SopsSecretGenerator" ]]; then
+  _SopsSecretGenerator "$@"
+else
+  compdef _SopsSecretGenerator SopsSecretGenerator
+fi
+`)
+}
+
+// fishCompletion writes the fish completion script.
+func fishCompletion(w io.Writer) {
+	_, _ = fmt.Fprint(w, "# fish completion for SopsSecretGenerator\ncomplete -c SopsSecretGenerator -f\n")
+	for _, f := range globalFlags() {
+		_, _ = fmt.Fprintf(w, "complete -c SopsSecretGenerator -n __fish_use_subcommand -l %s%s -d %s\n", f.Name, fishRequired(f), shellQuote(f.Usage))
+	}
+	for _, c := range commands {
+		_, _ = fmt.Fprintf(w, "complete -c SopsSecretGenerator -n __fish_use_subcommand -a %s -d %s\n", c.name, shellQuote(c.summary))
+		condition := shellQuote("__fish_seen_subcommand_from " + c.name)
+		for _, f := range commandFlags(c) {
+			_, _ = fmt.Fprintf(w, "complete -c SopsSecretGenerator -n %s -l %s%s\n", condition, f.Name, fishRequired(f))
+		}
+		_, _ = fmt.Fprintf(w, "complete -c SopsSecretGenerator -n %s -F\n", condition)
+	}
+}
+
+// fishRequired returns the option that makes fish complete the value of a
+// flag.
+func fishRequired(f completionFlag) string {
+	if f.Value {
+		return " -r"
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_commandFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		usage string
+		want  []completionFlag
+	}{
+		{"None", "validate [PATH...]", nil},
+		{"Bool", "convert [--write] PATH...", []completionFlag{{Name: "write"}}},
+		{"Value", "list-keys [--name NAME] GENERATOR", []completionFlag{{Name: "name", Value: true}}},
+		{"Repeated", "init [--file [KEY=]FILE]... [--force] NAME", []completionFlag{{Name: "file", Value: true}, {Name: "force"}}},
+		{"Separator", "exec-env GENERATOR -- COMMAND", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := commandFlags(command{usage: tt.usage}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("commandFlags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_globalFlags(t *testing.T) {
+	flags := globalFlags()
+	if got := flagWords(flags); got != "--annotations --dry-run --error-format --events --events-file --max-concurrency --namespace --no-cache --no-color --no-progress --output --parallel --timings --variant --version" {
+		t.Errorf("flagWords(globalFlags()) = %q", got)
+	}
+	if got := valueFlagPattern(flags); got != "--error-format|-error-format|--max-concurrency|-max-concurrency|--namespace|-namespace|--output|-output|--parallel|-parallel|--variant|-variant" {
+		t.Errorf("valueFlagPattern(globalFlags()) = %q", got)
+	}
+}
+
+func Test_writeCompletion(t *testing.T) {
+	tests := []struct {
+		shell   string
+		want    []string
+		wantErr bool
+	}{
+		{"bash", []string{"complete -o filenames -F _SopsSecretGenerator SopsSecretGenerator", "init) words='--output --env"}, false},
+		{"zsh", []string{"#compdef SopsSecretGenerator", "'rotate:Rotate the data keys"}, false},
+		{"fish", []string{"-n __fish_use_subcommand -l parallel -r", "-n '__fish_seen_subcommand_from list-keys' -l name -r"}, false},
+		{"powershell", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := writeCompletion(&buf, tt.shell)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("writeCompletion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("writeCompletion() = %q, want it to contain %q", buf.String(), want)
+				}
+			}
+			for _, c := range commands {
+				if !strings.Contains(buf.String(), c.name) {
+					t.Errorf("writeCompletion() does not complete command %s", c.name)
+				}
+			}
+		})
+	}
+}
+
+func Test_shellQuote(t *testing.T) {
+	if got := shellQuote("it's"); got != `'it'\''s'` {
+		t.Errorf("shellQuote() = %s", got)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Condition decides whether a generator generates a Secret. All conditions
+// that are set must hold; an empty condition always holds.
+type Condition struct {
+	// Env is the name of an environment variable that must be set and not
+	// empty, or NAME=value for a variable that must have the value
+	Env string `json:"env,omitempty" yaml:"env,omitempty"`
+	// FileExists is a path that must exist, relative to the working
+	// directory like the sources
+	FileExists string `json:"fileExists,omitempty" yaml:"fileExists,omitempty"`
+}
+
+// holds reports whether the condition holds.
+func (c Condition) holds() bool {
+	if c.Env != "" {
+		name, value, hasValue := strings.Cut(c.Env, "=")
+		actual := os.Getenv(name)
+		if actual == "" || (hasValue && actual != value) {
+			return false
+		}
+	}
+	if c.FileExists != "" {
+		if _, err := os.Stat(c.FileExists); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// skipMissingSources removes the sources whose files do not exist from a
+// generator with allowEmpty, so that it generates a Secret from the others,
+// or an empty one.
+func skipMissingSources(input SopsSecretGenerator, logger *slog.Logger) SopsSecretGenerator {
+	if !input.AllowEmpty {
+		return input
+	}
+	present := func(source string, fileName string) bool {
+		filePath, _, err := splitExtract(fileName)
+		if err != nil {
+			// Reported when the source is parsed
+			return true
+		}
+		if _, err := os.Stat(filePath); errors.Is(err, fs.ErrNotExist) {
+			logger.Info("skipped missing source", "generator", input.Name, "source", source)
+			return false
+		}
+		return true
+	}
+
+	var envs, files []string
+	for _, source := range input.EnvSources {
+		if present(source, source) {
+			envs = append(envs, source)
+		}
+	}
+	for _, source := range input.FileSources {
+		_, fileName, err := parseFileName(source)
+		if err != nil || present(source, fileName) {
+			files = append(files, source)
+		}
+	}
+	var helmValues []string
+	for _, source := range input.HelmValues.Files {
+		if present(source, source) {
+			helmValues = append(helmValues, source)
+		}
+	}
+	var dockerConfigs []string
+	for _, source := range input.DockerConfigs {
+		if present(source, source) {
+			dockerConfigs = append(dockerConfigs, source)
+		}
+	}
+	input.EnvSources = envs
+	input.FileSources = files
+	input.HelmValues.Files = helmValues
+	input.DockerConfigs = dockerConfigs
+	return input
+}
@@ -0,0 +1,133 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+)
+
+func Test_Condition_holds(t *testing.T) {
+	t.Setenv("SOPS_SECRETGEN_TEST_SET", "prod")
+	t.Setenv("SOPS_SECRETGEN_TEST_EMPTY", "")
+	tests := []struct {
+		name      string
+		condition Condition
+		want      bool
+	}{
+		{"Empty", Condition{}, true},
+		{"EnvSet", Condition{Env: "SOPS_SECRETGEN_TEST_SET"}, true},
+		{"EnvEmpty", Condition{Env: "SOPS_SECRETGEN_TEST_EMPTY"}, false},
+		{"EnvUnset", Condition{Env: "SOPS_SECRETGEN_TEST_UNSET"}, false},
+		{"EnvValue", Condition{Env: "SOPS_SECRETGEN_TEST_SET=prod"}, true},
+		{"EnvOtherValue", Condition{Env: "SOPS_SECRETGEN_TEST_SET=staging"}, false},
+		{"FileExists", Condition{FileExists: "testdata/file.txt"}, true},
+		{"FileMissing", Condition{FileExists: "testdata/missing.txt"}, false},
+		{"Both", Condition{Env: "SOPS_SECRETGEN_TEST_SET", FileExists: "testdata/missing.txt"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.condition.holds(); got != tt.want {
+				t.Errorf("holds() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_skipMissingSources(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     SopsSecretGenerator
+		wantEnvs  []string
+		wantFiles []string
+	}{
+		{"NotAllowed", SopsSecretGenerator{EnvSources: []string{"testdata/missing.env"}, FileSources: []string{"testdata/missing.txt"}},
+			[]string{"testdata/missing.env"}, []string{"testdata/missing.txt"}},
+		{"Present", SopsSecretGenerator{AllowEmpty: true, EnvSources: []string{"testdata/vars.env"}, FileSources: []string{"key=testdata/file.txt"}},
+			[]string{"testdata/vars.env"}, []string{"key=testdata/file.txt"}},
+		{"Missing", SopsSecretGenerator{AllowEmpty: true, EnvSources: []string{"testdata/vars.env", "testdata/missing.env"}, FileSources: []string{"key=testdata/missing.txt", `testdata/missing.yaml["a"]`}},
+			[]string{"testdata/vars.env"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := skipMissingSources(tt.input, Options{}.logger())
+			if !reflect.DeepEqual(got.EnvSources, tt.wantEnvs) || !reflect.DeepEqual(got.FileSources, tt.wantFiles) {
+				t.Errorf("skipMissingSources() = %v %v, want %v %v", got.EnvSources, got.FileSources, tt.wantEnvs, tt.wantFiles)
+			}
+		})
+	}
+}
+
+func Test_generateSecret_allowEmpty(t *testing.T) {
+	input, err := readInput([]byte("apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: secret\nallowEmpty: true\nenvs:\n  - testdata/missing.env\nfiles:\n  - testdata/missing.txt\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := generateSecret(context.Background(), input, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Name != "secret" || len(secret.Data) != 0 {
+		t.Errorf("generateSecret() = %+v, want an empty Secret", secret)
+	}
+
+	input.AllowEmpty = false
+	_, err = generateSecret(context.Background(), input, Options{})
+	if err == nil {
+		t.Errorf("generateSecret() accepted missing sources without allowEmpty")
+	}
+}
+
+func Test_Generate_when(t *testing.T) {
+	spec := SopsSecretGenerator{
+		TypeMeta:    TypeMeta{APIVersion: apiVersion, Kind: kind},
+		ObjectMeta:  ObjectMeta{Name: "secret"},
+		FileSources: []string{"testdata/missing.txt"},
+		When:        Condition{FileExists: "testdata/missing.txt"},
+	}
+	secrets, err := Generate(context.Background(), spec, Options{})
+	if err != nil || len(secrets) != 0 {
+		t.Errorf("Generate() = %v, %v, want no Secrets", secrets, err)
+	}
+}
+
+func Test_GenerateKRMManifest_when(t *testing.T) {
+	input := `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+  - apiVersion: kustomize.freightdog.com/v1
+    kind: SopsSecretGenerator
+    metadata:
+      name: skipped
+    when:
+      env: SOPS_SECRETGEN_TEST_UNSET
+    files:
+      - testdata/missing.txt
+  - apiVersion: kustomize.freightdog.com/v1
+    kind: SopsSecretGenerator
+    metadata:
+      name: generated
+    files:
+      - testdata/file.txt
+`
+	out, err := fn.Run(fn.ResourceListProcessorFunc(generateKRMManifest), []byte(input))
+	if err != nil {
+		t.Fatalf("generateKRMManifest() error = %v", err)
+	}
+	rl, err := fn.ParseResourceList(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, item := range rl.Items {
+		got = append(got, item.GetName())
+	}
+	if strings.Join(got, ",") != "generated" {
+		t.Errorf("generateKRMManifest() = %v, want only the generated Secret", got)
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// legacyAPIVersion is the apiVersion of the generator before it moved to
+// Freightdog. The fields are the same.
+const legacyAPIVersion = "goabout.com/v1beta1"
+
+// ksopsAPIVersion and ksopsKind identify a ksops generator
+const (
+	ksopsAPIVersion = "viaduct.ai/v1"
+	ksopsKind       = "ksops"
+)
+
+// Annotations that ksops reads from the metadata of a Secret
+const (
+	needsHashAnnotation = "kustomize.config.k8s.io/needs-hash"
+	behaviorAnnotation  = "kustomize.config.k8s.io/behavior"
+	functionAnnotation  = "config.kubernetes.io/function"
+)
+
+// ksopsGenerator is the manifest of a ksops generator
+type ksopsGenerator struct {
+	Metadata   ObjectMeta    `yaml:"metadata"`
+	Files      []string      `yaml:"files"`
+	SecretFrom []ksopsSecret `yaml:"secretFrom"`
+}
+
+// ksopsSecret is a Secret generated by ksops from encrypted files
+type ksopsSecret struct {
+	Metadata    ObjectMeta `yaml:"metadata"`
+	Type        string     `yaml:"type"`
+	Files       []string   `yaml:"files"`
+	BinaryFiles []string   `yaml:"binaryFiles"`
+	Envs        []string   `yaml:"envs"`
+}
+
+// generatorManifest is a generator as written by the commands, with the
+// fields that they set and without empty fields
+type generatorManifest struct {
+	TypeMeta              `yaml:",inline"`
+	ObjectMeta            `yaml:"metadata"`
+	Type                  string   `yaml:"type,omitempty"`
+	Behavior              string   `yaml:"behavior,omitempty"`
+	DisableNameSuffixHash bool     `yaml:"disableNameSuffixHash,omitempty"`
+	EnvSources            []string `yaml:"envs,omitempty"`
+	FileSources           []string `yaml:"files,omitempty"`
+}
+
+// runConvert implements the convert subcommand.
+func runConvert(args []string) error {
+	flags := newFlagSet("convert")
+	write := flags.Bool("write", false, "rewrite the files in place instead of printing them")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("expected files or directories to convert")
+	}
+
+	var files []string
+	for _, p := range flags.Args() {
+		found, err := yamlFiles(p)
+		if err != nil {
+			return err
+		}
+		files = append(files, found...)
+	}
+	failed, converted := 0, 0
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		output, n, err := convertManifest(content)
+		if err != nil {
+			failed++
+			_, _ = fmt.Fprintf(os.Stderr, "%s  %s: %v\n", colorize(colorRed, "FAILED"), file, err)
+			continue
+		}
+		if n == 0 {
+			continue
+		}
+		converted++
+		if *write {
+			err = writeFileAtomic(file, output)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(os.Stderr, "converted %s\n", file)
+			continue
+		}
+		fmt.Printf("# %s\n%s", file, output)
+	}
+	if failed > 0 {
+		return errors.Errorf("%d of %d files could not be converted", failed, failed+converted)
+	}
+	return nil
+}
+
+// yamlFiles returns a file, or the YAML files under a directory. Hidden
+// directories are skipped, like findGenerators does.
+func yamlFiles(root string) ([]string, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{root}, nil
+	}
+	var files []string
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isYAMLFile(p) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// convertManifest converts the legacy and ksops generators in a
+// (multi-document) YAML file, and returns the file with the number of
+// generators it converted. Other documents are kept. If nothing is converted,
+// the content is returned as it is.
+func convertManifest(content []byte) ([]byte, int, error) {
+	var documents []*yaml.Node
+	converted := 0
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for i := 0; ; i++ {
+		var node yaml.Node
+		err := decoder.Decode(&node)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		var typeMeta TypeMeta
+		_ = node.Decode(&typeMeta)
+		switch {
+		case typeMeta.APIVersion == legacyAPIVersion && typeMeta.Kind == kind:
+			setMappingValue(&node, "apiVersion", apiVersion)
+			documents = append(documents, &node)
+			converted++
+		case typeMeta.APIVersion == ksopsAPIVersion && typeMeta.Kind == ksopsKind:
+			var ksops ksopsGenerator
+			err = node.Decode(&ksops)
+			if err != nil {
+				return nil, 0, errors.Wrapf(err, "document %d", i+1)
+			}
+			generators, err := convertKsops(ksops)
+			if err != nil {
+				return nil, 0, errors.Wrapf(err, "ksops generator %s", ksops.Metadata.Name)
+			}
+			for j, g := range generators {
+				var document yaml.Node
+				err = document.Encode(g)
+				if err != nil {
+					return nil, 0, err
+				}
+				if j == 0 && len(document.Content) > 0 {
+					document.Content[0].HeadComment = headComment(&node)
+				}
+				documents = append(documents, &document)
+			}
+			converted++
+		default:
+			documents = append(documents, &node)
+		}
+	}
+	if converted == 0 {
+		return content, 0, nil
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	for _, document := range documents {
+		err := encoder.Encode(document)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	err := encoder.Close()
+	if err != nil {
+		return nil, 0, err
+	}
+	return out.Bytes(), converted, nil
+}
+
+// headComment returns the comment above a document. The decoder attaches it
+// to the document, its mapping or the first key of the mapping.
+func headComment(document *yaml.Node) string {
+	for node := document; node != nil; {
+		if node.HeadComment != "" {
+			return node.HeadComment
+		}
+		if len(node.Content) == 0 || node.Kind == yaml.ScalarNode {
+			break
+		}
+		node = node.Content[0]
+	}
+	return ""
+}
+
+// setMappingValue sets the value of a key of the mapping of a document.
+func setMappingValue(document *yaml.Node, key string, value string) {
+	mapping := document
+	if mapping.Kind == yaml.DocumentNode && len(mapping.Content) > 0 {
+		mapping = mapping.Content[0]
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1].Value = value
+			return
+		}
+	}
+}
+
+// convertKsops converts the Secrets of a ksops generator into generators.
+// ksops adds a name hash only if a Secret asks for it, and reads the behavior
+// from an annotation; generators have fields for both. ksops can also decrypt
+// whole manifests, which generators cannot, so such a ksops generator is not
+// converted.
+func convertKsops(ksops ksopsGenerator) ([]generatorManifest, error) {
+	if len(ksops.Files) > 0 {
+		return nil, errors.Errorf("files lists encrypted manifests (%s), which SopsSecretGenerator cannot generate; move their data into env or file sources under secretFrom", strings.Join(ksops.Files, ", "))
+	}
+	if len(ksops.SecretFrom) == 0 {
+		return nil, errors.New("secretFrom is empty")
+	}
+	var generators []generatorManifest
+	for _, secret := range ksops.SecretFrom {
+		if secret.Metadata.Name == "" {
+			return nil, errors.New("secretFrom entry without metadata.name")
+		}
+		annotations := make(kvMap)
+		for k, v := range secret.Metadata.Annotations {
+			annotations[k] = v
+		}
+		needsHash, _ := strconv.ParseBool(annotations[needsHashAnnotation])
+		behavior := annotations[behaviorAnnotation]
+		delete(annotations, needsHashAnnotation)
+		delete(annotations, behaviorAnnotation)
+		if _, ok := ksops.Metadata.Annotations[functionAnnotation]; ok {
+			annotations[functionAnnotation] = "exec:\n  path: SopsSecretGenerator\n"
+		}
+		if len(annotations) == 0 {
+			annotations = nil
+		}
+
+		generators = append(generators, generatorManifest{
+			TypeMeta: TypeMeta{APIVersion: apiVersion, Kind: kind},
+			ObjectMeta: ObjectMeta{
+				Name:        secret.Metadata.Name,
+				Namespace:   secret.Metadata.Namespace,
+				Labels:      secret.Metadata.Labels,
+				Annotations: annotations,
+			},
+			Type:                  secret.Type,
+			Behavior:              behavior,
+			DisableNameSuffixHash: !needsHash,
+			EnvSources:            secret.Envs,
+			FileSources:           append(append([]string{}, secret.Files...), secret.BinaryFiles...),
+		})
+	}
+	return generators, nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_convertManifest(t *testing.T) {
+	ksops := func(secretFrom string) string {
+		return "# Secrets\napiVersion: viaduct.ai/v1\nkind: ksops\nmetadata:\n  name: gen\n  annotations:\n    config.kubernetes.io/function: |\n      exec:\n        path: ksops\n" + secretFrom
+	}
+	tests := []struct {
+		name          string
+		manifest      string
+		want          string
+		wantConverted int
+		wantErr       bool
+	}{
+		{
+			"Legacy",
+			"apiVersion: goabout.com/v1beta1\nkind: SopsSecretGenerator\nmetadata:\n  name: legacy # comment\nenvs:\n  - vars.env\n",
+			"apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: legacy # comment\nenvs:\n  - vars.env\n",
+			1, false,
+		},
+		{
+			"Ksops",
+			ksops("secretFrom:\n  - metadata:\n      name: app\n      annotations:\n        kustomize.config.k8s.io/needs-hash: \"true\"\n        kustomize.config.k8s.io/behavior: merge\n    type: Opaque\n    envs:\n      - app.env\n    files:\n      - tls.crt\n    binaryFiles:\n      - key.p12=keystore.p12\n"),
+			"# Secrets\napiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: app\n  annotations:\n    config.kubernetes.io/function: |\n      exec:\n        path: SopsSecretGenerator\ntype: Opaque\nbehavior: merge\nenvs:\n  - app.env\nfiles:\n  - tls.crt\n  - key.p12=keystore.p12\n",
+			1, false,
+		},
+		{
+			"KsopsMultiple",
+			ksops("secretFrom:\n  - metadata:\n      name: a\n    files:\n      - a.txt\n  - metadata:\n      name: b\n    files:\n      - b.txt\n"),
+			"# Secrets\napiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: a\n  annotations:\n    config.kubernetes.io/function: |\n      exec:\n        path: SopsSecretGenerator\ndisableNameSuffixHash: true\nfiles:\n  - a.txt\n---\napiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: b\n  annotations:\n    config.kubernetes.io/function: |\n      exec:\n        path: SopsSecretGenerator\ndisableNameSuffixHash: true\nfiles:\n  - b.txt\n",
+			1, false,
+		},
+		{
+			"OtherDocuments",
+			"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n---\napiVersion: goabout.com/v1beta1\nkind: SopsSecretGenerator\nmetadata:\n  name: legacy\n",
+			"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n---\napiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: legacy\n",
+			1, false,
+		},
+		{
+			"Unchanged",
+			"apiVersion:   v1\nkind: ConfigMap\n",
+			"apiVersion:   v1\nkind: ConfigMap\n",
+			0, false,
+		},
+		{"KsopsManifests", ksops("files:\n  - secret.enc.yaml\n"), "", 0, true},
+		{"KsopsEmpty", ksops(""), "", 0, true},
+		{"KsopsNoName", ksops("secretFrom:\n  - files:\n      - a.txt\n"), "", 0, true},
+		{"InvalidYAML", "a: [\n", "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, converted, err := convertManifest([]byte(tt.manifest))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("convertManifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want || converted != tt.wantConverted {
+				t.Errorf("convertManifest() = %d, %q, want %d, %q", converted, got, tt.wantConverted, tt.want)
+			}
+		})
+	}
+}
+
+func Test_convertManifest_generates(t *testing.T) {
+	manifest := "apiVersion: viaduct.ai/v1\nkind: ksops\nmetadata:\n  name: gen\nsecretFrom:\n  - metadata:\n      name: app\n      labels:\n        app: web\n    files:\n      - testdata/file.txt\n"
+	got, _, err := convertManifest([]byte(manifest))
+	if err != nil {
+		t.Fatalf("convertManifest() error = %v", err)
+	}
+	input, err := readInput(got)
+	if err != nil {
+		t.Fatalf("readInput() error = %v", err)
+	}
+	data, _, err := parseInput(context.Background(), input, Options{})
+	if err != nil {
+		t.Fatalf("parseInput() error = %v", err)
+	}
+	if !reflect.DeepEqual(data, kvMap{"file.txt": b64("secret\n")}) || input.Labels["app"] != "web" {
+		t.Errorf("converted generator = %+v, data %v", input, data)
+	}
+}
+
+func Test_yamlFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.yaml", "b.yml", "c.txt", ".hidden/d.yaml", "sub/e.yaml"} {
+		fileName := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fileName), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		writeTestFile(t, fileName, "")
+	}
+	got, err := yamlFiles(dir)
+	if err != nil {
+		t.Fatalf("yamlFiles() error = %v", err)
+	}
+	for i := range got {
+		got[i] = strings.TrimPrefix(filepath.ToSlash(got[i]), filepath.ToSlash(dir)+"/")
+	}
+	if want := []string{"a.yaml", "b.yml", "sub/e.yaml"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("yamlFiles() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/config"
+	"github.com/getsops/sops/v3/pgp"
+	"github.com/pkg/errors"
+)
+
+// checkCreationRule verifies that a file is encrypted to exactly the key
+// groups mandated by the matching creation rule in the nearest .sops.yaml,
+// catching files that were encrypted with stale keys.
+func checkCreationRule(filePath string, metadata sops.Metadata) error {
+	rule, confPath, err := loadCreationRule(filePath, "")
+	if err != nil {
+		return err
+	}
+
+	want := keyGroupSignatures(rule.KeyGroups)
+	got := keyGroupSignatures(metadata.KeyGroups)
+	if strings.Join(got, "; ") != strings.Join(want, "; ") {
+		return errors.Errorf("file is encrypted to key groups [%s], but creation rule in %s requires [%s]; run sops updatekeys",
+			strings.Join(got, "; "), confPath, strings.Join(want, "; "))
+	}
+	if len(rule.KeyGroups) > 1 && rule.ShamirThreshold != 0 && rule.ShamirThreshold != shamirThreshold(metadata) {
+		return errors.Errorf("file has shamir threshold %d, but creation rule in %s requires %d",
+			shamirThreshold(metadata), confPath, rule.ShamirThreshold)
+	}
+	return nil
+}
+
+// loadCreationRule returns the creation rule that matches a file, along with
+// the path of the .sops.yaml it is defined in. If confPath is empty, the
+// nearest .sops.yaml is used.
+func loadCreationRule(filePath string, confPath string) (*config.Config, string, error) {
+	absPath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, "", err
+	}
+	if confPath == "" {
+		confPath, err = config.FindConfigFile(absPath)
+		if err != nil {
+			return nil, "", errors.New("no .sops.yaml found")
+		}
+	}
+	rule, err := config.LoadCreationRuleForFile(confPath, absPath, nil)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "creation rules in %s", confPath)
+	}
+	if rule == nil {
+		return nil, "", errors.Errorf("no creation rules in %s", confPath)
+	}
+	return rule, confPath, nil
+}
+
+// keyGroupSignatures describes key groups in a canonical, sorted form, so
+// that they can be compared regardless of the order of keys and groups.
+func keyGroupSignatures(groups []sops.KeyGroup) []string {
+	var signatures []string
+	for _, group := range groups {
+		var keys []string
+		for _, key := range group {
+			id := key.ToString()
+			if key.TypeToIdentifier() == pgp.KeyTypeIdentifier {
+				id = normalizeFingerprint(id)
+			}
+			keys = append(keys, key.TypeToIdentifier()+":"+id)
+		}
+		sort.Strings(keys)
+		signatures = append(signatures, strings.Join(keys, ","))
+	}
+	sort.Strings(signatures)
+	return signatures
+}
@@ -0,0 +1,71 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/age"
+	"github.com/getsops/sops/v3/pgp"
+)
+
+func Test_checkCreationRule(t *testing.T) {
+	staleDir := t.TempDir()
+	writeTestFile(t, filepath.Join(staleDir, ".sops.yaml"), "creation_rules:\n  - pgp: 0000000000000000000000000000000000000000\n")
+	noRulesDir := t.TempDir()
+	writeTestFile(t, filepath.Join(noRulesDir, ".sops.yaml"), "creation_rules:\n  - path_regex: \\.env$\n    pgp: "+testkeyFingerprint+"\n")
+
+	tests := []struct {
+		name     string
+		filePath string
+		wantErr  bool
+	}{
+		{"Matching", "testdata/file.txt", false},
+		{"StaleKeys", filepath.Join(staleDir, "file.txt"), true},
+		{"NoMatchingRule", filepath.Join(noRulesDir, "file.txt"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCreationRule(tt.filePath, pgpMetadata(testkeyFingerprint))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkCreationRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_keyGroupSignatures(t *testing.T) {
+	pgpKey := pgp.NewMasterKeyFromFingerprint("2d2483df73a3a0faee3c2a695bdc395360ce8ff4")
+	ageKey := &age.MasterKey{Recipient: testAgeRecipient}
+
+	got := keyGroupSignatures([]sops.KeyGroup{{ageKey}, {pgpKey, ageKey}})
+	want := []string{
+		"age:" + testAgeRecipient,
+		"age:" + testAgeRecipient + ",pgp:" + testkeyFingerprint,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("keyGroupSignatures() got = %v, want %v", got, want)
+	}
+}
+
+func Test_decryptFile_MatchCreationRules(t *testing.T) {
+	_, err := decryptFile("testdata/file.txt", decryptOptions{Policy: Policy{MatchCreationRules: true}})
+	if err != nil {
+		t.Errorf("decryptFile() error = %v", err)
+	}
+}
+
+// Test util functions
+
+func writeTestFile(t *testing.T, fileName string, content string) {
+	t.Helper()
+	err := os.WriteFile(fileName, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/pkg/errors"
+)
+
+// isCSV reports whether a file is a CSV file, by its extension. sops has no
+// CSV format and encrypts CSV files as binary files, so the extension is all
+// that tells them apart.
+func isCSV(filePath string) bool {
+	return strings.EqualFold(filepath.Ext(filePath), ".csv")
+}
+
+// parseEnvFileContent parses the decrypted content of an env source file in
+// the format of its extension. CSV and JSONC files are told apart by their
+// extension alone, as sops encrypts both as binary files.
+func parseEnvFileContent(content []byte, filePath string, data kvMap) error {
+	switch {
+	case isCSV(filePath):
+		return parseCSVContent(content, data)
+	case isJSONC(filePath):
+		return parseJSONCContent(content, data)
+	}
+	return parseEnvContent(content, formats.FormatForPath(filePath), data)
+}
+
+// parseCSVContent parses a two-column CSV env source, with a key and a value
+// on every row, as spreadsheets export them. Fields may be quoted as in RFC
+// 4180, so that values can contain commas, quotes and newlines. A first row
+// of key,value is a header and skipped. A key that is on more than one row is
+// an error, rather than the last value silently winning.
+func parseCSVContent(content []byte, data kvMap) error {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(content, utf8bom)))
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	keyLines := make(map[string]int)
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var csvErr *csv.ParseError
+		if errors.As(err, &csvErr) {
+			return parseError{Line: csvErr.Line, Column: csvErr.Column, Message: csvErr.Err.Error()}
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) != 2 {
+			return parseError{Line: line, Message: fmt.Sprintf("expected key,value, the row has %d fields", len(record))}
+		}
+		key := record[0]
+		if row == 1 && strings.EqualFold(key, "key") && strings.EqualFold(record[1], "value") {
+			continue
+		}
+		if key == "" {
+			return parseError{Line: line, Column: 1, Message: "the key is empty"}
+		}
+		if first, ok := keyLines[key]; ok {
+			return parseError{Line: line, Column: 1, Key: key, Message: fmt.Sprintf("duplicate key, first on line %d", first)}
+		}
+		keyLines[key] = line
+		data[key] = encodeBase64([]byte(record[1]))
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseCSVContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    kvMap
+		wantErr string
+	}{
+		{"Plain", "A,1\nB,2\n", kvMap{"A": "MQ==", "B": "Mg=="}, ""},
+		{"Header", "key,value\nA,1\n", kvMap{"A": "MQ=="}, ""},
+		{"HeaderCase", "Key,Value\r\nA,1\r\n", kvMap{"A": "MQ=="}, ""},
+		{"BOM", "\xef\xbb\xbfA,1\n", kvMap{"A": "MQ=="}, ""},
+		{"Quoted", "A,\"a,b \"\"c\"\"\nd\"\n", kvMap{"A": "YSxiICJjIgpk"}, ""},
+		{"EmptyValue", "A,\n", kvMap{"A": ""}, ""},
+		{"BlankLines", "A,1\n\nB,2\n", kvMap{"A": "MQ==", "B": "Mg=="}, ""},
+		{"Duplicate", "A,1\nB,2\nA,secret\n", nil, "line 3, column 1, key A: duplicate key, first on line 1"},
+		{"OneField", "A,1\nB\n", nil, "line 2: expected key,value, the row has 1 fields"},
+		{"ThreeFields", "A,1,secret\n", nil, "line 1: expected key,value, the row has 3 fields"},
+		{"EmptyKey", ",secret\n", nil, "line 1, column 1: the key is empty"},
+		{"BareQuote", "A,se\"cret\n", nil, "line 1, column "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(kvMap)
+			err := parseCSVContent([]byte(tt.content), got)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseCSVContent() error = %v, want %s", err, tt.wantErr)
+				}
+				if strings.Contains(err.Error(), "secret") {
+					t.Errorf("parseCSVContent() error = %v, has content", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCSVContent() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCSVContent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseEnvFileContent(t *testing.T) {
+	tests := []struct {
+		filePath string
+		content  string
+	}{
+		{"vars.csv", "A,1\n"},
+		{"VARS.CSV", "A,1\n"},
+		{"vars.env", "A=1\n"},
+		{"vars.yaml", "A: \"1\"\n"},
+		{"vars.jsonc", "{\"A\": \"1\", // one\n}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filePath, func(t *testing.T) {
+			got := make(kvMap)
+			err := parseEnvFileContent([]byte(tt.content), tt.filePath, got)
+			if err != nil {
+				t.Fatalf("parseEnvFileContent() error = %v", err)
+			}
+			if want := (kvMap{"A": "MQ=="}); !reflect.DeepEqual(got, want) {
+				t.Errorf("parseEnvFileContent() = %v, want %v", got, want)
+			}
+		})
+	}
+
+	err := parseEnvFileContent([]byte("{A: '1'}\n"), "vars.json5", make(kvMap))
+	if !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("parseEnvFileContent() of JSON5 error = %v, want ErrUnknownFormat", err)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"sync"
+
+	"github.com/getsops/sops/v3/keyservice"
+)
+
+// dataKeyCache holds the data keys that network key services decrypted in
+// this invocation, by key and encrypted data key. sops generates a data key
+// for every file, but files that were copied from one another, and the
+// variants of a file, share theirs; with the cache, each such data key is
+// decrypted by KMS once instead of once per file. Data keys are only kept in
+// memory.
+type dataKeyCache struct {
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*dataKeyEntry
+}
+
+// dataKeyEntry is a data key that is decrypted, or being decrypted
+type dataKeyEntry struct {
+	ready     chan struct{}
+	plaintext []byte
+	err       error
+}
+
+// invocationDataKeys are the data keys shared by all decryptions
+var invocationDataKeys = &dataKeyCache{}
+
+// dataKeyID identifies an encrypted data key and the key that it is encrypted
+// with.
+func dataKeyID(req *keyservice.DecryptRequest) [sha256.Size]byte {
+	keyType, keyID := describeKey(req.Key)
+	h := sha256.New()
+	for _, part := range [][]byte{[]byte(keyType), []byte(keyID), req.Ciphertext} {
+		_, _ = h.Write(part)
+		_, _ = h.Write([]byte{0})
+	}
+	var id [sha256.Size]byte
+	h.Sum(id[:0])
+	return id
+}
+
+// decrypt returns the data key of a request, decrypting it with decryptFn
+// unless it is cached. Concurrent requests for the same data key wait for a
+// single decryption. Failed decryptions are not cached. The caller owns the
+// returned buffer.
+func (c *dataKeyCache) decrypt(req *keyservice.DecryptRequest, decryptFn func() ([]byte, error)) ([]byte, error) {
+	id := dataKeyID(req)
+	c.mu.Lock()
+	entry, ok := c.entries[id]
+	if !ok {
+		entry = &dataKeyEntry{ready: make(chan struct{})}
+		if c.entries == nil {
+			c.entries = make(map[[sha256.Size]byte]*dataKeyEntry)
+		}
+		c.entries[id] = entry
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		plaintext, err := decryptFn()
+		if err == nil {
+			// sops wipes the data keys it is given, so the cache keeps a copy
+			entry.plaintext = bytes.Clone(plaintext)
+		}
+		entry.err = err
+		close(entry.ready)
+		if err != nil {
+			c.mu.Lock()
+			delete(c.entries, id)
+			c.mu.Unlock()
+		}
+		return plaintext, err
+	}
+	<-entry.ready
+	if entry.err != nil {
+		return nil, entry.err
+	}
+	return bytes.Clone(entry.plaintext), nil
+}
+
+// wipe overwrites and forgets all decrypted data keys. Data keys that are
+// still being decrypted are kept.
+func (c *dataKeyCache) wipe() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, entry := range c.entries {
+		select {
+		case <-entry.ready:
+			wipe(entry.plaintext)
+			delete(c.entries, id)
+		default:
+		}
+	}
+}
+
+// dataKeyServer is a local key service that decrypts every data key of a
+// network key service at most once per invocation. Other keys are passed on
+// unchanged.
+type dataKeyServer struct {
+	next  keyservice.KeyServiceServer
+	cache *dataKeyCache
+}
+
+// Encrypt encrypts a data key.
+func (s dataKeyServer) Encrypt(ctx context.Context, req *keyservice.EncryptRequest) (*keyservice.EncryptResponse, error) {
+	return s.next.Encrypt(ctx, req)
+}
+
+// Decrypt decrypts a data key.
+func (s dataKeyServer) Decrypt(ctx context.Context, req *keyservice.DecryptRequest) (*keyservice.DecryptResponse, error) {
+	switch req.Key.KeyType.(type) {
+	case *keyservice.Key_KmsKey, *keyservice.Key_GcpKmsKey, *keyservice.Key_AzureKeyvaultKey, *keyservice.Key_VaultKey:
+	default:
+		return s.next.Decrypt(ctx, req)
+	}
+	plaintext, err := s.cache.decrypt(req, func() ([]byte, error) {
+		response, err := s.next.Decrypt(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return response.Plaintext, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &keyservice.DecryptResponse{Plaintext: plaintext}, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/getsops/sops/v3/keyservice"
+	"github.com/pkg/errors"
+)
+
+func Test_dataKeyServer_Decrypt(t *testing.T) {
+	kmsRequest := func(ciphertext string) *keyservice.DecryptRequest {
+		return &keyservice.DecryptRequest{
+			Key:        &keyservice.Key{KeyType: &keyservice.Key_KmsKey{KmsKey: &keyservice.KmsKey{Arn: testKMSArnWest}}},
+			Ciphertext: []byte(ciphertext),
+		}
+	}
+	ageRequest := &keyservice.DecryptRequest{Key: &keyservice.Key{KeyType: &keyservice.Key_AgeKey{AgeKey: &keyservice.AgeKey{Recipient: testAgeRecipient}}}}
+	next := &countingKeyServer{}
+	server := dataKeyServer{next: next, cache: &dataKeyCache{}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := server.Decrypt(context.Background(), kmsRequest("shared"))
+			if err != nil || string(got.Plaintext) != "data key" {
+				t.Errorf("Decrypt() = %v, %v", got, err)
+				return
+			}
+			// sops wipes the data key after use
+			wipe(got.Plaintext)
+		}()
+	}
+	wg.Wait()
+	if calls := next.calls.Load(); calls != 1 {
+		t.Errorf("Decrypt() of a shared data key made %d calls, want 1", calls)
+	}
+
+	for _, req := range []*keyservice.DecryptRequest{kmsRequest("other"), ageRequest, ageRequest} {
+		if _, err := server.Decrypt(context.Background(), req); err != nil {
+			t.Fatalf("Decrypt() error = %v", err)
+		}
+	}
+	if calls := next.calls.Load(); calls != 4 {
+		t.Errorf("Decrypt() of other data keys made %d calls in total, want 4", calls)
+	}
+
+	server.cache.wipe()
+	if _, err := server.Decrypt(context.Background(), kmsRequest("shared")); err != nil || next.calls.Load() != 5 {
+		t.Errorf("Decrypt() after wipe() = %v, calls %d, want a new call", err, next.calls.Load())
+	}
+}
+
+func Test_dataKeyCache_decrypt_Error(t *testing.T) {
+	c := &dataKeyCache{}
+	req := &keyservice.DecryptRequest{
+		Key:        &keyservice.Key{KeyType: &keyservice.Key_KmsKey{KmsKey: &keyservice.KmsKey{Arn: testKMSArnWest}}},
+		Ciphertext: []byte("key"),
+	}
+	_, err := c.decrypt(req, func() ([]byte, error) { return nil, errors.New("throttled") })
+	if err == nil {
+		t.Fatal("decrypt() error = nil, want the error of the key service")
+	}
+	got, err := c.decrypt(req, func() ([]byte, error) { return []byte("data key"), nil })
+	if err != nil || string(got) != "data key" {
+		t.Errorf("decrypt() after a failure = %q, %v, want a new decryption", got, err)
+	}
+}
+
+// Test util functions
+
+type countingKeyServer struct {
+	calls atomic.Int64
+}
+
+func (s *countingKeyServer) Encrypt(context.Context, *keyservice.EncryptRequest) (*keyservice.EncryptResponse, error) {
+	return &keyservice.EncryptResponse{}, nil
+}
+
+func (s *countingKeyServer) Decrypt(context.Context, *keyservice.DecryptRequest) (*keyservice.DecryptResponse, error) {
+	s.calls.Add(1)
+	time.Sleep(10 * time.Millisecond)
+	return &keyservice.DecryptResponse{Plaintext: []byte("data key")}, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"sync/atomic"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/getsops/sops/v3/decrypt"
+	"github.com/getsops/sops/v3/keyservice"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Decryptor decrypts the content of a sops-encrypted file in the given
+// format, and returns the plaintext file. Policy checks, offline mode, the
+// cache and the audit log apply to every Decryptor.
+type Decryptor interface {
+	Decrypt(content []byte, format formats.Format) ([]byte, error)
+}
+
+// SopsDecryptor decrypts files like `sops decrypt`, with the keys available
+// to sops. It is the default Decryptor.
+type SopsDecryptor struct{}
+
+// Decrypt decrypts a file.
+func (SopsDecryptor) Decrypt(content []byte, format formats.Format) ([]byte, error) {
+	return decrypt.DataWithFormat(content, format)
+}
+
+// KeyServiceDecryptor decrypts files with data keys from the given sops key
+// services only, such as a remote `sops keyservice`.
+type KeyServiceDecryptor struct {
+	KeyServices []keyservice.KeyServiceClient
+}
+
+// Decrypt decrypts a file.
+func (d KeyServiceDecryptor) Decrypt(content []byte, format formats.Format) ([]byte, error) {
+	return decryptWithKeyServices(content, format, d.KeyServices)
+}
+
+// fileDecryptor returns the Decryptor for a file: the Decryptor of the
+// options, if set, and otherwise sops, with a local key service if the file
+// needs one. Data key decryptions by network key services are counted in
+// kmsCalls when timings are enabled, and traced when the file is; data keys
+// that other files share are decrypted once.
+func fileDecryptor(metadata sops.Metadata, opts decryptOptions, kmsCalls *atomic.Int64) (Decryptor, error) {
+	if opts.Decryptor != nil {
+		return opts.Decryptor, nil
+	}
+	server, err := decryptKeyServer(metadata, opts)
+	if err != nil {
+		return nil, err
+	}
+	if invocationTimings != nil {
+		if server == nil {
+			server = keyservice.Server{}
+		}
+		server = countingServer{next: server, calls: kmsCalls}
+	}
+	if trace.SpanFromContext(opts.context()).IsRecording() {
+		if server == nil {
+			server = keyservice.Server{}
+		}
+		server = tracingServer{next: server, ctx: opts.context()}
+	}
+	// Outermost, so that data keys that are reused are not counted or traced
+	// as KMS calls
+	if hasNetworkKeys(metadata) {
+		if server == nil {
+			server = keyservice.Server{}
+		}
+		server = dataKeyServer{next: server, cache: invocationDataKeys}
+	}
+	if server == nil {
+		return SopsDecryptor{}, nil
+	}
+	return KeyServiceDecryptor{KeyServices: []keyservice.KeyServiceClient{keyservice.NewCustomLocalClient(server)}}, nil
+}
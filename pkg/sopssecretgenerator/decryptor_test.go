@@ -0,0 +1,114 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/getsops/sops/v3/keyservice"
+	"github.com/getsops/sops/v3/kms"
+)
+
+// staticDecryptor returns the same plaintext for every file
+type staticDecryptor struct {
+	plaintext string
+	calls     *atomic.Int64
+}
+
+func (d staticDecryptor) Decrypt(content []byte, format formats.Format) ([]byte, error) {
+	d.calls.Add(1)
+	return []byte(d.plaintext), nil
+}
+
+func TestDecryptors(t *testing.T) {
+	content, err := os.ReadFile("testdata/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		decryptor Decryptor
+	}{
+		{"Sops", SopsDecryptor{}},
+		{"KeyService", KeyServiceDecryptor{KeyServices: []keyservice.KeyServiceClient{keyservice.NewLocalClient()}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.decryptor.Decrypt(content, formats.Binary)
+			if err != nil {
+				t.Fatalf("Decrypt() error = %v", err)
+			}
+			if string(got) != "secret\n" {
+				t.Errorf("Decrypt() = %q, want %q", got, "secret\n")
+			}
+		})
+	}
+}
+
+func Test_fileDecryptor(t *testing.T) {
+	custom := staticDecryptor{calls: &atomic.Int64{}}
+	kmsMetadata := keyGroupsMetadata(sops.KeyGroup{&kms.MasterKey{Arn: testKMSArn}})
+	tests := []struct {
+		name     string
+		metadata sops.Metadata
+		opts     decryptOptions
+		want     string
+	}{
+		{"PGP", pgpMetadata(testkeyFingerprint), decryptOptions{}, "SopsDecryptor"},
+		{"KMS", kmsMetadata, decryptOptions{}, "KeyServiceDecryptor"},
+		{"Injected", kmsMetadata, decryptOptions{Decryptor: custom}, "staticDecryptor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fileDecryptor(tt.metadata, tt.opts, &atomic.Int64{})
+			if err != nil {
+				t.Fatalf("fileDecryptor() error = %v", err)
+			}
+			var name string
+			switch got.(type) {
+			case SopsDecryptor:
+				name = "SopsDecryptor"
+			case KeyServiceDecryptor:
+				name = "KeyServiceDecryptor"
+			case staticDecryptor:
+				name = "staticDecryptor"
+			}
+			if name != tt.want {
+				t.Errorf("fileDecryptor() = %T, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerate_Decryptor(t *testing.T) {
+	decryptor := staticDecryptor{plaintext: "injected", calls: &atomic.Int64{}}
+	spec := SopsSecretGenerator{
+		TypeMeta:    TypeMeta{APIVersion: apiVersion, Kind: kind},
+		ObjectMeta:  ObjectMeta{Name: "injected"},
+		FileSources: []string{"testdata/file.txt", "testdata/file2.txt"},
+		Policy:      Policy{AllowedRecipients: []string{testkeyFingerprint}},
+	}
+	got, err := Generate(context.Background(), spec, Options{Decryptor: decryptor})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if got[0].Data["file.txt"] != b64("injected") || got[0].Data["file2.txt"] != b64("injected") {
+		t.Errorf("Generate() data = %v, want the injected plaintext", got[0].Data)
+	}
+	if calls := decryptor.calls.Load(); calls != 2 {
+		t.Errorf("Generate() called the Decryptor %d times, want 2", calls)
+	}
+
+	// Policy checks still apply.
+	spec.Policy.AllowedRecipients = []string{testAgeRecipient}
+	_, err = Generate(context.Background(), spec, Options{Decryptor: decryptor})
+	if err == nil {
+		t.Errorf("Generate() with a violated policy error = nil")
+	}
+}
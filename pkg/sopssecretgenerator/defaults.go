@@ -0,0 +1,139 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultsKind is the kind of the ResourceList item whose fields all
+// generators of the invocation inherit
+const defaultsKind = "SopsSecretGeneratorDefaults"
+
+// defaultsFields are the generator fields that a defaults item may set.
+// Sources, names and namespaces belong to each generator.
+var defaultsFields = map[string]bool{
+	"type":                  true,
+	"behavior":              true,
+	"disableNameSuffixHash": true,
+	"nameSuffixHash":        true,
+	"policy":                true,
+	"timeout":               true,
+	"offline":               true,
+	"kms":                   true,
+	"maxFileSize":           true,
+	"maxFileSizes":          true,
+	"reloader":              true,
+	"annotationPresets":     true,
+	"provenance":            true,
+	"rotateAfter":           true,
+	"rotateStrict":          true,
+	"outputKind":            true,
+	"caseInsensitiveKeys":   true,
+}
+
+// applyDefaults removes the defaults item from the items of a ResourceList,
+// if there is one, and merges its fields into every generator like a base
+// that the generators extend: labels and annotations are merged, and fields
+// of a generator take precedence.
+func applyDefaults(items fn.KubeObjects) (fn.KubeObjects, error) {
+	var defaults generatorFields
+	var defaultsName string
+	var rest fn.KubeObjects
+	for _, item := range items {
+		if item.GetAPIVersion() != apiVersion || item.GetKind() != defaultsKind {
+			rest = append(rest, item)
+			continue
+		}
+		if defaults != nil {
+			return nil, withCause(ErrInvalidGenerator, errors.Errorf("%s %s and %s: at most one is allowed", defaultsKind, defaultsName, item.GetName()))
+		}
+		var err error
+		defaults, err = decodeDefaults([]byte(item.String()))
+		if err != nil {
+			return nil, withCause(ErrInvalidGenerator, errors.Wrapf(err, "%s %s", defaultsKind, item.GetName()))
+		}
+		defaultsName = item.GetName()
+	}
+	if defaults == nil {
+		return items, nil
+	}
+
+	for i, item := range rest {
+		if item.GetAPIVersion() != apiVersion || item.GetKind() != kind {
+			continue
+		}
+		generator, err := decodeFields([]byte(item.String()))
+		if err != nil {
+			return nil, withCause(ErrInvalidGenerator, err)
+		}
+		content, err := yaml.Marshal(mergeGenerators(defaults, generator))
+		if err != nil {
+			return nil, err
+		}
+		rest[i], err = fn.ParseKubeObject(content)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rest, nil
+}
+
+// decodeDefaults returns the fields of a defaults item that generators
+// inherit. Fields that generators cannot inherit are errors, and the
+// annotations of the orchestrator are left out.
+func decodeDefaults(document []byte) (generatorFields, error) {
+	fields, err := decodeFields(document)
+	if err != nil {
+		return nil, err
+	}
+	defaults := make(generatorFields)
+	for field, value := range fields {
+		switch {
+		case field == "apiVersion", field == "kind":
+		case field == "metadata":
+			metadata, _ := value.(map[string]interface{})
+			inherited := make(map[string]interface{})
+			for key, v := range metadata {
+				switch key {
+				case "name":
+				case "labels":
+					inherited[key] = v
+				case "annotations":
+					annotations, _ := v.(map[string]interface{})
+					kept := make(map[string]interface{})
+					for name, annotation := range annotations {
+						if !isOrchestratorAnnotation(name) {
+							kept[name] = annotation
+						}
+					}
+					if len(kept) > 0 {
+						inherited[key] = kept
+					}
+				default:
+					return nil, errors.Errorf("metadata.%s cannot be a default", key)
+				}
+			}
+			if len(inherited) > 0 {
+				defaults[field] = inherited
+			}
+		case defaultsFields[field]:
+			defaults[field] = value
+		default:
+			return nil, errors.Errorf("%s cannot be a default", field)
+		}
+	}
+	return defaults, nil
+}
+
+// isOrchestratorAnnotation reports whether an annotation is one that
+// kustomize or kpt adds to the items of a ResourceList.
+func isOrchestratorAnnotation(name string) bool {
+	return name == "config.k8s.io/id" || strings.HasPrefix(name, "config.kubernetes.io/") ||
+		strings.HasPrefix(name, "internal.config.kubernetes.io/")
+}
@@ -0,0 +1,85 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+)
+
+func Test_decodeDefaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     generatorFields
+		wantErr  bool
+	}{
+		{"Fields", "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGeneratorDefaults\nmetadata:\n  name: defaults\n  labels:\n    team: a\n  annotations:\n    owner: a\n    config.kubernetes.io/index: '0'\ntype: Opaque\noffline: true\n",
+			generatorFields{"metadata": map[string]interface{}{"labels": map[string]interface{}{"team": "a"}, "annotations": map[string]interface{}{"owner": "a"}},
+				"type": "Opaque", "offline": true}, false},
+		{"OrchestratorAnnotations", "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGeneratorDefaults\nmetadata:\n  name: defaults\n  annotations:\n    internal.config.kubernetes.io/path: defaults.yaml\n",
+			generatorFields{}, false},
+		{"Sources", "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGeneratorDefaults\nmetadata:\n  name: defaults\nenvs:\n  - vars.env\n", nil, true},
+		{"Namespace", "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGeneratorDefaults\nmetadata:\n  name: defaults\n  namespace: prod\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeDefaults([]byte(tt.document))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeDefaults() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeDefaults() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_applyDefaults(t *testing.T) {
+	defaults := "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGeneratorDefaults\nmetadata:\n  name: defaults\n  labels:\n    team: a\n    tier: backend\ntype: Opaque\n"
+	tests := []struct {
+		name       string
+		items      []string
+		wantLabels map[string]string
+		wantType   string
+		wantErr    bool
+	}{
+		{"NoDefaults", []string{"apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: a\n"}, nil, "", false},
+		{"Merged", []string{defaults, "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: a\n  labels:\n    tier: frontend\n"},
+			map[string]string{"team": "a", "tier": "frontend"}, "Opaque", false},
+		{"Overridden", []string{"apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: a\ntype: kubernetes.io/tls\n", defaults},
+			map[string]string{"team": "a", "tier": "backend"}, "kubernetes.io/tls", false},
+		{"Twice", []string{defaults, defaults}, nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var items fn.KubeObjects
+			for _, item := range tt.items {
+				obj, err := fn.ParseKubeObject([]byte(item))
+				if err != nil {
+					t.Fatal(err)
+				}
+				items = append(items, obj)
+			}
+			got, err := applyDefaults(items)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyDefaults() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != 1 || got[0].GetKind() != kind {
+				t.Fatalf("applyDefaults() = %v, want the generator only", got)
+			}
+			if labels := got[0].GetLabels(); len(labels) != len(tt.wantLabels) || (len(labels) > 0 && !reflect.DeepEqual(labels, tt.wantLabels)) {
+				t.Errorf("applyDefaults() labels = %v, want %v", labels, tt.wantLabels)
+			}
+			if typ, _, _ := got[0].NestedString("type"); typ != tt.wantType {
+				t.Errorf("applyDefaults() type = %s, want %s", typ, tt.wantType)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// generatorFile is a generator manifest found on disk
+type generatorFile struct {
+	Path      string
+	Generator SopsSecretGenerator
+}
+
+// findGenerators walks a directory tree and returns all generators in YAML
+// files. Hidden directories are skipped, as are YAML files that have no
+// generators, even if they cannot be parsed, such as Helm templates. Files
+// that mention the kind of a generator but cannot be read, or that have an
+// invalid generator, are errors, so that commands never report success over
+// generators they did not look at; all of them are reported together.
+func findGenerators(root string) ([]generatorFile, error) {
+	var found []generatorFile
+	var errs []error
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isYAMLFile(p) {
+			return nil
+		}
+		generators, err := readGenerators(p)
+		if err != nil {
+			if mentionsGenerator(p) {
+				errs = append(errs, err)
+			}
+			return nil
+		}
+		found = append(found, generators...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = joinSourceErrors(errs)
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// mentionsGenerator reports whether a file may have generators, because it
+// mentions their kind, or cannot be read to tell.
+func mentionsGenerator(fileName string) bool {
+	content, err := os.ReadFile(fileName)
+	return err != nil || bytes.Contains(content, []byte(kind))
+}
+
+// isYAMLFile reports whether a file name has a YAML extension.
+func isYAMLFile(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// readGenerators returns the generators in a (multi-document) YAML file.
+func readGenerators(fileName string) ([]generatorFile, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	siblings, err := manifestGenerators(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var generators []generatorFile
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var node yaml.Node
+		err = decoder.Decode(&node)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, fileName)
+		}
+		documents, err := generatorDocuments(&node)
+		if err != nil {
+			return nil, errors.Wrap(err, fileName)
+		}
+		for _, documentNode := range documents {
+			document, err := yaml.Marshal(documentNode)
+			if err != nil {
+				return nil, err
+			}
+			document, err = resolveExtends(document, siblings, filepath.Clean(fileName), filepath.Dir(fileName))
+			if err != nil {
+				return nil, errors.Wrap(err, fileName)
+			}
+			generator, err := readInput(document)
+			if err != nil {
+				return nil, errors.Wrap(err, fileName)
+			}
+			generators = append(generators, generatorFile{Path: fileName, Generator: generator})
+		}
+	}
+	return generators, nil
+}
+
+// sourceFiles returns the encrypted files referenced by the generator, resolved
+// relative to the directory of the manifest, without duplicates.
+func (g generatorFile) sourceFiles() ([]string, error) {
+	sources, fileSources := allVariantSources(g.Generator)
+	for _, source := range fileSources {
+		_, fileName, err := parseFileName(source)
+		if err != nil {
+			return nil, errors.Wrapf(err, "file source \"%s\"", source)
+		}
+		sources = append(sources, fileName)
+	}
+
+	seen := make(map[string]bool)
+	var files []string
+	for _, source := range sources {
+		filePath, _, err := splitExtract(source)
+		if err != nil {
+			return nil, err
+		}
+		filePath = g.resolve(filePath)
+		if !seen[filePath] {
+			seen[filePath] = true
+			files = append(files, filePath)
+		}
+	}
+	return files, nil
+}
+
+// resolve returns the path of a source file relative to the directory of the
+// manifest.
+func (g generatorFile) resolve(filePath string) string {
+	if filepath.IsAbs(filePath) {
+		return filePath
+	}
+	return filepath.Join(filepath.Dir(g.Path), filePath)
+}
+
+// withBaseDir returns the generator with its baseDir resolved relative to the
+// directory of the manifest, so that it can be generated from any working
+// directory.
+func (g generatorFile) withBaseDir() SopsSecretGenerator {
+	generator := g.Generator
+	generator.BaseDir = g.resolve(generator.BaseDir)
+	return generator
+}
+
+// resolveSource returns a source with its file path resolved relative to the
+// directory of the manifest, keeping any extract suffix.
+func (g generatorFile) resolveSource(source string) (string, error) {
+	filePath, _, err := splitExtract(source)
+	if err != nil {
+		return "", err
+	}
+	return g.resolve(filePath) + source[len(filePath):], nil
+}
+
+// referencedFiles returns all encrypted files referenced by generators under
+// a directory, without duplicates.
+func referencedFiles(root string) ([]string, error) {
+	generators, err := findGenerators(root)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var files []string
+	for _, g := range generators {
+		sources, err := g.sourceFiles()
+		if err != nil {
+			return nil, errors.Wrap(err, g.Path)
+		}
+		for _, source := range sources {
+			if !seen[source] {
+				seen[source] = true
+				files = append(files, source)
+			}
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func Test_findGenerators(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "generator.yaml"), `
+apiVersion: kustomize.freightdog.com/v1
+kind: SopsSecretGenerator
+metadata:
+  name: first
+envs:
+  - vars.env
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: other
+---
+apiVersion: kustomize.freightdog.com/v1
+kind: SopsSecretGenerator
+metadata:
+  name: second
+files:
+  - key=file.txt
+  - secrets.yaml["db"]["password"]
+`)
+	writeTestFile(t, filepath.Join(dir, "kustomization.yml"), "generators:\n  - generator.yaml\n")
+	writeTestFile(t, filepath.Join(dir, "broken.yaml"), "{")
+	writeTestFile(t, filepath.Join(dir, "notes.txt"), "kind: SopsSecretGenerator")
+	err := os.Mkdir(filepath.Join(dir, ".git"), 0o700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(dir, ".git", "generator.yaml"), "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: hidden\n")
+
+	generators, err := findGenerators(dir)
+	if err != nil {
+		t.Fatalf("findGenerators() error = %v", err)
+	}
+	var names []string
+	for _, g := range generators {
+		names = append(names, g.Generator.Name)
+	}
+	if want := []string{"first", "second"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("findGenerators() names = %v, want %v", names, want)
+	}
+
+	files, err := referencedFiles(dir)
+	if err != nil {
+		t.Fatalf("referencedFiles() error = %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "vars.env"),
+		filepath.Join(dir, "file.txt"),
+		filepath.Join(dir, "secrets.yaml"),
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("referencedFiles() = %v, want %v", files, want)
+	}
+}
+
+func Test_findGenerators_invalid(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "good.yaml"), "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: good\n")
+	writeTestFile(t, filepath.Join(dir, "noname.yaml"), "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nenvs:\n  - vars.env\n")
+	writeTestFile(t, filepath.Join(dir, "malformed.yaml"), "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata: [\n")
+	writeTestFile(t, filepath.Join(dir, "template.yaml"), "{{ .Values.secret }}\n")
+
+	_, err := findGenerators(dir)
+	if err == nil {
+		t.Fatal("findGenerators() succeeded with invalid generators")
+	}
+	for _, want := range []string{filepath.Join(dir, "noname.yaml"), filepath.Join(dir, "malformed.yaml")} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("findGenerators() error = %v, want %s", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "template.yaml") {
+		t.Errorf("findGenerators() error = %v, want files without generators skipped", err)
+	}
+	if !errors.Is(err, ErrInvalidGenerator) {
+		t.Errorf("findGenerators() error = %v, want ErrInvalidGenerator", err)
+	}
+	_, err = referencedFiles(dir)
+	if err == nil {
+		t.Error("referencedFiles() succeeded with invalid generators")
+	}
+}
+
+func Test_generatorFile_sourceFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator generatorFile
+		want      []string
+		wantErr   bool
+	}{
+		{"Relative", generatorFile{"base/gen.yaml", SopsSecretGenerator{EnvSources: []string{"a.env"}, FileSources: []string{"b.txt"}}}, []string{"base/a.env", "base/b.txt"}, false},
+		{"Absolute", generatorFile{"base/gen.yaml", SopsSecretGenerator{FileSources: []string{"/secrets/b.txt"}}}, []string{"/secrets/b.txt"}, false},
+		{"Duplicate", generatorFile{"gen.yaml", SopsSecretGenerator{EnvSources: []string{"a.env"}, FileSources: []string{"k=a.env"}}}, []string{"a.env"}, false},
+		{"Extract", generatorFile{"gen.yaml", SopsSecretGenerator{FileSources: []string{`a.yaml["x"]`, `a.yaml["y"]`}}}, []string{"a.yaml"}, false},
+		{"Invalid", generatorFile{"gen.yaml", SopsSecretGenerator{FileSources: []string{"=a.env"}}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.generator.sourceFiles()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("sourceFiles() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sourceFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_generatorFile_resolveSource(t *testing.T) {
+	g := generatorFile{Path: "overlay/generator.yaml"}
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"vars.env", "overlay/vars.env"},
+		{`vars.yaml["a/b"]`, `overlay/vars.yaml["a/b"]`},
+		{"/abs/vars.env", "/abs/vars.env"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			got, err := g.resolveSource(tt.source)
+			if err != nil || got != tt.want {
+				t.Errorf("resolveSource() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
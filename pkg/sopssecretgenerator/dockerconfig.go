@@ -0,0 +1,188 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// The key and type of Secrets with a Docker config, which kubelet reads
+// image pull credentials from
+const (
+	dockerConfigJSONKey  = ".dockerconfigjson"
+	dockerConfigJSONType = "kubernetes.io/dockerconfigjson"
+)
+
+// dockerConfigJSON is the content of a .dockerconfigjson, with the entry of
+// each registry kept as it is
+type dockerConfigJSON struct {
+	Auths map[string]json.RawMessage `json:"auths"`
+}
+
+// dockerConfigMerge is the merge of the registries of Docker configs, with
+// the source that each registry came from
+type dockerConfigMerge struct {
+	auths   map[string]json.RawMessage
+	origins map[string]string
+	// registries maps normalized registries to their key in auths
+	registries map[string]string
+}
+
+// newDockerConfigMerge returns an empty merge.
+func newDockerConfigMerge() *dockerConfigMerge {
+	return &dockerConfigMerge{
+		auths:      make(map[string]json.RawMessage),
+		origins:    make(map[string]string),
+		registries: make(map[string]string),
+	}
+}
+
+// parseDockerConfigs decrypts the Docker configs of a generator and merges
+// their registries into a single .dockerconfigjson, so that one pull secret
+// covers all registries. A .dockerconfigjson that other sources already
+// added is merged first. A registry may be in several configs only with the
+// same entry.
+func parseDockerConfigs(sources []string, opts decryptOptions, data kvMap) error {
+	if len(sources) == 0 {
+		return nil
+	}
+	merged := newDockerConfigMerge()
+	if existing, ok := data[dockerConfigJSONKey]; ok {
+		decoded, err := base64.StdEncoding.DecodeString(existing)
+		if err != nil {
+			return errors.Wrapf(err, "could not decode %s", dockerConfigJSONKey)
+		}
+		err = merged.add(decoded, "key "+dockerConfigJSONKey)
+		wipe(decoded)
+		if err != nil {
+			return err
+		}
+	}
+	var errs []error
+	for _, source := range sources {
+		decrypted, err := decryptFile(source, opts)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "docker config \"%s\"", source))
+			if opts.context().Err() != nil {
+				break
+			}
+			continue
+		}
+		err = merged.add(decrypted, source)
+		wipe(decrypted)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "docker config \"%s\"", source))
+		}
+	}
+	if len(errs) > 0 {
+		return joinSourceErrors(errs)
+	}
+
+	encoded, err := json.Marshal(dockerConfigJSON{Auths: merged.auths})
+	if err != nil {
+		return errors.Wrap(err, "could not encode docker config")
+	}
+	data[dockerConfigJSONKey] = encodeBase64(encoded)
+	wipe(encoded)
+	return nil
+}
+
+// add merges the registries of a Docker config, in JSON or YAML, into the
+// merge. The config must have auths, as docker login writes them. A registry
+// that is already merged from another source is a conflict, unless its entry
+// is the same.
+func (m *dockerConfigMerge) add(content []byte, source string) error {
+	var config dockerConfigJSON
+	err := unmarshalDockerConfig(content, &config)
+	if err != nil {
+		return err
+	}
+	if config.Auths == nil {
+		return errors.New("docker config must have auths")
+	}
+	for _, registry := range slices.Sorted(maps.Keys(config.Auths)) {
+		entry := config.Auths[registry]
+		normalized := normalizeRegistry(registry)
+		existing, ok := m.registries[normalized]
+		if !ok {
+			m.auths[registry] = entry
+			m.origins[registry] = source
+			m.registries[normalized] = registry
+			continue
+		}
+		if !sameDockerAuth(m.auths[existing], entry) {
+			return errors.Errorf("registry %s has different credentials in %s and %s", registry, m.origins[existing], source)
+		}
+	}
+	return nil
+}
+
+// unmarshalDockerConfig reads a Docker config in JSON, or in YAML, as
+// sops-encrypted configs are often kept.
+func unmarshalDockerConfig(content []byte, config *dockerConfigJSON) error {
+	if json.Valid(content) {
+		return json.Unmarshal(content, config)
+	}
+	var document map[string]interface{}
+	err := yaml.Unmarshal(content, &document)
+	if err != nil {
+		return errors.Wrap(err, "docker config must be JSON or YAML")
+	}
+	converted, err := json.Marshal(document)
+	if err != nil {
+		return errors.Wrap(err, "docker config must be JSON or YAML")
+	}
+	defer wipe(converted)
+	return json.Unmarshal(converted, config)
+}
+
+// normalizeRegistry returns the registry of an auths key as kubelet matches
+// it: without scheme or trailing slash, and with the aliases of Docker Hub
+// as one registry.
+func normalizeRegistry(registry string) string {
+	registry = strings.TrimPrefix(registry, "https://")
+	registry = strings.TrimPrefix(registry, "http://")
+	registry = strings.TrimRight(registry, "/")
+	switch registry {
+	case "docker.io", "index.docker.io/v1", "registry-1.docker.io":
+		return "index.docker.io"
+	}
+	return registry
+}
+
+// sameDockerAuth reports whether two entries of auths are the same, whatever
+// the order of their fields.
+func sameDockerAuth(a json.RawMessage, b json.RawMessage) bool {
+	var decodedA, decodedB interface{}
+	if json.Unmarshal(a, &decodedA) != nil || json.Unmarshal(b, &decodedB) != nil {
+		return false
+	}
+	return reflect.DeepEqual(decodedA, decodedB)
+}
+
+// validateDockerConfigs checks that a generator with Docker configs makes a
+// Secret of the type that holds them.
+func validateDockerConfigs(input SopsSecretGenerator) error {
+	if len(input.DockerConfigs) > 0 && input.Type != "" && input.Type != dockerConfigJSONType {
+		return errors.Errorf("dockerConfigs require type %s, not %s", dockerConfigJSONType, input.Type)
+	}
+	return nil
+}
+
+// secretType returns the type of the Secret of a generator, which is
+// kubernetes.io/dockerconfigjson by default if it has Docker configs.
+func secretType(input SopsSecretGenerator) string {
+	if input.Type == "" && len(input.DockerConfigs) > 0 {
+		return dockerConfigJSONType
+	}
+	return input.Type
+}
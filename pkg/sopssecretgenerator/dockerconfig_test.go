@@ -0,0 +1,109 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func Test_dockerConfigMerge_add(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs []string
+		want    string
+		wantErr string
+	}{
+		{"Single", []string{`{"auths":{"ghcr.io":{"auth":"YTpi"}}}`}, `{"auths":{"ghcr.io":{"auth":"YTpi"}}}`, ""},
+		{"Merged", []string{`{"auths":{"ghcr.io":{"auth":"YTpi"}}}`, `{"auths":{"quay.io":{"auth":"Yzpk"}}}`},
+			`{"auths":{"ghcr.io":{"auth":"YTpi"},"quay.io":{"auth":"Yzpk"}}}`, ""},
+		{"YAML", []string{"auths:\n  ghcr.io:\n    username: a\n    password: b\n"}, `{"auths":{"ghcr.io":{"password":"b","username":"a"}}}`, ""},
+		{"Same", []string{`{"auths":{"ghcr.io":{"username":"a","password":"b"}}}`, `{"auths":{"ghcr.io":{"password":"b","username":"a"}}}`},
+			`{"auths":{"ghcr.io":{"password":"b","username":"a"}}}`, ""},
+		{"Conflict", []string{`{"auths":{"ghcr.io":{"auth":"YTpi"}}}`, `{"auths":{"ghcr.io":{"auth":"Yzpk"}}}`}, "", "different credentials in 0 and 1"},
+		{"ConflictDockerHub", []string{`{"auths":{"https://index.docker.io/v1/":{"auth":"YTpi"}}}`, `{"auths":{"docker.io":{"auth":"Yzpk"}}}`}, "", "registry docker.io"},
+		{"SamePath", []string{`{"auths":{"ghcr.io/a":{"auth":"YTpi"}}}`, `{"auths":{"ghcr.io/b":{"auth":"Yzpk"}}}`},
+			`{"auths":{"ghcr.io/a":{"auth":"YTpi"},"ghcr.io/b":{"auth":"Yzpk"}}}`, ""},
+		{"NoAuths", []string{`{"credHelpers":{"ghcr.io":"gh"}}`}, "", "must have auths"},
+		{"NotConfig", []string{"- a\n"}, "", "must be JSON or YAML"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := newDockerConfigMerge()
+			var err error
+			for i, config := range tt.configs {
+				err = merged.add([]byte(config), string(rune('0'+i)))
+				if err != nil {
+					break
+				}
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("add() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("add() error = %v", err)
+			}
+			got, _ := json.Marshal(dockerConfigJSON{Auths: merged.auths})
+			if string(got) != tt.want {
+				t.Errorf("add() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseDockerConfigs(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources []string
+		data    kvMap
+		want    kvMap
+		wantErr bool
+	}{
+		{"None", nil, kvMap{}, kvMap{}, false},
+		{"NoneKeepsKey", nil, kvMap{".dockerconfigjson": b64("{")}, kvMap{".dockerconfigjson": b64("{")}, false},
+		{"Missing", []string{"testdata/missing.json"}, kvMap{}, nil, true},
+		{"InvalidKey", []string{"testdata/missing.json"}, kvMap{".dockerconfigjson": b64("{")}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseDockerConfigs(tt.sources, decryptOptions{}, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDockerConfigs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(tt.data) != len(tt.want) {
+				t.Errorf("parseDockerConfigs() = %v, want %v", tt.data, tt.want)
+			}
+		})
+	}
+}
+
+func Test_secretType(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   SopsSecretGenerator
+		want    string
+		wantErr bool
+	}{
+		{"Default", SopsSecretGenerator{}, "", false},
+		{"Set", SopsSecretGenerator{Type: "kubernetes.io/tls"}, "kubernetes.io/tls", false},
+		{"DockerConfigs", SopsSecretGenerator{DockerConfigs: []string{"a.json"}}, "kubernetes.io/dockerconfigjson", false},
+		{"DockerConfigsType", SopsSecretGenerator{Type: "kubernetes.io/dockerconfigjson", DockerConfigs: []string{"a.json"}}, "kubernetes.io/dockerconfigjson", false},
+		{"DockerConfigsOtherType", SopsSecretGenerator{Type: "Opaque", DockerConfigs: []string{"a.json"}}, "Opaque", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateDockerConfigs(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateDockerConfigs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := secretType(tt.input); got != tt.want {
+				t.Errorf("secretType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
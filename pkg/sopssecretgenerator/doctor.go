@@ -0,0 +1,407 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/getsops/sops/v3/azkv"
+	"github.com/getsops/sops/v3/gcpkms"
+	"github.com/getsops/sops/v3/hcvault"
+	"github.com/getsops/sops/v3/kms"
+	"github.com/getsops/sops/v3/pgp"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2/google"
+	"gopkg.in/yaml.v3"
+)
+
+// Statuses of doctor checks
+const (
+	doctorOK     = "ok"
+	doctorWarn   = "WARN"
+	doctorFailed = "FAILED"
+)
+
+// doctorTimeout bounds each check that contacts a credential provider
+const doctorTimeout = 10 * time.Second
+
+// doctorCheck is the result of a check of the doctor command
+type doctorCheck struct {
+	Name   string
+	Status string
+	Detail string
+	// Hint tells the user how to fix a failed check
+	Hint string
+}
+
+// runDoctor implements the doctor subcommand.
+func runDoctor(args []string) error {
+	flags := newFlagSet("doctor")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	root := "."
+	if flags.NArg() > 0 {
+		root = flags.Arg(0)
+	}
+
+	generators, err := findGenerators(root)
+	if err != nil {
+		return err
+	}
+	checks := []doctorCheck{checkAgeIdentities(), checkGPG()}
+	files, keyTypes, fileChecks := doctorFiles(generators)
+	for _, keyType := range keyTypes {
+		if check, ok := checkCredentials(keyType); ok {
+			checks = append(checks, check)
+		}
+	}
+	checks = append(checks, fileChecks...)
+	for _, file := range files {
+		checks = append(checks, checkDecrypt(file.path, file.opts))
+	}
+	checks = append(checks, checkKustomize())
+	for _, g := range generators {
+		checks = append(checks, checkPlugin(g))
+	}
+
+	failed := 0
+	for _, check := range checks {
+		w := io.Writer(os.Stdout)
+		if check.Status == doctorFailed {
+			failed++
+			w = os.Stderr
+		}
+		printDoctorCheck(w, check)
+	}
+	if failed > 0 {
+		return errors.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
+
+// printDoctorCheck writes the result of a check, and its hint if it did not
+// pass.
+func printDoctorCheck(w io.Writer, check doctorCheck) {
+	_, _ = fmt.Fprintf(w, "%-7s %s: %s\n", check.Status, check.Name, check.Detail)
+	if check.Hint != "" && check.Status != doctorOK {
+		_, _ = fmt.Fprintf(w, "        hint: %s\n", check.Hint)
+	}
+}
+
+// doctorFile is a source file to decrypt, with the options of the first
+// generator that references it
+type doctorFile struct {
+	path string
+	opts decryptOptions
+}
+
+// doctorFiles returns the files referenced by generators, without duplicates,
+// and the sops key types they are encrypted to. Files that cannot be loaded
+// are left for the decryption check to report; generators with invalid
+// sources or options are reported as failed checks.
+func doctorFiles(generators []generatorFile) ([]doctorFile, []string, []doctorCheck) {
+	var files []doctorFile
+	var checks []doctorCheck
+	seen := make(map[string]bool)
+	types := make(map[string]bool)
+	for _, g := range generators {
+		sources, err := g.sourceFiles()
+		opts, optsErr := newDecryptOptions(g.Generator, runtimeSettings)
+		if optsErr != nil {
+			err = errors.Wrap(optsErr, "invalid options")
+		}
+		opts.Context = invocationContext
+		if err != nil {
+			checks = append(checks, doctorCheck{
+				Name:   "generator " + g.Generator.Name,
+				Status: doctorFailed,
+				Detail: err.Error(),
+				Hint:   "run `SopsSecretGenerator validate " + g.Path + "`",
+			})
+			continue
+		}
+		for _, source := range sources {
+			if seen[source] {
+				continue
+			}
+			seen[source] = true
+			files = append(files, doctorFile{source, opts})
+			tree, _, err := loadEncryptedTree(source)
+			if err != nil {
+				continue
+			}
+			for _, group := range tree.Metadata.KeyGroups {
+				for _, key := range group {
+					types[key.TypeToIdentifier()] = true
+				}
+			}
+		}
+	}
+	keyTypes := make([]string, 0, len(types))
+	for keyType := range types {
+		keyTypes = append(keyTypes, keyType)
+	}
+	sort.Strings(keyTypes)
+	return files, keyTypes, checks
+}
+
+// checkAgeIdentities reports the age identities that sops can use.
+func checkAgeIdentities() doctorCheck {
+	check := doctorCheck{Name: "age identities"}
+	sources, err := ageIdentitySources()
+	if err != nil {
+		check.Status, check.Detail = doctorFailed, err.Error()
+		check.Hint = "fix or unset SOPS_AGE_KEY_FILE"
+		return check
+	}
+	if len(sources) == 0 {
+		check.Status, check.Detail = doctorWarn, "none found"
+		check.Hint = "set SOPS_AGE_KEY_FILE, or create keys.txt in the sops config directory, to decrypt files encrypted to age recipients"
+		return check
+	}
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	check.Status, check.Detail = doctorOK, "found in "+strings.Join(names, ", ")
+	return check
+}
+
+// checkGPG reports whether GnuPG, which sops decrypts PGP keys with, has
+// secret keys.
+func checkGPG() doctorCheck {
+	check := doctorCheck{Name: "gpg"}
+	binary := os.Getenv(pgp.SopsGpgExecEnv)
+	if binary == "" {
+		binary = "gpg"
+	}
+	ctx, cancel := context.WithTimeout(invocationContext, doctorTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, binary, "--batch", "--list-secret-keys", "--with-colons").Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		check.Status, check.Detail = doctorWarn, binary+" not found"
+		check.Hint = "install GnuPG, or set " + pgp.SopsGpgExecEnv + ", to decrypt files encrypted to PGP keys"
+		return check
+	}
+	if err != nil {
+		check.Status, check.Detail = doctorWarn, "could not list secret keys: "+err.Error()
+		check.Hint = "check that " + binary + " --list-secret-keys works"
+		return check
+	}
+	keys := 0
+	for _, line := range bytes.Split(output, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("sec:")) {
+			keys++
+		}
+	}
+	if keys == 0 {
+		check.Status, check.Detail = doctorWarn, "no secret keys"
+		check.Hint = "import your PGP key, or set GNUPGHOME, to decrypt files encrypted to PGP keys"
+		return check
+	}
+	check.Status, check.Detail = doctorOK, fmt.Sprintf("secret keys found: %d", keys)
+	return check
+}
+
+// checkCredentials checks that the credentials for a network key service can
+// be loaded, for the key types that need them.
+func checkCredentials(keyType string) (doctorCheck, bool) {
+	ctx, cancel := context.WithTimeout(invocationContext, doctorTimeout)
+	defer cancel()
+	var check doctorCheck
+	var err error
+	switch keyType {
+	case kms.KeyTypeIdentifier:
+		check = doctorCheck{Name: "AWS credentials", Hint: "configure AWS credentials, for example with `aws sso login` or AWS_PROFILE"}
+		var provider aws.CredentialsProvider
+		provider, err = invocationCredentials.awsCredentials("", "", "")
+		if err == nil {
+			_, err = provider.Retrieve(ctx)
+		}
+	case gcpkms.KeyTypeIdentifier:
+		check = doctorCheck{Name: "GCP credentials", Hint: "run `gcloud auth application-default login`, or set GOOGLE_CREDENTIALS"}
+		if os.Getenv("GOOGLE_CREDENTIALS") == "" {
+			_, err = google.FindDefaultCredentials(ctx, "https://www.googleapis.com/auth/cloudkms")
+		}
+	case azkv.KeyTypeIdentifier:
+		check = doctorCheck{Name: "Azure credentials", Hint: "run `az login`, or set the AZURE_ environment variables of a service principal"}
+		var credential azcore.TokenCredential
+		credential, err = invocationCredentials.azureCredential()
+		if err == nil {
+			_, err = credential.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{"https://vault.azure.net/.default"}})
+		}
+	case hcvault.KeyTypeIdentifier:
+		check = doctorCheck{Name: "Vault token", Hint: "run `vault login`, or set VAULT_TOKEN"}
+		err = findVaultToken()
+	default:
+		return doctorCheck{}, false
+	}
+	if err != nil {
+		check.Status, check.Detail = doctorFailed, err.Error()
+		return check, true
+	}
+	check.Status, check.Detail = doctorOK, "available"
+	return check, true
+}
+
+// findVaultToken fails if there is no Vault token in the places sops looks
+// for one.
+func findVaultToken() error {
+	if os.Getenv("VAULT_TOKEN") != "" {
+		return nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	token, err := os.ReadFile(filepath.Join(home, ".vault-token"))
+	if err != nil || len(bytes.TrimSpace(token)) == 0 {
+		return errors.New("no token in VAULT_TOKEN or ~/.vault-token")
+	}
+	return nil
+}
+
+// checkDecrypt decrypts a file and discards the plaintext.
+func checkDecrypt(filePath string, opts decryptOptions) doctorCheck {
+	check := doctorCheck{Name: "decrypt " + filePath}
+	decrypted, err := decryptFile(filePath, opts)
+	wipe(decrypted)
+	if err == nil {
+		check.Status, check.Detail = doctorOK, "decrypted"
+		return check
+	}
+	check.Status, check.Detail = doctorFailed, err.Error()
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		check.Hint = "fix the path in the generator; paths are relative to the generator manifest"
+	case errors.Is(err, ErrNotEncrypted):
+		check.Hint = "encrypt the file with `SopsSecretGenerator encrypt` or `sops encrypt --in-place`"
+	case errors.Is(err, ErrKeyDenied):
+		check.Hint = "none of the keys of the file are available to you; see the key checks above, or ask for the file to be encrypted to your key"
+	}
+	return check
+}
+
+// checkKustomize reports the kustomize binary that runs the plugin.
+func checkKustomize() doctorCheck {
+	check := doctorCheck{Name: "kustomize"}
+	binary, err := exec.LookPath("kustomize")
+	if err != nil {
+		check.Status, check.Detail = doctorWarn, "not found in PATH"
+		check.Hint = "install kustomize, or use `kubectl kustomize`, with --enable-alpha-plugins --enable-exec"
+		return check
+	}
+	ctx, cancel := context.WithTimeout(invocationContext, doctorTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, binary, "version").Output()
+	if err != nil {
+		check.Status, check.Detail = doctorWarn, binary+": "+err.Error()
+		return check
+	}
+	check.Status, check.Detail = doctorOK, binary+" "+strings.TrimSpace(string(output))
+	return check
+}
+
+// functionConfig is the config.kubernetes.io/function annotation
+type functionConfig struct {
+	Exec struct {
+		Path string `yaml:"path"`
+	} `yaml:"exec"`
+	Container struct {
+		Image string `yaml:"image"`
+	} `yaml:"container"`
+}
+
+// checkPlugin checks that kustomize can find the plugin for a generator: the
+// exec function of its function annotation, or else the legacy plugin.
+func checkPlugin(g generatorFile) doctorCheck {
+	check := doctorCheck{Name: "plugin for " + g.Path + " (" + g.Generator.Name + ")"}
+	annotation, ok := g.Generator.Annotations["config.kubernetes.io/function"]
+	if !ok {
+		binary := legacyPluginPath()
+		check.Detail = "legacy plugin " + binary
+		if err := checkExecutable(binary); err != nil {
+			check.Status, check.Detail = doctorFailed, err.Error()
+			check.Hint = "install the plugin to " + binary + ", or add a config.kubernetes.io/function annotation"
+			return check
+		}
+		check.Status = doctorOK
+		return check
+	}
+
+	var function functionConfig
+	err := yaml.Unmarshal([]byte(annotation), &function)
+	switch {
+	case err != nil:
+		check.Status, check.Detail = doctorFailed, "invalid config.kubernetes.io/function annotation: "+err.Error()
+		return check
+	case function.Container.Image != "":
+		check.Status, check.Detail = doctorWarn, "container function "+function.Container.Image
+		check.Hint = "containers cannot reach local keys or files outside the kustomization; use an exec function"
+		return check
+	case function.Exec.Path == "":
+		check.Status, check.Detail = doctorFailed, "config.kubernetes.io/function annotation has no exec path"
+		return check
+	}
+	binary := function.Exec.Path
+	if strings.ContainsRune(binary, '/') && !filepath.IsAbs(binary) {
+		binary = filepath.Join(filepath.Dir(g.Path), binary)
+	} else if !strings.ContainsRune(binary, '/') {
+		binary, err = exec.LookPath(binary)
+		if err != nil {
+			check.Status, check.Detail = doctorFailed, err.Error()
+			check.Hint = "install SopsSecretGenerator to a directory in PATH, or set the exec path to the binary"
+			return check
+		}
+	}
+	check.Detail = "exec function " + binary
+	if err := checkExecutable(binary); err != nil {
+		check.Status, check.Detail = doctorFailed, err.Error()
+		check.Hint = "fix the exec path; relative paths are resolved from the kustomization directory"
+		return check
+	}
+	check.Status = doctorOK
+	return check
+}
+
+// legacyPluginPath returns where kustomize looks for the legacy exec plugin.
+func legacyPluginPath() string {
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		home, _ := os.UserHomeDir()
+		configDir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configDir, "kustomize", "plugin", apiVersion[:strings.Index(apiVersion, "/")], apiVersion[strings.Index(apiVersion, "/")+1:], strings.ToLower(kind), kind)
+}
+
+// checkExecutable fails if a file does not exist or cannot be executed.
+func checkExecutable(fileName string) error {
+	info, err := os.Stat(fileName)
+	if os.IsNotExist(err) {
+		return errors.Errorf("%s does not exist", fileName)
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() || (runtime.GOOS != "windows" && info.Mode()&0o111 == 0) {
+		return errors.Errorf("%s is not executable", fileName)
+	}
+	return nil
+}
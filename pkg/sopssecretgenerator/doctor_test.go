@@ -0,0 +1,177 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	sopsage "github.com/getsops/sops/v3/age"
+)
+
+func Test_checkDecrypt(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		wantStatus string
+		wantHint   string
+	}{
+		{"Decrypted", "testdata/file.txt", doctorOK, ""},
+		{"Missing", "testdata/missing.txt", doctorFailed, "fix the path"},
+		{"NotEncrypted", "testdata/notyaml.txt", doctorFailed, "encrypt the file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkDecrypt(tt.file, decryptOptions{})
+			if got.Status != tt.wantStatus || !strings.Contains(got.Hint, tt.wantHint) {
+				t.Errorf("checkDecrypt() = %+v, want status %s and hint %q", got, tt.wantStatus, tt.wantHint)
+			}
+		})
+	}
+}
+
+func Test_checkAgeIdentities(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        string
+		wantStatus string
+	}{
+		{"Env", "AGE-SECRET-KEY-1", doctorOK},
+		{"None", "", doctorWarn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			t.Setenv(sopsage.SopsAgeKeyFileEnv, "")
+			_ = os.Unsetenv(sopsage.SopsAgeKeyFileEnv)
+			t.Setenv(sopsage.SopsAgeKeyEnv, tt.key)
+			if tt.key == "" {
+				_ = os.Unsetenv(sopsage.SopsAgeKeyEnv)
+			}
+			if got := checkAgeIdentities(); got.Status != tt.wantStatus {
+				t.Errorf("checkAgeIdentities() = %+v, want status %s", got, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func Test_checkCredentials(t *testing.T) {
+	tests := []struct {
+		name       string
+		keyType    string
+		vaultToken string
+		wantStatus string
+		wantCheck  bool
+	}{
+		{"VaultToken", "hc_vault", "s.token", doctorOK, true},
+		{"NoVaultToken", "hc_vault", "", doctorFailed, true},
+		{"LocalKey", "pgp", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			t.Setenv("VAULT_TOKEN", tt.vaultToken)
+			got, ok := checkCredentials(tt.keyType)
+			if ok != tt.wantCheck || got.Status != tt.wantStatus {
+				t.Errorf("checkCredentials() = %+v, %v, want status %q, %v", got, ok, tt.wantStatus, tt.wantCheck)
+			}
+		})
+	}
+}
+
+func Test_doctorFiles(t *testing.T) {
+	generators := []generatorFile{
+		{Path: "testdata/generator.yaml", Generator: SopsSecretGenerator{ObjectMeta: ObjectMeta{Name: "a"}, EnvSources: []string{"vars.env"}, FileSources: []string{"file.txt"}}},
+		{Path: "testdata/generator.yaml", Generator: SopsSecretGenerator{ObjectMeta: ObjectMeta{Name: "b"}, FileSources: []string{"file.txt", "missing.txt"}}},
+		{Path: "testdata/generator.yaml", Generator: SopsSecretGenerator{ObjectMeta: ObjectMeta{Name: "c"}, Timeout: "soon"}},
+	}
+	files, keyTypes, checks := doctorFiles(generators)
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.path)
+	}
+	if want := []string{"testdata/vars.env", "testdata/file.txt", "testdata/missing.txt"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("doctorFiles() files = %v, want %v", paths, want)
+	}
+	if want := []string{"pgp"}; !reflect.DeepEqual(keyTypes, want) {
+		t.Errorf("doctorFiles() key types = %v, want %v", keyTypes, want)
+	}
+	if len(checks) != 1 || checks[0].Name != "generator c" || checks[0].Status != doctorFailed {
+		t.Errorf("doctorFiles() checks = %+v, want a failed check for generator c", checks)
+	}
+}
+
+func Test_checkPlugin(t *testing.T) {
+	function := func(config string) kvMap {
+		return kvMap{"config.kubernetes.io/function": config}
+	}
+	tests := []struct {
+		name        string
+		annotations kvMap
+		files       map[string]os.FileMode
+		wantStatus  string
+	}{
+		{"Legacy", nil, map[string]os.FileMode{"config/kustomize/plugin/kustomize.freightdog.com/v1/sopssecretgenerator/SopsSecretGenerator": 0o755}, doctorOK},
+		{"LegacyMissing", nil, nil, doctorFailed},
+		{"ExecRelative", function("exec:\n  path: ./SopsSecretGenerator\n"), map[string]os.FileMode{"kustomization/SopsSecretGenerator": 0o755}, doctorOK},
+		{"ExecNotExecutable", function("exec:\n  path: ./SopsSecretGenerator\n"), map[string]os.FileMode{"kustomization/SopsSecretGenerator": 0o644}, doctorFailed},
+		{"ExecPath", function("exec:\n  path: SopsSecretGenerator\n"), map[string]os.FileMode{"bin/SopsSecretGenerator": 0o755}, doctorOK},
+		{"ExecPathMissing", function("exec:\n  path: SopsSecretGenerator\n"), nil, doctorFailed},
+		{"Container", function("container:\n  image: example/sopssecretgenerator\n"), nil, doctorWarn},
+		{"NoExecPath", function("exec: {}\n"), nil, doctorFailed},
+		{"Invalid", function("exec: [\n"), nil, doctorFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, sub := range []string{"config", "kustomization", "bin"} {
+				if err := os.Mkdir(filepath.Join(dir, sub), 0o700); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for name, mode := range tt.files {
+				fileName := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(fileName), 0o700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(fileName, []byte("#!/bin/sh\n"), mode); err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+			t.Setenv("PATH", filepath.Join(dir, "bin"))
+			g := generatorFile{
+				Path:      filepath.Join(dir, "kustomization", "generator.yaml"),
+				Generator: SopsSecretGenerator{ObjectMeta: ObjectMeta{Name: "my-secret", Annotations: tt.annotations}},
+			}
+			if got := checkPlugin(g); got.Status != tt.wantStatus {
+				t.Errorf("checkPlugin() = %+v, want status %s", got, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func Test_printDoctorCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		check doctorCheck
+		want  string
+	}{
+		{"OK", doctorCheck{Name: "gpg", Status: doctorOK, Detail: "secret keys found: 1", Hint: "unused"}, "ok      gpg: secret keys found: 1\n"},
+		{"Failed", doctorCheck{Name: "decrypt a.env", Status: doctorFailed, Detail: "denied", Hint: "ask"}, "FAILED  decrypt a.env: denied\n        hint: ask\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			printDoctorCheck(&out, tt.check)
+			if out.String() != tt.want {
+				t.Errorf("printDoctorCheck() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+)
+
+// dryRunAnnotation enables dry-run mode for a single generator
+const dryRunAnnotation = "kustomize.freightdog.com/dry-run"
+
+// redactedPrefix starts the values of redacted Secrets
+const redactedPrefix = "<redacted:sha256:"
+
+// redactedHashLength is the number of hex digits of the value hash that a
+// redacted value keeps, enough to tell changed values apart
+const redactedHashLength = 12
+
+// isDryRun reports whether the Secret of a generator is redacted, either for
+// the whole invocation or by the annotation of the generator.
+func isDryRun(input SopsSecretGenerator, opts Options) bool {
+	if opts.DryRun {
+		return true
+	}
+	dryRun, _ := strconv.ParseBool(input.Annotations[dryRunAnnotation])
+	return dryRun
+}
+
+// redactData replaces the values of Secret data with a prefix of the SHA-256
+// hash of the plaintext, so that a preview shows which values changed without
+// showing the values.
+func redactData(data kvMap) {
+	for k, v := range data {
+		plaintext, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			plaintext = []byte(v)
+		}
+		sum := sha256.Sum256(plaintext)
+		wipe(plaintext)
+		data[k] = redactedPrefix + hex.EncodeToString(sum[:])[:redactedHashLength] + ">"
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+)
+
+func Test_isDryRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations kvMap
+		opts        Options
+		want        bool
+	}{
+		{"Off", nil, Options{}, false},
+		{"Option", nil, Options{DryRun: true}, true},
+		{"Annotation", kvMap{dryRunAnnotation: "true"}, Options{}, true},
+		{"AnnotationFalse", kvMap{dryRunAnnotation: "false"}, Options{}, false},
+		{"AnnotationInvalid", kvMap{dryRunAnnotation: "please"}, Options{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := SopsSecretGenerator{ObjectMeta: ObjectMeta{Annotations: tt.annotations}}
+			if got := isDryRun(input, tt.opts); got != tt.want {
+				t.Errorf("isDryRun() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_redactData(t *testing.T) {
+	tests := []struct {
+		name string
+		data kvMap
+		want kvMap
+	}{
+		{"Empty", kvMap{}, kvMap{}},
+		{"Values", kvMap{"file.txt": b64("secret\n"), "a": b64("a")}, kvMap{"file.txt": "<redacted:sha256:b37e50cedcd3>", "a": "<redacted:sha256:ca978112ca1b>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redactData(tt.data)
+			if !reflect.DeepEqual(tt.data, tt.want) {
+				t.Errorf("redactData() = %v, want %v", tt.data, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerate_DryRun(t *testing.T) {
+	spec := SopsSecretGenerator{
+		TypeMeta:    TypeMeta{APIVersion: apiVersion, Kind: kind},
+		ObjectMeta:  ObjectMeta{Name: "my-secret", Annotations: kvMap{dryRunAnnotation: "true", "team": "a"}},
+		FileSources: []string{"testdata/file.txt"},
+	}
+	secrets, err := Generate(context.Background(), spec, Options{})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if got := secrets[0].Data["file.txt"]; got != "<redacted:sha256:b37e50cedcd3>" {
+		t.Errorf("Generate() data = %q, want a redacted value", got)
+	}
+	if _, ok := secrets[0].Annotations[dryRunAnnotation]; ok || secrets[0].Annotations["team"] != "a" {
+		t.Errorf("Generate() annotations = %v, want the dry-run annotation stripped", secrets[0].Annotations)
+	}
+}
+
+func Test_generateSecretObject_dryRun(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	copyTestFile(t, "testdata/file.txt", file)
+	item, err := fn.ParseKubeObject([]byte(fmt.Sprintf("apiVersion: %s\nkind: %s\nmetadata:\n  name: state\n  annotations:\n    %s: \"true\"\nfiles:\n  - %s\n", apiVersion, kind, dryRunAnnotation, file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := loadStateFile(filepath.Join(dir, "secrets.state"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, _ := generatorDigest(context.Background(), []byte(item.String()), SopsSecretGenerator{FileSources: []string{file}})
+	s.put("/state", digest, "apiVersion: v1\nkind: Secret\nmetadata:\n  name: state\ndata:\n  file.txt: "+b64("secret\n")+"\n")
+
+	got, err := generateSecretObject(context.Background(), item, s)
+	if err != nil {
+		t.Fatalf("generateSecretObject() error = %v", err)
+	}
+	if value, _, _ := got.NestedString("data", "file.txt"); value != "<redacted:sha256:b37e50cedcd3>" {
+		t.Errorf("generateSecretObject() data = %q, want a redacted value instead of the recorded Secret", value)
+	}
+	if recorded, _ := s.get("/state", digest); recorded != "apiVersion: v1\nkind: Secret\nmetadata:\n  name: state\ndata:\n  file.txt: "+b64("secret\n")+"\n" {
+		t.Errorf("generateSecretObject() recorded a redacted Secret:\n%s", recorded)
+	}
+}
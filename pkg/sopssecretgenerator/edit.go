@@ -0,0 +1,195 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/getsops/sops/v3/aes"
+	"github.com/getsops/sops/v3/cmd/sops/common"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/pkg/errors"
+)
+
+// runEdit implements the edit subcommand.
+func runEdit(args []string) error {
+	flags := newFlagSet("edit")
+	dir := flags.String("dir", ".", "`directory` to search for generators that reference the file")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected a file to edit")
+	}
+
+	generators, err := findGenerators(*dir)
+	if err != nil {
+		return err
+	}
+	changed, err := editFile(flags.Arg(0), generators, openEditor)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		_, _ = fmt.Fprintln(os.Stderr, "file unchanged")
+	}
+	return nil
+}
+
+// openEditor opens a file in the editor from $EDITOR. If the previous edit
+// was invalid, the error is shown first.
+func openEditor(fileName string, lastErr error) error {
+	if lastErr != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%v\nPress enter to return to the editor, or Ctrl+C to exit.\n", lastErr)
+		_, _ = bufio.NewReader(os.Stdin).ReadString('\n')
+	}
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+	cmd := exec.Command(editor[0], append(editor[1:], fileName)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// editFile decrypts a file to a temporary file, lets the user edit it, and
+// encrypts the result with the same data key, like `sops edit`. The edited
+// content must still be usable by the generators that reference the file;
+// if it is not, the file is edited again. It reports whether the file was
+// changed.
+func editFile(fileName string, generators []generatorFile, edit func(fileName string, lastErr error) error) (bool, error) {
+	tree, store, err := loadEncryptedTree(fileName)
+	if err != nil {
+		return false, err
+	}
+	dataKey, err := decryptTree(tree, "edit")
+	if err != nil {
+		return false, err
+	}
+	defer wipe(dataKey)
+	plaintext, err := store.EmitPlainFile(tree.Branches)
+	if err != nil {
+		return false, err
+	}
+	defer wipe(plaintext)
+
+	tmp, err := os.CreateTemp("", "*-"+filepath.Base(fileName))
+	if err != nil {
+		return false, err
+	}
+	defer removeTempFile(tmp.Name())
+	_, err = tmp.Write(plaintext)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var lastErr error
+	for {
+		err = edit(tmp.Name(), lastErr)
+		if err != nil {
+			return false, errors.Wrap(err, "editor failed")
+		}
+		edited, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return false, err
+		}
+		if bytes.Equal(edited, plaintext) {
+			wipe(edited)
+			return false, nil
+		}
+
+		branches, err := store.LoadPlainFile(edited)
+		if err == nil {
+			err = validateSources(fileName, edited, generators)
+		}
+		wipe(edited)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		tree.Branches = branches
+		err = common.EncryptTree(common.EncryptTreeOpts{Tree: tree, Cipher: aes.NewCipher(), DataKey: dataKey})
+		if err != nil {
+			return false, err
+		}
+		return true, writeEncryptedTree(fileName, tree, store)
+	}
+}
+
+// removeTempFile overwrites a temporary plaintext file before removing it.
+func removeTempFile(fileName string) {
+	info, err := os.Stat(fileName)
+	if err == nil {
+		_ = os.WriteFile(fileName, make([]byte, info.Size()), 0o600)
+	}
+	_ = os.Remove(fileName)
+}
+
+// validateSources checks that the plaintext of a file can still be used by
+// every generator source that references it.
+func validateSources(fileName string, plaintext []byte, generators []generatorFile) error {
+	absPath, err := filepath.Abs(fileName)
+	if err != nil {
+		return err
+	}
+	format := formats.FormatForPath(fileName)
+	for _, g := range generators {
+		for _, source := range g.Generator.EnvSources {
+			filePath, treePath, err := splitExtract(source)
+			if err != nil || !samePath(g.resolve(filePath), absPath) {
+				continue
+			}
+			content := plaintext
+			if treePath != nil {
+				content, err = extractValue(plaintext, format, treePath)
+				if err != nil {
+					return errors.Wrapf(err, "env source \"%s\" of generator %s", source, g.Generator.Name)
+				}
+			}
+			err = parseEnvFileContent(content, fileName, make(kvMap))
+			if treePath != nil {
+				wipe(content)
+			}
+			if err != nil {
+				return errors.Wrapf(err, "env source \"%s\" of generator %s", source, g.Generator.Name)
+			}
+		}
+		for _, source := range g.Generator.FileSources {
+			_, filePath, err := parseFileName(source)
+			if err != nil {
+				continue
+			}
+			filePath, treePath, err := splitExtract(filePath)
+			if err != nil || treePath == nil || !samePath(g.resolve(filePath), absPath) {
+				continue
+			}
+			value, err := extractValue(plaintext, format, treePath)
+			if err != nil {
+				return errors.Wrapf(err, "file source \"%s\" of generator %s", source, g.Generator.Name)
+			}
+			wipe(value)
+		}
+	}
+	return nil
+}
+
+// samePath reports whether a path refers to the given absolute path.
+func samePath(p string, absPath string) bool {
+	abs, err := filepath.Abs(p)
+	return err == nil && abs == absPath
+}
@@ -0,0 +1,135 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_editFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		edits       []string
+		wantChanged bool
+		wantEdits   int
+		want        string
+		wantErr     bool
+	}{
+		{"Unchanged", []string{""}, false, 1, "VAR_ENV=val_env", false},
+		{"Changed", []string{"VAR_ENV=new_value\n"}, true, 1, "VAR_ENV=new_value", false},
+		{"InvalidThenValid", []string{"not an env line\n", "VAR_ENV=fixed\n"}, true, 2, "VAR_ENV=fixed", false},
+		{"EditorFails", nil, false, 1, "VAR_ENV=val_env", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			fileName := filepath.Join(dir, "vars.env")
+			copyTestFile(t, "testdata/vars.env", fileName)
+			generators := []generatorFile{{
+				Path:      filepath.Join(dir, "generator.yaml"),
+				Generator: SopsSecretGenerator{EnvSources: []string{"vars.env"}},
+			}}
+
+			edits := 0
+			edit := func(tmpName string, lastErr error) error {
+				edits++
+				if tt.edits == nil {
+					return errors.New("editor failed")
+				}
+				if (edits > 1) != (lastErr != nil) {
+					t.Errorf("edit() lastErr = %v on edit %d", lastErr, edits)
+				}
+				content := tt.edits[edits-1]
+				if content == "" {
+					return nil
+				}
+				return os.WriteFile(tmpName, []byte(content), 0o600)
+			}
+
+			changed, err := editFile(fileName, generators, edit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("editFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("editFile() changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if edits != tt.wantEdits {
+				t.Errorf("editFile() opened editor %d times, want %d", edits, tt.wantEdits)
+			}
+			decrypted, err := decryptFile(fileName, decryptOptions{})
+			if err != nil {
+				t.Fatalf("decryptFile() error = %v", err)
+			}
+			if !strings.Contains(string(decrypted), tt.want) {
+				t.Errorf("decryptFile() = %q, want it to contain %q", decrypted, tt.want)
+			}
+		})
+	}
+}
+
+func Test_editFile_audit(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "vars.env")
+	copyTestFile(t, "testdata/vars.env", fileName)
+	logFile := filepath.Join(dir, "audit.log")
+	auditLog := runtimeSettings.AuditLog
+	runtimeSettings.AuditLog = logFile
+	t.Cleanup(func() { runtimeSettings.AuditLog = auditLog })
+
+	_, err := editFile(fileName, nil, func(string, error) error { return nil })
+	if err != nil {
+		t.Fatalf("editFile() error = %v", err)
+	}
+
+	content, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("could not read audit log: %v", err)
+	}
+	var record auditRecord
+	err = json.Unmarshal(content, &record)
+	if err != nil {
+		t.Fatalf("could not parse audit record %q: %v", content, err)
+	}
+	if record.Command != "edit" || record.File != fileName || !record.Success {
+		t.Errorf("audit record = %+v", record)
+	}
+}
+
+func Test_validateSources(t *testing.T) {
+	generator := func(envs []string, files []string) []generatorFile {
+		return []generatorFile{{
+			Path:      "overlay/generator.yaml",
+			Generator: SopsSecretGenerator{EnvSources: envs, FileSources: files},
+		}}
+	}
+	tests := []struct {
+		name       string
+		fileName   string
+		plaintext  string
+		generators []generatorFile
+		wantErr    bool
+	}{
+		{"ValidEnv", "overlay/vars.env", "A=b\n", generator([]string{"vars.env"}, nil), false},
+		{"InvalidEnv", "overlay/vars.env", "A\n", generator([]string{"vars.env"}, nil), true},
+		{"OtherFile", "overlay/other.env", "A\n", generator([]string{"vars.env"}, nil), false},
+		{"NoGenerators", "overlay/vars.env", "A\n", nil, false},
+		{"ExtractedEnv", "overlay/vars.yaml", "db:\n  user: x\n", generator([]string{`vars.yaml["db"]`}, nil), false},
+		{"ExtractMissing", "overlay/vars.yaml", "other: x\n", generator([]string{`vars.yaml["db"]`}, nil), true},
+		{"FileExtractMissing", "overlay/vars.yaml", "other: x\n", generator(nil, []string{`key=vars.yaml["db"]`}), true},
+		{"PlainFile", "overlay/vars.yaml", "{", generator(nil, []string{"vars.yaml"}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSources(tt.fileName, []byte(tt.plaintext), tt.generators)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateSources() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
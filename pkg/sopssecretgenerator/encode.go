@@ -0,0 +1,30 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// encodeChunkSize is the number of plaintext bytes that are encoded at a
+// time. It is a multiple of 3, so that no padding is written between chunks.
+const encodeChunkSize = 3 * 1024
+
+// encodeBase64 encodes a value of any source for the data of a Secret. Unlike
+// base64.StdEncoding.EncodeToString, the encoding is streamed in chunks into a
+// single buffer of the final size, so that large binary values are not held
+// in memory twice in encoded form.
+func encodeBase64(b []byte) string {
+	var sb strings.Builder
+	sb.Grow(base64.StdEncoding.EncodedLen(len(b)))
+	encoder := base64.NewEncoder(base64.StdEncoding, &sb)
+	for len(b) > 0 {
+		n := min(len(b), encodeChunkSize)
+		_, _ = encoder.Write(b[:n])
+		b = b[n:]
+	}
+	_ = encoder.Close()
+	return sb.String()
+}
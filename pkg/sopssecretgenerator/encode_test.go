@@ -0,0 +1,49 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"encoding/base64"
+	"runtime"
+	"testing"
+)
+
+func Test_encodeBase64(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"Empty", []byte{}},
+		{"OneByte", []byte("a")},
+		{"TwoBytes", []byte("ab")},
+		{"ThreeBytes", []byte("abc")},
+		{"Chunk", bytes.Repeat([]byte{0xff}, encodeChunkSize)},
+		{"ChunkPlusOne", bytes.Repeat([]byte{0x01}, encodeChunkSize+1)},
+		{"Large", bytes.Repeat([]byte("binary\x00"), 300000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := base64.StdEncoding.EncodeToString(tt.input)
+			if got := encodeBase64(tt.input); got != want {
+				t.Errorf("encodeBase64() = %d bytes, want %d bytes equal to EncodeToString", len(got), len(want))
+			}
+		})
+	}
+}
+
+func Test_encodeBase64_memory(t *testing.T) {
+	input := bytes.Repeat([]byte{0x42}, 1<<20)
+	encodedLen := uint64(base64.StdEncoding.EncodedLen(len(input)))
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_ = encodeBase64(input)
+	runtime.ReadMemStats(&after)
+
+	// EncodeToString allocates the encoded size twice
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > encodedLen*3/2 {
+		t.Errorf("encodeBase64() allocated %d bytes, want about %d", allocated, encodedLen)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/cmd/sops/common"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/getsops/sops/v3/config"
+	"github.com/getsops/sops/v3/version"
+	"github.com/pkg/errors"
+)
+
+// runEncrypt implements the encrypt subcommand.
+func runEncrypt(args []string) error {
+	flags := newFlagSet("encrypt")
+	confPath := flags.String("config", "", "`.sops.yaml` with the creation rules (default: nearest to the output file)")
+	generatorPath := flags.String("generator", "", "generator `manifest` that must reference the output file, and whose policy must be met")
+	force := flags.Bool("force", false, "overwrite the output file if it exists")
+	fake := flags.Bool("fake", false, "create a fixture for fake decryption, which is not secret")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errors.New("expected a plaintext file and an output file")
+	}
+	input, output := flags.Arg(0), flags.Arg(1)
+
+	if !*force {
+		_, err = os.Stat(output)
+		if err == nil {
+			return errors.Errorf("%s already exists, use --force to overwrite it", output)
+		}
+	}
+
+	var policy Policy
+	if *generatorPath != "" {
+		generator, err := referencingGenerator(*generatorPath, output)
+		if err != nil {
+			return err
+		}
+		policy = generator.Policy
+	}
+
+	err = encryptFile(input, output, *confPath, policy, *fake)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("encrypted %s\n", output)
+	return nil
+}
+
+// referencingGenerator returns the generator in a manifest that references
+// the given file.
+func referencingGenerator(manifest string, fileName string) (SopsSecretGenerator, error) {
+	generators, err := readGenerators(manifest)
+	if err != nil {
+		return SopsSecretGenerator{}, errors.Wrapf(err, "could not read generator %s", manifest)
+	}
+	absPath, err := filepath.Abs(fileName)
+	if err != nil {
+		return SopsSecretGenerator{}, err
+	}
+	for _, g := range generators {
+		sources, err := g.sourceFiles()
+		if err != nil {
+			return SopsSecretGenerator{}, errors.Wrap(err, manifest)
+		}
+		for i := range sources {
+			sources[i], _ = filepath.Abs(sources[i])
+		}
+		if slices.Contains(sources, absPath) {
+			return g.Generator, nil
+		}
+	}
+	return SopsSecretGenerator{}, errors.Errorf("%s is not referenced by a generator in %s", fileName, manifest)
+}
+
+// encryptFile encrypts a plaintext file to the key groups of the creation rule
+// for the output file. The format is taken from the name of the output file, so
+// the plaintext must already be in that format. The encrypted file must satisfy
+// the policy before it is written. If fake is set, the file is encrypted as a
+// fixture for fake decryption instead, without creation rules or a policy.
+func encryptFile(input string, output string, confPath string, policy Policy, fake bool) error {
+	plaintext, err := os.ReadFile(input)
+	if err != nil {
+		return errors.Wrap(err, "could not read file")
+	}
+	defer wipe(plaintext)
+	return encryptPlaintext(plaintext, input, output, confPath, policy, fake)
+}
+
+// encryptPlaintext encrypts plaintext like encryptFile; the name of the input
+// is only used in errors.
+func encryptPlaintext(plaintext []byte, input string, output string, confPath string, policy Policy, fake bool) error {
+	store := common.StoreForFormat(formats.FormatForPath(output), config.NewStoresConfig())
+	branches, err := store.LoadPlainFile(plaintext)
+	if err != nil {
+		return errors.Wrapf(err, "could not parse %s", input)
+	}
+	absPath, err := filepath.Abs(output)
+	if err != nil {
+		return err
+	}
+	tree := &sops.Tree{
+		Branches: branches,
+		Metadata: sops.Metadata{Version: version.Version},
+		FilePath: absPath,
+	}
+	if fake {
+		err = encryptFakeTree(tree)
+		if err != nil {
+			return err
+		}
+		return writeEncryptedTree(output, tree, store)
+	}
+
+	rule, _, err := loadCreationRule(output, confPath)
+	if err != nil {
+		return err
+	}
+	tree.Metadata = sops.Metadata{
+		KeyGroups:               rule.KeyGroups,
+		ShamirThreshold:         rule.ShamirThreshold,
+		UnencryptedSuffix:       rule.UnencryptedSuffix,
+		EncryptedSuffix:         rule.EncryptedSuffix,
+		UnencryptedRegex:        rule.UnencryptedRegex,
+		EncryptedRegex:          rule.EncryptedRegex,
+		UnencryptedCommentRegex: rule.UnencryptedCommentRegex,
+		EncryptedCommentRegex:   rule.EncryptedCommentRegex,
+		MACOnlyEncrypted:        rule.MACOnlyEncrypted,
+		Version:                 version.Version,
+	}
+	err = policy.check(tree.Metadata)
+	if err != nil {
+		return err
+	}
+
+	err = encryptTree(tree)
+	if err != nil {
+		return err
+	}
+	return writeEncryptedTree(output, tree, store)
+}
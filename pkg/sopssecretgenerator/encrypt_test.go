@@ -0,0 +1,92 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_encryptFile(t *testing.T) {
+	setupEncryptionKeyring(t)
+	sopsConfig := "creation_rules:\n  - path_regex: \\.(env|yaml|txt)$\n    pgp: " + testkeyFingerprint + "\n"
+
+	tests := []struct {
+		name      string
+		plaintext string
+		output    string
+		policy    Policy
+		want      string
+		wantErr   bool
+	}{
+		{"Dotenv", "VAR=value\n", "secret.env", Policy{}, "VAR=value", false},
+		{"YAML", "VAR: value\n", "secret.yaml", Policy{}, "VAR: value", false},
+		{"Binary", "secret\n", "secret.txt", Policy{}, "secret\n", false},
+		{"Allowed", "VAR=value\n", "secret.env", Policy{AllowedRecipients: []string{testkeyFingerprint}}, "VAR=value", false},
+		{"Denied", "VAR=value\n", "secret.env", Policy{AllowedRecipients: []string{testAgeRecipient}}, "", true},
+		{"InvalidYAML", "{", "secret.yaml", Policy{}, "", true},
+		{"NoRule", "VAR=value\n", "secret.json", Policy{}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFile(t, filepath.Join(dir, ".sops.yaml"), sopsConfig)
+			input := filepath.Join(dir, "plaintext")
+			writeTestFile(t, input, tt.plaintext)
+			output := filepath.Join(dir, tt.output)
+
+			err := encryptFile(input, output, "", tt.policy, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("encryptFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if _, statErr := os.Stat(output); !os.IsNotExist(statErr) {
+					t.Errorf("encryptFile() wrote output on error")
+				}
+				return
+			}
+			decrypted, err := decryptFile(output, decryptOptions{})
+			if err != nil {
+				t.Fatalf("decryptFile() error = %v", err)
+			}
+			if !strings.Contains(string(decrypted), tt.want) {
+				t.Errorf("decryptFile() = %q, want it to contain %q", decrypted, tt.want)
+			}
+		})
+	}
+}
+
+func Test_referencingGenerator(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "generator.yaml")
+	writeTestFile(t, manifest, `
+apiVersion: kustomize.freightdog.com/v1
+kind: SopsSecretGenerator
+metadata:
+  name: secret
+envs:
+  - secret.env
+`)
+	tests := []struct {
+		name     string
+		fileName string
+		wantErr  bool
+	}{
+		{"Referenced", filepath.Join(dir, "secret.env"), false},
+		{"NotReferenced", filepath.Join(dir, "other.env"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := referencingGenerator(manifest, tt.fileName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("referencingGenerator() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Name != "secret" {
+				t.Errorf("referencingGenerator() = %v, want generator secret", got.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/cmd/sops/codes"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/pkg/errors"
+)
+
+// Errors returned by Generate, to check for with errors.Is. The messages of
+// the returned errors describe the failure in more detail.
+var (
+	// ErrInvalidGenerator is returned for a generator with an invalid
+	// apiVersion, kind, name or field.
+	ErrInvalidGenerator = errors.New("invalid generator")
+	// ErrNotEncrypted is returned for a source file without sops metadata.
+	ErrNotEncrypted = errors.New("file is not encrypted with sops")
+	// ErrUnknownFormat is returned for an env source that is not dotenv,
+	// YAML or JSON.
+	ErrUnknownFormat = errors.New("unknown file format")
+	// ErrKeyDenied is returned when none of the keys of a file could decrypt
+	// its data key, because they are not available or access was denied.
+	ErrKeyDenied = errors.New("no key could decrypt the file")
+	// ErrInvalidSecret is returned for a generated Secret that the API
+	// server would reject, such as one with an invalid key.
+	ErrInvalidSecret = errors.New("invalid Secret")
+)
+
+// causeError marks an error with one of the exported errors, keeping its
+// message.
+type causeError struct {
+	cause error
+	err   error
+}
+
+// Error returns the message of the marked error.
+func (e causeError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the exported error and the marked error.
+func (e causeError) Unwrap() []error {
+	return []error{e.cause, e.err}
+}
+
+// withCause marks err with one of the exported errors. It returns nil if err
+// is nil.
+func withCause(cause error, err error) error {
+	if err == nil || errors.Is(err, cause) {
+		return err
+	}
+	return causeError{cause: cause, err: err}
+}
+
+// sourceErrors are the errors of all failing sources of a generator, so that
+// a run reports every one of them instead of only the first.
+type sourceErrors []error
+
+// Error returns the messages of the errors, separated by semicolons.
+func (e sourceErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap returns the errors, so that errors.Is finds the exported errors of
+// any of them.
+func (e sourceErrors) Unwrap() []error {
+	return e
+}
+
+// joinSourceErrors returns the errors of several sources as one error,
+// flattening nested sourceErrors. It returns nil if there are none, and the
+// error itself if there is one.
+func joinSourceErrors(errs []error) error {
+	var joined sourceErrors
+	for _, err := range errs {
+		if nested, ok := err.(sourceErrors); ok {
+			joined = append(joined, nested...)
+		} else if err != nil {
+			joined = append(joined, err)
+		}
+	}
+	switch len(joined) {
+	case 0:
+		return nil
+	case 1:
+		return joined[0]
+	}
+	return joined
+}
+
+// decryptError marks an error of a Decryptor with the exported error for its
+// cause, if it has one that sops reports.
+func decryptError(err error) error {
+	var exitErr interface{ ExitCode() int }
+	var userErr sops.UserError
+	switch {
+	case errors.Is(err, sops.MetadataNotFound):
+		return withCause(ErrNotEncrypted, err)
+	// sops reports that no key group yielded the data key as a UserError,
+	// or as an exit error when decrypting with key services.
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == codes.CouldNotRetrieveKey,
+		errors.As(err, &userErr):
+		return withCause(ErrKeyDenied, err)
+	}
+	return err
+}
+
+// dotenvMetadata matches the metadata lines of an encrypted dotenv file
+var dotenvMetadata = regexp.MustCompile(`(?m)^sops_`)
+
+// loadError marks an error of loading an encrypted file with ErrNotEncrypted
+// if the file has no sops metadata. The YAML and JSON stores report missing
+// metadata themselves, but the dotenv and binary stores fail to parse it.
+func loadError(err error, content []byte, format formats.Format) error {
+	notEncrypted := errors.Is(err, sops.MetadataNotFound)
+	switch format {
+	case formats.Dotenv:
+		notEncrypted = notEncrypted || !dotenvMetadata.Match(content)
+	case formats.Binary:
+		var file map[string]json.RawMessage
+		notEncrypted = notEncrypted || json.Unmarshal(content, &file) != nil || file["sops"] == nil
+	}
+	if notEncrypted {
+		return withCause(ErrNotEncrypted, err)
+	}
+	return err
+}
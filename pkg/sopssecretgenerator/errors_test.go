@@ -0,0 +1,104 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerate_Errors(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"plain.env":  "VAR=value\n",
+		"plain.yaml": "VAR: value\n",
+		"plain.json": "{\"VAR\": \"value\"}\n",
+	} {
+		writeTestFile(t, filepath.Join(dir, name), content)
+	}
+	generator := func(envs []string, files []string) SopsSecretGenerator {
+		return SopsSecretGenerator{
+			TypeMeta:    TypeMeta{APIVersion: apiVersion, Kind: kind},
+			ObjectMeta:  ObjectMeta{Name: "my-secret"},
+			EnvSources:  envs,
+			FileSources: files,
+		}
+	}
+	noName := generator(nil, []string{"testdata/file.txt"})
+	noName.Name = ""
+	invalidTimeout := generator(nil, []string{"testdata/file.txt"})
+	invalidTimeout.Timeout = "soon"
+
+	tests := []struct {
+		name         string
+		spec         SopsSecretGenerator
+		opts         Options
+		emptyKeyring bool
+		want         error
+	}{
+		{"NoName", noName, Options{}, false, ErrInvalidGenerator},
+		{"InvalidTimeout", invalidTimeout, Options{}, false, ErrInvalidGenerator},
+		{"PlainFile", generator(nil, []string{"testdata/notyaml.txt"}), Options{}, false, ErrNotEncrypted},
+		{"PlainDotenv", generator([]string{filepath.Join(dir, "plain.env")}, nil), Options{}, false, ErrNotEncrypted},
+		{"PlainYAML", generator([]string{filepath.Join(dir, "plain.yaml")}, nil), Options{}, false, ErrNotEncrypted},
+		{"PlainJSON", generator([]string{filepath.Join(dir, "plain.json")}, nil), Options{}, false, ErrNotEncrypted},
+		{"UnknownFormat", generator([]string{"testdata/file.txt"}, nil), Options{}, false, ErrUnknownFormat},
+		{"KeyDenied", generator(nil, []string{"testdata/file.txt"}), Options{}, true, ErrKeyDenied},
+		{"KeyDeniedKeyServices", generator(nil, []string{"testdata/file.txt"}), Options{Decryptor: KeyServiceDecryptor{}}, false, ErrKeyDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.emptyKeyring {
+				t.Setenv("GNUPGHOME", t.TempDir())
+			}
+			_, err := Generate(context.Background(), tt.spec, tt.opts)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Generate() error = %v, want %v", err, tt.want)
+			}
+			for _, other := range []error{ErrInvalidGenerator, ErrNotEncrypted, ErrUnknownFormat, ErrKeyDenied} {
+				if other != tt.want && errors.Is(err, other) {
+					t.Errorf("Generate() error = %v, is also %v", err, other)
+				}
+			}
+		})
+	}
+}
+
+func Test_withCause(t *testing.T) {
+	err := errors.New("input must contain metadata.name value")
+	got := withCause(ErrInvalidGenerator, err)
+	if got.Error() != err.Error() {
+		t.Errorf("withCause() message = %q, want %q", got, err)
+	}
+	if !errors.Is(got, ErrInvalidGenerator) || !errors.Is(got, err) {
+		t.Errorf("withCause() = %v, want both errors", got)
+	}
+	if withCause(ErrInvalidGenerator, nil) != nil {
+		t.Errorf("withCause() of nil is not nil")
+	}
+	if again := withCause(ErrInvalidGenerator, got); again != got {
+		t.Errorf("withCause() marked an error twice")
+	}
+}
+
+func Test_joinSourceErrors(t *testing.T) {
+	first := errors.New("first")
+	second := withCause(ErrNotEncrypted, errors.New("second"))
+	third := errors.New("third")
+	if joinSourceErrors(nil) != nil {
+		t.Error("joinSourceErrors() of no errors is not nil")
+	}
+	if got := joinSourceErrors([]error{nil, first}); got != first {
+		t.Errorf("joinSourceErrors() = %v, want %v", got, first)
+	}
+	got := joinSourceErrors([]error{first, joinSourceErrors([]error{second, third})})
+	if got.Error() != "first; second; third" {
+		t.Errorf("joinSourceErrors() message = %q", got)
+	}
+	if !errors.Is(got, ErrNotEncrypted) || !errors.Is(got, third) {
+		t.Errorf("joinSourceErrors() = %v, want all errors", got)
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/pkg/errors"
+)
+
+// Types of the events that --events=ndjson emits
+const (
+	eventGeneratorStarted = "generator_started"
+	eventFileDecrypted    = "file_decrypted"
+	eventSecretEmitted    = "secret_emitted"
+	eventWarning          = "warning"
+	eventError            = "error"
+)
+
+// event is a line of the event stream. Fields that do not apply to the type
+// are left out. Events never include decrypted values.
+type event struct {
+	Time       string            `json:"time"`
+	Type       string            `json:"type"`
+	Generator  string            `json:"generator,omitempty"`
+	File       string            `json:"file,omitempty"`
+	Secret     string            `json:"secret,omitempty"`
+	Namespace  string            `json:"namespace,omitempty"`
+	Keys       int               `json:"keys,omitempty"`
+	DurationMS int64             `json:"durationMs,omitempty"`
+	Cached     bool              `json:"cached,omitempty"`
+	Message    string            `json:"message,omitempty"`
+	Attrs      map[string]string `json:"attrs,omitempty"`
+}
+
+// events writes events as newline-delimited JSON, one object per line. A nil
+// *events emits nothing, which is the default.
+type events struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// invocationEvents receives the events of the invocation, if enabled with
+// --events=ndjson or SOPS_SECRETGEN_EVENTS
+var invocationEvents *events
+
+// newEvents returns an event stream on a writer.
+func newEvents(w io.Writer) *events {
+	return &events{w: w}
+}
+
+// openEvents returns the event stream of the options: stderr, or the file of
+// --events-file, which may be a file descriptor such as /dev/fd/3. The file
+// stays open until the command exits, since events are written unbuffered.
+func openEvents(o Options) (*events, error) {
+	if o.Events == "" {
+		return nil, nil
+	}
+	if o.EventsFile == "" {
+		return newEvents(os.Stderr), nil
+	}
+	f, err := os.OpenFile(o.EventsFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open the events file")
+	}
+	return newEvents(f), nil
+}
+
+// emit writes an event with the current time. Write errors are ignored, so
+// that a closed reader does not fail the build.
+func (e *events) emit(ev event) {
+	if e == nil {
+		return
+	}
+	ev.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, _ = e.w.Write(append(line, '\n'))
+}
+
+// secretEvent returns the event for a Secret that a generator emitted, with
+// the number of its keys.
+func secretEvent(generator string, secret *fn.KubeObject) event {
+	data, _, _ := secret.NestedStringMap("data")
+	stringData, _, _ := secret.NestedStringMap("stringData")
+	return event{
+		Type:      eventSecretEmitted,
+		Generator: generator,
+		Secret:    secret.GetName(),
+		Namespace: secret.GetNamespace(),
+		Keys:      len(data) + len(stringData),
+	}
+}
+
+// toStderr reports whether the events are written to stderr, where they would
+// be garbled by the progress line.
+func (e *events) toStderr() bool {
+	return e != nil && e.w == os.Stderr
+}
+
+// eventHandler is a log handler that emits warnings and errors as events, in
+// addition to passing records on to the handler it wraps. Warnings are
+// emitted even if the log level hides them.
+type eventHandler struct {
+	handler slog.Handler
+	events  *events
+	attrs   []slog.Attr
+}
+
+// withEvents returns a logger that also emits its warnings and errors as
+// events, or the logger itself if events are disabled.
+func withEvents(logger *slog.Logger, e *events) *slog.Logger {
+	if e == nil {
+		return logger
+	}
+	if logger == nil {
+		logger = discardLogger
+	}
+	return slog.New(eventHandler{handler: logger.Handler(), events: e})
+}
+
+// Enabled reports whether records of the level are logged or emitted.
+func (h eventHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelWarn || h.handler.Enabled(ctx, level)
+}
+
+// Handle emits warnings and errors, and logs the record if its level is
+// enabled.
+func (h eventHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelWarn {
+		ev := event{Type: eventWarning, Message: r.Message}
+		if r.Level >= slog.LevelError {
+			ev.Type = eventError
+		}
+		addAttr := func(a slog.Attr) bool {
+			if a.Key == "generator" {
+				ev.Generator = a.Value.String()
+				return true
+			}
+			if ev.Attrs == nil {
+				ev.Attrs = map[string]string{}
+			}
+			ev.Attrs[a.Key] = a.Value.String()
+			return true
+		}
+		for _, a := range h.attrs {
+			addAttr(a)
+		}
+		r.Attrs(addAttr)
+		h.events.emit(ev)
+	}
+	if !h.handler.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a handler whose records, and events, have the attributes.
+func (h eventHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return eventHandler{
+		handler: h.handler.WithAttrs(attrs),
+		events:  h.events,
+		attrs:   append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...),
+	}
+}
+
+// WithGroup returns a handler whose records are in the group. Events are not
+// grouped.
+func (h eventHandler) WithGroup(name string) slog.Handler {
+	return eventHandler{handler: h.handler.WithGroup(name), events: h.events, attrs: h.attrs}
+}
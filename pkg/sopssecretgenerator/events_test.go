@@ -0,0 +1,127 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+)
+
+// readEvents decodes the lines of an event stream, without their times.
+func readEvents(t *testing.T, out string) []event {
+	t.Helper()
+	var got []event
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		var ev event
+		err := json.Unmarshal([]byte(line), &ev)
+		if err != nil {
+			t.Fatalf("invalid event %q: %v", line, err)
+		}
+		if ev.Time == "" {
+			t.Errorf("event %q has no time", line)
+		}
+		ev.Time = ""
+		got = append(got, ev)
+	}
+	return got
+}
+
+func Test_events_emit(t *testing.T) {
+	var out bytes.Buffer
+	e := newEvents(&out)
+	e.emit(event{Type: eventFileDecrypted, Generator: "my-secret", File: "vars.env", DurationMS: 12})
+	line := out.String()
+	if !strings.HasPrefix(line, `{"time":"`) || !strings.HasSuffix(line, `"type":"file_decrypted","generator":"my-secret","file":"vars.env","durationMs":12}`+"\n") {
+		t.Errorf("emit() wrote %q", line)
+	}
+
+	var none *events
+	none.emit(event{Type: eventWarning})
+	if none.toStderr() {
+		t.Errorf("toStderr() of nil events = true")
+	}
+}
+
+func Test_openEvents(t *testing.T) {
+	e, err := openEvents(Options{})
+	if err != nil || e != nil {
+		t.Errorf("openEvents() without events = %v, %v, want nil", e, err)
+	}
+	e, err = openEvents(Options{Events: "ndjson"})
+	if err != nil || !e.toStderr() {
+		t.Errorf("openEvents() = %v, %v, want stderr", e, err)
+	}
+	e, err = openEvents(Options{Events: "ndjson", EventsFile: filepath.Join(t.TempDir(), "events.ndjson")})
+	if err != nil || e.toStderr() {
+		t.Errorf("openEvents() with a file = %v, %v", e, err)
+	}
+	_, err = openEvents(Options{Events: "ndjson", EventsFile: filepath.Join(t.TempDir(), "missing", "events.ndjson")})
+	if err == nil {
+		t.Errorf("openEvents() with a missing directory succeeded")
+	}
+}
+
+func Test_withEvents(t *testing.T) {
+	var out, logs bytes.Buffer
+	e := newEvents(&out)
+	logger := withEvents(newLogger(&logs, slog.LevelError), e)
+	logger.Info("generated Secret", "generator", "my-secret")
+	logger.With("generator", "my-secret").Warn("skipped missing file", "file", "vars.env")
+	logger.Error("could not generate Secrets", "error", "no key")
+
+	got := readEvents(t, out.String())
+	want := []event{
+		{Type: eventWarning, Generator: "my-secret", Message: "skipped missing file", Attrs: map[string]string{"file": "vars.env"}},
+		{Type: eventError, Message: "could not generate Secrets", Attrs: map[string]string{"error": "no key"}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("withEvents() emitted %v, want %v", got, want)
+	}
+	for i := range want {
+		gotJSON, _ := json.Marshal(got[i])
+		wantJSON, _ := json.Marshal(want[i])
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("event %d = %s, want %s", i, gotJSON, wantJSON)
+		}
+	}
+	if strings.Contains(logs.String(), "skipped missing file") || !strings.Contains(logs.String(), "could not generate Secrets") {
+		t.Errorf("withEvents() logged %q, want only the error", logs.String())
+	}
+
+	plain := newLogger(&logs, slog.LevelWarn)
+	if withEvents(plain, nil) != plain {
+		t.Errorf("withEvents() without events replaced the logger")
+	}
+}
+
+func Test_secretEvent(t *testing.T) {
+	secret, err := fn.ParseKubeObject([]byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: my-secret-abc
+  namespace: apps
+data:
+  a: YQ==
+  b: Yg==
+stringData:
+  c: c
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := secretEvent("my-secret", secret)
+	want := event{Type: eventSecretEmitted, Generator: "my-secret", Secret: "my-secret-abc", Namespace: "apps", Keys: 3}
+	if got.Type != want.Type || got.Generator != want.Generator || got.Secret != want.Secret || got.Namespace != want.Namespace || got.Keys != want.Keys {
+		t.Errorf("secretEvent() = %+v, want %+v", got, want)
+	}
+}
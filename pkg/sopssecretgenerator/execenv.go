@@ -0,0 +1,115 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"encoding/base64"
+	"os"
+	"os/exec"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// runExecEnv implements the exec-env subcommand.
+func runExecEnv(args []string) error {
+	flags := newFlagSet("exec-env")
+	name := flags.String("name", "", "`name` of the generator, if the manifest contains more than one")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	command := flags.Args()
+	if len(command) > 0 {
+		command = command[1:]
+	}
+	if len(command) > 0 && command[0] == "--" {
+		command = command[1:]
+	}
+	if len(command) == 0 {
+		flags.Usage()
+		return errors.New("expected a generator manifest and a command")
+	}
+
+	generator, err := selectGenerator(flags.Arg(0), *name)
+	if err != nil {
+		return err
+	}
+	env, err := generatorEnv(generator)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// selectGenerator returns the generator with the given name from a manifest.
+// The name may be empty if the manifest contains a single generator.
+func selectGenerator(manifest string, name string) (generatorFile, error) {
+	generators, err := readGenerators(manifest)
+	if err != nil {
+		return generatorFile{}, errors.Wrapf(err, "could not read generator %s", manifest)
+	}
+	if name == "" {
+		if len(generators) != 1 {
+			return generatorFile{}, errors.Errorf("%s contains %d generators, select one with --name", manifest, len(generators))
+		}
+		return generators[0], nil
+	}
+	for _, g := range generators {
+		if g.Generator.Name == name {
+			return g, nil
+		}
+	}
+	return generatorFile{}, errors.Errorf("%s contains no generator named %s", manifest, name)
+}
+
+// generatorEnv decrypts the env sources of a generator into environment
+// variables, in "NAME=value" form and sorted by name. File sources are not
+// included.
+func generatorEnv(g generatorFile) ([]string, error) {
+	generator, err := applyVariant(g.Generator, activeVariant(g.Generator, runtimeSettings))
+	if err != nil {
+		return nil, err
+	}
+	g.Generator = generator
+	opts, err := newDecryptOptions(g.Generator, runtimeSettings)
+	if err != nil {
+		return nil, err
+	}
+	opts.Context = invocationContext
+	var sources []string
+	for _, source := range g.Generator.EnvSources {
+		resolved, err := g.resolveSource(source)
+		if err != nil {
+			return nil, errors.Wrapf(err, "env source \"%s\"", source)
+		}
+		sources = append(sources, resolved)
+	}
+	opts.Prefetched = prefetchFiles(sources, opts)
+	defer opts.Prefetched.wipe()
+
+	data := make(kvMap)
+	err = parseEnvSources(sources, opts, data)
+	if err != nil {
+		return nil, err
+	}
+
+	env := make([]string, 0, len(data))
+	for name, encoded := range data {
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, name+"="+string(value))
+		wipe(value)
+	}
+	sort.Strings(env)
+	return env, nil
+}
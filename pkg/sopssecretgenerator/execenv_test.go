@@ -0,0 +1,76 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_selectGenerator(t *testing.T) {
+	dir := t.TempDir()
+	single := filepath.Join(dir, "single.yaml")
+	writeTestFile(t, single, "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: one\n")
+	multiple := filepath.Join(dir, "multiple.yaml")
+	writeTestFile(t, multiple, "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: one\n"+
+		"---\napiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: two\n")
+
+	tests := []struct {
+		name     string
+		manifest string
+		genName  string
+		want     string
+		wantErr  bool
+	}{
+		{"Single", single, "", "one", false},
+		{"SingleByName", single, "one", "one", false},
+		{"MultipleByName", multiple, "two", "two", false},
+		{"MultipleWithoutName", multiple, "", "", true},
+		{"UnknownName", multiple, "three", "", true},
+		{"Missing", filepath.Join(dir, "missing.yaml"), "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectGenerator(tt.manifest, tt.genName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectGenerator() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Generator.Name != tt.want {
+				t.Errorf("selectGenerator() = %v, want %v", got.Generator.Name, tt.want)
+			}
+		})
+	}
+}
+
+func Test_generatorEnv(t *testing.T) {
+	generator := func(envs ...string) generatorFile {
+		return generatorFile{
+			Path:      "testdata/generator.yaml",
+			Generator: SopsSecretGenerator{EnvSources: envs, FileSources: []string{"file.txt"}},
+		}
+	}
+	tests := []struct {
+		name      string
+		generator generatorFile
+		want      []string
+		wantErr   bool
+	}{
+		{"Env", generator("vars.env"), []string{"VAR_ENV=val_env"}, false},
+		{"Multiple", generator("vars.yaml", "vars.env"), []string{"VAR_ENV=val_env", "VAR_YAML=val_yaml"}, false},
+		{"None", generator(), []string{}, false},
+		{"Missing", generator("missing.env"), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := generatorEnv(tt.generator)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("generatorEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("generatorEnv() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
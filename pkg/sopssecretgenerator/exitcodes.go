@@ -0,0 +1,71 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"encoding/json"
+	"io"
+	"io/fs"
+
+	"github.com/pkg/errors"
+)
+
+// Exit codes of the command, by the class of the error that failed it. They
+// are stable, so that scripts can react to them.
+const (
+	exitInternal = 1
+	exitConfig   = 2
+	exitDecrypt  = 3
+	exitIO       = 4
+)
+
+// Error classes, as reported in the code of an error envelope
+const (
+	errorClassInternal = "internal"
+	errorClassConfig   = "config"
+	errorClassDecrypt  = "decrypt"
+	errorClassIO       = "io"
+)
+
+// errFlags marks errors in the flags or environment variables of the
+// command, which are configuration errors
+var errFlags = errors.New("invalid flags")
+
+// errorEnvelope is an error as written with --error-format=json
+type errorEnvelope struct {
+	Error errorObject `json:"error"`
+}
+
+// errorObject describes an error for scripts: its class, the exit code that
+// goes with it, and its message.
+type errorObject struct {
+	Code     string `json:"code"`
+	ExitCode int    `json:"exitCode"`
+	Message  string `json:"message"`
+}
+
+// classifyError returns the class and exit code of an error. Invalid
+// generators, Secrets and flags are configuration errors, like sources in an
+// unknown format; sources that are not encrypted or that no key decrypts are
+// decryption errors; missing or unreadable files are IO errors. Anything
+// else is internal.
+func classifyError(err error) (string, int) {
+	var pathErr *fs.PathError
+	switch {
+	case errors.Is(err, ErrInvalidGenerator), errors.Is(err, ErrInvalidSecret), errors.Is(err, ErrUnknownFormat), errors.Is(err, errFlags):
+		return errorClassConfig, exitConfig
+	case errors.Is(err, ErrKeyDenied), errors.Is(err, ErrNotEncrypted):
+		return errorClassDecrypt, exitDecrypt
+	case errors.As(err, &pathErr), errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrPermission):
+		return errorClassIO, exitIO
+	}
+	return errorClassInternal, exitInternal
+}
+
+// writeErrorJSON writes an error as a JSON error envelope on a line of its
+// own.
+func writeErrorJSON(w io.Writer, err error) error {
+	class, code := classifyError(err)
+	return json.NewEncoder(w).Encode(errorEnvelope{Error: errorObject{Code: class, ExitCode: code, Message: err.Error()}})
+}
@@ -0,0 +1,55 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func Test_classifyError(t *testing.T) {
+	_, missing := os.ReadFile("testdata/missing.txt")
+	tests := []struct {
+		name      string
+		err       error
+		wantClass string
+		wantCode  int
+	}{
+		{"Generator", withCause(ErrInvalidGenerator, errors.New("input must contain metadata.name value")), errorClassConfig, exitConfig},
+		{"Flags", withCause(errFlags, errors.New("invalid --output")), errorClassConfig, exitConfig},
+		{"Format", errors.Wrap(ErrUnknownFormat, "env source \"vars.toml\""), errorClassConfig, exitConfig},
+		{"KeyDenied", errors.Wrap(withCause(ErrKeyDenied, errors.New("denied")), "file source \"file.txt\""), errorClassDecrypt, exitDecrypt},
+		{"NotEncrypted", withCause(ErrNotEncrypted, errors.New("no metadata")), errorClassDecrypt, exitDecrypt},
+		{"Missing", errors.Wrap(missing, "file source \"missing.txt\""), errorClassIO, exitIO},
+		{"Other", errors.New("could not encode"), errorClassInternal, exitInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			class, code := classifyError(tt.err)
+			if class != tt.wantClass || code != tt.wantCode {
+				t.Errorf("classifyError() = %s, %d, want %s, %d", class, code, tt.wantClass, tt.wantCode)
+			}
+		})
+	}
+}
+
+func Test_writeErrorJSON(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeErrorJSON(&buf, withCause(ErrKeyDenied, errors.New("no key could decrypt file.txt")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got errorEnvelope
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("writeErrorJSON() = %s, not JSON: %v", buf.String(), err)
+	}
+	want := errorObject{Code: "decrypt", ExitCode: 3, Message: "no key could decrypt file.txt"}
+	if got.Error != want {
+		t.Errorf("writeErrorJSON() = %+v, want %+v", got.Error, want)
+	}
+}
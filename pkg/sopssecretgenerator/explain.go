@@ -0,0 +1,322 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// explainWidth is the width that explain wraps descriptions at
+const explainWidth = 80
+
+// fieldDoc documents a field of the generator schema
+type fieldDoc struct {
+	description string
+	// example is a YAML snippet, indented as in a manifest
+	example string
+}
+
+// fieldDocs document the fields of the generator by path, such as
+// kms.regions. Elements of lists and values of maps share the path of the
+// field. The root is the empty path. Every field of SopsSecretGenerator must
+// be documented.
+var fieldDocs = map[string]fieldDoc{
+	"": {description: "SopsSecretGenerator generates a Secret from sops-encrypted env and file sources, and from secret stores, for Kustomize."},
+
+	"apiVersion":           {description: "The API version of the generator, kustomize.freightdog.com/v1."},
+	"kind":                 {description: "The kind of the generator, SopsSecretGenerator."},
+	"metadata":             {description: "The name, namespace, labels and annotations of the generated Secret."},
+	"metadata.name":        {description: "The name of the Secret. It may be a Go template, such as api-{{ env \"SLUG\" }}."},
+	"metadata.namespace":   {description: "The namespace of the Secret. SOPS_SECRETGEN_NAMESPACE overrides it."},
+	"metadata.labels":      {description: "Labels of the Secret."},
+	"metadata.annotations": {description: "Annotations of the Secret."},
+
+	"envs": {
+		description: "Encrypted dotenv, YAML, JSON, JSONC or two-column CSV files, each key of which becomes a key of the Secret. A [\"key\"] suffix extracts a single value.",
+		example:     "envs:\n  - secret-vars.env\n  - db.yaml",
+	},
+	"files": {
+		description: "Encrypted files that become a key each, named after the file or KEY= in front of it. Escape '=' in file names as \\=.",
+		example:     "files:\n  - keystore.p12\n  - tls.crt=certs/server.crt",
+	},
+	"behavior":              {description: "How the Secret is combined with a Secret of the same name: create, the default, merge or replace."},
+	"disableNameSuffixHash": {description: "Leave the content hash out of the name of the Secret."},
+	"type":                  {description: "The type of the Secret, Opaque if empty."},
+	"policy":                {description: "Requirements on how the source files are encrypted, checked before they are decrypted."},
+	"policy.allowedRecipients": {
+		description: "The only age recipients, PGP fingerprints and KMS keys that source files may be encrypted to.",
+	},
+	"policy.minKeyGroups":       {description: "The minimum number of sops key groups of every source file."},
+	"policy.minShamirThreshold": {description: "The minimum Shamir threshold of every source file."},
+	"policy.matchCreationRules": {description: "Require the key groups of the matching creation rule in the nearest .sops.yaml."},
+	"timeout":                   {description: "The maximum duration of decrypting each source file, such as 30s. Overrides SOPS_SECRETGEN_TIMEOUT."},
+	"offline":                   {description: "Only use local age and PGP keys, and never contact network key services such as KMS or Vault."},
+	"kms": {
+		description: "How AWS KMS keys in several regions are tried, and how network keys are retried.",
+		example:     "kms:\n  regions:\n    - eu-west-1\n  attemptTimeout: 5s\n  retries: 3",
+	},
+	"kms.regions":        {description: "Regions whose KMS keys are tried first, in order. Overrides SOPS_SECRETGEN_KMS_REGIONS."},
+	"kms.attemptTimeout": {description: "The maximum duration for each KMS key before the next is tried, such as 5s."},
+	"kms.retries":        {description: "How often a network key that fails with a throttling or server error is retried."},
+	"kms.retryBackoff":   {description: "The wait before the first retry, 500ms by default. It doubles with every retry."},
+	"maxFileSize":        {description: "The maximum size of each encrypted source file, such as 1Mi."},
+	"maxFileSizes":       {description: "Maximum sizes of individual source files, by path, overriding maxFileSize."},
+	"reloader":           {description: "Annotate the Secret so that Reloader restarts the workloads that use it."},
+	"replicateTo":        {description: "Namespaces, or patterns, that kubernetes-replicator copies the Secret to."},
+	"annotationPresets":  {description: "Annotations for the tools that sync the Secret, by preset name, such as argocd-no-prune."},
+	"provenance":         {description: "Annotate the Secret with the git commit of its sources and whether the working tree was dirty."},
+	"rotateAfter":        {description: "How long after sops last encrypted a source it is due for rotation, such as 90d, 12w or 2160h."},
+	"rotateStrict":       {description: "Fail instead of warning when a source is due for rotation."},
+	"extends":            {description: "A generator in the same manifest, by name, or the path of a manifest, whose fields this generator inherits."},
+	"variant":            {description: "The variant to generate if neither --variant nor SOPS_SECRETGEN_VARIANT is set."},
+	"variants": {
+		description: "Sources by variant name, which replace envs and files when the variant is generated.",
+		example:     "variants:\n  prod:\n    envs:\n      - prod.env",
+	},
+	"variants.envs":            {description: "The env sources of the variant."},
+	"variants.files":           {description: "The file sources of the variant."},
+	"allowEmpty":               {description: "Skip sources whose files do not exist, instead of failing."},
+	"when":                     {description: "A condition that must hold for the Secret to be generated."},
+	"when.env":                 {description: "An environment variable that must be set and not empty, or NAME=value for one that must have the value."},
+	"when.fileExists":          {description: "A path that must exist, relative to the working directory."},
+	"nameSuffixHash":           {description: "How the content hash in the name of the Secret is computed."},
+	"nameSuffixHash.algorithm": {description: "kustomize, for the hash that kustomize computes, or sha256."},
+	"nameSuffixHash.length":    {description: "The number of hex digits of a sha256 hash, 10 by default."},
+	"baseDir":                  {description: "The directory that relative sources resolve against, itself relative to the manifest."},
+	"clusterSources": {
+		description: "Encrypted files stored in a key of a Secret or ConfigMap in a cluster.",
+		example:     "clusterSources:\n  - name: shared-secrets\n    namespace: platform\n    key: db.env",
+	},
+	"clusterSources.kind":      {description: "Secret, the default, or ConfigMap."},
+	"clusterSources.name":      {description: "The name of the Secret or ConfigMap."},
+	"clusterSources.namespace": {description: "The namespace, that of the kubeconfig context if empty."},
+	"clusterSources.key":       {description: "The key that holds the encrypted file. Its extension gives the format."},
+	"clusterSources.context":   {description: "The kubeconfig context, the current one if empty."},
+	"vaultSources": {
+		description: "Secrets in a HashiCorp Vault KV engine, at VAULT_ADDR with VAULT_TOKEN.",
+		example:     "vaultSources:\n  - path: apps/web\n    keys:\n      - DB_PASSWORD",
+	},
+	"vaultSources.mount":     {description: "The path the KV engine is mounted at, secret if empty."},
+	"vaultSources.path":      {description: "The path of the secret in the engine."},
+	"vaultSources.kvVersion": {description: "The version of the KV engine, 1 or 2, the default."},
+	"vaultSources.version":   {description: "The version of the secret in a KV 2 engine, the latest if 0."},
+	"vaultSources.keys":      {description: "The keys of the secret to add, all of them if empty."},
+	"awsSecretsManager": {
+		description: "Secrets in AWS Secrets Manager. A secret is a JSON object of keys, unless key is set.",
+		example:     "awsSecretsManager:\n  - secretId: prod/web\n    region: eu-west-1",
+	},
+	"awsSecretsManager.secretId":     {description: "The name or ARN of the secret."},
+	"awsSecretsManager.key":          {description: "The key to add the whole secret as."},
+	"awsSecretsManager.versionStage": {description: "The staging label of the version, AWSCURRENT if empty."},
+	"awsSecretsManager.region":       {description: "The region, that of the ARN or the environment if empty."},
+	"awsSecretsManager.profile":      {description: "The AWS profile to use."},
+	"ssmParameters": {
+		description: "Parameters in AWS Systems Manager Parameter Store, decrypted.",
+		example:     "ssmParameters:\n  - name: /prod/web/db-password\n    key: DB_PASSWORD",
+	},
+	"ssmParameters.name":    {description: "The name or ARN of the parameter."},
+	"ssmParameters.key":     {description: "The key to add the parameter as, the last element of the name if empty."},
+	"ssmParameters.region":  {description: "The region, that of the ARN or the environment if empty."},
+	"ssmParameters.profile": {description: "The AWS profile to use."},
+	"gcpSecretSources": {
+		description: "Secrets in GCP Secret Manager.",
+		example:     "gcpSecretSources:\n  - name: projects/my-project/secrets/db-password",
+	},
+	"gcpSecretSources.name":     {description: "projects/*/secrets/*/versions/*, the latest version if the version is left out."},
+	"gcpSecretSources.key":      {description: "The key to add the secret as, the secret ID if empty."},
+	"gcpSecretSources.optional": {description: "Skip the source if the secret or version does not exist."},
+	"azureKeyVaultSources": {
+		description: "Secrets in Azure Key Vault.",
+		example:     "azureKeyVaultSources:\n  - vaultUri: https://myvault.vault.azure.net\n    secrets:\n      - DB_PASSWORD=db-password",
+	},
+	"azureKeyVaultSources.vaultUri": {description: "The URI of the vault."},
+	"azureKeyVaultSources.secrets":  {description: "The secrets to add, as NAME or KEY=NAME, with an optional /VERSION."},
+	"onePasswordSources": {
+		description: "Fields of 1Password items, by secret reference, read with the op CLI or 1Password Connect.",
+		example:     "onePasswordSources:\n  - DB_PASSWORD=op://prod/db/password",
+	},
+	"helmValues": {
+		description: "Encrypted Helm values files, merged in order into a single values.yaml key.",
+		example:     "helmValues:\n  files:\n    - values.enc.yaml",
+	},
+	"helmValues.key":   {description: "The key of the merged values, values.yaml if empty."},
+	"helmValues.files": {description: "The encrypted values files, in the order they are merged."},
+	"dockerConfigs": {
+		description: "Encrypted Docker configs, whose registries are merged into a single .dockerconfigjson key.",
+		example:     "dockerConfigs:\n  - ghcr.enc.json\n  - ecr.enc.json",
+	},
+	"outputKind": {description: "What the plugin outputs: Secret, the default, or EncryptedSecret."},
+	"stringData": {
+		description: "Keys that go in the stringData of the Secret, in plain text, instead of in its data.",
+		example:     "stringData:\n  keys:\n    - config.json",
+	},
+	"stringData.keys":     {description: "Keys from any source."},
+	"stringData.sources":  {description: "Env and file sources, as listed under envs and files, all of whose keys are strings."},
+	"caseInsensitiveKeys": {description: "Reject keys that differ only by case, which clash on case-insensitive file systems."},
+}
+
+// schemaField is a field of the generator schema
+type schemaField struct {
+	name string
+	path string
+	// typeName is the type as explain shows it, such as []string or Object
+	typeName string
+	// object is the struct type of the field or of its elements, if any
+	object reflect.Type
+}
+
+// runExplain implements the explain subcommand.
+func runExplain(args []string) error {
+	flags := newFlagSet("explain")
+	recursive := flags.Bool("recursive", false, "list all nested fields")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return errors.New("expected at most one field")
+	}
+	return explain(os.Stdout, flags.Arg(0), *recursive)
+}
+
+// explain writes the documentation of a field of the generator, given by
+// path, or of the generator itself if the path is empty, and lists its
+// fields.
+func explain(w io.Writer, path string, recursive bool) error {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, strings.ToLower(kind)+"."), kind+".")
+	object := reflect.TypeOf(SopsSecretGenerator{})
+	_, _ = fmt.Fprintf(w, "KIND:     %s\nVERSION:  %s\n\n", kind, apiVersion)
+	if path != "" {
+		field, err := lookupField(path)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(w, "FIELD: %s <%s>\n\n", field.path, field.typeName)
+		object = field.object
+	}
+
+	doc := fieldDocs[path]
+	_, _ = fmt.Fprintln(w, "DESCRIPTION:")
+	writeWrapped(w, doc.description, "    ")
+	if doc.example != "" {
+		_, _ = fmt.Fprintln(w, "\nEXAMPLE:")
+		for _, line := range strings.Split(doc.example, "\n") {
+			_, _ = fmt.Fprintf(w, "    %s\n", line)
+		}
+	}
+	if object == nil {
+		return nil
+	}
+	_, _ = fmt.Fprintln(w, "\nFIELDS:")
+	writeFields(w, object, path, "  ", recursive)
+	return nil
+}
+
+// writeFields lists the fields of a struct type with their descriptions, and
+// those of nested objects if recursive.
+func writeFields(w io.Writer, object reflect.Type, prefix string, indent string, recursive bool) {
+	for _, field := range schemaFields(object, prefix) {
+		_, _ = fmt.Fprintf(w, "%s%s\t<%s>\n", indent, field.name, field.typeName)
+		if recursive && field.object != nil {
+			writeFields(w, field.object, field.path, indent+"  ", recursive)
+			continue
+		}
+		writeWrapped(w, fieldDocs[field.path].description, indent+"  ")
+		_, _ = fmt.Fprintln(w)
+	}
+}
+
+// lookupField returns the field of the generator at a path.
+func lookupField(path string) (schemaField, error) {
+	object := reflect.TypeOf(SopsSecretGenerator{})
+	var found schemaField
+	prefix := ""
+	for _, name := range strings.Split(path, ".") {
+		if object == nil {
+			return schemaField{}, withCause(errFlags, errors.Errorf("field \"%s\" has no fields", prefix))
+		}
+		ok := false
+		for _, field := range schemaFields(object, prefix) {
+			if field.name == name {
+				found, ok = field, true
+				break
+			}
+		}
+		if !ok {
+			return schemaField{}, withCause(errFlags, errors.Errorf("field \"%s\" does not exist", path))
+		}
+		object = found.object
+		prefix = found.path
+	}
+	return found, nil
+}
+
+// schemaFields returns the fields of a struct type by their YAML names, with
+// the fields of inlined structs in place.
+func schemaFields(object reflect.Type, prefix string) []schemaField {
+	var fields []schemaField
+	for i := 0; i < object.NumField(); i++ {
+		f := object.Field(i)
+		name, options, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if options == "inline" {
+			fields = append(fields, schemaFields(f.Type, prefix)...)
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		typeName, nested := schemaType(f.Type)
+		fields = append(fields, schemaField{name: name, path: path, typeName: typeName, object: nested})
+	}
+	return fields
+}
+
+// schemaType returns the name of a type as explain shows it, and the struct
+// type of its value or elements, if any.
+func schemaType(t reflect.Type) (string, reflect.Type) {
+	switch t.Kind() {
+	case reflect.Struct:
+		return "Object", t
+	case reflect.Slice:
+		name, nested := schemaType(t.Elem())
+		return "[]" + name, nested
+	case reflect.Map:
+		name, nested := schemaType(t.Elem())
+		return "map[string]" + name, nested
+	case reflect.Bool:
+		return "boolean", nil
+	case reflect.Int, reflect.Int64:
+		return "integer", nil
+	}
+	return "string", nil
+}
+
+// writeWrapped writes text wrapped at explainWidth, with every line indented.
+func writeWrapped(w io.Writer, text string, indent string) {
+	line := indent
+	for _, word := range strings.Fields(text) {
+		if len(line) > len(indent) && len(line)+1+len(word) > explainWidth {
+			_, _ = fmt.Fprintln(w, line)
+			line = indent
+		}
+		if len(line) > len(indent) {
+			line += " "
+		}
+		line += word
+	}
+	if len(line) > len(indent) {
+		_, _ = fmt.Fprintln(w, line)
+	}
+}
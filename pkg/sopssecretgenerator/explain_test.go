@@ -0,0 +1,107 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_fieldDocs(t *testing.T) {
+	paths := map[string]bool{"": true}
+	var walk func(object reflect.Type, prefix string)
+	walk = func(object reflect.Type, prefix string) {
+		for _, field := range schemaFields(object, prefix) {
+			paths[field.path] = true
+			if _, ok := fieldDocs[field.path]; !ok {
+				t.Errorf("field %s is not documented", field.path)
+			}
+			if field.object != nil {
+				walk(field.object, field.path)
+			}
+		}
+	}
+	walk(reflect.TypeOf(SopsSecretGenerator{}), "")
+	for path := range fieldDocs {
+		if !paths[path] {
+			t.Errorf("documented field %s does not exist", path)
+		}
+	}
+}
+
+func Test_lookupField(t *testing.T) {
+	tests := []struct {
+		path     string
+		typeName string
+		wantErr  bool
+	}{
+		{"envs", "[]string", false},
+		{"metadata.labels", "map[string]string", false},
+		{"kms.regions", "[]string", false},
+		{"variants.envs", "[]string", false},
+		{"clusterSources", "[]Object", false},
+		{"nameSuffixHash.length", "integer", false},
+		{"offline", "boolean", false},
+		{"apiVersion", "string", false},
+		{"missing", "", true},
+		{"envs.name", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			field, err := lookupField(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lookupField() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if field.typeName != tt.typeName {
+				t.Errorf("lookupField() type = %s, want %s", field.typeName, tt.typeName)
+			}
+		})
+	}
+}
+
+func Test_explain(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		recursive bool
+		want      []string
+		notWant   []string
+	}{
+		{"Root", "", false, []string{"KIND:     SopsSecretGenerator", "FIELDS:", "  envs\t<[]string>", "  kms\t<Object>"}, []string{"    regions\t"}},
+		{"Recursive", "", true, []string{"  kms\t<Object>\n    regions\t<[]string>"}, nil},
+		{"Field", "kms", false, []string{"FIELD: kms <Object>", "EXAMPLE:\n    kms:\n      regions:", "  attemptTimeout\t<string>"}, nil},
+		{"KindPrefix", "sopssecretgenerator.kms.retries", false, []string{"FIELD: kms.retries <integer>"}, []string{"FIELDS:"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := explain(&out, tt.path, tt.recursive)
+			if err != nil {
+				t.Fatalf("explain() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("explain() = %q, want it to contain %q", out.String(), want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(out.String(), notWant) {
+					t.Errorf("explain() = %q, want it not to contain %q", out.String(), notWant)
+				}
+			}
+		})
+	}
+}
+
+func Test_writeWrapped(t *testing.T) {
+	var out bytes.Buffer
+	writeWrapped(&out, strings.Repeat("word ", 30), "  ")
+	for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+		if len(line) > explainWidth || !strings.HasPrefix(line, "  word") {
+			t.Errorf("writeWrapped() line = %q", line)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// defaultExportEnvFile is the file that export-env writes by default
+const defaultExportEnvFile = ".env.local"
+
+// exportEnvDirsVariable lists the directories that export-env may write to,
+// separated like PATH. The exportEnvDirs of a configuration file set it.
+const exportEnvDirsVariable = envPrefix + "EXPORT_ENV_DIRS"
+
+// plainEnvValue matches values that need no quotes in a .env file
+var plainEnvValue = regexp.MustCompile(`^[-A-Za-z0-9_./:@%+,=]*$`)
+
+// runExportEnv implements the export-env subcommand.
+func runExportEnv(args []string) error {
+	flags := newFlagSet("export-env")
+	name := flags.String("name", "", "`name` of the generator, if the manifest contains more than one")
+	out := flags.String("out", defaultExportEnvFile, "`file` to write the variables to")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	// Flags may also follow the manifest, as in export-env generator.yaml --out .env
+	manifest := flags.Arg(0)
+	if flags.NArg() > 1 {
+		err = flags.Parse(flags.Args()[1:])
+		if err != nil {
+			return err
+		}
+	}
+	if manifest == "" || flags.NArg() > 0 {
+		flags.Usage()
+		return errors.New("expected a generator manifest")
+	}
+
+	err = checkExportDir(*out, filepath.SplitList(os.Getenv(exportEnvDirsVariable)))
+	if err != nil {
+		return err
+	}
+	generator, err := selectGenerator(manifest, *name)
+	if err != nil {
+		return err
+	}
+	env, err := generatorEnv(generator)
+	if err != nil {
+		return err
+	}
+	// The file is ignored before it is written, so that it is never
+	// committed by accident, not even when writing it fails halfway.
+	ignored, err := ignoreFile(*out)
+	if err != nil {
+		runtimeSettings.logger().Warn("could not add the file to .gitignore, do not commit it", "file", *out, "error", err)
+	} else if ignored != "" {
+		_, _ = fmt.Fprintf(os.Stderr, "export-env: added %s to %s\n", filepath.Base(*out), ignored)
+	}
+	content := envFileContent(env, fmt.Sprintf("from %s (%s)", manifest, generator.Generator.Name))
+	defer wipe(content)
+	// An existing file is replaced, so that the secrets are never readable
+	// by others, whatever its mode was
+	err = writeFileAtomicPerm(*out, content, 0o600)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("wrote %d variables to %s\n", len(env), *out)
+	return nil
+}
+
+// checkExportDir checks that a file is in one of the allowed directories or
+// below it, so that decrypted secrets are only written where the team
+// expects them. Symbolic links in the directory are resolved, so that a link
+// cannot lead out of an allowed directory, and the file itself must not be
+// a symbolic link.
+func checkExportDir(file string, allowed []string) error {
+	if len(allowed) == 0 {
+		return withCause(errFlags, errors.Errorf("no directory allows exporting decrypted variables, list them in exportEnvDirs of %s or in %s", repoConfigFileName, exportEnvDirsVariable))
+	}
+	info, err := os.Lstat(file)
+	if err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return withCause(errFlags, errors.Errorf("%s is a symbolic link, export-env only writes regular files", file))
+	}
+	dir, err := resolvedPath(filepath.Dir(file))
+	if err != nil {
+		return err
+	}
+	for _, allowedDir := range allowed {
+		if allowedDir == "" {
+			continue
+		}
+		resolved, err := resolvedPath(allowedDir)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(resolved, dir)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	return withCause(errFlags, errors.Errorf("%s is not in a directory that allows exporting decrypted variables: %s", file, strings.Join(allowed, ", ")))
+}
+
+// resolvedPath returns the absolute path of a directory with symbolic links
+// resolved.
+func resolvedPath(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// envFileContent returns variables in "NAME=value" form as a .env file that
+// docker compose reads, with a header that warns against committing it.
+func envFileContent(env []string, origin string) []byte {
+	var b bytes.Buffer
+	b.WriteString("# Decrypted by SopsSecretGenerator export-env " + origin + ".\n")
+	b.WriteString("# Do not commit this file.\n")
+	for _, variable := range env {
+		name, value, _ := strings.Cut(variable, "=")
+		b.WriteString(name + "=" + quoteEnvValue(value) + "\n")
+	}
+	return b.Bytes()
+}
+
+// quoteEnvValue quotes a value for a .env file of docker compose: values
+// without special characters as they are, values without single quotes and
+// newlines in single quotes, which compose takes literally, and others in
+// double quotes with escapes. Compose interpolates $ in double quotes, so it
+// is doubled.
+func quoteEnvValue(value string) string {
+	if plainEnvValue.MatchString(value) {
+		return value
+	}
+	if !strings.ContainsAny(value, "'\n\r") {
+		return "'" + value + "'"
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "$", "$$")
+	return `"` + replacer.Replace(value) + `"`
+}
+
+// ignoreFile makes sure that git ignores a file, by adding it to the
+// .gitignore in its directory unless git already ignores it. It returns the
+// .gitignore it changed, or "" if the file was already ignored.
+func ignoreFile(file string) (string, error) {
+	dir := filepath.Dir(file)
+	if gitPath, err := exec.LookPath("git"); err == nil {
+		cmd := exec.Command(gitPath, "check-ignore", "-q", "--", filepath.Base(file))
+		cmd.Dir = dir
+		if cmd.Run() == nil {
+			return "", nil
+		}
+	}
+
+	gitignore := filepath.Join(dir, ".gitignore")
+	entry := "/" + filepath.Base(file)
+	content, err := os.ReadFile(gitignore)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == entry || line == filepath.Base(file) {
+			return "", nil
+		}
+	}
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		entry = "\n" + entry
+	}
+	f, err := os.OpenFile(gitignore, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.WriteString(entry + "\n")
+	closeErr := f.Close()
+	if err != nil {
+		return "", err
+	}
+	return gitignore, closeErr
+}
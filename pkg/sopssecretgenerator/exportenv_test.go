@@ -0,0 +1,137 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_quoteEnvValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"Plain", "postgres://db:5432/app", "postgres://db:5432/app"},
+		{"Empty", "", ""},
+		{"Space", "two words", "'two words'"},
+		{"Dollar", "pa$$word", "'pa$$word'"},
+		{"Hash", "a#b", "'a#b'"},
+		{"SingleQuote", `it's "x" $HOME`, `"it's \"x\" $$HOME"`},
+		{"Newline", "line1\nline2", `"line1\nline2"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quoteEnvValue(tt.value); got != tt.want {
+				t.Errorf("quoteEnvValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_checkExportDir(t *testing.T) {
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "dev")
+	other := filepath.Join(dir, "other")
+	for _, d := range []string{filepath.Join(allowed, "compose"), other} {
+		err := os.MkdirAll(d, 0o700)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := os.Symlink(other, filepath.Join(allowed, "escape"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink(filepath.Join(other, ".env.local"), filepath.Join(allowed, ".env.link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		allowed []string
+		wantErr bool
+	}{
+		{"Allowed", filepath.Join(allowed, ".env.local"), []string{allowed}, false},
+		{"Below", filepath.Join(allowed, "compose", ".env"), []string{other, allowed}, false},
+		{"Outside", filepath.Join(other, ".env.local"), []string{allowed}, true},
+		{"Prefix", filepath.Join(dir, "dev2", ".env"), []string{allowed}, true},
+		{"Symlink", filepath.Join(allowed, "escape", ".env.local"), []string{allowed}, true},
+		{"FileSymlink", filepath.Join(allowed, ".env.link"), []string{allowed}, true},
+		{"NoneAllowed", filepath.Join(allowed, ".env.local"), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkExportDir(tt.file, tt.allowed)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkExportDir() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_ignoreFile(t *testing.T) {
+	dir := t.TempDir()
+	gitignore := filepath.Join(dir, ".gitignore")
+	writeTestFile(t, gitignore, "node_modules")
+
+	changed, err := ignoreFile(filepath.Join(dir, ".env.local"))
+	if err != nil || changed != gitignore {
+		t.Fatalf("ignoreFile() = %v, %v, want %v", changed, err, gitignore)
+	}
+	changed, err = ignoreFile(filepath.Join(dir, ".env.local"))
+	if err != nil || changed != "" {
+		t.Errorf("ignoreFile() again = %v, %v, want no change", changed, err)
+	}
+	content, _ := os.ReadFile(gitignore)
+	if string(content) != "node_modules\n/.env.local\n" {
+		t.Errorf(".gitignore = %q", content)
+	}
+}
+
+func Test_runExportEnv(t *testing.T) {
+	dir := t.TempDir()
+	copyTestFile(t, "testdata/vars.env", filepath.Join(dir, "vars.env"))
+	manifest := filepath.Join(dir, "generator.yaml")
+	writeTestFile(t, manifest, "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: app\nenvs:\n  - vars.env\n")
+	out := filepath.Join(dir, ".env.local")
+
+	t.Setenv(exportEnvDirsVariable, "")
+	err := runExportEnv([]string{manifest, "--out", out})
+	if err == nil {
+		t.Fatal("runExportEnv() without allowed directories succeeded")
+	}
+
+	t.Setenv(exportEnvDirsVariable, dir)
+	// An existing file that others can read is replaced
+	writeTestFile(t, out, "")
+	err = os.Chmod(out, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = runExportEnv([]string{manifest, "--out", out})
+	if err != nil {
+		t.Fatalf("runExportEnv() error = %v", err)
+	}
+	content, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(content), "\nVAR_ENV=val_env\n") || !strings.HasPrefix(string(content), "# Decrypted by SopsSecretGenerator") {
+		t.Errorf("%s = %q", out, content)
+	}
+	info, _ := os.Stat(out)
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("%s mode = %v, want 0600", out, info.Mode().Perm())
+	}
+	gitignore, _ := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if string(gitignore) != "/.env.local\n" {
+		t.Errorf(".gitignore = %q", gitignore)
+	}
+}
@@ -0,0 +1,341 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// generatorFields is a generator manifest as a generic map, so that
+// generators can be merged by the fields that they set
+type generatorFields map[string]interface{}
+
+// resolveExtends returns a generator manifest with its extends field replaced
+// by the fields of the generator it extends. A base is either a generator
+// named in the same manifest or ResourceList, given as siblings by name, or
+// the generator in a manifest at a path relative to dir. The source of the
+// siblings identifies them to detect cycles. Manifests without extends are
+// returned as they are.
+func resolveExtends(document []byte, siblings map[string][]byte, source string, dir string) ([]byte, error) {
+	generator, err := decodeFields(document)
+	if err != nil {
+		return nil, withCause(ErrInvalidGenerator, err)
+	}
+	if _, ok := generator["extends"]; !ok {
+		return document, nil
+	}
+	seen := map[string]bool{source + "#" + generator.name(): true}
+	merged, err := extendGenerator(generator, siblings, source, dir, seen)
+	if err != nil {
+		return nil, withCause(ErrInvalidGenerator, errors.Wrapf(err, "generator %s", generator.name()))
+	}
+	return yaml.Marshal(merged)
+}
+
+// extendGenerator merges a generator into the generator it extends, after
+// resolving the extends of that generator in turn.
+func extendGenerator(generator generatorFields, siblings map[string][]byte, source string, dir string, seen map[string]bool) (generatorFields, error) {
+	extends, ok := generator["extends"]
+	if !ok {
+		return generator, nil
+	}
+	delete(generator, "extends")
+	ref, _ := extends.(string)
+	if ref == "" {
+		return nil, errors.New("extends must be the name of a generator or the path of a manifest")
+	}
+
+	var content []byte
+	prefix := ""
+	if isExtendsPath(ref) {
+		fileName := ref
+		if !filepath.IsAbs(fileName) {
+			fileName = filepath.Join(dir, fileName)
+		}
+		documents, err := manifestGenerators(fileName)
+		if err != nil {
+			return nil, errors.Wrap(err, "extends")
+		}
+		content, err = pickBase(documents, fileName, generator.name())
+		if err != nil {
+			return nil, err
+		}
+		siblings, source, dir, prefix = documents, filepath.Clean(fileName), filepath.Dir(fileName), filepath.Dir(ref)
+	} else {
+		content, ok = siblings[ref]
+		if !ok {
+			return nil, errors.Errorf("extends: no generator named %s", ref)
+		}
+	}
+
+	base, err := decodeFields(content)
+	if err != nil {
+		return nil, err
+	}
+	key := source + "#" + base.name()
+	if seen[key] {
+		return nil, errors.Errorf("extends: %s is part of a cycle", ref)
+	}
+	seen[key] = true
+	base, err = extendGenerator(base, siblings, source, dir, seen)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" && prefix != "." {
+		rebaseSources(base, prefix)
+	}
+	return mergeGenerators(base, generator), nil
+}
+
+// isExtendsPath reports whether the extends field of a generator is a path,
+// rather than the name of a generator.
+func isExtendsPath(ref string) bool {
+	return strings.ContainsAny(ref, `/\`) || isYAMLFile(ref)
+}
+
+// decodeFields decodes a generator manifest. Its nested mappings decode as
+// map[string]interface{} only when the outer one is of that type.
+func decodeFields(document []byte) (generatorFields, error) {
+	var fields map[string]interface{}
+	err := yaml.Unmarshal(document, &fields)
+	return fields, err
+}
+
+// name returns the name of a generator.
+func (g generatorFields) name() string {
+	metadata, _ := g["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	return name
+}
+
+// manifestGenerators returns the generators in a manifest by name.
+func manifestGenerators(fileName string) (map[string][]byte, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var documents [][]byte
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var node yaml.Node
+		err = decoder.Decode(&node)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, fileName)
+		}
+		nodes, err := generatorDocuments(&node)
+		if err != nil {
+			return nil, errors.Wrap(err, fileName)
+		}
+		for _, n := range nodes {
+			document, err := yaml.Marshal(n)
+			if err != nil {
+				return nil, err
+			}
+			documents = append(documents, document)
+		}
+	}
+	return generatorsByName(documents), nil
+}
+
+// generatorsByName indexes generator manifests by their name. If several
+// generators have the same name, the first one is used.
+func generatorsByName(documents [][]byte) map[string][]byte {
+	byName := make(map[string][]byte)
+	for _, document := range documents {
+		generator, err := decodeFields(document)
+		if err != nil {
+			continue
+		}
+		if _, ok := byName[generator.name()]; !ok {
+			byName[generator.name()] = document
+		}
+	}
+	return byName
+}
+
+// pickBase returns the generator of a manifest that another generator
+// extends: the only generator in it, or else the one with the same name.
+func pickBase(documents map[string][]byte, fileName string, name string) ([]byte, error) {
+	if len(documents) == 1 {
+		for _, document := range documents {
+			return document, nil
+		}
+	}
+	if document, ok := documents[name]; ok {
+		return document, nil
+	}
+	return nil, errors.Errorf("extends: %s has %d generators, and none is named %s", fileName, len(documents), name)
+}
+
+// mergeGenerators returns a base generator with the fields of an overlay.
+// Sources are appended, so that the overlay's keys override the base's;
+// labels and annotations are merged; other fields of the overlay replace the
+// base's.
+func mergeGenerators(base generatorFields, overlay generatorFields) generatorFields {
+	merged := make(generatorFields, len(base)+len(overlay))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overlay {
+		switch k {
+		case "envs", "files", "clusterSources", "vaultSources", "awsSecretsManager", "ssmParameters",
+			"gcpSecretSources", "azureKeyVaultSources", "onePasswordSources":
+			baseList, _ := base[k].([]interface{})
+			overlayList, _ := v.([]interface{})
+			merged[k] = append(append([]interface{}{}, baseList...), overlayList...)
+		case "metadata":
+			baseMeta, _ := base[k].(map[string]interface{})
+			overlayMeta, _ := v.(map[string]interface{})
+			merged[k] = mergeMetadata(baseMeta, overlayMeta)
+		default:
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// mergeMetadata merges the labels and annotations of two generators, and
+// takes the other metadata of the overlay.
+func mergeMetadata(base map[string]interface{}, overlay map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(overlay))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overlay {
+		if k != "labels" && k != "annotations" {
+			merged[k] = v
+			continue
+		}
+		values := make(map[string]interface{})
+		baseValues, _ := base[k].(map[string]interface{})
+		overlayValues, _ := v.(map[string]interface{})
+		for name, value := range baseValues {
+			values[name] = value
+		}
+		for name, value := range overlayValues {
+			values[name] = value
+		}
+		merged[k] = values
+	}
+	return merged
+}
+
+// rebaseSources makes the relative source paths of a generator from another
+// directory, including those of its variants, relative to the directory of
+// the generator that extends it. A generator with a baseDir has its baseDir
+// rebased instead.
+func rebaseSources(generator map[string]interface{}, prefix string) {
+	if baseDir, ok := generator["baseDir"].(string); ok && baseDir != "" {
+		if !filepath.IsAbs(baseDir) {
+			generator["baseDir"] = filepath.Join(prefix, baseDir)
+		}
+		return
+	}
+	if envs, ok := generator["envs"].([]interface{}); ok {
+		for i, env := range envs {
+			if source, ok := env.(string); ok {
+				envs[i] = rebaseSource(source, prefix)
+			}
+		}
+	}
+	if files, ok := generator["files"].([]interface{}); ok {
+		for i, file := range files {
+			if source, ok := file.(string); ok {
+				files[i] = rebaseFileSource(source, prefix)
+			}
+		}
+	}
+	if helmValues, ok := generator["helmValues"].(map[string]interface{}); ok {
+		if files, ok := helmValues["files"].([]interface{}); ok {
+			for i, file := range files {
+				if source, ok := file.(string); ok {
+					files[i] = rebaseSource(source, prefix)
+				}
+			}
+		}
+	}
+	if dockerConfigs, ok := generator["dockerConfigs"].([]interface{}); ok {
+		for i, config := range dockerConfigs {
+			if source, ok := config.(string); ok {
+				dockerConfigs[i] = rebaseSource(source, prefix)
+			}
+		}
+	}
+	if stringData, ok := generator["stringData"].(map[string]interface{}); ok {
+		if sources, ok := stringData["sources"].([]interface{}); ok {
+			for i, s := range sources {
+				if source, ok := s.(string); ok {
+					sources[i] = rebaseFileSource(source, prefix)
+				}
+			}
+		}
+	}
+	if variants, ok := generator["variants"].(map[string]interface{}); ok {
+		for _, variant := range variants {
+			if fields, ok := variant.(map[string]interface{}); ok {
+				rebaseSources(fields, prefix)
+			}
+		}
+	}
+}
+
+// rebaseSource prefixes the path of a relative env source with a directory,
+// keeping any extract suffix.
+func rebaseSource(source string, prefix string) string {
+	filePath, _, err := splitExtract(source)
+	if err != nil || filePath == "" || filepath.IsAbs(filePath) {
+		return source
+	}
+	return filepath.Join(prefix, filePath) + source[len(filePath):]
+}
+
+// rebaseFileSource prefixes the path of a relative file source with a
+// directory, keeping any key and escapes.
+func rebaseFileSource(source string, prefix string) string {
+	if key, filePath, found := cutFileSource(source); found {
+		return key + "=" + rebaseSource(filePath, prefix)
+	}
+	return rebaseSource(source, prefix)
+}
+
+// extendItems resolves the extends fields of the generators among the items
+// of a ResourceList. Relative paths are relative to the working directory,
+// where kustomize runs the function.
+func extendItems(items fn.KubeObjects) (fn.KubeObjects, error) {
+	var documents [][]byte
+	for _, item := range items {
+		if item.GetAPIVersion() == apiVersion && item.GetKind() == kind {
+			documents = append(documents, []byte(item.String()))
+		}
+	}
+	siblings := generatorsByName(documents)
+
+	extended := make(fn.KubeObjects, len(items))
+	for i, item := range items {
+		extended[i] = item
+		if _, found, _ := item.NestedString("extends"); !found {
+			continue
+		}
+		content, err := resolveExtends([]byte(item.String()), siblings, "", ".")
+		if err != nil {
+			return nil, err
+		}
+		extended[i], err = fn.ParseKubeObject(content)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return extended, nil
+}
@@ -0,0 +1,195 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+)
+
+const testTypeMeta = "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\n"
+
+const testBaseGenerator = testTypeMeta + `metadata:
+  name: base
+  labels:
+    app: web
+    tier: backend
+envs:
+  - vars.env
+files:
+  - key=file.txt
+  - file.yaml?key
+type: Opaque
+variants:
+  prod:
+    envs:
+      - prod.env
+`
+
+func Test_mergeGenerators(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		overlay string
+		want    string
+	}{
+		{"Sources", "envs: [a.env]\nfiles: [a.txt]\n", "files: [b.txt]\n", "envs: [a.env]\nfiles: [a.txt, b.txt]\n"},
+		{"ClusterSources", "clusterSources: [{name: a, key: a.env}]\n", "clusterSources: [{name: b, key: b.env}]\n", "clusterSources: [{name: a, key: a.env}, {name: b, key: b.env}]\n"},
+		{"Labels", "metadata: {name: base, labels: {a: \"1\", b: \"2\"}}\n", "metadata: {name: overlay, labels: {b: \"3\"}}\n",
+			"metadata: {name: overlay, labels: {a: \"1\", b: \"3\"}}\n"},
+		{"Annotations", "metadata: {annotations: {a: \"1\"}}\n", "metadata: {name: overlay}\n", "metadata: {name: overlay, annotations: {a: \"1\"}}\n"},
+		{"Replace", "type: Opaque\ndisableNameSuffixHash: true\n", "type: kubernetes.io/tls\n", "type: kubernetes.io/tls\ndisableNameSuffixHash: true\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields []generatorFields
+			for _, content := range []string{tt.base, tt.overlay, tt.want} {
+				f, err := decodeFields([]byte(content))
+				if err != nil {
+					t.Fatal(err)
+				}
+				fields = append(fields, f)
+			}
+			base, overlay, want := fields[0], fields[1], fields[2]
+			if got := mergeGenerators(base, overlay); !reflect.DeepEqual(got, want) {
+				t.Errorf("mergeGenerators() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func Test_resolveExtends(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "base"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(dir, "base", "generator.yaml"), testBaseGenerator)
+	writeTestFile(t, filepath.Join(dir, "base", "two.yaml"), testBaseGenerator+"---\n"+strings.Replace(testBaseGenerator, "name: base", "name: other", 1))
+	writeTestFile(t, filepath.Join(dir, "base", "chain.yaml"), "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: chain\nextends: generator.yaml\n")
+	writeTestFile(t, filepath.Join(dir, "base", "basedir.yaml"), "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: base\nbaseDir: ../secrets\nenvs:\n  - vars.env\n")
+	siblings := map[string][]byte{
+		"base": []byte(testBaseGenerator),
+		"a":    []byte("metadata:\n  name: a\nextends: b\n"),
+		"b":    []byte("metadata:\n  name: b\nextends: a\n"),
+	}
+
+	tests := []struct {
+		name     string
+		document string
+		want     string
+		wantErr  bool
+	}{
+		{"NoExtends", "metadata:\n  name: secret\n", "metadata:\n  name: secret\n", false},
+		{"Name", "metadata:\n  name: secret\n  labels:\n    tier: frontend\nextends: base\nenvs:\n  - more.env\n",
+			testTypeMeta + "envs: [vars.env, more.env]\nfiles: [key=file.txt, \"file.yaml?key\"]\nmetadata: {name: secret, labels: {app: web, tier: frontend}}\ntype: Opaque\nvariants: {prod: {envs: [prod.env]}}\n", false},
+		{"Path", "metadata:\n  name: secret\nextends: base/generator.yaml\n",
+			testTypeMeta + "envs: [base/vars.env]\nfiles: [key=base/file.txt, \"base/file.yaml?key\"]\nmetadata: {name: secret, labels: {app: web, tier: backend}}\ntype: Opaque\nvariants: {prod: {envs: [base/prod.env]}}\n", false},
+		{"PathByName", "metadata:\n  name: other\nextends: base/two.yaml\ntype: kubernetes.io/tls\n",
+			testTypeMeta + "envs: [base/vars.env]\nfiles: [key=base/file.txt, \"base/file.yaml?key\"]\nmetadata: {name: other, labels: {app: web, tier: backend}}\ntype: kubernetes.io/tls\nvariants: {prod: {envs: [base/prod.env]}}\n", false},
+		{"Chain", "metadata:\n  name: secret\nextends: base/chain.yaml\n",
+			testTypeMeta + "envs: [base/vars.env]\nfiles: [key=base/file.txt, \"base/file.yaml?key\"]\nmetadata: {name: secret, labels: {app: web, tier: backend}}\ntype: Opaque\nvariants: {prod: {envs: [base/prod.env]}}\n", false},
+		{"BaseDir", "metadata:\n  name: secret\nextends: base/basedir.yaml\n",
+			testTypeMeta + "baseDir: secrets\nenvs: [vars.env]\nmetadata: {name: secret}\n", false},
+		{"PathAmbiguous", "metadata:\n  name: secret\nextends: base/two.yaml\n", "", true},
+		{"MissingName", "metadata:\n  name: secret\nextends: missing\n", "", true},
+		{"MissingPath", "metadata:\n  name: secret\nextends: missing.yaml\n", "", true},
+		{"Empty", "metadata:\n  name: secret\nextends: \"\"\n", "", true},
+		{"Cycle", "metadata:\n  name: a\nextends: b\n", "", true},
+		{"Self", "metadata:\n  name: base\nextends: base\n", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveExtends([]byte(tt.document), siblings, "", dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveExtends() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			gotFields, err := decodeFields(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantFields, err := decodeFields([]byte(tt.want))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotFields, wantFields) {
+				t.Errorf("resolveExtends() = %v, want %v", gotFields, wantFields)
+			}
+		})
+	}
+}
+
+func Test_GenerateKRMManifest_extends(t *testing.T) {
+	input := `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+  - apiVersion: kustomize.freightdog.com/v1
+    kind: SopsSecretGenerator
+    metadata:
+      name: base
+    files:
+      - testdata/file.txt
+  - apiVersion: kustomize.freightdog.com/v1
+    kind: SopsSecretGenerator
+    metadata:
+      name: overlay
+    extends: base
+    envs:
+      - testdata/vars.env
+`
+	out, err := fn.Run(fn.ResourceListProcessorFunc(generateKRMManifest), []byte(input))
+	if err != nil {
+		t.Fatalf("generateKRMManifest() error = %v", err)
+	}
+	rl, err := fn.ParseResourceList(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range rl.Items {
+		if item.GetName() != "overlay" {
+			continue
+		}
+		data := item.GetMap("data")
+		if _, found, _ := data.NestedString("file.txt"); !found {
+			t.Errorf("overlay Secret lacks the base's file.txt: %s", item.String())
+		}
+		if _, found, _ := data.NestedString("VAR_ENV"); !found {
+			t.Errorf("overlay Secret lacks its own VAR_ENV: %s", item.String())
+		}
+		return
+	}
+	t.Errorf("generateKRMManifest() did not generate the overlay Secret: %s", out)
+}
+
+func Test_readGenerators_extends(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "generators.yaml")
+	writeTestFile(t, fileName, testBaseGenerator+"---\napiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: overlay\nextends: base\nfiles:\n  - other.txt\n")
+	generators, err := readGenerators(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(generators) != 2 {
+		t.Fatalf("readGenerators() = %+v", generators)
+	}
+	want := []string{"key=file.txt", "file.yaml?key", "other.txt"}
+	if got := generators[1].Generator.FileSources; !reflect.DeepEqual(got, want) {
+		t.Errorf("readGenerators() files = %v, want %v", got, want)
+	}
+
+	results, err := validateManifest(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[1].Name != "overlay" {
+		t.Errorf("validateManifest() = %+v", results)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/cmd/sops/common"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/getsops/sops/v3/config"
+	"github.com/pkg/errors"
+)
+
+// extractPattern matches a sops extract expression such as `["db"]["hosts"][0]`
+var extractPattern = regexp.MustCompile(`^(\["[^"]*"\]|\['[^']*'\]|\[[0-9]+\])+$`)
+
+// extractComponent matches a single component of an extract expression
+var extractComponent = regexp.MustCompile(`\["([^"]*)"\]|\['([^']*)'\]|\[([0-9]+)\]`)
+
+// splitExtract splits a source such as `secrets.yaml["db"]["password"]` into
+// the file path and the tree path to extract, using the same syntax as
+// `sops decrypt --extract`. Sources without an extract expression are
+// returned as they are, with a nil tree path.
+func splitExtract(source string) (string, []interface{}, error) {
+	for i := 1; i < len(source); i++ {
+		if source[i] == '[' && extractPattern.MatchString(source[i:]) {
+			treePath, err := parseExtract(source[i:])
+			return source[:i], treePath, err
+		}
+	}
+	return source, nil, nil
+}
+
+// parseExtract converts an extract expression into a sops tree path.
+func parseExtract(expression string) ([]interface{}, error) {
+	var treePath []interface{}
+	for _, m := range extractComponent.FindAllStringSubmatch(expression, -1) {
+		switch {
+		case m[3] != "":
+			i, err := strconv.Atoi(m[3])
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid index in %s", expression)
+			}
+			treePath = append(treePath, i)
+		case strings.HasPrefix(m[0], `["`):
+			treePath = append(treePath, m[1])
+		default:
+			treePath = append(treePath, m[2])
+		}
+	}
+	return treePath, nil
+}
+
+// extractKey returns the default data key for an extracted value, which is
+// the last named component of the tree path.
+func extractKey(source string, treePath []interface{}) string {
+	for i := len(treePath) - 1; i >= 0; i-- {
+		if name, ok := treePath[i].(string); ok {
+			return name
+		}
+	}
+	return path.Base(source)
+}
+
+// extractValue keeps only the value at the tree path of decrypted content.
+// Branches are emitted in the format of the source file, so that env sources
+// can flatten them; scalar values are returned as raw bytes. sops verifies the
+// integrity of a file over all of its values, so the whole file is always
+// decrypted, but only the extracted value is retained.
+func extractValue(plaintext []byte, format formats.Format, treePath []interface{}) ([]byte, error) {
+	store := common.StoreForFormat(format, config.NewStoresConfig())
+	branches, err := store.LoadPlainFile(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	if len(branches) == 0 {
+		return nil, errors.New("cannot extract from an empty file")
+	}
+	value, err := branches[0].Truncate(treePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not extract value")
+	}
+	if branch, ok := value.(sops.TreeBranch); ok {
+		return store.EmitPlainFile(sops.TreeBranches{branch})
+	}
+	return sops.ToBytes(value)
+}
@@ -0,0 +1,73 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+)
+
+func Test_splitExtract(t *testing.T) {
+	tests := []struct {
+		name         string
+		source       string
+		wantPath     string
+		wantTreePath []interface{}
+	}{
+		{"NoExtract", "testdata/vars.yaml", "testdata/vars.yaml", nil},
+		{"Key", `testdata/vars.yaml["VAR_YAML"]`, "testdata/vars.yaml", []interface{}{"VAR_YAML"}},
+		{"NestedKeys", `secrets.yaml["db"]['password']`, "secrets.yaml", []interface{}{"db", "password"}},
+		{"Index", `secrets.yaml["hosts"][1]`, "secrets.yaml", []interface{}{"hosts", 1}},
+		{"BracketInPath", `dir[1]/secrets.yaml["db"]`, "dir[1]/secrets.yaml", []interface{}{"db"}},
+		{"NotAnExpression", "secrets[prod].yaml", "secrets[prod].yaml", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotPath, gotTreePath, err := splitExtract(tt.source)
+			if err != nil {
+				t.Errorf("splitExtract() error = %v", err)
+				return
+			}
+			if gotPath != tt.wantPath {
+				t.Errorf("splitExtract() path = %v, want %v", gotPath, tt.wantPath)
+			}
+			if !reflect.DeepEqual(gotTreePath, tt.wantTreePath) {
+				t.Errorf("splitExtract() treePath = %v, want %v", gotTreePath, tt.wantTreePath)
+			}
+		})
+	}
+}
+
+func Test_extractValue(t *testing.T) {
+	type args struct {
+		plaintext []byte
+		format    formats.Format
+		treePath  []interface{}
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{"Scalar", args{b("db:\n  password: hunter2\n"), formats.Yaml, []interface{}{"db", "password"}}, "hunter2", false},
+		{"Number", args{b(`{"port": 5432}`), formats.Json, []interface{}{"port"}}, "5432", false},
+		{"Branch", args{b("db:\n  user: app\n"), formats.Yaml, []interface{}{"db"}}, "user: app\n", false},
+		{"Missing", args{b("db:\n  user: app\n"), formats.Yaml, []interface{}{"cache"}}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractValue(tt.args.plaintext, tt.args.format, tt.args.treePath)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("extractValue() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("extractValue() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"encoding/base64"
+	"time"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/aes"
+	"github.com/getsops/sops/v3/cmd/sops/common"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/getsops/sops/v3/config"
+	"github.com/getsops/sops/v3/pgp"
+	"github.com/pkg/errors"
+)
+
+// fakeDataKey is the data key of fake fixtures. It is published here, so the
+// content of a fixture is not secret in any way.
+var fakeDataKey = []byte("sops-secretgen-fake-data-key-v1!")
+
+// fakeFingerprint marks the only key of a fake fixture. sops requires files to
+// have at least one key, so fixtures carry a PGP key entry with this
+// fingerprint whose "encrypted" data key is just the base64 encoded data key.
+const fakeFingerprint = "SOPS-SECRETGEN-FAKE-FIXTURE"
+
+// FakeDecryptor decrypts fixtures created with `encrypt --fake`. Fixtures are
+// sops files whose data key is a fixed, published value, so that tests can
+// render kustomizations without access to real keys. Files that are encrypted
+// with real keys are never decrypted.
+type FakeDecryptor struct{}
+
+// Decrypt decrypts a fixture.
+func (FakeDecryptor) Decrypt(content []byte, format formats.Format) ([]byte, error) {
+	store := common.StoreForFormat(format, config.NewStoresConfig())
+	tree, err := store.LoadEncryptedFile(content)
+	if err != nil {
+		return nil, err
+	}
+	if !isFakeFixture(tree.Metadata) {
+		return nil, errors.New("fake decryption only decrypts fixtures created with `encrypt --fake`, but the file has real keys")
+	}
+	cipher := aes.NewCipher()
+	mac, err := tree.Decrypt(fakeDataKey, cipher)
+	if err != nil {
+		return nil, errors.Wrap(err, "not a fake fixture")
+	}
+	fileMac, err := cipher.Decrypt(tree.Metadata.MessageAuthenticationCode, fakeDataKey, tree.Metadata.LastModified.Format(time.RFC3339))
+	if err != nil || fileMac != mac {
+		return nil, errors.New("not a fake fixture, or the fixture was modified")
+	}
+	return store.EmitPlainFile(tree.Branches)
+}
+
+// isFakeFixture returns whether the only key of a file is the fake fixture key.
+func isFakeFixture(metadata sops.Metadata) bool {
+	if len(metadata.KeyGroups) != 1 || len(metadata.KeyGroups[0]) != 1 {
+		return false
+	}
+	key, ok := metadata.KeyGroups[0][0].(*pgp.MasterKey)
+	return ok && key.Fingerprint == fakeFingerprint
+}
+
+// encryptFakeTree encrypts a tree in place as a fake fixture.
+func encryptFakeTree(tree *sops.Tree) error {
+	key := pgp.NewMasterKeyFromFingerprint(fakeFingerprint)
+	key.EncryptedKey = base64.StdEncoding.EncodeToString(fakeDataKey)
+	tree.Metadata.KeyGroups = []sops.KeyGroup{{key}}
+	return common.EncryptTree(common.EncryptTreeOpts{Tree: tree, Cipher: aes.NewCipher(), DataKey: fakeDataKey})
+}
@@ -0,0 +1,10 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+//go:build !fakedecrypt
+
+package sopssecretgenerator
+
+// fakeDecryptBuild is false in regular builds, which only decrypt fixtures if
+// SOPS_SECRETGEN_FAKE_DECRYPT is set.
+const fakeDecryptBuild = false
@@ -0,0 +1,10 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+//go:build fakedecrypt
+
+package sopssecretgenerator
+
+// fakeDecryptBuild enables fake decryption in binaries built with the
+// fakedecrypt build tag, which are meant for tests only.
+const fakeDecryptBuild = true
@@ -0,0 +1,123 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+)
+
+func TestFakeDecryptor(t *testing.T) {
+	tests := []struct {
+		name      string
+		plaintext string
+		output    string
+	}{
+		{"Dotenv", "VAR=fixture-value\n", "fixture.env"},
+		{"YAML", "VAR: fixture-value\n", "fixture.yaml"},
+		{"JSON", "{\n\t\"VAR\": \"fixture-value\"\n}", "fixture.json"},
+		{"Binary", "fixture-value\n", "fixture.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			input := filepath.Join(dir, "plaintext")
+			writeTestFile(t, input, tt.plaintext)
+			output := filepath.Join(dir, tt.output)
+
+			err := encryptFile(input, output, "", Policy{}, true)
+			if err != nil {
+				t.Fatalf("encryptFile() error = %v", err)
+			}
+			content, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(content), "fixture-value") {
+				t.Errorf("encryptFile() wrote the plaintext:\n%s", content)
+			}
+			format := formats.FormatForPath(output)
+			got, err := FakeDecryptor{}.Decrypt(content, format)
+			if err != nil {
+				t.Fatalf("Decrypt() error = %v", err)
+			}
+			if !strings.Contains(string(got), "fixture-value") {
+				t.Errorf("Decrypt() = %q, want %q", got, tt.plaintext)
+			}
+			_, err = SopsDecryptor{}.Decrypt(content, format)
+			if err == nil {
+				t.Errorf("SopsDecryptor.Decrypt() of a fixture error = nil")
+			}
+		})
+	}
+}
+
+func TestFakeDecryptor_Rejected(t *testing.T) {
+	real, err := os.ReadFile("testdata/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = FakeDecryptor{}.Decrypt(real, formats.Binary)
+	if err == nil || !strings.Contains(err.Error(), "the file has real keys") {
+		t.Errorf("Decrypt() of a real file error = %v", err)
+	}
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "plaintext")
+	writeTestFile(t, input, "VAR=value\nOTHER=value\n")
+	output := filepath.Join(dir, "fixture.env")
+	err = encryptFile(input, output, "", Policy{}, true)
+	if err != nil {
+		t.Fatalf("encryptFile() error = %v", err)
+	}
+	content, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modified := strings.Replace(string(content), "OTHER=", "RENAMED=", 1)
+	_, err = FakeDecryptor{}.Decrypt([]byte(modified), formats.Dotenv)
+	if err == nil {
+		t.Errorf("Decrypt() of a modified fixture error = nil")
+	}
+}
+
+func TestGenerate_FakeDecryptor(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "plaintext")
+	writeTestFile(t, input, "VAR=value\n")
+	fixture := filepath.Join(dir, "fixture.env")
+	err := encryptFile(input, fixture, "", Policy{}, true)
+	if err != nil {
+		t.Fatalf("encryptFile() error = %v", err)
+	}
+
+	spec := SopsSecretGenerator{
+		TypeMeta:   TypeMeta{APIVersion: apiVersion, Kind: kind},
+		ObjectMeta: ObjectMeta{Name: "fake"},
+		EnvSources: []string{fixture},
+	}
+	got, err := Generate(context.Background(), spec, Options{Decryptor: FakeDecryptor{}})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if got[0].Data["VAR"] != b64("value") {
+		t.Errorf("Generate() data = %v", got[0].Data)
+	}
+}
+
+func TestOptionsFromEnv_FakeDecrypt(t *testing.T) {
+	t.Setenv(envPrefix+"FAKE_DECRYPT", "true")
+	got, err := OptionsFromEnv()
+	if err != nil {
+		t.Fatalf("OptionsFromEnv() error = %v", err)
+	}
+	if _, ok := got.Decryptor.(FakeDecryptor); !ok {
+		t.Errorf("OptionsFromEnv() Decryptor = %T, want FakeDecryptor", got.Decryptor)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+)
+
+// Parameters of a ConfigMap functionConfig, which override the options of
+// the run
+const (
+	// variantParameter selects the variant of the generators
+	variantParameter = "variant"
+	// namespaceParameter sets the namespace of the Secrets
+	namespaceParameter = "namespace"
+)
+
+// functionConfigParameter returns a parameter of a ConfigMap functionConfig,
+// as passed by kpt fn eval, or an empty string. Other functionConfigs, such
+// as the generator that kustomize passes, have no parameters.
+func functionConfigParameter(config *fn.KubeObject, name string) string {
+	if config == nil || config.GetKind() != "ConfigMap" {
+		return ""
+	}
+	value, _, _ := config.NestedString("data", name)
+	return value
+}
+
+// applyFunctionConfig applies the parameters of a functionConfig to the
+// options of the run, and returns a function that restores the options.
+func applyFunctionConfig(config *fn.KubeObject) func() {
+	previous := runtimeSettings
+	if variant := functionConfigParameter(config, variantParameter); variant != "" {
+		runtimeSettings.Variant = variant
+	}
+	if namespace := functionConfigParameter(config, namespaceParameter); namespace != "" {
+		runtimeSettings.Namespace = namespace
+	}
+	return func() { runtimeSettings = previous }
+}
@@ -0,0 +1,73 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+)
+
+func Test_functionConfigParameter(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{"None", "", ""},
+		{"ConfigMap", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\ndata:\n  namespace: pr-42\n", "pr-42"},
+		{"NoParameter", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\ndata:\n  variant: prod\n", ""},
+		{"Generator", "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: secret\n  namespace: apps\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var config *fn.KubeObject
+			if tt.config != "" {
+				var err error
+				config, err = fn.ParseKubeObject([]byte(tt.config))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := functionConfigParameter(config, namespaceParameter); got != tt.want {
+				t.Errorf("functionConfigParameter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_GenerateKRMManifest_namespace(t *testing.T) {
+	input := `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+  - apiVersion: kustomize.freightdog.com/v1
+    kind: SopsSecretGenerator
+    metadata:
+      name: secret
+      namespace: apps
+    files:
+      - testdata/file.txt
+functionConfig:
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: config
+  data:
+    namespace: pr-42
+`
+	out, err := fn.Run(fn.ResourceListProcessorFunc(generateKRMManifest), []byte(input))
+	if err != nil {
+		t.Fatalf("generateKRMManifest() error = %v", err)
+	}
+	rl, err := fn.ParseResourceList(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rl.Items) != 1 || rl.Items[0].GetNamespace() != "pr-42" {
+		t.Errorf("generateKRMManifest() = %s, want the Secret in namespace pr-42", out)
+	}
+	if runtimeSettings.Namespace != "" {
+		t.Errorf("generateKRMManifest() left the namespace %s set", runtimeSettings.Namespace)
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// gcpSecretManagerEndpoint returns the Secret Manager API of a location, or
+// the global API if the location is empty
+var gcpSecretManagerEndpoint = func(location string) string {
+	if location == "" {
+		return "https://secretmanager.googleapis.com"
+	}
+	return "https://secretmanager." + location + ".rep.googleapis.com"
+}
+
+// gcpSecretVersionPattern matches the resource name of a secret, with an
+// optional version
+var gcpSecretVersionPattern = regexp.MustCompile(`^projects/([^/]+)/(locations/([^/]+)/)?secrets/([^/]+)(/versions/([^/]+))?$`)
+
+// GCPSecretSource is a secret version in Google Cloud Secret Manager, whose
+// payload is added under Key.
+type GCPSecretSource struct {
+	// Name is projects/*/secrets/*/versions/*, the latest version if the
+	// version is left out
+	Name string `json:"name" yaml:"name"`
+	// Key is the secret ID if empty
+	Key string `json:"key,omitempty" yaml:"key,omitempty"`
+	// Optional skips the source if the secret or version does not exist
+	Optional bool `json:"optional,omitempty" yaml:"optional,omitempty"`
+}
+
+// String returns the source as gcp-secretmanager:name.
+func (s GCPSecretSource) String() string {
+	return "gcp-secretmanager:" + s.Name
+}
+
+// validate checks the fields of a Secret Manager source.
+func (s GCPSecretSource) validate() error {
+	if !gcpSecretVersionPattern.MatchString(s.Name) {
+		return errors.Errorf("gcpSecretSources: name %s must be projects/*/secrets/*/versions/*", s.Name)
+	}
+	return nil
+}
+
+// version returns the resource name of the secret version.
+func (s GCPSecretSource) version() string {
+	if gcpSecretVersionPattern.FindStringSubmatch(s.Name)[6] == "" {
+		return s.Name + "/versions/latest"
+	}
+	return s.Name
+}
+
+// key returns the data key of the secret.
+func (s GCPSecretSource) key() string {
+	if s.Key != "" {
+		return s.Key
+	}
+	return gcpSecretVersionPattern.FindStringSubmatch(s.Name)[4]
+}
+
+// endpoint returns the Secret Manager API of the secret, which is regional
+// for regional secrets.
+func (s GCPSecretSource) endpoint() string {
+	return gcpSecretManagerEndpoint(gcpSecretVersionPattern.FindStringSubmatch(s.Name)[3])
+}
+
+// gcpError is the error response of a Google API
+type gcpError struct {
+	Error struct {
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// errGCPSecretNotFound is returned for secrets and versions that do not exist
+var errGCPSecretNotFound = errors.New("secret version not found")
+
+// access returns the payload of a secret version.
+func (s GCPSecretSource) access(client *http.Client, opts decryptOptions) ([]byte, error) {
+	req, err := http.NewRequestWithContext(opts.context(), http.MethodGet, s.endpoint()+"/v1/"+s.version()+":access", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errGCPSecretNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e gcpError
+		_ = json.Unmarshal(body, &e)
+		if e.Error.Message == "" {
+			return nil, errors.Errorf("secret manager returned %s", resp.Status)
+		}
+		return nil, errors.Errorf("secret manager returned %s: %s", e.Error.Status, strings.TrimSpace(e.Error.Message))
+	}
+	var response struct {
+		Payload struct {
+			Data []byte `json:"data"`
+		} `json:"payload"`
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid secret manager response")
+	}
+	return response.Payload.Data, nil
+}
+
+// parseGCPSecretSources reads the Secret Manager sources of a generator into
+// the Secret data. Optional sources that do not exist are skipped.
+func parseGCPSecretSources(sources []GCPSecretSource, opts decryptOptions, data kvMap) error {
+	if len(sources) == 0 {
+		return nil
+	}
+	if opts.Offline {
+		return errors.New("GCP Secret Manager sources cannot be read in offline mode")
+	}
+	tokenSource, err := invocationCredentials.gcpTokenSource()
+	if err != nil {
+		return err
+	}
+	client := oauth2.NewClient(opts.context(), tokenSource)
+	var errs []error
+	for _, source := range sources {
+		var payload []byte
+		err := withWorker(opts, func() error {
+			var err error
+			payload, err = source.access(client, opts)
+			return err
+		})
+		if errors.Is(err, errGCPSecretNotFound) && source.Optional {
+			opts.logger().Info("skipped optional source that does not exist", "generator", opts.Generator, "source", source.String())
+			continue
+		}
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "source %s", source))
+			if opts.context().Err() != nil {
+				break
+			}
+			continue
+		}
+		data[source.key()] = encodeBase64(payload)
+		wipe(payload)
+	}
+	return joinSourceErrors(errs)
+}
@@ -0,0 +1,98 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func Test_GCPSecretSource(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      GCPSecretSource
+		wantVersion string
+		wantKey     string
+		wantErr     bool
+	}{
+		{"Version", GCPSecretSource{Name: "projects/p/secrets/db-password/versions/3"}, "projects/p/secrets/db-password/versions/3", "db-password", false},
+		{"Latest", GCPSecretSource{Name: "projects/p/secrets/db-password", Key: "DB_PASSWORD"}, "projects/p/secrets/db-password/versions/latest", "DB_PASSWORD", false},
+		{"Regional", GCPSecretSource{Name: "projects/p/locations/europe-west4/secrets/token"}, "projects/p/locations/europe-west4/secrets/token/versions/latest", "token", false},
+		{"Invalid", GCPSecretSource{Name: "db-password"}, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.source.validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := tt.source.version(); got != tt.wantVersion {
+				t.Errorf("version() = %v, want %v", got, tt.wantVersion)
+			}
+			if got := tt.source.key(); got != tt.wantKey {
+				t.Errorf("key() = %v, want %v", got, tt.wantKey)
+			}
+		})
+	}
+}
+
+func Test_parseGCPSecretSources(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/projects/p/secrets/db-password/versions/latest:access":
+			_, _ = w.Write([]byte(`{"payload":{"data":"c2VjcmV0"}}`))
+		case "/v1/projects/p/secrets/disabled/versions/latest:access":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"status":"FAILED_PRECONDITION","message":"version is disabled"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"status":"NOT_FOUND","message":"not found"}}`))
+		}
+	}))
+	defer server.Close()
+	previousEndpoint, previousCredentials := gcpSecretManagerEndpoint, invocationCredentials
+	gcpSecretManagerEndpoint = func(string) string { return server.URL }
+	invocationCredentials = &sharedCredentials{gcp: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"})}
+	defer func() { gcpSecretManagerEndpoint, invocationCredentials = previousEndpoint, previousCredentials }()
+
+	tests := []struct {
+		name    string
+		source  GCPSecretSource
+		offline bool
+		want    map[string]string
+		wantErr bool
+	}{
+		{"Latest", GCPSecretSource{Name: "projects/p/secrets/db-password"}, false, map[string]string{"db-password": "secret"}, false},
+		{"Key", GCPSecretSource{Name: "projects/p/secrets/db-password/versions/latest", Key: "DB_PASSWORD"}, false, map[string]string{"DB_PASSWORD": "secret"}, false},
+		{"Optional", GCPSecretSource{Name: "projects/p/secrets/missing", Optional: true}, false, map[string]string{}, false},
+		{"NotFound", GCPSecretSource{Name: "projects/p/secrets/missing"}, false, nil, true},
+		{"OptionalDisabled", GCPSecretSource{Name: "projects/p/secrets/disabled", Optional: true}, false, nil, true},
+		{"Offline", GCPSecretSource{Name: "projects/p/secrets/db-password"}, true, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make(kvMap)
+			opts := decryptOptions{Context: context.Background(), Offline: tt.offline}
+			err := parseGCPSecretSources([]GCPSecretSource{tt.source}, opts, data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseGCPSecretSources() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(decodeData(data), tt.want) {
+				t.Errorf("parseGCPSecretSources() = %v, want %v", decodeData(data), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+// Package sopssecretgenerator generates Kubernetes Secrets from
+// sops-encrypted files. It implements the SopsSecretGenerator Kustomize
+// plugin, and can be embedded in other tools with Generate.
+package sopssecretgenerator
+
+import (
+	"context"
+)
+
+// Generate generates the Secrets for a SopsSecretGenerator. Relative source
+// paths are resolved against the working directory. The spec must have the
+// apiVersion, kind and name of a generator. Canceling the context abandons
+// decryptions in progress. A generator whose when condition does not hold
+// generates no Secrets. The outputKind of the generator only applies to the
+// plugin; Generate always returns Secrets.
+func Generate(ctx context.Context, spec SopsSecretGenerator, opts Options) ([]Secret, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+	err = validateInput(spec)
+	if err != nil {
+		return nil, err
+	}
+	if !spec.When.holds() {
+		return nil, nil
+	}
+	invocationProvenance.reset()
+	secret, err := generateSecret(ctx, spec, opts)
+	if err != nil {
+		return nil, err
+	}
+	return []Secret{secret}, nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	spec := SopsSecretGenerator{
+		TypeMeta:    TypeMeta{APIVersion: apiVersion, Kind: kind},
+		ObjectMeta:  ObjectMeta{Name: "my-secret", Namespace: "ns"},
+		EnvSources:  []string{"testdata/vars.env"},
+		FileSources: []string{"testdata/file.txt"},
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		spec    func(SopsSecretGenerator) SopsSecretGenerator
+		opts    Options
+		want    kvMap
+		wantErr bool
+	}{
+		{"Generate", context.Background(), func(s SopsSecretGenerator) SopsSecretGenerator { return s }, Options{}, kvMap{"VAR_ENV": b64("val_env"), "file.txt": b64("secret\n")}, false},
+		{"MaxFileSize", context.Background(), func(s SopsSecretGenerator) SopsSecretGenerator { return s }, Options{MaxFileSize: 1}, nil, true},
+		{"NoName", context.Background(), func(s SopsSecretGenerator) SopsSecretGenerator { s.Name = ""; return s }, Options{}, nil, true},
+		{"WrongKind", context.Background(), func(s SopsSecretGenerator) SopsSecretGenerator { s.Kind = "SecretGenerator"; return s }, Options{}, nil, true},
+		{"Canceled", canceled, func(s SopsSecretGenerator) SopsSecretGenerator { return s }, Options{}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Generate(tt.ctx, tt.spec(spec), tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Generate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("Generate() returned %d Secrets, want 1", len(got))
+			}
+			if got[0].Kind != "Secret" || got[0].Name != "my-secret" || got[0].Namespace != "ns" {
+				t.Errorf("Generate() = %+v", got[0].ObjectMeta)
+			}
+			if !reflect.DeepEqual(got[0].Data, tt.want) {
+				t.Errorf("Generate() data = %v, want %v", got[0].Data, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/pgp"
+	"github.com/pkg/errors"
+	"golang.org/x/term"
+)
+
+// gpgTTYVariable names the terminal that gpg-agent shows pinentry prompts on
+const gpgTTYVariable = "GPG_TTY"
+
+// gpgTTYPassthrough is set in standalone runs, which may pass their terminal
+// on to gpg-agent. Library users set GPG_TTY themselves.
+var gpgTTYPassthrough bool
+
+// gpgTTYOnce looks for the terminal at most once per invocation
+var gpgTTYOnce sync.Once
+
+// gpgAgentHints are explanations of what gpg reports when gpg-agent could
+// not get the passphrase of a PGP key, by a part of the message
+var gpgAgentHints = []struct {
+	message string
+	hint    string
+}{
+	{"Inappropriate ioctl for device", "gpg-agent has no terminal to ask for the passphrase of the PGP key on: run in a terminal, export GPG_TTY=$(tty), or configure a graphical pinentry-program in gpg-agent.conf"},
+	{"No pinentry", "gpg-agent has no pinentry to ask for the passphrase of the PGP key: install pinentry, or set pinentry-program in gpg-agent.conf"},
+	{"can't connect to the agent", "gpg-agent is not running: start it with `gpgconf --launch gpg-agent`, or check GNUPGHOME"},
+	{"No agent running", "gpg-agent is not running: start it with `gpgconf --launch gpg-agent`, or check GNUPGHOME"},
+	{"Operation cancelled", "the passphrase prompt of the PGP key was cancelled"},
+}
+
+// passGPGTTY lets gpg-agent ask for the passphrase of a PGP key on the
+// terminal of a standalone run, for files with PGP keys. sops pipes the
+// encrypted data key to the stdin of gpg, so gpg cannot find the terminal
+// itself, and pinentry fails with "Inappropriate ioctl for device" unless
+// GPG_TTY names it. A GPG_TTY that is already set is kept.
+func passGPGTTY(metadata sops.Metadata) {
+	if !gpgTTYPassthrough || !hasPGPKeys(metadata) {
+		return
+	}
+	gpgTTYOnce.Do(func() {
+		if os.Getenv(gpgTTYVariable) != "" {
+			return
+		}
+		name := terminalName()
+		if name == "" {
+			return
+		}
+		_ = os.Setenv(gpgTTYVariable, name)
+		runtimeSettings.logger().Debug("passing the terminal to gpg-agent", gpgTTYVariable, name)
+	})
+}
+
+// hasPGPKeys reports whether a file is encrypted to PGP keys.
+func hasPGPKeys(metadata sops.Metadata) bool {
+	for _, group := range metadata.KeyGroups {
+		for _, key := range group {
+			if key.TypeToIdentifier() == pgp.KeyTypeIdentifier {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// terminalName returns the device name of the terminal of stdin, stderr or
+// stdout, whichever is one first, or "" if none is. It asks tty(1), which
+// prints the terminal of its stdin.
+func terminalName() string {
+	for _, f := range []*os.File{os.Stdin, os.Stderr, os.Stdout} {
+		if !term.IsTerminal(int(f.Fd())) {
+			continue
+		}
+		cmd := exec.Command("tty")
+		cmd.Stdin = f
+		output, err := cmd.Output()
+		if err == nil {
+			return strings.TrimSpace(string(output))
+		}
+	}
+	return ""
+}
+
+// gpgAgentError explains an error of gpg-agent in decrypting a PGP key,
+// which sops passes on as the bare output of gpg. Other errors are returned
+// as they are.
+func gpgAgentError(err error) error {
+	if err == nil {
+		return nil
+	}
+	message := err.Error()
+	for _, h := range gpgAgentHints {
+		if strings.Contains(message, h.message) {
+			return errors.WithMessage(err, h.hint)
+		}
+	}
+	return err
+}
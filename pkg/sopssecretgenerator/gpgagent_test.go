@@ -0,0 +1,78 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/age"
+	"github.com/getsops/sops/v3/pgp"
+	"github.com/pkg/errors"
+)
+
+func Test_gpgAgentError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantHint string
+	}{
+		{"Nil", nil, ""},
+		{"Other", errors.New("0 successful groups required, got 0"), ""},
+		{"NoTerminal", errors.New("failed to decrypt sops data key with pgp: gpg: public key decryption failed: Inappropriate ioctl for device"), "GPG_TTY"},
+		{"NoPinentry", errors.New("gpg: public key decryption failed: No pinentry"), "pinentry-program"},
+		{"NoAgent", errors.New("gpg: can't connect to the agent: IPC connect call failed"), "gpgconf --launch gpg-agent"},
+		{"Cancelled", errors.New("gpg: public key decryption failed: Operation cancelled"), "cancelled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := gpgAgentError(tt.err)
+			if tt.wantHint == "" {
+				if got != tt.err {
+					t.Errorf("gpgAgentError() = %v, want %v", got, tt.err)
+				}
+				return
+			}
+			if !strings.Contains(got.Error(), tt.wantHint) || !strings.Contains(got.Error(), tt.err.Error()) {
+				t.Errorf("gpgAgentError() = %v, want a hint with %q", got, tt.wantHint)
+			}
+			if errors.Cause(got) != tt.err {
+				t.Errorf("gpgAgentError() cause = %v, want %v", errors.Cause(got), tt.err)
+			}
+		})
+	}
+}
+
+func Test_hasPGPKeys(t *testing.T) {
+	pgpKey := pgp.NewMasterKeyFromFingerprint(testkeyFingerprint)
+	ageKey := &age.MasterKey{Recipient: testAgeRecipient}
+	tests := []struct {
+		name      string
+		keyGroups []sops.KeyGroup
+		want      bool
+	}{
+		{"None", nil, false},
+		{"Age", []sops.KeyGroup{{ageKey}}, false},
+		{"PGP", []sops.KeyGroup{{ageKey}, {pgpKey}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasPGPKeys(sops.Metadata{KeyGroups: tt.keyGroups}); got != tt.want {
+				t.Errorf("hasPGPKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_passGPGTTY(t *testing.T) {
+	t.Setenv(gpgTTYVariable, "/dev/pts/test")
+	gpgTTYPassthrough = true
+	t.Cleanup(func() { gpgTTYPassthrough = false })
+	passGPGTTY(sops.Metadata{KeyGroups: []sops.KeyGroup{{pgp.NewMasterKeyFromFingerprint(testkeyFingerprint)}}})
+	if got := os.Getenv(gpgTTYVariable); got != "/dev/pts/test" {
+		t.Errorf("passGPGTTY() GPG_TTY = %v, want the GPG_TTY that was set", got)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Name suffix hash algorithms
+const (
+	// hashKustomize is the hash that kustomize appends to generated names
+	hashKustomize = "kustomize"
+	// hashSHA256 is a prefix of the hex SHA-256 digest of the Secret
+	hashSHA256 = "sha256"
+)
+
+// kustomizeHashLength is the length of the hash that kustomize appends
+const kustomizeHashLength = 10
+
+// NameSuffixHash makes the plugin append a hash of the Secret to its name,
+// instead of leaving that to kustomize
+type NameSuffixHash struct {
+	// Algorithm is kustomize, for the same hash as kustomize, or sha256
+	Algorithm string `json:"algorithm,omitempty" yaml:"algorithm,omitempty"`
+	// Length is the number of hex digits of a sha256 hash, 10 by default
+	Length int `json:"length,omitempty" yaml:"length,omitempty"`
+}
+
+// validateNameSuffixHash checks the nameSuffixHash field of a generator. The
+// hashed name cannot be merged with or replace a Secret of another
+// generator, which would have a name with another hash.
+func validateNameSuffixHash(input SopsSecretGenerator) error {
+	h := input.NameSuffixHash
+	switch h.Algorithm {
+	case "":
+		if h.Length != 0 {
+			return errors.New("nameSuffixHash: length requires an algorithm")
+		}
+		return nil
+	case hashKustomize:
+		if h.Length != 0 && h.Length != kustomizeHashLength {
+			return errors.Errorf("nameSuffixHash: the kustomize hash has %d digits", kustomizeHashLength)
+		}
+	case hashSHA256:
+		if h.Length < 0 || h.Length > sha256.Size*2 {
+			return errors.Errorf("nameSuffixHash: length must be between 1 and %d", sha256.Size*2)
+		}
+	default:
+		return errors.Errorf("nameSuffixHash: unknown algorithm \"%s\", expected %s or %s", h.Algorithm, hashKustomize, hashSHA256)
+	}
+	if input.DisableNameSuffixHash {
+		return errors.New("nameSuffixHash cannot be combined with disableNameSuffixHash")
+	}
+	if input.Behavior != "" && input.Behavior != "create" {
+		return errors.Errorf("nameSuffixHash cannot be combined with behavior %s", input.Behavior)
+	}
+	return nil
+}
+
+// secretNameHash returns the hash of a Secret to append to its name. The
+// kustomize hash is computed like kustomize does: the SHA-256 digest of the
+// JSON encoding of the kind, name, type, data and any stringData of the
+// Secret, of which the first 10 hex digits are kept, with some replaced to
+// avoid words.
+func secretNameHash(secret Secret, h NameSuffixHash) (string, error) {
+	fields := map[string]interface{}{
+		"kind": "Secret",
+		"name": secret.Name,
+		"type": secret.Type,
+		"data": secret.Data,
+	}
+	if len(secret.StringData) > 0 {
+		fields["stringData"] = secret.StringData
+	}
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	digest := hex.EncodeToString(sum[:])
+
+	if h.Algorithm == hashSHA256 {
+		length := h.Length
+		if length == 0 {
+			length = kustomizeHashLength
+		}
+		return digest[:length], nil
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '0':
+			return 'g'
+		case '1':
+			return 'h'
+		case '3':
+			return 'k'
+		case 'a':
+			return 'm'
+		case 'e':
+			return 't'
+		}
+		return r
+	}, digest[:kustomizeHashLength]), nil
+}
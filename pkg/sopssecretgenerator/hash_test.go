@@ -0,0 +1,79 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func Test_secretNameHash(t *testing.T) {
+	// The kustomize hash of this Secret is from the kustomize hasher tests
+	secret := Secret{Type: "my-type", Data: kvMap{"one": ""}}
+	tests := []struct {
+		name string
+		hash NameSuffixHash
+		want string
+	}{
+		{"Kustomize", NameSuffixHash{Algorithm: hashKustomize}, "74bd68bm66"},
+		{"SHA256", NameSuffixHash{Algorithm: hashSHA256}, "74bd68ba66"},
+		{"SHA256Length", NameSuffixHash{Algorithm: hashSHA256, Length: 6}, "74bd68"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := secretNameHash(secret, tt.hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("secretNameHash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_validateNameSuffixHash(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   SopsSecretGenerator
+		wantErr bool
+	}{
+		{"None", SopsSecretGenerator{}, false},
+		{"Kustomize", SopsSecretGenerator{NameSuffixHash: NameSuffixHash{Algorithm: hashKustomize}}, false},
+		{"SHA256", SopsSecretGenerator{NameSuffixHash: NameSuffixHash{Algorithm: hashSHA256, Length: 8}}, false},
+		{"Create", SopsSecretGenerator{Behavior: "create", NameSuffixHash: NameSuffixHash{Algorithm: hashSHA256}}, false},
+		{"LengthOnly", SopsSecretGenerator{NameSuffixHash: NameSuffixHash{Length: 8}}, true},
+		{"KustomizeLength", SopsSecretGenerator{NameSuffixHash: NameSuffixHash{Algorithm: hashKustomize, Length: 8}}, true},
+		{"TooLong", SopsSecretGenerator{NameSuffixHash: NameSuffixHash{Algorithm: hashSHA256, Length: 65}}, true},
+		{"Unknown", SopsSecretGenerator{NameSuffixHash: NameSuffixHash{Algorithm: "md5"}}, true},
+		{"Disabled", SopsSecretGenerator{DisableNameSuffixHash: true, NameSuffixHash: NameSuffixHash{Algorithm: hashSHA256}}, true},
+		{"Merge", SopsSecretGenerator{Behavior: "merge", NameSuffixHash: NameSuffixHash{Algorithm: hashSHA256}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateNameSuffixHash(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateNameSuffixHash() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_generateSecret_nameSuffixHash(t *testing.T) {
+	input, err := readInput([]byte("apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: secret\nnameSuffixHash:\n  algorithm: sha256\n  length: 8\nfiles:\n  - testdata/file.txt\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := generateSecret(context.Background(), input, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret.Name, "secret-") || len(secret.Name) != len("secret-")+8 {
+		t.Errorf("generateSecret() name = %s, want secret- and an 8 digit hash", secret.Name)
+	}
+	if _, ok := secret.Annotations[needsHashAnnotation]; ok {
+		t.Errorf("generateSecret() asked kustomize to hash the name too")
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultHelmValuesKey is the data key of Helm values, which is also the
+// default of HelmRelease valuesFrom
+const defaultHelmValuesKey = "values.yaml"
+
+// HelmValues are sops-encrypted Helm values files, which are merged and
+// added as a single YAML document, for HelmRelease valuesFrom.
+type HelmValues struct {
+	// Key is the data key of the values, values.yaml if empty
+	Key   string   `json:"key,omitempty" yaml:"key,omitempty"`
+	Files []string `json:"files,omitempty" yaml:"files,omitempty"`
+}
+
+// key returns the data key of the values.
+func (v HelmValues) key() string {
+	if v.Key == "" {
+		return defaultHelmValuesKey
+	}
+	return v.Key
+}
+
+// parseHelmValues decrypts the Helm values files of a generator and adds
+// them, merged as Helm merges values files, under the key of the values.
+func parseHelmValues(values HelmValues, opts decryptOptions, data kvMap) error {
+	if len(values.Files) == 0 {
+		return nil
+	}
+	merged := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	var errs []error
+	for _, source := range values.Files {
+		node, err := decryptHelmValues(source, opts)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "helm values \"%s\"", source))
+			if opts.context().Err() != nil {
+				break
+			}
+			continue
+		}
+		mergeHelmValues(merged, node)
+	}
+	if len(errs) > 0 {
+		return joinSourceErrors(errs)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	err := encoder.Encode(merged)
+	if err != nil {
+		return errors.Wrap(err, "could not encode helm values")
+	}
+	data[values.key()] = encodeBase64(buf.Bytes())
+	wipe(buf.Bytes())
+	return nil
+}
+
+// decryptHelmValues decrypts a values file, in YAML or JSON, which must be
+// a mapping.
+func decryptHelmValues(source string, opts decryptOptions) (*yaml.Node, error) {
+	decrypted, err := decryptFile(source, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(decrypted)
+
+	var document yaml.Node
+	err = yaml.Unmarshal(decrypted, &document)
+	if err != nil {
+		return nil, err
+	}
+	if len(document.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, nil
+	}
+	if document.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("values must be a mapping")
+	}
+	return document.Content[0], nil
+}
+
+// mergeHelmValues merges values into base like Helm merges values files:
+// mappings are merged recursively, other values replace those of base, and
+// null removes a key.
+func mergeHelmValues(base *yaml.Node, values *yaml.Node) {
+	for i := 0; i+1 < len(values.Content); i += 2 {
+		key, value := values.Content[i], values.Content[i+1]
+		j := mappingIndex(base, key.Value)
+		switch {
+		case value.Tag == "!!null":
+			if j >= 0 {
+				base.Content = append(base.Content[:j], base.Content[j+2:]...)
+			}
+		case j < 0:
+			base.Content = append(base.Content, key, value)
+		case base.Content[j+1].Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+			mergeHelmValues(base.Content[j+1], value)
+		default:
+			base.Content[j+1] = value
+		}
+	}
+}
+
+// mappingIndex returns the index of a key in a mapping node, or -1.
+func mappingIndex(mapping *yaml.Node, key string) int {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,77 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func Test_mergeHelmValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		base   string
+		values string
+		want   string
+	}{
+		{"Add", "a: 1\n", "b: 2\n", "a: 1\nb: 2\n"},
+		{"Replace", "a: 1\nb: [1, 2]\n", "b: [3]\n", "a: 1\nb: [3]\n"},
+		{"Nested", "db:\n  host: db\n  port: 5432\n", "db:\n  port: 6432\n  user: app\n", "db:\n  host: db\n  port: 6432\n  user: app\n"},
+		{"ReplaceMapping", "db:\n  host: db\n", "db: external\n", "db: external\n"},
+		{"Null", "a: 1\nb: 2\n", "a: null\n", "b: 2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var base, values yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.base), &base); err != nil {
+				t.Fatal(err)
+			}
+			if err := yaml.Unmarshal([]byte(tt.values), &values); err != nil {
+				t.Fatal(err)
+			}
+			mergeHelmValues(base.Content[0], values.Content[0])
+
+			var want yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			got, _ := yaml.Marshal(base.Content[0])
+			wanted, _ := yaml.Marshal(want.Content[0])
+			if !bytes.Equal(got, wanted) {
+				t.Errorf("mergeHelmValues() = %s, want %s", got, wanted)
+			}
+		})
+	}
+}
+
+func Test_parseHelmValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  HelmValues
+		want    kvMap
+		wantErr bool
+	}{
+		{"None", HelmValues{}, kvMap{}, false},
+		{"Single", HelmValues{Files: []string{"testdata/file.yaml"}}, kvMap{"values.yaml": b64("var: secret\n")}, false},
+		{"Merged", HelmValues{Key: "values-prod.yaml", Files: []string{"testdata/file.yaml", "testdata/vars.yaml"}},
+			kvMap{"values-prod.yaml": b64("var: secret\nVAR_YAML: val_yaml\n")}, false},
+		{"NotMapping", HelmValues{Files: []string{"testdata/file.txt"}}, nil, true},
+		{"Missing", HelmValues{Files: []string{"testdata/missing.yaml"}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make(kvMap)
+			err := parseHelmValues(tt.values, decryptOptions{}, data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseHelmValues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(data, tt.want) {
+				t.Errorf("parseHelmValues() = %v, want %v", data, tt.want)
+			}
+		})
+	}
+}
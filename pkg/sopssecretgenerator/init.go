@@ -0,0 +1,226 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// secretNamePattern matches valid Secret names, DNS subdomains in Kubernetes
+var secretNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// initOptions are the options of the init subcommand
+type initOptions struct {
+	Name        string
+	Output      string
+	EnvSources  []string
+	FileSources []string
+	Exec        string
+	Age         []string
+	PGP         []string
+	Starter     bool
+	Force       bool
+}
+
+// sopsConfigFile is a .sops.yaml as written by init
+type sopsConfigFile struct {
+	CreationRules []sopsCreationRule `yaml:"creation_rules"`
+}
+
+// sopsCreationRule is a creation rule of a .sops.yaml
+type sopsCreationRule struct {
+	PathRegex string `yaml:"path_regex"`
+	Age       string `yaml:"age,omitempty"`
+	PGP       string `yaml:"pgp,omitempty"`
+}
+
+// runInit implements the init subcommand.
+func runInit(args []string) error {
+	flags := newFlagSet("init")
+	var opts initOptions
+	flags.StringVar(&opts.Output, "output", "generator.yaml", "`file` to write the generator manifest to")
+	flags.Func("env", "env source `file` (repeatable)", func(s string) error {
+		opts.EnvSources = append(opts.EnvSources, s)
+		return nil
+	})
+	flags.Func("file", "file source `[KEY=]FILE` (repeatable)", func(s string) error {
+		opts.FileSources = append(opts.FileSources, s)
+		return nil
+	})
+	flags.StringVar(&opts.Exec, "exec", "SopsSecretGenerator", "`path` of the exec function, or empty for the legacy plugin")
+	flags.Func("age", "age `recipient` for a .sops.yaml creation rule (repeatable)", func(s string) error {
+		opts.Age = append(opts.Age, s)
+		return nil
+	})
+	flags.Func("pgp", "PGP `fingerprint` for a .sops.yaml creation rule (repeatable)", func(s string) error {
+		opts.PGP = append(opts.PGP, s)
+		return nil
+	})
+	flags.BoolVar(&opts.Starter, "starter", false, "create encrypted starter files for sources that do not exist")
+	flags.BoolVar(&opts.Force, "force", false, "overwrite the manifest and .sops.yaml if they exist")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected the name of the Secret")
+	}
+	opts.Name = flags.Arg(0)
+	return scaffold(opts)
+}
+
+// scaffold writes a generator manifest, and optionally a .sops.yaml and
+// encrypted starter files, next to each other. Everything is checked before
+// the first file is written.
+func scaffold(opts initOptions) error {
+	if !secretNamePattern.MatchString(opts.Name) || len(opts.Name) > 253 {
+		return errors.Errorf("invalid Secret name \"%s\": use lowercase letters, digits, '-' and '.'", opts.Name)
+	}
+	if len(opts.EnvSources)+len(opts.FileSources) == 0 {
+		return errors.New("expected at least one --env or --file source")
+	}
+	manifest := generatorManifest{
+		TypeMeta:    TypeMeta{APIVersion: apiVersion, Kind: kind},
+		ObjectMeta:  ObjectMeta{Name: opts.Name},
+		EnvSources:  opts.EnvSources,
+		FileSources: opts.FileSources,
+	}
+	if opts.Exec != "" {
+		manifest.Annotations = kvMap{functionAnnotation: "exec:\n  path: " + opts.Exec + "\n"}
+	}
+	input := SopsSecretGenerator{TypeMeta: manifest.TypeMeta, ObjectMeta: manifest.ObjectMeta, EnvSources: opts.EnvSources, FileSources: opts.FileSources}
+	g := generatorFile{Path: opts.Output, Generator: input}
+	sources, err := g.sourceFiles()
+	if err != nil {
+		return err
+	}
+	for _, source := range opts.EnvSources {
+		filePath, _, _ := splitExtract(source)
+		if format := formats.FormatForPath(filePath); format == formats.Binary && !isCSV(filePath) && !isJSONC(filePath) {
+			return withCause(ErrUnknownFormat, errors.Errorf("env source \"%s\" must be a dotenv, yaml, json, jsonc or csv file", source))
+		}
+	}
+
+	dir := filepath.Dir(opts.Output)
+	confPath := filepath.Join(dir, ".sops.yaml")
+	writeConfig := len(opts.Age)+len(opts.PGP) > 0
+	var config []byte
+	if writeConfig {
+		rules, err := creationRuleConfig(dir, sources, opts.Age, opts.PGP)
+		if err != nil {
+			return err
+		}
+		config, err = marshalManifest(rules)
+		if err != nil {
+			return err
+		}
+	}
+	content, err := marshalManifest(manifest)
+	if err != nil {
+		return err
+	}
+	for _, fileName := range []string{opts.Output, confPath} {
+		if fileName == confPath && !writeConfig {
+			continue
+		}
+		if _, err := os.Stat(fileName); err == nil && !opts.Force {
+			return errors.Errorf("%s already exists, use --force to overwrite it", fileName)
+		}
+	}
+
+	err = os.WriteFile(opts.Output, content, 0o644)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("created %s\n", opts.Output)
+	if writeConfig {
+		err = os.WriteFile(confPath, config, 0o644)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created %s\n", confPath)
+	}
+	if opts.Starter {
+		for _, source := range sources {
+			if _, err := os.Stat(source); err == nil {
+				fmt.Printf("kept    %s\n", source)
+				continue
+			}
+			err = os.MkdirAll(filepath.Dir(source), 0o755)
+			if err != nil {
+				return err
+			}
+			err = encryptPlaintext(starterContent(source), "starter content", source, "", Policy{}, false)
+			if err != nil {
+				return errors.Wrapf(err, "could not create %s", source)
+			}
+			fmt.Printf("created %s, edit it with `SopsSecretGenerator edit %s`\n", source, source)
+		}
+	}
+	return nil
+}
+
+// creationRuleConfig returns a .sops.yaml for a directory, with a creation
+// rule for the source files. sops matches the rule against the path of a file
+// relative to the .sops.yaml, so the sources must be in the directory.
+func creationRuleConfig(dir string, sources []string, age []string, pgp []string) (sopsConfigFile, error) {
+	var paths []string
+	for _, source := range sources {
+		rel, err := filepath.Rel(dir, source)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return sopsConfigFile{}, errors.Errorf("source %s is outside %s, so a creation rule in %s cannot match it", source, dir, filepath.Join(dir, ".sops.yaml"))
+		}
+		paths = append(paths, regexp.QuoteMeta(filepath.ToSlash(rel)))
+	}
+	return sopsConfigFile{CreationRules: []sopsCreationRule{{
+		PathRegex: "^(" + strings.Join(paths, "|") + ")$",
+		Age:       strings.Join(age, ","),
+		PGP:       strings.Join(pgp, ","),
+	}}}, nil
+}
+
+// marshalManifest encodes a value as YAML with the indentation of the
+// manifests that kustomize users write.
+func marshalManifest(v interface{}) ([]byte, error) {
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	err := encoder.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	err = encoder.Close()
+	return out.Bytes(), err
+}
+
+// starterContent returns placeholder plaintext for a source file, in the
+// format of the file.
+func starterContent(fileName string) []byte {
+	switch {
+	case isCSV(fileName):
+		return []byte("EXAMPLE,changeme\n")
+	case isJSONC(fileName):
+		return []byte("{\n  // A comment\n  \"EXAMPLE\": \"changeme\",\n}\n")
+	}
+	switch formats.FormatForPath(fileName) {
+	case formats.Dotenv:
+		return []byte("EXAMPLE=changeme\n")
+	case formats.Yaml:
+		return []byte("EXAMPLE: changeme\n")
+	case formats.Json:
+		return []byte("{\"EXAMPLE\": \"changeme\"}\n")
+	default:
+		return []byte("changeme\n")
+	}
+}
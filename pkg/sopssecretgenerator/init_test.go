@@ -0,0 +1,115 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_scaffold(t *testing.T) {
+	setupEncryptionKeyring(t)
+
+	tests := []struct {
+		name       string
+		opts       initOptions
+		existing   []string
+		want       []string
+		wantConfig string
+		wantErr    bool
+	}{
+		{"Manifest", initOptions{Name: "app", EnvSources: []string{"app.env"}, Exec: "SopsSecretGenerator"}, nil,
+			[]string{"name: app", "envs:\n  - app.env", "path: SopsSecretGenerator"}, "", false},
+		{"LegacyPlugin", initOptions{Name: "app", FileSources: []string{"tls.crt"}}, nil,
+			[]string{"files:\n  - tls.crt"}, "", false},
+		{"CreationRule", initOptions{Name: "app", EnvSources: []string{"app.env"}, FileSources: []string{"key=secrets/db.yaml"}, PGP: []string{testkeyFingerprint}}, nil,
+			[]string{"key=secrets/db.yaml"}, "path_regex: ^(app\\.env|secrets/db\\.yaml)$\n    pgp: " + testkeyFingerprint, false},
+		{"Starter", initOptions{Name: "app", EnvSources: []string{"app.env"}, FileSources: []string{"secrets/db.yaml", "tls.crt"}, PGP: []string{testkeyFingerprint}, Starter: true}, nil,
+			[]string{"name: app"}, "pgp: " + testkeyFingerprint, false},
+		{"Force", initOptions{Name: "app", EnvSources: []string{"app.env"}, Force: true}, []string{"generator.yaml"},
+			[]string{"name: app"}, "", false},
+		{"Exists", initOptions{Name: "app", EnvSources: []string{"app.env"}}, []string{"generator.yaml"}, nil, "", true},
+		{"ConfigExists", initOptions{Name: "app", EnvSources: []string{"app.env"}, Age: []string{testAgeRecipient}}, []string{".sops.yaml"}, nil, "", true},
+		{"InvalidName", initOptions{Name: "My_App", EnvSources: []string{"app.env"}}, nil, nil, "", true},
+		{"NoSources", initOptions{Name: "app"}, nil, nil, "", true},
+		{"BinaryEnv", initOptions{Name: "app", EnvSources: []string{"app.txt"}}, nil, nil, "", true},
+		{"OutsideRule", initOptions{Name: "app", EnvSources: []string{"../app.env"}, Age: []string{testAgeRecipient}}, nil, nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tt.existing {
+				writeTestFile(t, filepath.Join(dir, name), "existing\n")
+			}
+			tt.opts.Output = filepath.Join(dir, "generator.yaml")
+
+			err := scaffold(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("scaffold() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if len(tt.existing) == 0 {
+					if _, statErr := os.Stat(tt.opts.Output); !os.IsNotExist(statErr) {
+						t.Errorf("scaffold() wrote the manifest on error")
+					}
+				}
+				return
+			}
+			manifest, err := os.ReadFile(tt.opts.Output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(manifest), want) {
+					t.Errorf("manifest = %q, want it to contain %q", manifest, want)
+				}
+			}
+			config, err := os.ReadFile(filepath.Join(dir, ".sops.yaml"))
+			if tt.wantConfig == "" {
+				if !os.IsNotExist(err) {
+					t.Errorf("scaffold() wrote .sops.yaml without recipients")
+				}
+			} else if !strings.Contains(string(config), tt.wantConfig) {
+				t.Errorf(".sops.yaml = %q, want it to contain %q", config, tt.wantConfig)
+			}
+			if !tt.opts.Starter {
+				return
+			}
+			input, err := readInput(manifest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys, err := generatorKeys(generatorFile{Path: tt.opts.Output, Generator: input})
+			if err != nil {
+				t.Fatalf("generatorKeys() error = %v", err)
+			}
+			if len(keys) != 3 {
+				t.Errorf("generatorKeys() = %v, want the starter keys of 3 sources", keys)
+			}
+		})
+	}
+}
+
+func Test_starterContent(t *testing.T) {
+	tests := []struct {
+		fileName string
+		want     string
+	}{
+		{"app.env", "EXAMPLE=changeme\n"},
+		{"app.yaml", "EXAMPLE: changeme\n"},
+		{"app.json", "{\"EXAMPLE\": \"changeme\"}\n"},
+		{"app.csv", "EXAMPLE,changeme\n"},
+		{"app.jsonc", "{\n  // A comment\n  \"EXAMPLE\": \"changeme\",\n}\n"},
+		{"tls.crt", "changeme\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			if got := string(starterContent(tt.fileName)); got != tt.want {
+				t.Errorf("starterContent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
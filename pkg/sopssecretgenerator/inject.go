@@ -0,0 +1,124 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// runInject implements the inject subcommand.
+func runInject(args []string) error {
+	flags := newFlagSet("inject")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return errors.New("expected manifests on stdin")
+	}
+	return injectSecrets(os.Stdin, os.Stdout)
+}
+
+// injectSecrets reads a stream of manifests, such as those that Skaffold
+// renders, and writes it with its generators replaced by the Secrets they
+// generate. The Secrets come first, so that they exist before the workloads
+// that use them, followed by the other manifests in their order. Annotations
+// that only kustomize acts on are removed from the Secrets.
+func injectSecrets(r io.Reader, w io.Writer) error {
+	objects, err := parseManifests(r)
+	if err != nil {
+		return errors.Wrap(err, "could not read manifests")
+	}
+	var generators, others fn.KubeObjects
+	for _, obj := range objects {
+		switch {
+		case obj.GetAPIVersion() != apiVersion:
+			others = append(others, obj)
+		case obj.GetKind() == kind, obj.GetKind() == listKind, obj.GetKind() == defaultsKind:
+			generators = append(generators, obj)
+		default:
+			others = append(others, obj)
+		}
+	}
+
+	var secrets fn.KubeObjects
+	if len(generators) > 0 {
+		rl := &fn.ResourceList{Items: generators}
+		_, err = generateKRMManifest(rl)
+		if err != nil {
+			return err
+		}
+		secrets = rl.Items
+	}
+	for _, secret := range secrets {
+		for name := range secret.GetAnnotations() {
+			if isOrchestratorAnnotation(name) || strings.HasPrefix(name, "kustomize.config.k8s.io/") {
+				_, err = secret.RemoveNestedField("metadata", "annotations", name)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if len(secret.GetAnnotations()) == 0 {
+			_, _ = secret.RemoveNestedField("metadata", "annotations")
+		}
+	}
+
+	for i, obj := range append(secrets, others...) {
+		if i > 0 {
+			_, err = io.WriteString(w, "---\n")
+			if err != nil {
+				return err
+			}
+		}
+		_, err = io.WriteString(w, obj.String())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseManifests parses a stream of YAML documents into objects, skipping
+// empty documents.
+func parseManifests(r io.Reader) (fn.KubeObjects, error) {
+	var objects fn.KubeObjects
+	decoder := yaml.NewDecoder(r)
+	for i := 1; ; i++ {
+		var node yaml.Node
+		err := decoder.Decode(&node)
+		if err == io.EOF {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "document %d", i)
+		}
+		if len(node.Content) == 0 || node.Content[0].Tag == "!!null" {
+			continue
+		}
+		var content bytes.Buffer
+		encoder := yaml.NewEncoder(&content)
+		encoder.SetIndent(2)
+		err = encoder.Encode(&node)
+		if err == nil {
+			err = encoder.Close()
+		}
+		if err != nil {
+			return nil, err
+		}
+		obj, err := fn.ParseKubeObject(content.Bytes())
+		if err != nil {
+			return nil, errors.Wrapf(err, "document %d", i)
+		}
+		objects = append(objects, obj)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_injectSecrets(t *testing.T) {
+	input := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+---
+---
+apiVersion: kustomize.freightdog.com/v1
+kind: SopsSecretGenerator
+metadata:
+  name: app-secrets
+  annotations:
+    owner: team
+envs:
+  - testdata/vars.env
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+`
+	var out bytes.Buffer
+	err := injectSecrets(strings.NewReader(input), &out)
+	if err != nil {
+		t.Fatalf("injectSecrets() error = %v", err)
+	}
+	objects, err := parseManifests(&out)
+	if err != nil {
+		t.Fatalf("injectSecrets() wrote invalid manifests: %v\n%s", err, out.String())
+	}
+	var got []string
+	for _, obj := range objects {
+		got = append(got, obj.GetKind()+"/"+obj.GetName())
+	}
+	want := []string{"Secret/app-secrets", "Deployment/app", "ConfigMap/app-config"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("injectSecrets() = %v, want %v", got, want)
+	}
+	if annotations := objects[0].GetAnnotations(); !reflect.DeepEqual(annotations, map[string]string{"owner": "team"}) {
+		t.Errorf("injectSecrets() Secret annotations = %v, want only the generator's", annotations)
+	}
+	if value, _, _ := objects[0].NestedString("data", "VAR_ENV"); value != b64("val_env") {
+		t.Errorf("injectSecrets() Secret VAR_ENV = %q, want %q", value, b64("val_env"))
+	}
+}
+
+func Test_injectSecrets_noGenerators(t *testing.T) {
+	input := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n"
+	var out bytes.Buffer
+	err := injectSecrets(strings.NewReader(input), &out)
+	if err != nil {
+		t.Fatalf("injectSecrets() error = %v", err)
+	}
+	if out.String() != input {
+		t.Errorf("injectSecrets() = %q, want the manifests as they are", out.String())
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+// isJSONC reports whether a file is JSON with comments, by its .jsonc
+// extension. sops cannot parse comments, so it encrypts these files as
+// binary files, like any file with an extension it does not know. JSON5
+// files are not taken as JSONC, since most of JSON5 is not.
+func isJSONC(filePath string) bool {
+	return strings.EqualFold(filepath.Ext(filePath), ".jsonc")
+}
+
+// parseJSONCContent parses a JSON env source with comments and trailing
+// commas. They are blanked out, so that errors have the same line and
+// column as in the file, and the rest is parsed as JSON.
+func parseJSONCContent(content []byte, data kvMap) error {
+	plain, err := stripJSONC(content)
+	if err != nil {
+		return err
+	}
+	defer wipe(plain)
+	return parseJSONContent(plain, data)
+}
+
+// stripJSONC returns a copy of JSONC content with `//` and `/* */` comments
+// and commas before a closing bracket replaced by spaces. Newlines are kept,
+// so that every byte stays at its position.
+func stripJSONC(content []byte) ([]byte, error) {
+	plain := make([]byte, len(content))
+	copy(plain, content)
+	inString := false
+	// comma is the offset of the last comma, while only whitespace and
+	// comments follow it
+	comma := -1
+	for i := 0; i < len(plain); i++ {
+		switch c := plain[i]; {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '/' && i+1 < len(plain) && plain[i+1] == '/':
+			for ; i < len(plain) && plain[i] != '\n'; i++ {
+				plain[i] = ' '
+			}
+		case c == '/' && i+1 < len(plain) && plain[i+1] == '*':
+			end := bytes.Index(plain[i+2:], []byte("*/"))
+			if end < 0 {
+				line, column := offsetPosition(content, int64(i))
+				wipe(plain)
+				return nil, parseError{Line: line, Column: column, Message: "comment is not closed"}
+			}
+			end += i + 4
+			for ; i < end; i++ {
+				if plain[i] != '\n' {
+					plain[i] = ' '
+				}
+			}
+			i--
+		case c == ',':
+			comma = i
+		case c == '}' || c == ']':
+			if comma >= 0 {
+				plain[comma] = ' '
+			}
+			comma = -1
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			// Whitespace keeps a trailing comma pending
+		default:
+			inString = c == '"'
+			comma = -1
+		}
+	}
+	return plain, nil
+}
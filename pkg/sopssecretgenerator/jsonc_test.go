@@ -0,0 +1,63 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseJSONCContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    kvMap
+		wantErr string
+	}{
+		{"Plain", `{"A": "a", "B": "b"}`, kvMap{"A": b64("a"), "B": b64("b")}, ""},
+		{"LineComments", "{\n  // The first\n  \"A\": \"a\", // trailing\n  \"B\": \"b\"\n}\n", kvMap{"A": b64("a"), "B": b64("b")}, ""},
+		{"BlockComments", "/* header\n */\n{\"A\": /* inline */ \"a\"}", kvMap{"A": b64("a")}, ""},
+		{"TrailingComma", "{\n  \"A\": \"a\",\n  \"B\": \"b\", // last\n}\n", kvMap{"A": b64("a"), "B": b64("b")}, ""},
+		{"TrailingCommaComment", `{"A": "a", /* none */ }`, kvMap{"A": b64("a")}, ""},
+		{"CommentsInStrings", `{"A": "http://x/*y*/", "B": ",}"}`, kvMap{"A": b64("http://x/*y*/"), "B": b64(",}")}, ""},
+		{"EscapedQuote", `{"A": "a\"//b", "B": "c\\"}`, kvMap{"A": b64(`a"//b`), "B": b64(`c\`)}, ""},
+		{"Empty", "// nothing\n{}\n", kvMap{}, ""},
+		{"NotClosed", `{"A": "a" /* secret`, nil, "line 1, column 11: comment is not closed"},
+		{"TypeAfterComment", "{\n  // \"A\": \"secret\"\n  \"A\": 1\n}", nil, "line 3, column "},
+		{"DoubleComma", `{"A": "a",,}`, nil, "invalid character"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(kvMap)
+			err := parseJSONCContent([]byte(tt.content), got)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseJSONCContent() error = %v, want %s", err, tt.wantErr)
+				}
+				if strings.Contains(err.Error(), "secret") {
+					t.Errorf("parseJSONCContent() error = %v, has content", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseJSONCContent() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseJSONCContent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_stripJSONC_positions(t *testing.T) {
+	content := "{\n  /* a\n  b */ \"A\": \"a\", // c\n}"
+	got, err := stripJSONC([]byte(content))
+	if err != nil {
+		t.Fatalf("stripJSONC() error = %v", err)
+	}
+	if want := "{\n      \n       \"A\": \"a\"      \n}"; string(got) != want {
+		t.Errorf("stripJSONC() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// checkKeyCase returns an error for keys of a Secret that differ only by
+// case, such as Token and TOKEN. Both would become distinct environment
+// variables, while the generator most likely meant one of them.
+func checkKeyCase(data kvMap) error {
+	seen := make(map[string]string, len(data))
+	for _, key := range slices.Sorted(maps.Keys(data)) {
+		folded := strings.ToLower(key)
+		if other, ok := seen[folded]; ok {
+			return errors.Errorf("keys %s and %s differ only by case", other, key)
+		}
+		seen[folded] = key
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func Test_checkKeyCase(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    kvMap
+		wantErr bool
+	}{
+		{"None", kvMap{}, false},
+		{"Distinct", kvMap{"TOKEN": "", "SECRET": "", "token.txt": ""}, false},
+		{"Case", kvMap{"Token": "", "TOKEN": ""}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkKeyCase(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkKeyCase() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_generateSecret_caseInsensitiveKeys(t *testing.T) {
+	input := ssg([]string{"testdata/vars.env"}, []string{"var_env=testdata/file.txt"})
+	_, err := generateSecret(context.Background(), input, Options{})
+	if err != nil {
+		t.Fatalf("generateSecret() error = %v", err)
+	}
+	input.CaseInsensitiveKeys = true
+	_, err = generateSecret(context.Background(), input, Options{})
+	if !errors.Is(err, ErrInvalidGenerator) {
+		t.Errorf("generateSecret() error = %v, want %v", err, ErrInvalidGenerator)
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	sopsage "github.com/getsops/sops/v3/age"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// runKeygen implements the keygen subcommand.
+func runKeygen(args []string) error {
+	flags := newFlagSet("keygen")
+	output := flags.String("output", "", "`file` to append the identity to (default: $SOPS_AGE_KEY_FILE or the sops age key file), - for standard output")
+	sopsConfig := flags.String("sops-config", "", "`.sops.yaml` whose creation rules the recipient is added to")
+	pathRegex := flags.String("path-regex", "", "only add the recipient to the creation rules with this `path_regex`")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return errors.New("expected no arguments")
+	}
+	if *pathRegex != "" && *sopsConfig == "" {
+		return withCause(errFlags, errors.New("--path-regex requires --sops-config"))
+	}
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return err
+	}
+	recipient := identity.Recipient().String()
+	if *output == "-" {
+		err = writeIdentity(os.Stdout, identity)
+	} else {
+		if *output == "" {
+			*output = os.Getenv(sopsage.SopsAgeKeyFileEnv)
+		}
+		if *output == "" {
+			*output, err = userAgeKeyFile()
+			if err != nil {
+				return errors.Wrap(err, "could not find the sops age key file, use --output")
+			}
+		}
+		err = appendIdentity(*output, identity)
+		if err == nil {
+			_, _ = fmt.Fprintf(os.Stderr, "keygen: added the identity to %s\n", *output)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(os.Stdout, recipient)
+
+	if *sopsConfig != "" {
+		added, err := addAgeRecipient(*sopsConfig, recipient, *pathRegex)
+		if err != nil {
+			return errors.Wrap(err, *sopsConfig)
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "keygen: added the recipient to %d creation rules of %s; "+
+			"existing files are only encrypted to it after someone who can decrypt them runs `rotate --update-keys`\n", added, *sopsConfig)
+	}
+	return nil
+}
+
+// writeIdentity writes an age identity in the format of age-keygen, with its
+// creation time and public key in comments.
+func writeIdentity(w io.Writer, identity *age.X25519Identity) error {
+	_, err := fmt.Fprintf(w, "# created: %s\n# public key: %s\n%s\n",
+		time.Now().Format(time.RFC3339), identity.Recipient(), identity)
+	return err
+}
+
+// appendIdentity appends an age identity to a key file that only the user
+// can read, creating the file and its directory if needed.
+func appendIdentity(fileName string, identity *age.X25519Identity) error {
+	err := os.MkdirAll(filepath.Dir(fileName), 0o700)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fileName, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	err = writeIdentity(f, identity)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// addAgeRecipient adds an age recipient to the creation rules of a
+// .sops.yaml, or only to those with the given path_regex, keeping comments.
+// Rules with key groups are left alone, since it is not clear which group
+// the recipient belongs in. It returns the number of rules it changed, and
+// fails if there are none, unless all matching rules have the recipient
+// already.
+func addAgeRecipient(confPath string, recipient string, pathRegex string) (int, error) {
+	info, err := os.Stat(confPath)
+	if err != nil {
+		return 0, err
+	}
+	content, err := os.ReadFile(confPath)
+	if err != nil {
+		return 0, err
+	}
+	var document yaml.Node
+	err = yaml.Unmarshal(content, &document)
+	if err != nil {
+		return 0, err
+	}
+	rules := mappingValue(&document, "creation_rules")
+	if rules == nil || rules.Kind != yaml.SequenceNode {
+		return 0, errors.New("no creation_rules")
+	}
+
+	added, present := 0, 0
+	for _, rule := range rules.Content {
+		if rule.Kind != yaml.MappingNode {
+			continue
+		}
+		if regex := mappingValue(rule, "path_regex"); pathRegex != "" && (regex == nil || regex.Value != pathRegex) {
+			continue
+		}
+		if mappingValue(rule, "key_groups") != nil {
+			continue
+		}
+		recipients := mappingValue(rule, "age")
+		switch {
+		case recipients == nil:
+			rule.Content = append(rule.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "age"},
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: recipient})
+		case recipients.Kind == yaml.SequenceNode:
+			if hasRecipient(recipients.Content, recipient) {
+				present++
+				continue
+			}
+			recipients.Content = append(recipients.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: recipient})
+		case recipients.Kind == yaml.ScalarNode:
+			if hasRecipient([]*yaml.Node{recipients}, recipient) {
+				present++
+				continue
+			}
+			recipients.Value = strings.TrimRight(recipients.Value, ", \n") + "," + recipient
+		default:
+			continue
+		}
+		added++
+	}
+	if added == 0 {
+		if present > 0 {
+			return 0, nil
+		}
+		if pathRegex != "" {
+			return 0, errors.Errorf("no creation rule with path_regex %s and without key_groups", pathRegex)
+		}
+		return 0, errors.New("no creation rule without key_groups")
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	err = encoder.Encode(&document)
+	if err == nil {
+		err = encoder.Close()
+	}
+	if err != nil {
+		return 0, err
+	}
+	return added, os.WriteFile(confPath, out.Bytes(), info.Mode().Perm())
+}
+
+// mappingValue returns the value of a key of a mapping, or of the mapping of
+// a document, or nil if there is no such key.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// hasRecipient reports whether scalar nodes of comma-separated recipients
+// include a recipient.
+func hasRecipient(nodes []*yaml.Node, recipient string) bool {
+	for _, node := range nodes {
+		for _, r := range strings.Split(node.Value, ",") {
+			if strings.TrimSpace(r) == recipient {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func Test_appendIdentity(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "sops", "age", "keys.txt")
+	for i := 0; i < 2; i++ {
+		identity, err := age.GenerateX25519Identity()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = appendIdentity(fileName, identity)
+		if err != nil {
+			t.Fatalf("appendIdentity() error = %v", err)
+		}
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	identities, err := age.ParseIdentities(f)
+	if err != nil || len(identities) != 2 {
+		t.Errorf("appendIdentity() wrote %d identities, error = %v, want 2", len(identities), err)
+	}
+	info, err := os.Stat(fileName)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("appendIdentity() wrote %v, %v, want mode 0600", info, err)
+	}
+}
+
+func Test_addAgeRecipient(t *testing.T) {
+	const recipient = "age1new"
+	tests := []struct {
+		name      string
+		config    string
+		pathRegex string
+		want      string
+		wantAdded int
+		wantErr   bool
+	}{
+		{"Scalar", "# keys\ncreation_rules:\n  - path_regex: \\.env$\n    age: age1old\n", "",
+			"# keys\ncreation_rules:\n  - path_regex: \\.env$\n    age: age1old,age1new\n", 1, false},
+		{"Sequence", "creation_rules:\n  - age:\n      - age1old\n", "",
+			"creation_rules:\n  - age:\n      - age1old\n      - age1new\n", 1, false},
+		{"Missing", "creation_rules:\n  - pgp: ABCD\n", "",
+			"creation_rules:\n  - pgp: ABCD\n    age: age1new\n", 1, false},
+		{"PathRegex", "creation_rules:\n  - path_regex: prod\n    age: age1old\n  - path_regex: dev\n    age: age1old\n", "dev",
+			"creation_rules:\n  - path_regex: prod\n    age: age1old\n  - path_regex: dev\n    age: age1old,age1new\n", 1, false},
+		{"Present", "creation_rules:\n  - age: age1old, age1new\n", "",
+			"creation_rules:\n  - age: age1old, age1new\n", 0, false},
+		{"KeyGroups", "creation_rules:\n  - key_groups:\n      - age:\n          - age1old\n", "", "", 0, true},
+		{"UnknownPathRegex", "creation_rules:\n  - age: age1old\n", "prod", "", 0, true},
+		{"NoRules", "stores: {}\n", "", "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			confPath := filepath.Join(t.TempDir(), ".sops.yaml")
+			writeTestFile(t, confPath, tt.config)
+			added, err := addAgeRecipient(confPath, recipient, tt.pathRegex)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("addAgeRecipient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if added != tt.wantAdded {
+				t.Errorf("addAgeRecipient() = %d, want %d", added, tt.wantAdded)
+			}
+			if tt.wantErr {
+				return
+			}
+			content, err := os.ReadFile(confPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != tt.want {
+				t.Errorf("addAgeRecipient() wrote %q, want %q", content, tt.want)
+			}
+		})
+	}
+}
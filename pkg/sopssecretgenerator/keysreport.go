@@ -0,0 +1,122 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+)
+
+// fileKeysReport is the sops keys of a source file, as keys-report reports
+// them
+type fileKeysReport struct {
+	File string `json:"file"`
+	// ShamirThreshold is the number of key groups needed to decrypt the
+	// file
+	ShamirThreshold int             `json:"shamirThreshold,omitempty"`
+	KeyGroups       [][]reportedKey `json:"keyGroups,omitempty"`
+	Error           string          `json:"error,omitempty"`
+}
+
+// reportedKey is a key of a key group: its type, as sops names it in the
+// metadata, such as age, pgp or kms, and the recipient, fingerprint or ARN
+type reportedKey struct {
+	Type string `json:"type"`
+	Key  string `json:"key"`
+}
+
+// runKeysReport implements the keys-report subcommand.
+func runKeysReport(args []string) error {
+	flags := newFlagSet("keys-report")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	root := "."
+	if flags.NArg() > 0 {
+		root = flags.Arg(0)
+	}
+
+	files, err := referencedFiles(root)
+	if err != nil {
+		return err
+	}
+	report := keysReport(files)
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = printKeysReport(os.Stdout, report)
+	}
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, file := range report {
+		if file.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("%d of %d files could not be read", failed, len(report))
+	}
+	return nil
+}
+
+// keysReport reads the key groups of source files from their sops metadata,
+// without decrypting them. Files that do not exist are skipped, lint reports
+// them; files that are not encrypted are reported with an error.
+func keysReport(files []string) []fileKeysReport {
+	report := make([]fileKeysReport, 0, len(files))
+	for _, file := range files {
+		tree, _, err := loadEncryptedTree(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		entry := fileKeysReport{File: file}
+		if err != nil {
+			entry.Error = err.Error()
+			report = append(report, entry)
+			continue
+		}
+		entry.ShamirThreshold = shamirThreshold(tree.Metadata)
+		for _, group := range tree.Metadata.KeyGroups {
+			keys := make([]reportedKey, 0, len(group))
+			for _, key := range group {
+				keys = append(keys, reportedKey{Type: key.TypeToIdentifier(), Key: key.ToString()})
+			}
+			entry.KeyGroups = append(entry.KeyGroups, keys)
+		}
+		report = append(report, entry)
+	}
+	return report
+}
+
+// printKeysReport writes a report as a table, with a row for every key of
+// every file, so that it can be filtered with grep. Groups are numbered from
+// 1, and the threshold is the number of groups needed to decrypt the file.
+func printKeysReport(w io.Writer, report []fileKeysReport) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "FILE\tGROUP\tTHRESHOLD\tTYPE\tKEY")
+	for _, file := range report {
+		if file.Error != "" {
+			_, _ = fmt.Fprintf(tw, "%s\t-\t-\terror\t%s\n", file.File, file.Error)
+			continue
+		}
+		for i, group := range file.KeyGroups {
+			for _, key := range group {
+				_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\n", file.File, i+1, file.ShamirThreshold, key.Type, key.Key)
+			}
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,50 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func Test_keysReport(t *testing.T) {
+	got := keysReport([]string{"testdata/file.txt", "testdata/missing.env", "testdata/notyaml.txt"})
+	if len(got) != 2 {
+		t.Fatalf("keysReport() = %+v, want 2 files", got)
+	}
+	want := fileKeysReport{
+		File:            "testdata/file.txt",
+		ShamirThreshold: 1,
+		KeyGroups:       [][]reportedKey{{{Type: "pgp", Key: testkeyFingerprint}}},
+	}
+	if !reflect.DeepEqual(got[0], want) {
+		t.Errorf("keysReport() = %+v, want %+v", got[0], want)
+	}
+	if got[1].File != "testdata/notyaml.txt" || got[1].Error == "" {
+		t.Errorf("keysReport() = %+v, want an error for a file that is not encrypted", got[1])
+	}
+}
+
+func Test_printKeysReport(t *testing.T) {
+	report := []fileKeysReport{
+		{File: "app.env", ShamirThreshold: 2, KeyGroups: [][]reportedKey{
+			{{Type: "age", Key: testAgeRecipient}},
+			{{Type: "pgp", Key: testkeyFingerprint}, {Type: "kms", Key: "arn:aws:kms:eu-west-1:111122223333:key/1"}},
+		}},
+		{File: "plain.env", Error: "file is not encrypted with sops"},
+	}
+	var buf bytes.Buffer
+	if err := printKeysReport(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+	want := "FILE       GROUP  THRESHOLD  TYPE   KEY\n" +
+		"app.env    1      2          age    " + testAgeRecipient + "\n" +
+		"app.env    2      2          pgp    " + testkeyFingerprint + "\n" +
+		"app.env    2      2          kms    arn:aws:kms:eu-west-1:111122223333:key/1\n" +
+		"plain.env  -      -          error  file is not encrypted with sops\n"
+	if buf.String() != want {
+		t.Errorf("printKeysReport() = \n%s, want \n%s", buf.String(), want)
+	}
+}
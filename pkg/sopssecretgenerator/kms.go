@@ -0,0 +1,110 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/keyservice"
+	"github.com/getsops/sops/v3/kms"
+	"github.com/pkg/errors"
+)
+
+// KMSOptions controls how AWS KMS keys are tried when a file is encrypted to
+// KMS keys in several regions, and how transient errors of network key
+// services are retried
+type KMSOptions struct {
+	Regions        []string `json:"regions,omitempty" yaml:"regions,omitempty"`
+	AttemptTimeout string   `json:"attemptTimeout,omitempty" yaml:"attemptTimeout,omitempty"`
+	Retries        int      `json:"retries,omitempty" yaml:"retries,omitempty"`
+	RetryBackoff   string   `json:"retryBackoff,omitempty" yaml:"retryBackoff,omitempty"`
+}
+
+// kmsRegion returns the region of a KMS key ARN, or an empty string if the
+// ARN cannot be parsed.
+func kmsRegion(arn string) string {
+	parts := strings.SplitN(arn, ":", 5)
+	if len(parts) < 5 || parts[0] != "arn" || parts[2] != "kms" {
+		return ""
+	}
+	return parts[3]
+}
+
+// orderKMSKeys moves the KMS keys in the given regions to the front of each
+// key group, in the order of the regions. sops tries the keys of a group in
+// order, so this decides which region is asked first. Other keys keep their
+// relative order.
+func orderKMSKeys(metadata *sops.Metadata, regions []string) {
+	rank := func(key interface{ TypeToIdentifier() string }) int {
+		if k, ok := key.(*kms.MasterKey); ok {
+			for i, region := range regions {
+				if kmsRegion(k.Arn) == region {
+					return i
+				}
+			}
+		}
+		return len(regions)
+	}
+	for _, group := range metadata.KeyGroups {
+		sort.SliceStable(group, func(i, j int) bool {
+			return rank(group[i]) < rank(group[j])
+		})
+	}
+}
+
+// hasKMSKeys reports whether a file is encrypted to any AWS KMS keys.
+func hasKMSKeys(metadata sops.Metadata) bool {
+	for _, group := range metadata.KeyGroups {
+		for _, key := range group {
+			if key.TypeToIdentifier() == kms.KeyTypeIdentifier {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// kmsFailoverServer is a local key service that gives up on a KMS key after a
+// timeout, so that sops falls through to the next key instead of waiting for
+// an unreachable region. Other keys are passed on unchanged.
+type kmsFailoverServer struct {
+	next    keyservice.KeyServiceServer
+	timeout time.Duration
+}
+
+// Encrypt encrypts a data key.
+func (s kmsFailoverServer) Encrypt(ctx context.Context, req *keyservice.EncryptRequest) (*keyservice.EncryptResponse, error) {
+	return s.next.Encrypt(ctx, req)
+}
+
+// Decrypt decrypts a data key.
+func (s kmsFailoverServer) Decrypt(ctx context.Context, req *keyservice.DecryptRequest) (*keyservice.DecryptResponse, error) {
+	key, ok := req.Key.KeyType.(*keyservice.Key_KmsKey)
+	if !ok {
+		return s.next.Decrypt(ctx, req)
+	}
+
+	type result struct {
+		response *keyservice.DecryptResponse
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		response, err := s.next.Decrypt(ctx, req)
+		done <- result{response, err}
+	}()
+
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.response, r.err
+	case <-timer.C:
+		return nil, errors.Errorf("KMS key %s did not respond within %s", key.KmsKey.Arn, s.timeout)
+	}
+}
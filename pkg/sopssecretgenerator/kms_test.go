@@ -0,0 +1,144 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/age"
+	"github.com/getsops/sops/v3/keyservice"
+	"github.com/getsops/sops/v3/kms"
+)
+
+const (
+	testKMSArnWest    = "arn:aws:kms:eu-west-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+	testKMSArnCentral = "arn:aws:kms:eu-central-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+	testKMSArnUS      = "arn:aws:kms:us-east-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+)
+
+func Test_kmsRegion(t *testing.T) {
+	tests := []struct {
+		arn  string
+		want string
+	}{
+		{testKMSArnWest, "eu-west-1"},
+		{testKMSArn, "eu-west-1"},
+		{"arn:aws-cn:kms:cn-north-1:111122223333:alias/x", "cn-north-1"},
+		{"not-an-arn", ""},
+		{"arn:aws:s3:::bucket", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arn, func(t *testing.T) {
+			if got := kmsRegion(tt.arn); got != tt.want {
+				t.Errorf("kmsRegion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_orderKMSKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		regions []string
+		want    []string
+	}{
+		{"NoRegions", nil, []string{testKMSArnUS, testAgeRecipient, testKMSArnWest, testKMSArnCentral}},
+		{"Preferred", []string{"eu-central-1", "eu-west-1"}, []string{testKMSArnCentral, testKMSArnWest, testKMSArnUS, testAgeRecipient}},
+		{"UnknownRegion", []string{"ap-south-1"}, []string{testKMSArnUS, testAgeRecipient, testKMSArnWest, testKMSArnCentral}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata := keyGroupsMetadata(sops.KeyGroup{
+				&kms.MasterKey{Arn: testKMSArnUS},
+				&age.MasterKey{Recipient: testAgeRecipient},
+				&kms.MasterKey{Arn: testKMSArnWest},
+				&kms.MasterKey{Arn: testKMSArnCentral},
+			})
+			orderKMSKeys(&metadata, tt.regions)
+			var got []string
+			for _, key := range metadata.KeyGroups[0] {
+				got = append(got, key.ToString())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("orderKMSKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_kmsFailoverServer_Decrypt(t *testing.T) {
+	kmsRequest := &keyservice.DecryptRequest{Key: &keyservice.Key{KeyType: &keyservice.Key_KmsKey{KmsKey: &keyservice.KmsKey{Arn: testKMSArnWest}}}}
+	ageRequest := &keyservice.DecryptRequest{Key: &keyservice.Key{KeyType: &keyservice.Key_AgeKey{AgeKey: &keyservice.AgeKey{Recipient: testAgeRecipient}}}}
+
+	tests := []struct {
+		name    string
+		delay   time.Duration
+		request *keyservice.DecryptRequest
+		wantErr bool
+	}{
+		{"Fast", 0, kmsRequest, false},
+		{"Slow", time.Second, kmsRequest, true},
+		{"SlowNotKMS", 100 * time.Millisecond, ageRequest, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := kmsFailoverServer{next: slowKeyServer{tt.delay}, timeout: 50 * time.Millisecond}
+			got, err := server.Decrypt(context.Background(), tt.request)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decrypt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got.Plaintext) != "data key" {
+				t.Errorf("Decrypt() = %q, want %q", got.Plaintext, "data key")
+			}
+		})
+	}
+}
+
+func Test_decryptKeyServer(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	kmsMetadata := keyGroupsMetadata(sops.KeyGroup{&kms.MasterKey{Arn: testKMSArnWest}})
+	tests := []struct {
+		name     string
+		metadata sops.Metadata
+		opts     decryptOptions
+		want     bool
+	}{
+		{"PGP", pgpMetadata(testkeyFingerprint), decryptOptions{}, false},
+		{"KMS", kmsMetadata, decryptOptions{}, true},
+		{"AttemptTimeout", kmsMetadata, decryptOptions{KMSAttemptTimeout: time.Second}, true},
+		{"AttemptTimeoutNoKMS", pgpMetadata(testkeyFingerprint), decryptOptions{KMSAttemptTimeout: time.Second}, false},
+		{"Retries", kmsMetadata, decryptOptions{Retry: retryPolicy{retries: 2}}, true},
+		{"RetriesNoNetworkKeys", pgpMetadata(testkeyFingerprint), decryptOptions{Retry: retryPolicy{retries: 2}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decryptKeyServer(tt.metadata, tt.opts)
+			if err != nil {
+				t.Fatalf("decryptKeyServer() error = %v", err)
+			}
+			if (got != nil) != tt.want {
+				t.Errorf("decryptKeyServer() = %v, want key server %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test util functions
+
+type slowKeyServer struct {
+	delay time.Duration
+}
+
+func (s slowKeyServer) Encrypt(context.Context, *keyservice.EncryptRequest) (*keyservice.EncryptResponse, error) {
+	return &keyservice.EncryptResponse{}, nil
+}
+
+func (s slowKeyServer) Decrypt(context.Context, *keyservice.DecryptRequest) (*keyservice.DecryptResponse, error) {
+	time.Sleep(s.delay)
+	return &keyservice.DecryptResponse{Plaintext: []byte("data key")}, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/getsops/sops/v3/cmd/sops/common"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/getsops/sops/v3/config"
+	"github.com/pkg/errors"
+)
+
+// Kinds of drift between generators and the files on disk
+const (
+	lintMissing = "MISSING"
+	lintUnused  = "UNUSED"
+	lintInvalid = "INVALID"
+)
+
+// lintProblem is a file that generators and the files on disk disagree about
+type lintProblem struct {
+	Kind   string
+	Path   string
+	Detail string
+	// Manifest is the generator manifest that the problem is in, if any
+	Manifest string
+}
+
+// runLint implements the lint subcommand.
+func runLint(args []string) error {
+	flags := newFlagSet("lint")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	root := "."
+	if flags.NArg() > 0 {
+		root = flags.Arg(0)
+	}
+
+	problems, generators, err := lint(root)
+	if err != nil {
+		return err
+	}
+	var annotations []annotation
+	for _, problem := range problems {
+		_, _ = fmt.Fprintf(os.Stderr, "%s %s: %s\n", colorize(colorYellow, fmt.Sprintf("%-7s", problem.Kind)), problem.Path, problem.Detail)
+		annotations = append(annotations, problem.annotation())
+	}
+	err = writeAnnotations(os.Stdout, runtimeSettings.Annotations, annotations)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("%d problems found in %s", len(problems), root)
+	}
+	// A GitLab report is the only output on stdout
+	if runtimeSettings.Annotations != annotationsGitLab {
+		fmt.Printf("ok      %d generators in %s\n", generators, root)
+	}
+	return nil
+}
+
+// annotation returns a lint problem as a CI annotation. Missing sources and
+// invalid generators are errors in the generator manifest; unused files are
+// warnings on the file itself.
+func (p lintProblem) annotation() annotation {
+	if p.Manifest == "" {
+		return annotation{Severity: severityWarning, File: p.Path, Title: "lint " + p.Kind, Message: p.Detail}
+	}
+	message := p.Detail
+	if p.Path != p.Manifest {
+		message = p.Path + ": " + message
+	}
+	return annotation{Severity: severityError, File: p.Manifest, Title: "lint " + p.Kind, Message: message}
+}
+
+// lint cross-references the generators under a directory with the files in
+// it: sources that do not exist are missing, and sops-encrypted files that no
+// generator references are unused. It returns the problems and the number of
+// generators found. Hidden directories are skipped, like findGenerators does.
+func lint(root string) ([]lintProblem, int, error) {
+	generators, err := findGenerators(root)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var problems []lintProblem
+	referenced := make(map[string]bool)
+	for _, g := range generators {
+		files, err := g.sourceFiles()
+		if err != nil {
+			problems = append(problems, lintProblem{lintInvalid, g.Path, fmt.Sprintf("generator %s: %v", g.Generator.Name, err), g.Path})
+			continue
+		}
+		for _, file := range files {
+			abs, err := filepath.Abs(file)
+			if err != nil {
+				return nil, 0, err
+			}
+			referenced[abs] = true
+			if _, err := os.Stat(file); errors.Is(err, fs.ErrNotExist) && !g.Generator.AllowEmpty {
+				problems = append(problems, lintProblem{lintMissing, file, fmt.Sprintf("source of generator %s in %s does not exist", g.Generator.Name, g.Path), g.Path})
+			}
+		}
+	}
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		if !referenced[abs] && d.Type().IsRegular() && isEncryptedFile(p) {
+			problems = append(problems, lintProblem{lintUnused, p, "encrypted with sops, but not referenced by any generator", ""})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return problems, len(generators), nil
+}
+
+// isEncryptedFile reports whether a file has sops metadata for the format of
+// its name.
+func isEncryptedFile(fileName string) bool {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return false
+	}
+	store := common.StoreForFormat(formats.FormatForPath(fileName), config.NewStoresConfig())
+	_, err = store.LoadEncryptedFile(content)
+	return err == nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_lint(t *testing.T) {
+	generator := func(sources string) string {
+		return "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: app\n" + sources
+	}
+
+	tests := []struct {
+		name           string
+		manifest       string
+		files          map[string]string
+		want           []string
+		wantGenerators int
+	}{
+		{"Clean", generator("envs:\n  - vars.env\nfiles:\n  - file.txt\n"),
+			map[string]string{"vars.env": "testdata/vars.env", "file.txt": "testdata/file.txt"}, nil, 1},
+		{"Extract", generator("files:\n  - key=vars.yaml[\"VAR_YAML\"]\n"),
+			map[string]string{"vars.yaml": "testdata/vars.yaml"}, nil, 1},
+		{"Missing", generator("envs:\n  - vars.env\n"), nil, []string{"MISSING vars.env"}, 1},
+		{"Unused", generator("envs:\n  - vars.env\n"),
+			map[string]string{"vars.env": "testdata/vars.env", "old/file.yaml": "testdata/file.yaml"}, []string{"UNUSED old/file.yaml"}, 1},
+		{"Plaintext", generator("envs:\n  - vars.env\n"),
+			map[string]string{"vars.env": "testdata/vars.env", "notes.txt": "testdata/notyaml.txt", "other.yaml": "testdata/generator-wrongkind.yaml"}, nil, 1},
+		{"Hidden", generator("envs:\n  - vars.env\n"),
+			map[string]string{"vars.env": "testdata/vars.env", ".git/file.yaml": "testdata/file.yaml"}, nil, 1},
+		{"Invalid", generator("files:\n  - =file.txt\n"), nil, []string{"INVALID generator.yaml"}, 1},
+		{"NoGenerators", "", map[string]string{"file.yaml": "testdata/file.yaml"}, []string{"UNUSED file.yaml"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.manifest != "" {
+				writeTestFile(t, filepath.Join(dir, "generator.yaml"), tt.manifest)
+			}
+			for dst, src := range tt.files {
+				err := os.MkdirAll(filepath.Dir(filepath.Join(dir, dst)), 0o700)
+				if err != nil {
+					t.Fatal(err)
+				}
+				copyTestFile(t, src, filepath.Join(dir, dst))
+			}
+
+			problems, generators, err := lint(dir)
+			if err != nil {
+				t.Fatalf("lint() error = %v", err)
+			}
+			var got []string
+			for _, problem := range problems {
+				rel, _ := filepath.Rel(dir, problem.Path)
+				got = append(got, problem.Kind+" "+filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lint() = %v, want %v", got, tt.want)
+			}
+			if generators != tt.wantGenerators {
+				t.Errorf("lint() generators = %d, want %d", generators, tt.wantGenerators)
+			}
+		})
+	}
+}
+
+func Test_isEncryptedFile(t *testing.T) {
+	tests := []struct {
+		fileName string
+		want     bool
+	}{
+		{"testdata/vars.env", true},
+		{"testdata/vars.yaml", true},
+		{"testdata/vars.json", true},
+		{"testdata/file.txt", true},
+		{"testdata/notyaml.txt", false},
+		{"testdata/generator.yaml", false},
+		{"testdata/missing.yaml", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fileName, func(t *testing.T) {
+			if got := isEncryptedFile(tt.fileName); got != tt.want {
+				t.Errorf("isEncryptedFile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
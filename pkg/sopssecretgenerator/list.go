@@ -0,0 +1,83 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// listKind is the kind of a list of generators, whose items are generated
+// like separate generators
+const listKind = kind + "List"
+
+// generatorList is a SopsSecretGeneratorList
+type generatorList struct {
+	Items []yaml.Node `yaml:"items"`
+}
+
+// listItems returns the items of a generator list document.
+func listItems(document *yaml.Node) ([]*yaml.Node, error) {
+	var list generatorList
+	err := document.Decode(&list)
+	if err != nil {
+		return nil, withCause(ErrInvalidGenerator, err)
+	}
+	items := make([]*yaml.Node, len(list.Items))
+	for i := range list.Items {
+		items[i] = &list.Items[i]
+	}
+	return items, nil
+}
+
+// generatorDocuments returns the generators in a YAML document: the document
+// if it is a generator, its items if it is a generator list, and nothing
+// otherwise.
+func generatorDocuments(document *yaml.Node) ([]*yaml.Node, error) {
+	var typeMeta TypeMeta
+	if document.Decode(&typeMeta) != nil || typeMeta.APIVersion != apiVersion {
+		return nil, nil
+	}
+	switch typeMeta.Kind {
+	case kind:
+		return []*yaml.Node{document}, nil
+	case listKind:
+		return listItems(document)
+	}
+	return nil, nil
+}
+
+// expandLists replaces the generator lists among the items of a ResourceList
+// with their items.
+func expandLists(items fn.KubeObjects) (fn.KubeObjects, error) {
+	var expanded fn.KubeObjects
+	for _, item := range items {
+		if item.GetAPIVersion() != apiVersion || item.GetKind() != listKind {
+			expanded = append(expanded, item)
+			continue
+		}
+		var document yaml.Node
+		err := yaml.Unmarshal([]byte(item.String()), &document)
+		if err != nil {
+			return nil, withCause(ErrInvalidGenerator, err)
+		}
+		nodes, err := listItems(&document)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s %s", listKind, item.GetName())
+		}
+		for i, node := range nodes {
+			content, err := yaml.Marshal(node)
+			if err != nil {
+				return nil, err
+			}
+			obj, err := fn.ParseKubeObject(content)
+			if err != nil {
+				return nil, withCause(ErrInvalidGenerator, errors.Wrapf(err, "%s %s, item %d", listKind, item.GetName(), i+1))
+			}
+			expanded = append(expanded, obj)
+		}
+	}
+	return expanded, nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"gopkg.in/yaml.v3"
+)
+
+const testGeneratorList = `apiVersion: kustomize.freightdog.com/v1
+kind: SopsSecretGeneratorList
+items:
+  - apiVersion: kustomize.freightdog.com/v1
+    kind: SopsSecretGenerator
+    metadata:
+      name: from-file
+    files:
+      - testdata/file.txt
+  - apiVersion: kustomize.freightdog.com/v1
+    kind: SopsSecretGenerator
+    metadata:
+      name: from-env
+    envs:
+      - testdata/vars.env
+`
+
+func Test_generatorDocuments(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     []string
+		wantErr  bool
+	}{
+		{"Generator", "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: a\n", []string{"a"}, false},
+		{"List", testGeneratorList, []string{"from-file", "from-env"}, false},
+		{"EmptyList", "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGeneratorList\nitems: []\n", nil, false},
+		{"OtherKind", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n", nil, false},
+		{"OtherVersion", "apiVersion: goabout.com/v1beta1\nkind: SopsSecretGeneratorList\nitems: []\n", nil, false},
+		{"InvalidItems", "apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGeneratorList\nitems: a\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var node yaml.Node
+			if err := yaml.Unmarshal([]byte(tt.document), &node); err != nil {
+				t.Fatal(err)
+			}
+			documents, err := generatorDocuments(&node)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("generatorDocuments() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, document := range documents {
+				var meta struct {
+					ObjectMeta `yaml:"metadata"`
+				}
+				if err := document.Decode(&meta); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, meta.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("generatorDocuments() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_expandLists(t *testing.T) {
+	tests := []struct {
+		name    string
+		items   []string
+		want    []string
+		wantErr bool
+	}{
+		{"NoLists", []string{"apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: a\n"}, []string{"a"}, false},
+		{"List", []string{"apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: a\n", testGeneratorList},
+			[]string{"a", "from-file", "from-env"}, false},
+		{"ScalarItem", []string{"apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGeneratorList\nmetadata:\n  name: list\nitems:\n  - a\n"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var items fn.KubeObjects
+			for _, item := range tt.items {
+				obj, err := fn.ParseKubeObject([]byte(item))
+				if err != nil {
+					t.Fatal(err)
+				}
+				items = append(items, obj)
+			}
+			expanded, err := expandLists(items)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandLists() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, obj := range expanded {
+				got = append(got, obj.GetName())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandLists() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_GenerateKRMManifest_list(t *testing.T) {
+	items := "  - " + strings.ReplaceAll(strings.TrimSuffix(testGeneratorList, "\n"), "\n", "\n    ") + "\n"
+	input := "apiVersion: config.kubernetes.io/v1\nkind: ResourceList\nitems:\n" + items
+	out, err := fn.Run(fn.ResourceListProcessorFunc(generateKRMManifest), []byte(input))
+	if err != nil {
+		t.Fatalf("generateKRMManifest() error = %v", err)
+	}
+	rl, err := fn.ParseResourceList(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, item := range rl.Items {
+		got = append(got, item.GetKind()+"/"+item.GetName())
+	}
+	want := []string{"Secret/from-env", "Secret/from-file"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("generateKRMManifest() = %v, want %v", got, want)
+	}
+}
+
+func Test_readGenerators_list(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "generators.yaml")
+	writeTestFile(t, fileName, testGeneratorList)
+	generators, err := readGenerators(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(generators) != 2 || generators[0].Generator.Name != "from-file" || generators[1].Generator.Name != "from-env" {
+		t.Errorf("readGenerators() = %+v", generators)
+	}
+
+	results, err := validateManifest(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[1].Name != "from-env" {
+		t.Errorf("validateManifest() = %+v", results)
+	}
+}
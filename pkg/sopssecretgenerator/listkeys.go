@@ -0,0 +1,190 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// keyOrigin is a data key of a Secret and the source that it comes from
+type keyOrigin struct {
+	Key    string
+	Source string
+	// Overrides are the earlier sources with the same key, in order
+	Overrides []string
+}
+
+// runListKeys implements the list-keys subcommand.
+func runListKeys(args []string) error {
+	flags := newFlagSet("list-keys")
+	name := flags.String("name", "", "only list the generator with this `name`")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected a generator manifest")
+	}
+
+	generators, err := readGenerators(flags.Arg(0))
+	if err != nil {
+		return errors.Wrapf(err, "could not read generator %s", flags.Arg(0))
+	}
+	if *name != "" {
+		g, err := selectGenerator(flags.Arg(0), *name)
+		if err != nil {
+			return err
+		}
+		generators = []generatorFile{g}
+	}
+	if len(generators) == 0 {
+		return errors.Errorf("%s contains no generators", flags.Arg(0))
+	}
+	for _, g := range generators {
+		keys, err := generatorKeys(g)
+		if err != nil {
+			return errors.Wrap(err, g.Generator.Name)
+		}
+		printKeys(os.Stdout, g.Generator.Name, keys)
+	}
+	return nil
+}
+
+// generatorKeys returns the data keys of the Secret of a generator, sorted
+// by key, with the source each comes from. Env, cluster, Vault and Secrets
+// Manager sources are read to find their keys, and the values are discarded;
+// the keys of file sources and the other remote sources follow from the
+// manifest. Sources are applied in the order the Secret is generated
+// in, env sources first, so a later source overrides an earlier one.
+func generatorKeys(g generatorFile) ([]keyOrigin, error) {
+	opts, err := newDecryptOptions(g.Generator, runtimeSettings)
+	if err != nil {
+		return nil, err
+	}
+	opts.Context = invocationContext
+	var resolved []string
+	for _, source := range g.Generator.EnvSources {
+		r, err := g.resolveSource(source)
+		if err != nil {
+			return nil, errors.Wrapf(err, "env source \"%s\"", source)
+		}
+		resolved = append(resolved, r)
+	}
+	opts.Prefetched = prefetchFiles(resolved, opts)
+	defer opts.Prefetched.wipe()
+
+	origins := make(map[string]*keyOrigin)
+	add := func(key string, source string) {
+		if origin, ok := origins[key]; ok {
+			origin.Overrides = append(origin.Overrides, origin.Source)
+			origin.Source = source
+			return
+		}
+		origins[key] = &keyOrigin{Key: key, Source: source}
+	}
+	addAll := func(data kvMap, source string) {
+		keys := make([]string, 0, len(data))
+		for key := range data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			add(key, source)
+		}
+	}
+	for i, source := range g.Generator.EnvSources {
+		data := make(kvMap)
+		err := parseEnvSource(resolved[i], opts, data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "env source \"%s\"", source)
+		}
+		addAll(data, source)
+	}
+	for _, source := range g.Generator.ClusterSources {
+		data := make(kvMap)
+		err := parseClusterSources([]ClusterSource{source}, opts, data)
+		if err != nil {
+			return nil, err
+		}
+		addAll(data, source.String())
+	}
+	for _, source := range g.Generator.VaultSources {
+		data := make(kvMap)
+		err := parseVaultSources([]VaultSource{source}, opts, data)
+		if err != nil {
+			return nil, err
+		}
+		addAll(data, source.String())
+	}
+	for _, source := range g.Generator.AWSSecretsManager {
+		data := make(kvMap)
+		err := parseAWSSecretsManagerSources([]AWSSecretsManagerSource{source}, opts, data)
+		if err != nil {
+			return nil, err
+		}
+		addAll(data, source.String())
+	}
+	for _, parameter := range g.Generator.SSMParameters {
+		add(parameter.key(), parameter.String())
+	}
+	for _, source := range g.Generator.GCPSecretSources {
+		add(source.key(), source.String())
+	}
+	for _, source := range g.Generator.AzureKeyVaultSources {
+		for _, ref := range source.Secrets {
+			secret, err := parseAzureSecret(ref)
+			if err != nil {
+				return nil, errors.Wrapf(err, "source %s", source)
+			}
+			add(secret.key, source.String()+"/secrets/"+secret.name)
+		}
+	}
+	for _, source := range g.Generator.OnePasswordSources {
+		r, err := parseOnePasswordRef(source)
+		if err != nil {
+			return nil, err
+		}
+		add(r.key, r.ref)
+	}
+	for _, source := range g.Generator.FileSources {
+		key, _, err := parseFileName(source)
+		if err != nil {
+			return nil, errors.Wrapf(err, "file source \"%s\"", source)
+		}
+		add(key, source)
+	}
+
+	if len(g.Generator.HelmValues.Files) > 0 {
+		add(g.Generator.HelmValues.key(), strings.Join(g.Generator.HelmValues.Files, ", "))
+	}
+	if len(g.Generator.DockerConfigs) > 0 {
+		add(dockerConfigJSONKey, strings.Join(g.Generator.DockerConfigs, ", "))
+	}
+
+	keys := make([]keyOrigin, 0, len(origins))
+	for _, origin := range origins {
+		keys = append(keys, *origin)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+	return keys, nil
+}
+
+// printKeys lists the data keys of a Secret with their sources.
+func printKeys(w io.Writer, name string, keys []keyOrigin) {
+	_, _ = fmt.Fprintf(w, "%s:\n", name)
+	for _, k := range keys {
+		_, _ = fmt.Fprintf(w, "  %s: %s", k.Key, k.Source)
+		if len(k.Overrides) > 0 {
+			_, _ = fmt.Fprintf(w, " (overrides %s)", strings.Join(k.Overrides, ", "))
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+}
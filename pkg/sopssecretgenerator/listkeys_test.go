@@ -0,0 +1,64 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func Test_generatorKeys(t *testing.T) {
+	generator := func(envs []string, files []string) generatorFile {
+		return generatorFile{
+			Path:      "testdata/generator.yaml",
+			Generator: SopsSecretGenerator{EnvSources: envs, FileSources: files},
+		}
+	}
+	tests := []struct {
+		name      string
+		generator generatorFile
+		want      []keyOrigin
+		wantErr   bool
+	}{
+		{"None", generator(nil, nil), []keyOrigin{}, false},
+		{"Env", generator([]string{"vars.env", "vars.yaml"}, nil), []keyOrigin{
+			{Key: "VAR_ENV", Source: "vars.env"},
+			{Key: "VAR_YAML", Source: "vars.yaml"},
+		}, false},
+		{"Files", generator(nil, []string{"file.txt", "other=file2.txt", `vars.yaml["VAR_YAML"]`}), []keyOrigin{
+			{Key: "VAR_YAML", Source: `vars.yaml["VAR_YAML"]`},
+			{Key: "file.txt", Source: "file.txt"},
+			{Key: "other", Source: "other=file2.txt"},
+		}, false},
+		{"Overrides", generator([]string{"vars.env", "vars.env"}, []string{"VAR_ENV=file.txt"}), []keyOrigin{
+			{Key: "VAR_ENV", Source: "VAR_ENV=file.txt", Overrides: []string{"vars.env", "vars.env"}},
+		}, false},
+		{"MissingEnv", generator([]string{"missing.env"}, nil), nil, true},
+		{"InvalidFile", generator(nil, []string{"a=b=c"}), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := generatorKeys(tt.generator)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("generatorKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("generatorKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_printKeys(t *testing.T) {
+	var out bytes.Buffer
+	printKeys(&out, "my-secret", []keyOrigin{
+		{Key: "VAR_ENV", Source: "VAR_ENV=file.txt", Overrides: []string{"vars.env"}},
+		{Key: "file.txt", Source: "file.txt"},
+	})
+	want := "my-secret:\n  VAR_ENV: VAR_ENV=file.txt (overrides vars.env)\n  file.txt: file.txt\n"
+	if out.String() != want {
+		t.Errorf("printKeys() = %q, want %q", out.String(), want)
+	}
+}
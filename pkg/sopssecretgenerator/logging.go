@@ -0,0 +1,58 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// logLevels are the accepted values of SOPS_SECRETGEN_LOG
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// defaultLogLevel only logs warnings and errors, so that builds stay quiet
+const defaultLogLevel = slog.LevelWarn
+
+// discardLogger is the logger of options without one
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// newLogger returns a logger that writes records of the level and above as
+// text. The command logs to stderr, as stdout is reserved for the
+// ResourceList.
+func newLogger(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level}))
+}
+
+// parseLogLevel parses a log level name, ignoring case.
+func parseLogLevel(name string) (slog.Level, error) {
+	level, ok := logLevels[strings.ToLower(name)]
+	if !ok {
+		return 0, errors.Errorf("unknown log level %q, use debug, info, warn or error", name)
+	}
+	return level, nil
+}
+
+// logger returns the logger of the options, or one that discards everything.
+func (o Options) logger() *slog.Logger {
+	if o.Logger == nil {
+		return discardLogger
+	}
+	return o.Logger
+}
+
+// logger returns the logger of the options, or one that discards everything.
+func (o decryptOptions) logger() *slog.Logger {
+	if o.Logger == nil {
+		return discardLogger
+	}
+	return o.Logger
+}
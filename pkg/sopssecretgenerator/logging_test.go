@@ -0,0 +1,104 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func Test_parseLogLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"debug", slog.LevelDebug, false},
+		{"info", slog.LevelInfo, false},
+		{"warn", slog.LevelWarn, false},
+		{"error", slog.LevelError, false},
+		{"DEBUG", slog.LevelDebug, false},
+		{"verbose", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLogLevel(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLogLevel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseLogLevel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_envLogLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    slog.Level
+		wantErr bool
+	}{
+		{"Unset", "", defaultLogLevel, false},
+		{"Debug", "debug", slog.LevelDebug, false},
+		{"Invalid", "loud", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(envPrefix+"TEST_LOG", tt.value)
+			got, err := envLogLevel("TEST_LOG")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("envLogLevel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("envLogLevel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogging(t *testing.T) {
+	spec := SopsSecretGenerator{
+		TypeMeta:    TypeMeta{APIVersion: apiVersion, Kind: kind},
+		ObjectMeta:  ObjectMeta{Name: "my-secret"},
+		FileSources: []string{"testdata/file.txt"},
+	}
+	tests := []struct {
+		name    string
+		level   slog.Level
+		want    []string
+		notWant []string
+	}{
+		{"Debug", slog.LevelDebug, []string{`msg="decrypted file"`, "file=testdata/file.txt", `msg="generated Secret"`, "generator=my-secret"}, nil},
+		{"Info", slog.LevelInfo, []string{`msg="generated Secret"`}, []string{"decrypted file"}},
+		{"Warn", slog.LevelWarn, nil, []string{"decrypted file", "generated Secret"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			_, err := Generate(context.Background(), spec, Options{Logger: newLogger(&buf, tt.level)})
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("log does not contain %q:\n%s", want, buf.String())
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(buf.String(), notWant) {
+					t.Errorf("log contains %q:\n%s", notWant, buf.String())
+				}
+			}
+			if strings.Contains(buf.String(), "secret\n") {
+				t.Errorf("log contains the plaintext:\n%s", buf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/pkg/errors"
+)
+
+// kustomizeAnnotationPrefix is the prefix of the annotations that tell
+// kustomize how to handle a generated resource
+const kustomizeAnnotationPrefix = "kustomize.config.k8s.io/"
+
+// mergeSecrets merges the Secrets that several generators in a ResourceList
+// generate with the same name and namespace into the first of them. The
+// generators after the first must have behavior merge. A key or label that
+// two generators set to different values is a conflict. The kustomize
+// annotations of the first Secret are kept, so that it is created or merged
+// like that generator asks.
+func mergeSecrets(secrets fn.KubeObjects) (fn.KubeObjects, error) {
+	var merged fn.KubeObjects
+	first := make(map[string]*fn.KubeObject)
+	for _, secret := range secrets {
+		id := secret.GetNamespace() + "/" + secret.GetName()
+		target, ok := first[id]
+		if !ok {
+			first[id] = secret
+			merged = append(merged, secret)
+			continue
+		}
+		name := strings.TrimPrefix(id, "/")
+		if secret.GetAnnotation(behaviorAnnotation) != "merge" {
+			return nil, withCause(ErrInvalidGenerator,
+				errors.Errorf("several generators generate Secret %s, set behavior: merge on all but the first to merge them", name))
+		}
+		err := mergeSecret(target, secret)
+		if err != nil {
+			return nil, withCause(ErrInvalidGenerator, errors.Wrapf(err, "merge Secret %s", name))
+		}
+	}
+	return merged, nil
+}
+
+// mergeSecret merges the data, stringData, labels and annotations of a Secret into
+// another.
+func mergeSecret(target *fn.KubeObject, secret *fn.KubeObject) error {
+	targetType, _, _ := target.NestedString("type")
+	secretType, _, _ := secret.NestedString("type")
+	if targetType != "" && secretType != "" && targetType != secretType {
+		return errors.Errorf("type %s conflicts with %s", secretType, targetType)
+	}
+	if targetType == "" && secretType != "" {
+		err := target.SetNestedString(secretType, "type")
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, field := range [][]string{{"data"}, {"stringData"}, {"metadata", "labels"}, {"metadata", "annotations"}} {
+		values, _, err := target.NestedStringMap(field...)
+		if err != nil {
+			return err
+		}
+		if values == nil {
+			values = make(map[string]string)
+		}
+		additions, _, err := secret.NestedStringMap(field...)
+		if err != nil {
+			return err
+		}
+		if len(additions) == 0 {
+			continue
+		}
+		for k, v := range additions {
+			if strings.HasPrefix(k, kustomizeAnnotationPrefix) && field[len(field)-1] == "annotations" {
+				continue
+			}
+			if previous, ok := values[k]; ok && previous != v {
+				return errors.Errorf("%s %s is set by two generators to different values", strings.Join(field, "."), k)
+			}
+			values[k] = v
+		}
+		_, err = target.RemoveNestedField(field...)
+		if err != nil {
+			return err
+		}
+		for _, k := range slices.Sorted(maps.Keys(values)) {
+			err = target.SetNestedString(values[k], append(field, k)...)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+)
+
+func testSecret(name string, namespace string, behavior string, data kvMap) Secret {
+	secret := Secret{
+		TypeMeta:   TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: ObjectMeta{Name: name, Namespace: namespace, Labels: kvMap{"from": name}},
+		Data:       data,
+		Type:       "Opaque",
+	}
+	if behavior != "" {
+		secret.Annotations = kvMap{behaviorAnnotation: behavior}
+	}
+	return secret
+}
+
+func Test_mergeSecrets(t *testing.T) {
+	tests := []struct {
+		name     string
+		secrets  []Secret
+		want     []string
+		wantData kvMap
+		wantErr  bool
+	}{
+		{"Distinct", []Secret{testSecret("a", "", "", kvMap{"x": "MQ=="}), testSecret("b", "", "", kvMap{"y": "Mg=="})},
+			[]string{"a", "b"}, kvMap{"x": "MQ=="}, false},
+		{"OtherNamespace", []Secret{testSecret("a", "one", "", kvMap{"x": "MQ=="}), testSecret("a", "two", "", kvMap{"y": "Mg=="})},
+			[]string{"a", "a"}, kvMap{"x": "MQ=="}, false},
+		{"Merge", []Secret{testSecret("a", "", "", kvMap{"x": "MQ=="}), testSecret("a", "", "merge", kvMap{"y": "Mg=="})},
+			[]string{"a"}, kvMap{"x": "MQ==", "y": "Mg=="}, false},
+		{"SameValue", []Secret{testSecret("a", "", "", kvMap{"x": "MQ=="}), testSecret("a", "", "merge", kvMap{"x": "MQ=="})},
+			[]string{"a"}, kvMap{"x": "MQ=="}, false},
+		{"Conflict", []Secret{testSecret("a", "", "", kvMap{"x": "MQ=="}), testSecret("a", "", "merge", kvMap{"x": "Mg=="})},
+			nil, nil, true},
+		{"NoMergeBehavior", []Secret{testSecret("a", "", "", kvMap{"x": "MQ=="}), testSecret("a", "", "replace", kvMap{"y": "Mg=="})},
+			nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var secrets fn.KubeObjects
+			for _, secret := range tt.secrets {
+				obj, err := newSecretKubeObject(secret)
+				if err != nil {
+					t.Fatal(err)
+				}
+				secrets = append(secrets, obj)
+			}
+			merged, err := mergeSecrets(secrets)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("mergeSecrets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var got []string
+			for _, obj := range merged {
+				got = append(got, obj.GetName())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeSecrets() = %v, want %v", got, tt.want)
+			}
+			data, _, _ := merged[0].NestedStringMap("data")
+			if !reflect.DeepEqual(kvMap(data), tt.wantData) {
+				t.Errorf("mergeSecrets() data = %v, want %v", data, tt.wantData)
+			}
+		})
+	}
+}
+
+func Test_mergeSecrets_metadata(t *testing.T) {
+	first, err := newSecretKubeObject(testSecret("a", "", "", kvMap{"x": "MQ=="}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := testSecret("a", "", "merge", kvMap{"y": "Mg=="})
+	second.Labels = kvMap{"team": "web"}
+	second.Annotations["note"] = "merged"
+	secondObj, err := newSecretKubeObject(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, err := mergeSecrets(fn.KubeObjects{first, secondObj})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := merged[0].GetLabels(); !reflect.DeepEqual(got, map[string]string{"from": "a", "team": "web"}) {
+		t.Errorf("mergeSecrets() labels = %v", got)
+	}
+	if got := merged[0].GetAnnotations(); !reflect.DeepEqual(got, map[string]string{"note": "merged"}) {
+		t.Errorf("mergeSecrets() annotations = %v, want the kustomize annotations of the first Secret", got)
+	}
+}
+
+func Test_GenerateKRMManifest_merge(t *testing.T) {
+	input := `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+  - apiVersion: kustomize.freightdog.com/v1
+    kind: SopsSecretGenerator
+    metadata:
+      name: secret
+    files:
+      - testdata/file.txt
+  - apiVersion: kustomize.freightdog.com/v1
+    kind: SopsSecretGenerator
+    metadata:
+      name: secret
+    behavior: merge
+    envs:
+      - testdata/vars.env
+`
+	out, err := fn.Run(fn.ResourceListProcessorFunc(generateKRMManifest), []byte(input))
+	if err != nil {
+		t.Fatalf("generateKRMManifest() error = %v", err)
+	}
+	rl, err := fn.ParseResourceList(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rl.Items) != 1 {
+		t.Fatalf("generateKRMManifest() = %d items, want 1", len(rl.Items))
+	}
+	data, _, _ := rl.Items[0].NestedStringMap("data")
+	if _, ok := data["file.txt"]; !ok {
+		t.Errorf("merged Secret lacks file.txt: %v", data)
+	}
+	if _, ok := data["VAR_ENV"]; !ok {
+		t.Errorf("merged Secret lacks VAR_ENV: %v", data)
+	}
+}
@@ -0,0 +1,13 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+// applyNamespace sets the namespace of a generator to the namespace of the
+// options, if any, which overrides metadata.namespace.
+func applyNamespace(input SopsSecretGenerator, options Options) SopsSecretGenerator {
+	if options.Namespace != "" {
+		input.Namespace = options.Namespace
+	}
+	return input
+}
@@ -0,0 +1,45 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"testing"
+)
+
+func Test_applyNamespace(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace string
+		options   Options
+		want      string
+	}{
+		{"None", "", Options{}, ""},
+		{"Generator", "apps", Options{}, "apps"},
+		{"Options", "", Options{Namespace: "pr-42"}, "pr-42"},
+		{"Override", "apps", Options{Namespace: "pr-42"}, "pr-42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := SopsSecretGenerator{ObjectMeta: ObjectMeta{Name: "secret", Namespace: tt.namespace}}
+			if got := applyNamespace(input, tt.options); got.Namespace != tt.want {
+				t.Errorf("applyNamespace() = %v, want %v", got.Namespace, tt.want)
+			}
+		})
+	}
+}
+
+func Test_generateSecret_namespace(t *testing.T) {
+	input, err := readInput([]byte("apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: secret\n  namespace: apps\nfiles:\n  - testdata/file.txt\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := generateSecret(context.Background(), input, Options{Namespace: "pr-42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Namespace != "pr-42" {
+		t.Errorf("generateSecret() namespace = %v, want pr-42", secret.Namespace)
+	}
+}
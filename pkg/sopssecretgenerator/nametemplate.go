@@ -0,0 +1,76 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// isNameTemplate reports whether a generator name has template placeholders.
+func isNameTemplate(name string) bool {
+	return strings.Contains(name, "{{")
+}
+
+// nameTemplateFuncs returns the functions that a name template can call:
+// env returns an environment variable, which must be set, gitSHA the
+// abbreviated commit of the working directory, and lower converts to lower
+// case.
+func nameTemplateFuncs(ctx context.Context) template.FuncMap {
+	return template.FuncMap{
+		"env": func(name string) (string, error) {
+			value := os.Getenv(name)
+			if value == "" {
+				return "", errors.Errorf("environment variable %s is not set", name)
+			}
+			return value, nil
+		},
+		"gitSHA": func() (string, error) {
+			output, err := exec.CommandContext(ctx, "git", "rev-parse", "--short", "HEAD").Output()
+			if err != nil {
+				return "", errors.Wrap(err, "git rev-parse")
+			}
+			return strings.TrimSpace(string(output)), nil
+		},
+		"lower": strings.ToLower,
+	}
+}
+
+// parseNameTemplate parses the name of a generator as a template.
+func parseNameTemplate(ctx context.Context, name string) (*template.Template, error) {
+	tmpl, err := template.New("metadata.name").Option("missingkey=error").Funcs(nameTemplateFuncs(ctx)).Parse(name)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid name template")
+	}
+	return tmpl, nil
+}
+
+// renderName replaces the name template of a generator with the name it
+// renders to, which must be a valid Secret name. Names without placeholders
+// are kept as they are.
+func renderName(ctx context.Context, input SopsSecretGenerator) (SopsSecretGenerator, error) {
+	if !isNameTemplate(input.Name) {
+		return input, nil
+	}
+	tmpl, err := parseNameTemplate(ctx, input.Name)
+	if err != nil {
+		return SopsSecretGenerator{}, withCause(ErrInvalidGenerator, err)
+	}
+	var name strings.Builder
+	err = tmpl.Execute(&name, nil)
+	if err != nil {
+		return SopsSecretGenerator{}, withCause(ErrInvalidGenerator, errors.Wrapf(err, "name %s", input.Name))
+	}
+	if !secretNamePattern.MatchString(name.String()) || name.Len() > 253 {
+		return SopsSecretGenerator{}, withCause(ErrInvalidGenerator,
+			errors.Errorf("name %s renders to \"%s\", which is not a valid Secret name", input.Name, name.String()))
+	}
+	input.Name = name.String()
+	return input, nil
+}
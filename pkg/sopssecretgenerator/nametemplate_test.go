@@ -0,0 +1,80 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"regexp"
+	"testing"
+)
+
+func Test_renderName(t *testing.T) {
+	t.Setenv("SOPS_SECRETGEN_TEST_ENV", "Preview-42")
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"Plain", "secret", "secret", false},
+		{"Env", `secret-{{ env "SOPS_SECRETGEN_TEST_ENV" | lower }}`, "secret-preview-42", false},
+		{"UnsetEnv", `secret-{{ env "SOPS_SECRETGEN_TEST_UNSET" }}`, "", true},
+		{"InvalidName", `secret-{{ env "SOPS_SECRETGEN_TEST_ENV" }}`, "", true},
+		{"InvalidTemplate", `secret-{{ env `, "", true},
+		{"UnknownFunction", `secret-{{ branch }}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderName(context.Background(), SopsSecretGenerator{ObjectMeta: ObjectMeta{Name: tt.input}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renderName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Name != tt.want {
+				t.Errorf("renderName() = %v, want %v", got.Name, tt.want)
+			}
+		})
+	}
+}
+
+func Test_renderName_gitSHA(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "test"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, output)
+		}
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+
+	got, err := renderName(context.Background(), SopsSecretGenerator{ObjectMeta: ObjectMeta{Name: "secret-{{ gitSHA }}"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^secret-[0-9a-f]{7,}$`).MatchString(got.Name) {
+		t.Errorf("renderName() = %v, want secret- and a short commit hash", got.Name)
+	}
+}
+
+func Test_readInput_nameTemplate(t *testing.T) {
+	_, err := readInput([]byte("apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: secret-{{ env\n"))
+	if err == nil {
+		t.Errorf("readInput() accepted an invalid name template")
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/age"
+	"github.com/getsops/sops/v3/pgp"
+	"github.com/pkg/errors"
+)
+
+// localKeyTypes are the sops key types that can be used without network access
+var localKeyTypes = map[string]bool{
+	age.KeyTypeIdentifier: true,
+	pgp.KeyTypeIdentifier: true,
+}
+
+// localKeysOnly removes all keys that require a network key service, such as
+// cloud KMS or Vault, from the metadata. It fails if too few key groups remain
+// to recover the data key, so that offline builds fail immediately instead of
+// attempting network calls.
+func localKeysOnly(metadata *sops.Metadata) error {
+	threshold := shamirThreshold(*metadata)
+	usable := 0
+	for i, group := range metadata.KeyGroups {
+		var local sops.KeyGroup
+		for _, key := range group {
+			if localKeyTypes[key.TypeToIdentifier()] {
+				local = append(local, key)
+			}
+		}
+		if len(local) > 0 {
+			usable++
+		}
+		metadata.KeyGroups[i] = local
+	}
+	if usable < threshold {
+		return errors.Errorf("offline mode: file needs %d key groups with an age or PGP key, but has %d", threshold, usable)
+	}
+	return nil
+}
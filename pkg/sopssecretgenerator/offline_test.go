@@ -0,0 +1,64 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/age"
+	"github.com/getsops/sops/v3/kms"
+	"github.com/getsops/sops/v3/pgp"
+)
+
+func Test_localKeysOnly(t *testing.T) {
+	pgpKey := pgp.NewMasterKeyFromFingerprint(testkeyFingerprint)
+	ageKey := &age.MasterKey{Recipient: testAgeRecipient}
+	kmsKey := &kms.MasterKey{Arn: testKMSArn}
+
+	tests := []struct {
+		name     string
+		metadata sops.Metadata
+		wantKeys []int
+		wantErr  bool
+	}{
+		{"LocalOnly", keyGroupsMetadata(sops.KeyGroup{pgpKey, ageKey}), []int{2}, false},
+		{"Mixed", keyGroupsMetadata(sops.KeyGroup{kmsKey, pgpKey}), []int{1}, false},
+		{"KMSOnly", keyGroupsMetadata(sops.KeyGroup{kmsKey}), nil, true},
+		{"ShamirAllGroups", keyGroupsMetadata(sops.KeyGroup{pgpKey}, sops.KeyGroup{kmsKey}), nil, true},
+		{"ShamirThresholdMet", func() sops.Metadata {
+			m := keyGroupsMetadata(sops.KeyGroup{pgpKey}, sops.KeyGroup{kmsKey})
+			m.ShamirThreshold = 1
+			return m
+		}(), []int{1, 0}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := localKeysOnly(&tt.metadata)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("localKeysOnly() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			for i, want := range tt.wantKeys {
+				if got := len(tt.metadata.KeyGroups[i]); got != want {
+					t.Errorf("localKeysOnly() group %d has %d keys, want %d", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_decryptFile_Offline(t *testing.T) {
+	got, err := decryptFile("testdata/vars.yaml", decryptOptions{Offline: true})
+	if err != nil {
+		t.Fatalf("decryptFile() error = %v", err)
+	}
+	if !strings.Contains(string(got), "val_yaml") {
+		t.Errorf("decryptFile() got = %q", got)
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// onePasswordRef is a 1Password secret reference,
+// op://vault/item/[section/]field, with the key to add its value under
+type onePasswordRef struct {
+	key     string
+	ref     string
+	vault   string
+	item    string
+	section string
+	field   string
+}
+
+// parseOnePasswordRef parses a 1Password source, op://vault/item/field or
+// key=op://vault/item/[section/]field. The key is the field name by default.
+func parseOnePasswordRef(source string) (onePasswordRef, error) {
+	key, ref, found := strings.Cut(source, "=")
+	if !found || strings.HasPrefix(source, "op://") {
+		key, ref = "", source
+	}
+	path, ok := strings.CutPrefix(ref, "op://")
+	if !ok {
+		return onePasswordRef{}, errors.Errorf("invalid 1Password reference %s, use [key=]op://vault/item/[section/]field", source)
+	}
+	parts := strings.Split(path, "/")
+	r := onePasswordRef{key: key, ref: ref}
+	switch len(parts) {
+	case 3:
+		r.vault, r.item, r.field = parts[0], parts[1], parts[2]
+	case 4:
+		r.vault, r.item, r.section, r.field = parts[0], parts[1], parts[2], parts[3]
+	default:
+		return onePasswordRef{}, errors.Errorf("invalid 1Password reference %s, use [key=]op://vault/item/[section/]field", source)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return onePasswordRef{}, errors.Errorf("invalid 1Password reference %s, use [key=]op://vault/item/[section/]field", source)
+		}
+	}
+	if r.key == "" {
+		r.key = r.field
+	}
+	return r, nil
+}
+
+// onePasswordConnect reads items from a 1Password Connect server, configured
+// with OP_CONNECT_HOST and OP_CONNECT_TOKEN.
+type onePasswordConnect struct {
+	host  string
+	token string
+}
+
+// onePasswordField is a field of a 1Password item
+type onePasswordField struct {
+	ID      string `json:"id"`
+	Label   string `json:"label"`
+	Value   string `json:"value"`
+	Section struct {
+		ID string `json:"id"`
+	} `json:"section"`
+}
+
+// onePasswordItem is a 1Password item
+type onePasswordItem struct {
+	Fields   []onePasswordField `json:"fields"`
+	Sections []struct {
+		ID    string `json:"id"`
+		Label string `json:"label"`
+	} `json:"sections"`
+}
+
+// get decodes the response to a Connect API request.
+func (c onePasswordConnect) get(path string, opts decryptOptions, out interface{}) error {
+	req, err := http.NewRequestWithContext(opts.context(), http.MethodGet, strings.TrimRight(c.host, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &e) == nil && e.Message != "" {
+			return errors.Errorf("1Password Connect returned %s: %s", resp.Status, e.Message)
+		}
+		return errors.Errorf("1Password Connect returned %s", resp.Status)
+	}
+	return errors.Wrap(json.Unmarshal(body, out), "invalid 1Password Connect response")
+}
+
+// id returns the ID of the vault or item with a name, or the name itself if
+// nothing has that name, as references may use IDs.
+func (c onePasswordConnect) id(path string, attribute string, name string, opts decryptOptions) (string, error) {
+	var found []struct {
+		ID string `json:"id"`
+	}
+	filter := url.QueryEscape(attribute + ` eq "` + name + `"`)
+	err := c.get(path+"?filter="+filter, opts, &found)
+	if err != nil {
+		return "", err
+	}
+	if len(found) == 0 {
+		return name, nil
+	}
+	return found[0].ID, nil
+}
+
+// read returns the value of a field.
+func (c onePasswordConnect) read(r onePasswordRef, opts decryptOptions) (string, error) {
+	vaultID, err := c.id("/v1/vaults", "name", r.vault, opts)
+	if err != nil {
+		return "", err
+	}
+	itemID, err := c.id("/v1/vaults/"+url.PathEscape(vaultID)+"/items", "title", r.item, opts)
+	if err != nil {
+		return "", err
+	}
+	var item onePasswordItem
+	err = c.get("/v1/vaults/"+url.PathEscape(vaultID)+"/items/"+url.PathEscape(itemID), opts, &item)
+	if err != nil {
+		return "", err
+	}
+	sectionID := ""
+	if r.section != "" {
+		sectionID = r.section
+		for _, section := range item.Sections {
+			if section.Label == r.section {
+				sectionID = section.ID
+			}
+		}
+	}
+	for _, field := range item.Fields {
+		if (field.Label == r.field || field.ID == r.field) && (sectionID == "" || field.Section.ID == sectionID) {
+			return field.Value, nil
+		}
+	}
+	return "", errors.Errorf("item has no field %s", r.field)
+}
+
+// readOnePasswordCLI returns the value of a reference with `op read`, which
+// authenticates with OP_SERVICE_ACCOUNT_TOKEN or the signed in account.
+func readOnePasswordCLI(r onePasswordRef, opts decryptOptions) ([]byte, error) {
+	cmd := exec.CommandContext(opts.context(), "op", "read", "--no-newline", r.ref)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "op read: %s", strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// parseOnePasswordSources reads the 1Password sources of a generator into
+// the Secret data, from a Connect server if OP_CONNECT_HOST is set, and
+// otherwise with the op CLI.
+func parseOnePasswordSources(sources []string, opts decryptOptions, data kvMap) error {
+	if len(sources) == 0 {
+		return nil
+	}
+	if opts.Offline {
+		return errors.New("1Password sources cannot be read in offline mode")
+	}
+	connect := onePasswordConnect{host: os.Getenv("OP_CONNECT_HOST"), token: os.Getenv("OP_CONNECT_TOKEN")}
+	if connect.host != "" && connect.token == "" {
+		return errors.New("OP_CONNECT_HOST is set, but OP_CONNECT_TOKEN is not")
+	}
+	var errs []error
+	for _, source := range sources {
+		r, err := parseOnePasswordRef(source)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		var value []byte
+		err = withWorker(opts, func() error {
+			if connect.host != "" {
+				s, err := connect.read(r, opts)
+				value = []byte(s)
+				return err
+			}
+			var err error
+			value, err = readOnePasswordCLI(r, opts)
+			return err
+		})
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "1Password source %s", r.ref))
+			if opts.context().Err() != nil {
+				break
+			}
+			continue
+		}
+		data[r.key] = encodeBase64(value)
+		wipe(value)
+	}
+	return joinSourceErrors(errs)
+}
@@ -0,0 +1,112 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_parseOnePasswordRef(t *testing.T) {
+	tests := []struct {
+		source  string
+		want    onePasswordRef
+		wantErr bool
+	}{
+		{"op://infra/db/password", onePasswordRef{key: "password", ref: "op://infra/db/password", vault: "infra", item: "db", field: "password"}, false},
+		{"DB_PASSWORD=op://infra/db/password", onePasswordRef{key: "DB_PASSWORD", ref: "op://infra/db/password", vault: "infra", item: "db", field: "password"}, false},
+		{"op://infra/db/admin/password", onePasswordRef{key: "password", ref: "op://infra/db/admin/password", vault: "infra", item: "db", section: "admin", field: "password"}, false},
+		{"infra/db/password", onePasswordRef{}, true},
+		{"op://infra/db", onePasswordRef{}, true},
+		{"op://infra//password", onePasswordRef{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			got, err := parseOnePasswordRef(tt.source)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseOnePasswordRef() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseOnePasswordRef() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseOnePasswordSources_connect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"status":401,"message":"Invalid token"}`))
+			return
+		}
+		switch r.URL.RequestURI() {
+		case "/v1/vaults?filter=name+eq+%22infra%22":
+			_, _ = w.Write([]byte(`[{"id":"vault1"}]`))
+		case "/v1/vaults/vault1/items?filter=title+eq+%22db%22":
+			_, _ = w.Write([]byte(`[{"id":"item1"}]`))
+		case "/v1/vaults/vault1/items/item1":
+			_, _ = w.Write([]byte(`{"sections":[{"id":"s1","label":"admin"}],"fields":[` +
+				`{"id":"password","label":"password","value":"secret"},` +
+				`{"id":"f2","label":"password","value":"admin-secret","section":{"id":"s1"}}]}`))
+		default:
+			_, _ = w.Write([]byte(`[]`))
+		}
+	}))
+	defer server.Close()
+	t.Setenv("OP_CONNECT_HOST", server.URL)
+	t.Setenv("OP_CONNECT_TOKEN", "token")
+
+	tests := []struct {
+		name    string
+		sources []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"Field", []string{"op://infra/db/password"}, map[string]string{"password": "secret"}, false},
+		{"Section", []string{"ADMIN_PASSWORD=op://infra/db/admin/password"}, map[string]string{"ADMIN_PASSWORD": "admin-secret"}, false},
+		{"MissingField", []string{"op://infra/db/username"}, nil, true},
+		{"MissingItem", []string{"op://infra/cache/password"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make(kvMap)
+			err := parseOnePasswordSources(tt.sources, decryptOptions{Context: context.Background()}, data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseOnePasswordSources() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(decodeData(data), tt.want) {
+				t.Errorf("parseOnePasswordSources() = %v, want %v", decodeData(data), tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseOnePasswordSources_cli(t *testing.T) {
+	bin := t.TempDir()
+	writeTestFile(t, filepath.Join(bin, "op"), "#!/bin/sh\n[ \"$3\" = op://infra/db/password ] || { echo 'item not found' >&2; exit 1; }\nprintf secret\n")
+	if err := os.Chmod(filepath.Join(bin, "op"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	t.Setenv("OP_CONNECT_HOST", "")
+
+	data := make(kvMap)
+	err := parseOnePasswordSources([]string{"DB_PASSWORD=op://infra/db/password"}, decryptOptions{Context: context.Background()}, data)
+	if err != nil {
+		t.Fatalf("parseOnePasswordSources() error = %v", err)
+	}
+	if got := decodeData(data); !reflect.DeepEqual(got, map[string]string{"DB_PASSWORD": "secret"}) {
+		t.Errorf("parseOnePasswordSources() = %v", got)
+	}
+	err = parseOnePasswordSources([]string{"op://infra/cache/password"}, decryptOptions{Context: context.Background()}, data)
+	if err == nil {
+		t.Error("parseOnePasswordSources() of a missing item succeeded")
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// otlpExporter exports spans to an OTLP/HTTP endpoint with JSON encoding,
+// which every OpenTelemetry collector accepts on its HTTP port.
+type otlpExporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+// otlpExporterFromEnv returns an exporter for the endpoint in
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, or OTEL_EXPORTER_OTLP_ENDPOINT with the
+// traces path appended, with the headers in the corresponding _HEADERS
+// variables. It returns nil if no endpoint is set.
+func otlpExporterFromEnv() (*otlpExporter, error) {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if endpoint == "" {
+			return nil, nil
+		}
+		endpoint = strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	}
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	if protocol != "" && protocol != "http/json" {
+		return nil, errors.Errorf("unsupported OTLP protocol %q, only http/json is supported", protocol)
+	}
+	headers, err := parseOTLPHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	if err != nil {
+		return nil, err
+	}
+	traceHeaders, err := parseOTLPHeaders(os.Getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS"))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range traceHeaders {
+		headers[name] = value
+	}
+	return &otlpExporter{endpoint: endpoint, headers: headers, client: &http.Client{}}, nil
+}
+
+// parseOTLPHeaders parses headers in the "name=value,name=value" form of
+// OTEL_EXPORTER_OTLP_HEADERS. Values are URL-encoded.
+func parseOTLPHeaders(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, encoded, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, errors.Errorf("invalid OTLP header %q, use name=value", pair)
+		}
+		decoded, err := url.PathUnescape(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid OTLP header %q", name)
+		}
+		headers[strings.TrimSpace(name)] = decoded
+	}
+	return headers, nil
+}
+
+// ExportSpans sends spans to the endpoint.
+func (e *otlpExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range e.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "could not export spans")
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.Errorf("could not export spans: %s responded %s", e.endpoint, resp.Status)
+	}
+	return nil
+}
+
+// Shutdown stops the exporter.
+func (e *otlpExporter) Shutdown(ctx context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// otlpRequest builds an OTLP ExportTraceServiceRequest in its JSON mapping.
+// All spans of an invocation come from the same provider, so they share the
+// resource of the first span.
+func otlpRequest(spans []sdktrace.ReadOnlySpan) map[string]any {
+	var scopes []map[string]any
+	scopeIndex := make(map[string]int)
+	for _, span := range spans {
+		scope := span.InstrumentationScope()
+		i, ok := scopeIndex[scope.Name]
+		if !ok {
+			i = len(scopes)
+			scopeIndex[scope.Name] = i
+			scopes = append(scopes, map[string]any{
+				"scope": map[string]any{"name": scope.Name, "version": scope.Version},
+				"spans": []map[string]any{},
+			})
+		}
+		scopes[i]["spans"] = append(scopes[i]["spans"].([]map[string]any), otlpSpan(span))
+	}
+	var resourceAttributes []map[string]any
+	if res := spans[0].Resource(); res != nil {
+		resourceAttributes = otlpAttributes(res.Attributes())
+	}
+	return map[string]any{
+		"resourceSpans": []map[string]any{{
+			"resource":   map[string]any{"attributes": resourceAttributes},
+			"scopeSpans": scopes,
+		}},
+	}
+}
+
+// otlpSpan returns the JSON mapping of a span. IDs are hex encoded, and
+// 64-bit integers are strings.
+func otlpSpan(span sdktrace.ReadOnlySpan) map[string]any {
+	s := map[string]any{
+		"traceId":           span.SpanContext().TraceID().String(),
+		"spanId":            span.SpanContext().SpanID().String(),
+		"name":              span.Name(),
+		"kind":              int(span.SpanKind()),
+		"startTimeUnixNano": strconv.FormatInt(span.StartTime().UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(span.EndTime().UnixNano(), 10),
+		"attributes":        otlpAttributes(span.Attributes()),
+		"status":            otlpStatus(span.Status()),
+	}
+	if span.Parent().HasSpanID() {
+		s["parentSpanId"] = span.Parent().SpanID().String()
+	}
+	var events []map[string]any
+	for _, event := range span.Events() {
+		events = append(events, map[string]any{
+			"name":         event.Name,
+			"timeUnixNano": strconv.FormatInt(event.Time.UnixNano(), 10),
+			"attributes":   otlpAttributes(event.Attributes),
+		})
+	}
+	if events != nil {
+		s["events"] = events
+	}
+	return s
+}
+
+// otlpStatus maps a span status to OTLP, whose codes differ from the API.
+func otlpStatus(status sdktrace.Status) map[string]any {
+	switch status.Code {
+	case codes.Ok:
+		return map[string]any{"code": 1}
+	case codes.Error:
+		return map[string]any{"code": 2, "message": status.Description}
+	}
+	return map[string]any{}
+}
+
+// otlpAttributes returns the JSON mapping of attributes.
+func otlpAttributes(attributes []attribute.KeyValue) []map[string]any {
+	result := make([]map[string]any, 0, len(attributes))
+	for _, kv := range attributes {
+		result = append(result, map[string]any{"key": string(kv.Key), "value": otlpValue(kv.Value)})
+	}
+	return result
+}
+
+// otlpValue returns the JSON mapping of an attribute value.
+func otlpValue(v attribute.Value) map[string]any {
+	switch v.Type() {
+	case attribute.BOOL:
+		return map[string]any{"boolValue": v.AsBool()}
+	case attribute.INT64:
+		return map[string]any{"intValue": strconv.FormatInt(v.AsInt64(), 10)}
+	case attribute.FLOAT64:
+		return map[string]any{"doubleValue": v.AsFloat64()}
+	case attribute.STRINGSLICE:
+		values := make([]map[string]any, 0, len(v.AsStringSlice()))
+		for _, s := range v.AsStringSlice() {
+			values = append(values, map[string]any{"stringValue": s})
+		}
+		return map[string]any{"arrayValue": map[string]any{"values": values}}
+	}
+	return map[string]any{"stringValue": v.Emit()}
+}
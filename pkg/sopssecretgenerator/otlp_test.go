@@ -0,0 +1,139 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func Test_otlpExporterFromEnv(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		wantEndpoint string
+		wantHeaders  map[string]string
+		wantErr      bool
+	}{
+		{"Unset", nil, "", nil, false},
+		{"Endpoint", map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318/"}, "http://collector:4318/v1/traces", map[string]string{}, false},
+		{"TracesEndpoint", map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://traces:4318/custom"}, "http://traces:4318/custom", map[string]string{}, false},
+		{"Headers", map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318", "OTEL_EXPORTER_OTLP_HEADERS": "Authorization=Bearer%20token, x-tenant=a", "OTEL_EXPORTER_OTLP_TRACES_HEADERS": "x-tenant=b"}, "http://collector:4318/v1/traces", map[string]string{"Authorization": "Bearer token", "x-tenant": "b"}, false},
+		{"InvalidHeader", map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318", "OTEL_EXPORTER_OTLP_HEADERS": "token"}, "", nil, true},
+		{"JSONProtocol", map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318", "OTEL_EXPORTER_OTLP_PROTOCOL": "http/json"}, "http://collector:4318/v1/traces", map[string]string{}, false},
+		{"GRPCProtocol", map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4317", "OTEL_EXPORTER_OTLP_PROTOCOL": "grpc"}, "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_PROTOCOL", "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL", "OTEL_EXPORTER_OTLP_HEADERS", "OTEL_EXPORTER_OTLP_TRACES_HEADERS"} {
+				t.Setenv(name, tt.env[name])
+			}
+			got, err := otlpExporterFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("otlpExporterFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.wantEndpoint == "" {
+				if got != nil {
+					t.Errorf("otlpExporterFromEnv() = %+v, want nil", got)
+				}
+				return
+			}
+			if got.endpoint != tt.wantEndpoint || !reflect.DeepEqual(got.headers, tt.wantHeaders) {
+				t.Errorf("otlpExporterFromEnv() = %s %v, want %s %v", got.endpoint, got.headers, tt.wantEndpoint, tt.wantHeaders)
+			}
+		})
+	}
+}
+
+func Test_otlpExporter(t *testing.T) {
+	var body map[string]any
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		content, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(content, &body)
+	}))
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	ctx, parent := provider.Tracer(tracerName).Start(context.Background(), "generate")
+	_, child := provider.Tracer(tracerName).Start(ctx, "decrypt")
+	child.SetAttributes(attribute.String("file", "secret.env"), attribute.Bool("cached", true), attribute.StringSlice("sops.key_types", []string{"kms"}))
+	endSpan(child, errors.New("access denied"))
+	parent.End()
+
+	exporter := &otlpExporter{endpoint: server.URL + "/v1/traces", headers: map[string]string{"Authorization": "Bearer token"}, client: server.Client()}
+	err := exporter.ExportSpans(context.Background(), recorder.Ended())
+	if err != nil {
+		t.Fatalf("ExportSpans() error = %v", err)
+	}
+	if header.Get("Content-Type") != "application/json" || header.Get("Authorization") != "Bearer token" {
+		t.Errorf("ExportSpans() headers = %v", header)
+	}
+
+	var request struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Scope struct{ Name string }
+				Spans []struct {
+					TraceID      string `json:"traceId"`
+					SpanID       string `json:"spanId"`
+					ParentSpanID string `json:"parentSpanId"`
+					Name         string
+					Attributes   []map[string]any
+					Status       struct {
+						Code    int
+						Message string
+					}
+				}
+			}
+		}
+	}
+	content, _ := json.Marshal(body)
+	err = json.Unmarshal(content, &request)
+	if err != nil || len(request.ResourceSpans) != 1 || len(request.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("ExportSpans() sent %s", content)
+	}
+	scope := request.ResourceSpans[0].ScopeSpans[0]
+	if scope.Scope.Name != tracerName || len(scope.Spans) != 2 {
+		t.Fatalf("ExportSpans() sent %s", content)
+	}
+	decrypt, generate := scope.Spans[0], scope.Spans[1]
+	if len(decrypt.TraceID) != 32 || len(decrypt.SpanID) != 16 || decrypt.ParentSpanID != generate.SpanID || generate.ParentSpanID != "" {
+		t.Errorf("ExportSpans() IDs = %+v, %+v", decrypt, generate)
+	}
+	if decrypt.Status.Code != 2 || decrypt.Status.Message != "access denied" {
+		t.Errorf("ExportSpans() status = %+v, want error", decrypt.Status)
+	}
+	want := []map[string]any{
+		{"key": "file", "value": map[string]any{"stringValue": "secret.env"}},
+		{"key": "cached", "value": map[string]any{"boolValue": true}},
+		{"key": "sops.key_types", "value": map[string]any{"arrayValue": map[string]any{"values": []any{map[string]any{"stringValue": "kms"}}}}},
+	}
+	if !reflect.DeepEqual(decrypt.Attributes, want) {
+		t.Errorf("ExportSpans() attributes = %v, want %v", decrypt.Attributes, want)
+	}
+
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	err = exporter.ExportSpans(context.Background(), recorder.Ended())
+	if err == nil {
+		t.Errorf("ExportSpans() error = nil for a rejected request")
+	}
+}
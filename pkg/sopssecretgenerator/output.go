@@ -0,0 +1,78 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// jsonList is a Kubernetes List of the generated Secrets, as written by
+// standalone runs with --output=json
+type jsonList struct {
+	TypeMeta `json:",inline"`
+	Items    []map[string]interface{} `json:"items"`
+}
+
+// generateJSON reads a ResourceList, generates its Secrets like the KRM
+// function does, and writes them as a JSON List. Nothing is written if
+// generation fails.
+func generateJSON(r io.Reader, w io.Writer) error {
+	in, err := io.ReadAll(r)
+	if err != nil {
+		return errors.Wrap(err, "could not read ResourceList")
+	}
+	rl, err := fn.ParseResourceList(in)
+	if err != nil {
+		return err
+	}
+	_, err = generateKRMManifest(rl)
+	if err != nil {
+		return err
+	}
+	rl.Sort()
+	return writeJSONList(w, rl.Items)
+}
+
+// writeJSONList writes objects as an indented JSON List, without the
+// annotations that only matter to a KRM function orchestrator.
+func writeJSONList(w io.Writer, items fn.KubeObjects) error {
+	list := jsonList{TypeMeta: TypeMeta{APIVersion: "v1", Kind: "List"}, Items: []map[string]interface{}{}}
+	for _, item := range items {
+		var object map[string]interface{}
+		err := yaml.Unmarshal([]byte(item.String()), &object)
+		if err != nil {
+			return err
+		}
+		removeOrchestratorAnnotations(object)
+		list.Items = append(list.Items, object)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(list)
+}
+
+// removeOrchestratorAnnotations removes the annotations that kustomize and kpt
+// use to track the items of a ResourceList, and the annotations field if
+// nothing else is left.
+func removeOrchestratorAnnotations(object map[string]interface{}) {
+	metadata, _ := object["metadata"].(map[string]interface{})
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	if annotations == nil {
+		return
+	}
+	for k := range annotations {
+		if k == "config.k8s.io/id" || strings.HasPrefix(k, "internal.config.kubernetes.io/") {
+			delete(annotations, k)
+		}
+	}
+	if len(annotations) == 0 {
+		delete(metadata, "annotations")
+	}
+}
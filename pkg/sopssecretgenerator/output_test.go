@@ -0,0 +1,113 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func Test_generateJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		rlFile    string
+		wantNames []string
+		wantData  map[string]interface{}
+		wantErr   bool
+	}{
+		{"Multiple", "testdata/krm-function-input.yaml", []string{"secret-from-env", "secret-from-file"},
+			map[string]interface{}{"VAR_ENV": "dmFsX2Vudg=="}, false},
+		{"Single", "testdata/krm-combined.yaml", []string{"combined"},
+			map[string]interface{}{"VAR_ENV": "dmFsX2Vudg==", "file.txt": "c2VjcmV0Cg=="}, false},
+		{"Malformed", "testdata/krm-error.yaml", nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, err := os.Open(tt.rlFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = in.Close() }()
+			var out bytes.Buffer
+
+			err = generateJSON(in, &out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("generateJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if out.Len() > 0 {
+					t.Errorf("generateJSON() wrote %q on error", out.String())
+				}
+				return
+			}
+			var list struct {
+				APIVersion string `json:"apiVersion"`
+				Kind       string `json:"kind"`
+				Items      []Secret
+			}
+			err = json.Unmarshal(out.Bytes(), &list)
+			if err != nil {
+				t.Fatalf("generateJSON() wrote invalid JSON: %v", err)
+			}
+			if list.APIVersion != "v1" || list.Kind != "List" {
+				t.Errorf("generateJSON() = %s/%s, want v1/List", list.APIVersion, list.Kind)
+			}
+			var names []string
+			for _, item := range list.Items {
+				names = append(names, item.Name)
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("generateJSON() names = %v, want %v", names, tt.wantNames)
+			}
+			got := make(map[string]interface{})
+			for k, v := range list.Items[0].Data {
+				got[k] = v
+			}
+			if !reflect.DeepEqual(got, tt.wantData) {
+				t.Errorf("generateJSON() data = %v, want %v", got, tt.wantData)
+			}
+		})
+	}
+}
+
+func Test_removeOrchestratorAnnotations(t *testing.T) {
+	tests := []struct {
+		name   string
+		object map[string]interface{}
+		want   map[string]interface{}
+	}{
+		{"None", map[string]interface{}{"metadata": map[string]interface{}{"name": "a"}},
+			map[string]interface{}{"metadata": map[string]interface{}{"name": "a"}}},
+		{"OnlyOrchestrator",
+			map[string]interface{}{"metadata": map[string]interface{}{"annotations": map[string]interface{}{"config.k8s.io/id": "1", "internal.config.kubernetes.io/index": "0"}}},
+			map[string]interface{}{"metadata": map[string]interface{}{}}},
+		{"Kept",
+			map[string]interface{}{"metadata": map[string]interface{}{"annotations": map[string]interface{}{"config.k8s.io/id": "1", "team": "a"}}},
+			map[string]interface{}{"metadata": map[string]interface{}{"annotations": map[string]interface{}{"team": "a"}}}},
+		{"NoMetadata", map[string]interface{}{"kind": "Secret"}, map[string]interface{}{"kind": "Secret"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			removeOrchestratorAnnotations(tt.object)
+			if !reflect.DeepEqual(tt.object, tt.want) {
+				t.Errorf("removeOrchestratorAnnotations() = %v, want %v", tt.object, tt.want)
+			}
+		})
+	}
+}
+
+func Test_writeJSONList(t *testing.T) {
+	var out bytes.Buffer
+	err := writeJSONList(&out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n  \"apiVersion\": \"v1\",\n  \"kind\": \"List\",\n  \"items\": []\n}\n"
+	if out.String() != want {
+		t.Errorf("writeJSONList() = %q, want %q", out.String(), want)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// parseError is an error in the decrypted content of an env source, at a
+// line and column, both starting at 1, and the key it is in, if known. It
+// never includes values, which are secret.
+type parseError struct {
+	Line    int
+	Column  int
+	Key     string
+	Message string
+}
+
+// Error returns the message, prefixed with the position and key.
+func (e parseError) Error() string {
+	var position []string
+	if e.Line > 0 {
+		position = append(position, fmt.Sprintf("line %d", e.Line))
+	}
+	if e.Column > 0 {
+		position = append(position, fmt.Sprintf("column %d", e.Column))
+	}
+	if e.Key != "" {
+		position = append(position, "key "+e.Key)
+	}
+	if len(position) == 0 {
+		return e.Message
+	}
+	return strings.Join(position, ", ") + ": " + e.Message
+}
+
+// invalidUTF8Column returns the column of the first invalid UTF-8 byte in a
+// line, or 0 if the line is valid.
+func invalidUTF8Column(line []byte) int {
+	column := 1
+	for len(line) > 0 {
+		r, size := utf8.DecodeRune(line)
+		if r == utf8.RuneError && size <= 1 {
+			return column
+		}
+		line = line[size:]
+		column++
+	}
+	return 0
+}
+
+// offsetPosition returns the line and column of a byte offset in content.
+func offsetPosition(content []byte, offset int64) (int, int) {
+	offset = max(0, min(offset, int64(len(content))))
+	before := content[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := utf8.RuneCount(before[bytes.LastIndexByte(before, '\n')+1:]) + 1
+	return line, column
+}
+
+// yamlKindName names the kind of a YAML node for errors.
+func yamlKindName(kind yaml.Kind) string {
+	switch kind {
+	case yaml.MappingNode:
+		return "mapping"
+	case yaml.SequenceNode:
+		return "sequence"
+	}
+	return "scalar"
+}
+
+// jsonParseError adds the line and column of a JSON syntax or type error,
+// and the key of a value that is not a string. JSON errors have the offset
+// after the byte that failed.
+func jsonParseError(content []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		line, column := offsetPosition(content, syntaxErr.Offset-1)
+		return parseError{Line: line, Column: column, Message: strings.TrimPrefix(syntaxErr.Error(), "json: ")}
+	case errors.As(err, &typeErr):
+		line, column := offsetPosition(content, typeErr.Offset-1)
+		return parseError{Line: line, Column: column, Key: typeErr.Field,
+			Message: fmt.Sprintf("value must be a string, not %s", typeErr.Value)}
+	}
+	return err
+}
@@ -0,0 +1,66 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+)
+
+func Test_parseEnvContent_positions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		format  formats.Format
+		want    []string
+	}{
+		{"DotenvNoValue", "A=1\n# comment\nsecret-without-key\n", formats.Dotenv, []string{"line 3, column 19: expected KEY=value"}},
+		{"DotenvUTF8", "A=1\nB=\xff\n", formats.Dotenv, []string{"line 2, column 3: invalid UTF-8"}},
+		{"YAMLSequence", "A: a\nB:\n  - 1\n", formats.Yaml, []string{"line 3, column 3, key B: value must be a string, not a sequence"}},
+		{"YAMLMapping", "A: a\nB: {c: d}\n", formats.Yaml, []string{"line 2, column 4, key B: value must be a string, not a mapping"}},
+		{"YAMLNotMapping", "- a\n", formats.Yaml, []string{"line 1, column 1: content must be a mapping"}},
+		{"YAMLSyntax", "A: a\n\tB: b\n", formats.Yaml, []string{"line 2"}},
+		{"JSONType", "{\n  \"A\": \"a\",\n  \"B\": 1\n}", formats.Json, []string{"line 3, column ", "key B: value must be a string, not number"}},
+		{"JSONSyntax", "{\n  \"A\": \"a\"\n  \"B\": \"b\"\n}", formats.Json, []string{"line 3, column ", "invalid character"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := parseEnvContent([]byte(tt.content), tt.format, make(kvMap))
+			if err == nil {
+				t.Fatal("parseEnvContent() succeeded")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("parseEnvContent() error = %v, want %s", err, want)
+				}
+			}
+			if strings.Contains(err.Error(), "secret-without-key") {
+				t.Errorf("parseEnvContent() error = %v, has content", err)
+			}
+		})
+	}
+}
+
+func Test_offsetPosition(t *testing.T) {
+	content := []byte("ab\ncdé\nf")
+	tests := []struct {
+		offset     int64
+		wantLine   int
+		wantColumn int
+	}{
+		{0, 1, 1},
+		{2, 1, 3},
+		{3, 2, 1},
+		{7, 2, 4},
+		{100, 3, 2},
+	}
+	for _, tt := range tests {
+		line, column := offsetPosition(content, tt.offset)
+		if line != tt.wantLine || column != tt.wantColumn {
+			t.Errorf("offsetPosition(%d) = %d, %d, want %d, %d", tt.offset, line, column, tt.wantLine, tt.wantColumn)
+		}
+	}
+}
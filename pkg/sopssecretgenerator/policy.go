@@ -0,0 +1,91 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"strings"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/pgp"
+	"github.com/pkg/errors"
+)
+
+// Policy restricts the sops keys that source files may be encrypted with
+type Policy struct {
+	AllowedRecipients  []string `json:"allowedRecipients,omitempty" yaml:"allowedRecipients,omitempty"`
+	MinKeyGroups       int      `json:"minKeyGroups,omitempty" yaml:"minKeyGroups,omitempty"`
+	MinShamirThreshold int      `json:"minShamirThreshold,omitempty" yaml:"minShamirThreshold,omitempty"`
+	MatchCreationRules bool     `json:"matchCreationRules,omitempty" yaml:"matchCreationRules,omitempty"`
+}
+
+// check verifies the sops metadata of a source file against the policy.
+func (p Policy) check(metadata sops.Metadata) error {
+	err := p.checkKeyGroups(metadata)
+	if err != nil {
+		return err
+	}
+	return p.checkRecipients(metadata)
+}
+
+// checkKeyGroups verifies the number of key groups and the Shamir threshold.
+func (p Policy) checkKeyGroups(metadata sops.Metadata) error {
+	groups := len(metadata.KeyGroups)
+	if groups < p.MinKeyGroups {
+		return errors.Errorf("file has %d key groups, policy requires at least %d", groups, p.MinKeyGroups)
+	}
+	threshold := shamirThreshold(metadata)
+	if threshold < p.MinShamirThreshold {
+		return errors.Errorf("file has shamir threshold %d, policy requires at least %d", threshold, p.MinShamirThreshold)
+	}
+	return nil
+}
+
+// shamirThreshold returns the number of key groups needed to recover the data
+// key. sops omits the threshold for single-group files, and defaults it to the
+// number of key groups otherwise.
+func shamirThreshold(metadata sops.Metadata) int {
+	if len(metadata.KeyGroups) <= 1 {
+		return len(metadata.KeyGroups)
+	}
+	if metadata.ShamirThreshold == 0 {
+		return len(metadata.KeyGroups)
+	}
+	return metadata.ShamirThreshold
+}
+
+// checkRecipients verifies that every key is an allowed recipient.
+func (p Policy) checkRecipients(metadata sops.Metadata) error {
+	if len(p.AllowedRecipients) == 0 {
+		return nil
+	}
+
+	allowed := make(map[string]bool)
+	allowedFingerprints := make(map[string]bool)
+	for _, recipient := range p.AllowedRecipients {
+		allowed[recipient] = true
+		allowedFingerprints[normalizeFingerprint(recipient)] = true
+	}
+	for _, group := range metadata.KeyGroups {
+		for _, key := range group {
+			var ok bool
+			if key.TypeToIdentifier() == pgp.KeyTypeIdentifier {
+				ok = allowedFingerprints[normalizeFingerprint(key.ToString())]
+			} else {
+				ok = allowed[key.ToString()]
+			}
+			if !ok {
+				return errors.Errorf("%s key %s is not an allowed recipient", key.TypeToIdentifier(), key.ToString())
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeFingerprint makes PGP fingerprints comparable regardless of case
+// and whitespace, so that they can be written in their usual grouped form.
+// Other key identifiers, such as KMS ARNs and Vault paths, are case-sensitive
+// and are compared exactly.
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(fingerprint), ""))
+}
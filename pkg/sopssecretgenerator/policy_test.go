@@ -0,0 +1,126 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/age"
+	"github.com/getsops/sops/v3/kms"
+	"github.com/getsops/sops/v3/pgp"
+)
+
+const testAgeRecipient = "age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p"
+const testKMSArn = "arn:aws:kms:eu-west-1:111122223333:alias/Production"
+
+func Test_Policy_check(t *testing.T) {
+	type args struct {
+		policy   Policy
+		metadata sops.Metadata
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{"NoPolicy", args{Policy{}, pgpMetadata(testkeyFingerprint)}, false},
+		{"Allowed", args{Policy{AllowedRecipients: []string{testkeyFingerprint}}, pgpMetadata(testkeyFingerprint)}, false},
+		{"AllowedGrouped", args{Policy{AllowedRecipients: []string{"2d24 83df 73a3 a0fa ee3c  2a69 5bdc 3953 60ce 8ff4"}}, pgpMetadata(testkeyFingerprint)}, false},
+		{"Denied", args{Policy{AllowedRecipients: []string{testkeyFingerprint}}, pgpMetadata(testkeyFingerprint, "0000000000000000000000000000000000000000")}, true},
+		{"AgeAllowed", args{Policy{AllowedRecipients: []string{testAgeRecipient}}, keyGroupsMetadata(sops.KeyGroup{&age.MasterKey{Recipient: testAgeRecipient}})}, false},
+		{"AgeDenied", args{Policy{AllowedRecipients: []string{testkeyFingerprint}}, keyGroupsMetadata(sops.KeyGroup{&age.MasterKey{Recipient: testAgeRecipient}})}, true},
+		{"SecondKeyGroupDenied", args{
+			Policy{AllowedRecipients: []string{testkeyFingerprint}},
+			keyGroupsMetadata(
+				sops.KeyGroup{pgp.NewMasterKeyFromFingerprint(testkeyFingerprint)},
+				sops.KeyGroup{&age.MasterKey{Recipient: testAgeRecipient}},
+			),
+		}, true},
+		{"KMSExact", args{Policy{AllowedRecipients: []string{testKMSArn}}, keyGroupsMetadata(sops.KeyGroup{&kms.MasterKey{Arn: testKMSArn}})}, false},
+		{"KMSCaseSensitive", args{Policy{AllowedRecipients: []string{strings.ToLower(testKMSArn)}}, keyGroupsMetadata(sops.KeyGroup{&kms.MasterKey{Arn: testKMSArn}})}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.args.policy.check(tt.args.metadata)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Policy.check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_Policy_checkKeyGroups(t *testing.T) {
+	pgpGroup := sops.KeyGroup{pgp.NewMasterKeyFromFingerprint(testkeyFingerprint)}
+	ageGroup := sops.KeyGroup{&age.MasterKey{Recipient: testAgeRecipient}}
+	twoGroups := keyGroupsMetadata(pgpGroup, ageGroup)
+	oneOfTwoGroups := keyGroupsMetadata(pgpGroup, ageGroup)
+	oneOfTwoGroups.ShamirThreshold = 1
+
+	type args struct {
+		policy   Policy
+		metadata sops.Metadata
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{"NoPolicy", args{Policy{}, keyGroupsMetadata(pgpGroup)}, false},
+		{"EnoughGroups", args{Policy{MinKeyGroups: 2}, twoGroups}, false},
+		{"TooFewGroups", args{Policy{MinKeyGroups: 2}, keyGroupsMetadata(pgpGroup)}, true},
+		{"DefaultThreshold", args{Policy{MinShamirThreshold: 2}, twoGroups}, false},
+		{"ThresholdTooLow", args{Policy{MinKeyGroups: 2, MinShamirThreshold: 2}, oneOfTwoGroups}, true},
+		{"SingleGroupThreshold", args{Policy{MinShamirThreshold: 2}, keyGroupsMetadata(pgpGroup)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.args.policy.check(tt.args.metadata)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Policy.check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_decryptFile_Policy(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    decryptOptions
+		wantErr string
+	}{
+		{"Allowed", decryptOptions{Policy: Policy{AllowedRecipients: []string{testkeyFingerprint}}}, ""},
+		{"Denied", decryptOptions{Policy: Policy{AllowedRecipients: []string{"0000000000000000000000000000000000000000"}}}, "is not an allowed recipient"},
+		{"TooFewKeyGroups", decryptOptions{Policy: Policy{MinKeyGroups: 2}}, "policy requires at least 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decryptFile("testdata/file.txt", tt.opts)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("decryptFile() error = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("decryptFile() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Test util functions
+
+func pgpMetadata(fingerprints ...string) sops.Metadata {
+	var group sops.KeyGroup
+	for _, fp := range fingerprints {
+		group = append(group, pgp.NewMasterKeyFromFingerprint(fp))
+	}
+	return keyGroupsMetadata(group)
+}
+
+func keyGroupsMetadata(groups ...sops.KeyGroup) sops.Metadata {
+	return sops.Metadata{KeyGroups: groups}
+}
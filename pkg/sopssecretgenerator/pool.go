@@ -0,0 +1,182 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/pkg/errors"
+)
+
+// maxWorkers bounds the number of files that are decrypted, and remote
+// sources that are fetched, at the same time across all generators, unless
+// MaxConcurrency is set. Both are dominated by round-trips to key services
+// and secret stores, so this is independent of the number of CPUs.
+const maxWorkers = 8
+
+var (
+	workerPoolsMutex sync.Mutex
+	workerPools      = make(map[int]chan struct{})
+)
+
+// openWorkers returns the worker pool with a number of slots, or maxWorkers
+// slots if the number is zero. Each pool holds a slot for every decryption or
+// fetch in progress, and is shared by all generators with the same limit.
+func openWorkers(size int) chan struct{} {
+	if size <= 0 {
+		size = maxWorkers
+	}
+	workerPoolsMutex.Lock()
+	defer workerPoolsMutex.Unlock()
+	workers, ok := workerPools[size]
+	if !ok {
+		workers = make(chan struct{}, size)
+		workerPools[size] = workers
+	}
+	return workers
+}
+
+// withWorker runs f in a slot of the worker pool. If the context is canceled
+// while waiting for a slot, f is not run.
+func withWorker(opts decryptOptions, f func() error) error {
+	workers := opts.workers()
+	select {
+	case workers <- struct{}{}:
+	case <-opts.context().Done():
+		return errors.Wrap(opts.context().Err(), "canceled")
+	}
+	defer func() { <-workers }()
+	return f()
+}
+
+// generateSecretObjects generates the Secrets for generator items, processing
+// at most parallel items at a time, or maxWorkers if parallel is zero. The
+// Secrets are returned in the order of the items. After an item fails, no
+// further items are started, and the error of the first failed item is
+// returned. No further items are started either once the context is
+// canceled. If state is not nil, unchanged Secrets are reused from it.
+func generateSecretObjects(ctx context.Context, items fn.KubeObjects, parallel int, state *stateFile) (fn.KubeObjects, error) {
+	if parallel <= 0 {
+		parallel = maxWorkers
+	}
+	slots := make(chan struct{}, parallel)
+	secrets := make(fn.KubeObjects, len(items))
+	errs := make([]error, len(items))
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for i, item := range items {
+		slots <- struct{}{}
+		if failed.Load() || ctx.Err() != nil {
+			<-slots
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			start := time.Now()
+			invocationEvents.emit(event{Type: eventGeneratorStarted, Generator: item.GetName()})
+			secrets[i], errs[i] = generateSecretObject(ctx, item, state)
+			invocationTimings.addGenerator(item.GetName(), time.Since(start))
+			if errs[i] != nil {
+				failed.Store(true)
+				invocationEvents.emit(event{Type: eventError, Generator: item.GetName(), Message: errs[i].Error()})
+				annotateGeneratorError(os.Stderr, runtimeSettings.Annotations, item, errs[i])
+			} else if secrets[i] != nil {
+				invocationEvents.emit(secretEvent(item.GetName(), secrets[i]))
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := ctx.Err()
+	if err != nil {
+		return nil, errors.Wrap(err, "generation canceled")
+	}
+	// Generators whose condition does not hold generate no Secret
+	generated := secrets[:0]
+	for _, secret := range secrets {
+		if secret != nil {
+			generated = append(generated, secret)
+		}
+	}
+	return generated, nil
+}
+
+// decryptResult is the outcome of decrypting a source
+type decryptResult struct {
+	decrypted []byte
+	err       error
+}
+
+// prefetchedFiles holds the results of sources that were decrypted ahead of
+// parsing, by source
+type prefetchedFiles map[string]decryptResult
+
+// prefetchFiles decrypts sources concurrently with the worker pool. Sources
+// that occur more than once are decrypted once. Sources that are still waiting
+// for a worker when the context is canceled are not decrypted.
+func prefetchFiles(sources []string, opts decryptOptions) prefetchedFiles {
+	var unique []string
+	seen := make(map[string]bool)
+	for _, source := range sources {
+		if !seen[source] {
+			seen[source] = true
+			unique = append(unique, source)
+		}
+	}
+
+	workers := opts.workers()
+	results := make([]decryptResult, len(unique))
+	var wg sync.WaitGroup
+	for i, source := range unique {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case workers <- struct{}{}:
+			case <-opts.context().Done():
+				results[i] = decryptResult{err: errors.Wrap(opts.context().Err(), "decryption canceled")}
+				return
+			}
+			defer func() { <-workers }()
+			decrypted, err := decryptFile(source, opts)
+			results[i] = decryptResult{decrypted, err}
+		}()
+	}
+	wg.Wait()
+
+	prefetched := make(prefetchedFiles, len(unique))
+	for i, source := range unique {
+		prefetched[source] = results[i]
+	}
+	return prefetched
+}
+
+// take returns the result for a source and removes it, so that the caller
+// owns the decrypted buffer. A source that occurs more than once is only
+// prefetched for its first use.
+func (p prefetchedFiles) take(source string) (decryptResult, bool) {
+	result, ok := p[source]
+	delete(p, source)
+	return result, ok
+}
+
+// wipe overwrites the results that were not taken.
+func (p prefetchedFiles) wipe() {
+	for source, result := range p {
+		wipe(result.decrypted)
+		delete(p, source)
+	}
+}
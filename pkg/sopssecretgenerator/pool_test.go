@@ -0,0 +1,185 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+)
+
+func Test_prefetchFiles(t *testing.T) {
+	sources := []string{"testdata/file.txt", "testdata/file2.txt", "testdata/file.txt", "testdata/missing.txt"}
+	prefetched := prefetchFiles(sources, decryptOptions{})
+	defer prefetched.wipe()
+
+	if len(prefetched) != 3 {
+		t.Errorf("prefetchFiles() returned %d results, want 3", len(prefetched))
+	}
+	tests := []struct {
+		source  string
+		want    string
+		wantOk  bool
+		wantErr bool
+	}{
+		{"testdata/file.txt", "secret\n", true, false},
+		{"testdata/file2.txt", "secret2\n", true, false},
+		{"testdata/missing.txt", "", true, true},
+		// Results are removed when taken.
+		{"testdata/file.txt", "", false, false},
+		{"testdata/vars.env", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			got, ok := prefetched.take(tt.source)
+			if ok != tt.wantOk {
+				t.Fatalf("take() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if (got.err != nil) != tt.wantErr {
+				t.Errorf("take() error = %v, wantErr %v", got.err, tt.wantErr)
+			}
+			if string(got.decrypted) != tt.want {
+				t.Errorf("take() = %q, want %q", got.decrypted, tt.want)
+			}
+		})
+	}
+}
+
+func Test_openWorkers(t *testing.T) {
+	if got := cap(openWorkers(0)); got != maxWorkers {
+		t.Errorf("openWorkers(0) has %d slots, want %d", got, maxWorkers)
+	}
+	if openWorkers(3) != openWorkers(3) {
+		t.Error("openWorkers() returned a new pool for the same size")
+	}
+	if got := cap(openWorkers(3)); got != 3 {
+		t.Errorf("openWorkers(3) has %d slots, want 3", got)
+	}
+}
+
+func Test_withWorker(t *testing.T) {
+	opts := decryptOptions{Workers: make(chan struct{}, 1)}
+	var running atomic.Int32
+	var peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = withWorker(opts, func() error {
+				n := running.Add(1)
+				if n > peak.Load() {
+					peak.Store(n)
+				}
+				time.Sleep(time.Millisecond)
+				running.Add(-1)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+	if peak.Load() != 1 {
+		t.Errorf("withWorker() ran %d functions at a time, want 1", peak.Load())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	opts.Workers <- struct{}{}
+	opts.Context = ctx
+	ran := false
+	err := withWorker(opts, func() error { ran = true; return nil })
+	if err == nil || ran {
+		t.Errorf("withWorker() with a full pool and a canceled context = %v, ran %v", err, ran)
+	}
+}
+
+func Test_prefetchedFiles_wipe(t *testing.T) {
+	buf := []byte("secret")
+	prefetched := prefetchedFiles{"file.txt": {decrypted: buf}}
+	prefetched.wipe()
+	if len(prefetched) != 0 {
+		t.Errorf("wipe() left %d results", len(prefetched))
+	}
+	for _, b := range buf {
+		if b != 0 {
+			t.Fatalf("wipe() did not overwrite buffer: %q", buf)
+		}
+	}
+}
+
+func Test_decryptFile_Prefetched(t *testing.T) {
+	opts := decryptOptions{Prefetched: prefetchedFiles{"testdata/missing.txt": {decrypted: []byte("prefetched")}}}
+	got, err := decryptFile("testdata/missing.txt", opts)
+	if err != nil || string(got) != "prefetched" {
+		t.Errorf("decryptFile() = %q, %v, want prefetched result", got, err)
+	}
+	_, err = decryptFile("testdata/missing.txt", opts)
+	if err == nil {
+		t.Errorf("decryptFile() used prefetched result twice")
+	}
+}
+
+func Test_inputFiles(t *testing.T) {
+	input := SopsSecretGenerator{
+		EnvSources:  []string{"vars.env"},
+		FileSources: []string{"file.txt", "key=file2.txt", "=invalid"},
+	}
+	got := inputFiles(input)
+	want := []string{"vars.env", "file.txt", "file2.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("inputFiles() = %v, want %v", got, want)
+	}
+}
+
+func Test_generateSecretObjects(t *testing.T) {
+	generator := func(name string, file string) *fn.KubeObject {
+		obj, err := fn.ParseKubeObject([]byte(fmt.Sprintf("apiVersion: %s\nkind: %s\nmetadata:\n  name: %s\nfiles:\n  - %s\n", apiVersion, kind, name, file)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obj
+	}
+	var items fn.KubeObjects
+	for i := 0; i < 10; i++ {
+		items = append(items, generator(fmt.Sprintf("secret-%d", i), "testdata/file.txt"))
+	}
+
+	tests := []struct {
+		name     string
+		items    fn.KubeObjects
+		parallel int
+		wantErr  string
+	}{
+		{"Default", items, 0, ""},
+		{"Sequential", items, 1, ""},
+		{"Parallel", items, 3, ""},
+		{"FirstError", fn.KubeObjects{items[0], generator("broken-1", "testdata/missing1.txt"), generator("broken-2", "testdata/missing2.txt")}, 3, "missing1.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := generateSecretObjects(context.Background(), tt.items, tt.parallel, nil)
+			if (err != nil) != (tt.wantErr != "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("generateSecretObjects() error = %v, want %q", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(got) != len(tt.items) {
+				t.Fatalf("generateSecretObjects() returned %d items, want %d", len(got), len(tt.items))
+			}
+			for i, item := range tt.items {
+				if got[i].GetKind() != "Secret" || got[i].GetName() != item.GetName() {
+					t.Errorf("generateSecretObjects()[%d] = %s %s, want Secret %s", i, got[i].GetKind(), got[i].GetName(), item.GetName())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Annotations of controllers that act on Secrets
+const (
+	// reloaderAnnotation makes stakater Reloader restart the workloads that
+	// use the Secret and are annotated with reloader.stakater.com/search
+	reloaderAnnotation = "reloader.stakater.com/match"
+	// replicateToAnnotation makes kubernetes-replicator copy the Secret to
+	// the listed namespaces
+	replicateToAnnotation = "replicator.v1.mittwald.de/replicate-to"
+)
+
+// gitOpsPresets are the annotations of GitOps tools that the names in the
+// annotationPresets field expand to
+var gitOpsPresets = map[string]kvMap{
+	"argocd-ignore-extraneous": {"argocd.argoproj.io/compare-options": "IgnoreExtraneous"},
+	"argocd-no-prune":          {"argocd.argoproj.io/sync-options": "Prune=false"},
+	"argocd-replace":           {"argocd.argoproj.io/sync-options": "Replace=true"},
+	"fluxcd-force":             {"kustomize.toolkit.fluxcd.io/force": "enabled"},
+	"fluxcd-no-prune":          {"kustomize.toolkit.fluxcd.io/prune": "disabled"},
+	"fluxcd-ignore":            {"kustomize.toolkit.fluxcd.io/reconcile": "disabled"},
+}
+
+// presetAnnotations returns the annotations that the convenience fields of a
+// generator expand to. Argo CD reads its options annotations as lists, so
+// presets that set the same annotation are joined with commas. An annotation
+// that is also set in the metadata must have the same value.
+func presetAnnotations(input SopsSecretGenerator) (kvMap, error) {
+	annotations := make(kvMap)
+	seen := make(map[string]bool)
+	for _, name := range input.AnnotationPresets {
+		preset, ok := gitOpsPresets[name]
+		if !ok {
+			return nil, errors.Errorf("annotationPresets: unknown preset \"%s\", expected one of %s", name, strings.Join(presetNames(), ", "))
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		for k, v := range preset {
+			if annotations[k] != "" {
+				v = annotations[k] + "," + v
+			}
+			annotations[k] = v
+		}
+	}
+	if input.Reloader {
+		annotations[reloaderAnnotation] = "true"
+	}
+	if len(input.ReplicateTo) > 0 {
+		for _, namespace := range input.ReplicateTo {
+			if namespace == "" || strings.ContainsAny(namespace, ", ") {
+				return nil, errors.Errorf("replicateTo: invalid namespace \"%s\"", namespace)
+			}
+		}
+		annotations[replicateToAnnotation] = strings.Join(input.ReplicateTo, ",")
+	}
+
+	keys := make([]string, 0, len(annotations))
+	for k := range annotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if v, ok := input.Annotations[k]; ok && v != annotations[k] {
+			return nil, errors.Errorf("annotation %s is \"%s\", but the generator fields set it to \"%s\"", k, v, annotations[k])
+		}
+	}
+	return annotations, nil
+}
+
+// presetNames returns the names of the annotation presets, sorted.
+func presetNames() []string {
+	names := make([]string, 0, len(gitOpsPresets))
+	for name := range gitOpsPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,69 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func Test_presetAnnotations(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   SopsSecretGenerator
+		want    kvMap
+		wantErr bool
+	}{
+		{"None", SopsSecretGenerator{}, kvMap{}, false},
+		{"Reloader", SopsSecretGenerator{Reloader: true}, kvMap{reloaderAnnotation: "true"}, false},
+		{"ReplicateTo", SopsSecretGenerator{ReplicateTo: []string{"apps", "team-.*"}},
+			kvMap{replicateToAnnotation: "apps,team-.*"}, false},
+		{"Both", SopsSecretGenerator{Reloader: true, ReplicateTo: []string{"apps"}},
+			kvMap{reloaderAnnotation: "true", replicateToAnnotation: "apps"}, false},
+		{"SameAnnotation", SopsSecretGenerator{ObjectMeta: ObjectMeta{Annotations: kvMap{reloaderAnnotation: "true"}}, Reloader: true},
+			kvMap{reloaderAnnotation: "true"}, false},
+		{"ConflictingAnnotation", SopsSecretGenerator{ObjectMeta: ObjectMeta{Annotations: kvMap{replicateToAnnotation: "other"}}, ReplicateTo: []string{"apps"}},
+			nil, true},
+		{"GitOpsPreset", SopsSecretGenerator{AnnotationPresets: []string{"fluxcd-force"}},
+			kvMap{"kustomize.toolkit.fluxcd.io/force": "enabled"}, false},
+		{"JoinedPresets", SopsSecretGenerator{AnnotationPresets: []string{"argocd-no-prune", "argocd-replace", "argocd-no-prune", "argocd-ignore-extraneous"}},
+			kvMap{"argocd.argoproj.io/sync-options": "Prune=false,Replace=true", "argocd.argoproj.io/compare-options": "IgnoreExtraneous"}, false},
+		{"UnknownPreset", SopsSecretGenerator{AnnotationPresets: []string{"argocd-compare-none"}}, nil, true},
+		{"ConflictingPreset", SopsSecretGenerator{ObjectMeta: ObjectMeta{Annotations: kvMap{"kustomize.toolkit.fluxcd.io/force": "disabled"}}, AnnotationPresets: []string{"fluxcd-force"}},
+			nil, true},
+		{"EmptyNamespace", SopsSecretGenerator{ReplicateTo: []string{""}}, nil, true},
+		{"ListInNamespace", SopsSecretGenerator{ReplicateTo: []string{"apps,web"}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := presetAnnotations(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("presetAnnotations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("presetAnnotations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_generateSecret_presets(t *testing.T) {
+	input, err := readInput([]byte("apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: secret\nreloader: true\nreplicateTo:\n  - apps\nfiles:\n  - testdata/file.txt\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := generateSecret(context.Background(), input, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Annotations[reloaderAnnotation] != "true" || secret.Annotations[replicateToAnnotation] != "apps" {
+		t.Errorf("generateSecret() annotations = %v", secret.Annotations)
+	}
+
+	_, err = readInput([]byte("apiVersion: kustomize.freightdog.com/v1\nkind: SopsSecretGenerator\nmetadata:\n  name: secret\nreplicateTo:\n  - \"\"\n"))
+	if err == nil {
+		t.Errorf("readInput() accepted an empty replicateTo namespace")
+	}
+}
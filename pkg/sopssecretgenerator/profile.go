@@ -0,0 +1,95 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// profiles writes pprof profiles of the invocation, to attach to reports of
+// slow renders. A nil *profiles writes nothing, which is the default.
+type profiles struct {
+	cpu     *os.File
+	memPath string
+	once    sync.Once
+}
+
+// invocationProfiles writes the profiles of the invocation, if enabled with
+// --cpuprofile and --memprofile or SOPS_SECRETGEN_CPU_PROFILE and
+// SOPS_SECRETGEN_MEM_PROFILE
+var invocationProfiles *profiles
+
+// startProfiles starts a CPU profile into cpuPath and prepares a heap profile
+// into memPath, either of which may be empty. It returns nil if both are.
+func startProfiles(cpuPath, memPath string) (*profiles, error) {
+	if cpuPath == "" && memPath == "" {
+		return nil, nil
+	}
+	p := &profiles{memPath: memPath}
+	if cpuPath != "" {
+		f, err := os.Create(cpuPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not create CPU profile")
+		}
+		err = pprof.StartCPUProfile(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, errors.Wrap(err, "could not start CPU profile")
+		}
+		p.cpu = f
+	}
+	return p, nil
+}
+
+// stop stops the CPU profile and writes the heap profile. Only the first call
+// has an effect, so it can be called on every way out of the command.
+func (p *profiles) stop() error {
+	if p == nil {
+		return nil
+	}
+	var err error
+	p.once.Do(func() {
+		if p.cpu != nil {
+			pprof.StopCPUProfile()
+			err = p.cpu.Close()
+		}
+		if p.memPath != "" {
+			memErr := writeHeapProfile(p.memPath)
+			if err == nil {
+				err = memErr
+			}
+		}
+	})
+	return err
+}
+
+// writeHeapProfile writes a profile of the live heap to a file.
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "could not create memory profile")
+	}
+	// Collect garbage, so that the profile shows the live heap
+	runtime.GC()
+	err = pprof.WriteHeapProfile(f)
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	return errors.Wrap(err, "could not write memory profile")
+}
+
+// stopProfiles stops the profiles of the invocation, and warns if they could
+// not be written.
+func stopProfiles() {
+	err := invocationProfiles.stop()
+	if err != nil {
+		runtimeSettings.logger().Warn("could not write profile", "error", err)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_startProfiles(t *testing.T) {
+	p, err := startProfiles("", "")
+	if err != nil || p != nil {
+		t.Fatalf("startProfiles() = %v, %v, want nil", p, err)
+	}
+	if err := p.stop(); err != nil {
+		t.Errorf("stop() of nil profiles error = %v", err)
+	}
+
+	dir := t.TempDir()
+	cpuPath, memPath := filepath.Join(dir, "cpu.pprof"), filepath.Join(dir, "mem.pprof")
+	p, err = startProfiles(cpuPath, memPath)
+	if err != nil {
+		t.Fatalf("startProfiles() error = %v", err)
+	}
+	_ = encodeBase64(make([]byte, 1<<20))
+	if err := p.stop(); err != nil {
+		t.Fatalf("stop() error = %v", err)
+	}
+	if err := p.stop(); err != nil {
+		t.Errorf("second stop() error = %v", err)
+	}
+	for _, path := range []string{cpuPath, memPath} {
+		info, err := os.Stat(path)
+		if err != nil || info.Size() == 0 {
+			t.Errorf("profile %s was not written: %v", filepath.Base(path), err)
+		}
+	}
+
+	_, err = startProfiles(filepath.Join(dir, "missing", "cpu.pprof"), "")
+	if err == nil {
+		t.Error("startProfiles() into a missing directory succeeded")
+	}
+}
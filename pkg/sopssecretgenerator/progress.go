@@ -0,0 +1,118 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// progressInterval is how often the progress line is redrawn while no file
+// completes, so that the elapsed time keeps counting
+const progressInterval = time.Second
+
+// progress shows how many source files have been decrypted on a single
+// terminal line, with the elapsed time and the last file. A nil *progress
+// shows nothing, which is the default.
+type progress struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+	total int
+	done  int
+	last  string
+	drawn bool
+	stop  chan struct{}
+}
+
+// invocationProgress shows the progress of the invocation, if stderr is a
+// terminal and it is not disabled with --no-progress or
+// SOPS_SECRETGEN_NO_PROGRESS
+var invocationProgress *progress
+
+// newProgress returns a progress line on a writer, which is redrawn every
+// interval until it is finished. An interval of zero only redraws the line
+// when a file completes.
+func newProgress(w io.Writer, interval time.Duration) *progress {
+	p := &progress{w: w, start: time.Now(), stop: make(chan struct{})}
+	if interval > 0 {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					p.mu.Lock()
+					if p.drawn {
+						p.draw()
+					}
+					p.mu.Unlock()
+				case <-p.stop:
+					return
+				}
+			}
+		}()
+	}
+	return p
+}
+
+// add announces files that are going to be decrypted.
+func (p *progress) add(files int) {
+	if p == nil || files == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total += files
+	p.draw()
+}
+
+// fileDone records that a file has been decrypted, or has failed.
+func (p *progress) fileDone(file string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	if p.done > p.total {
+		p.total = p.done
+	}
+	p.last = file
+	p.draw()
+}
+
+// draw writes the progress line over the previous one. The caller holds the
+// lock.
+func (p *progress) draw() {
+	elapsed := time.Since(p.start).Truncate(time.Second)
+	line := fmt.Sprintf("Decrypting: %d/%d files, %s", p.done, p.total, elapsed)
+	if p.last != "" {
+		line += ", " + p.last
+	}
+	_, _ = fmt.Fprintf(p.w, "\r\033[K%s", line)
+	p.drawn = true
+}
+
+// finish stops redrawing and clears the progress line, so that it does not
+// mix with the output that follows.
+func (p *progress) finish() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-p.stop:
+		return
+	default:
+		close(p.stop)
+	}
+	if p.drawn {
+		_, _ = fmt.Fprint(p.w, "\r\033[K")
+		p.drawn = false
+	}
+}
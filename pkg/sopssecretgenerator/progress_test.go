@@ -0,0 +1,35 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func Test_progress(t *testing.T) {
+	var out bytes.Buffer
+	p := newProgress(&out, 0)
+	p.add(2)
+	p.fileDone("a.env")
+	p.fileDone("b.env")
+	p.fileDone("c.env")
+	p.finish()
+	p.finish()
+
+	lines := strings.Split(out.String(), "\r\033[K")
+	want := []string{"", "Decrypting: 0/2 files, 0s", "Decrypting: 1/2 files, 0s, a.env",
+		"Decrypting: 2/2 files, 0s, b.env", "Decrypting: 3/3 files, 0s, c.env", ""}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("progress wrote %q, want %q", lines, want)
+	}
+}
+
+func Test_progress_nil(t *testing.T) {
+	var p *progress
+	p.add(1)
+	p.fileDone("a.env")
+	p.finish()
+}
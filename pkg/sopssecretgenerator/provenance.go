@@ -0,0 +1,134 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Annotations that provenance: true adds, which trace a Secret back to the
+// commit of its encrypted sources
+const (
+	gitCommitAnnotation = "kustomize.freightdog.com/git-commit"
+	gitDirtyAnnotation  = "kustomize.freightdog.com/git-dirty"
+)
+
+// gitProvenance is the state of the git repository that Secrets are
+// generated in
+type gitProvenance struct {
+	// Commit is the full SHA of HEAD
+	Commit string
+	// Dirty is whether the working tree has uncommitted changes, in which
+	// case the sources may differ from those of the commit
+	Dirty bool
+}
+
+// provenanceCache holds the git state of the directories that generators
+// are rendered from, so that git runs once per directory and invocation
+type provenanceCache struct {
+	mu      sync.Mutex
+	entries map[string]provenanceEntry
+}
+
+// provenanceEntry is the git state of a directory, or why it could not be
+// read
+type provenanceEntry struct {
+	provenance gitProvenance
+	err        error
+}
+
+// invocationProvenance is the git state read in this invocation
+var invocationProvenance = &provenanceCache{}
+
+// get returns the git state of a directory, reading it on first use. A read
+// that was cancelled is not kept.
+func (c *provenanceCache) get(ctx context.Context, dir string) (gitProvenance, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[dir]; ok {
+		return entry.provenance, entry.err
+	}
+	provenance, err := readGitProvenance(ctx, dir)
+	if ctx.Err() == nil {
+		if c.entries == nil {
+			c.entries = make(map[string]provenanceEntry)
+		}
+		c.entries[dir] = provenanceEntry{provenance: provenance, err: err}
+	}
+	return provenance, err
+}
+
+// reset forgets the git state read so far. Every invocation starts with it,
+// since commits may have been made since the last one of a process.
+func (c *provenanceCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
+
+// generatorProvenance returns the git state of the repository of a
+// generator: that of the directory of its first source file, or of the
+// working directory if it has none.
+func generatorProvenance(ctx context.Context, input SopsSecretGenerator) (gitProvenance, error) {
+	dir := ""
+	if files := inputFiles(applyBaseDir(input)); len(files) > 0 {
+		filePath, _, err := splitExtract(files[0])
+		if err == nil {
+			dir = filepath.Dir(filePath)
+		}
+	}
+	return invocationProvenance.get(ctx, dir)
+}
+
+// readGitProvenance returns the commit of HEAD of the repository in a
+// directory, the working directory if empty, and whether it has uncommitted
+// changes, including untracked files that are not ignored.
+func readGitProvenance(ctx context.Context, dir string) (gitProvenance, error) {
+	git := func(args ...string) (string, error) {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = dir
+		output, err := cmd.Output()
+		if err != nil {
+			return "", errors.Wrapf(err, "git %s", args[0])
+		}
+		return strings.TrimSpace(string(output)), nil
+	}
+	commit, err := git("rev-parse", "HEAD")
+	if err != nil {
+		return gitProvenance{}, errors.Wrap(err, "provenance: could not read the commit, the generator must be in a git repository with a commit")
+	}
+	status, err := git("status", "--porcelain")
+	if err != nil {
+		return gitProvenance{}, errors.Wrap(err, "provenance: could not read the state of the working tree")
+	}
+	return gitProvenance{Commit: commit, Dirty: status != ""}, nil
+}
+
+// annotations returns the provenance as annotations.
+func (p gitProvenance) annotations() kvMap {
+	return kvMap{
+		gitCommitAnnotation: p.Commit,
+		gitDirtyAnnotation:  strconv.FormatBool(p.Dirty),
+	}
+}
+
+// provenanceAnnotations returns the provenance annotations of a generator,
+// or none if it does not ask for them.
+func provenanceAnnotations(ctx context.Context, input SopsSecretGenerator) (kvMap, error) {
+	if !input.Provenance {
+		return nil, nil
+	}
+	provenance, err := generatorProvenance(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return provenance.annotations(), nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func Test_readGitProvenance(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, output)
+		}
+	}
+	git("init", "-q")
+
+	_, err := readGitProvenance(context.Background(), dir)
+	if err == nil {
+		t.Errorf("readGitProvenance() accepted a repository without commits")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "secrets.env"), []byte("ENC\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "secrets.env")
+	git("-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "test")
+	clean, err := readGitProvenance(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{40,64}$`).MatchString(clean.Commit) || clean.Dirty {
+		t.Errorf("readGitProvenance() = %+v, want a full commit hash and a clean tree", clean)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "secrets.env"), []byte("PLAIN\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	dirty, err := readGitProvenance(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dirty.Commit != clean.Commit || !dirty.Dirty {
+		t.Errorf("readGitProvenance() = %+v, want commit %s and a dirty tree", dirty, clean.Commit)
+	}
+}
+
+func Test_gitProvenance_annotations(t *testing.T) {
+	got := gitProvenance{Commit: "0123abcd", Dirty: true}.annotations()
+	want := kvMap{
+		"kustomize.freightdog.com/git-commit": "0123abcd",
+		"kustomize.freightdog.com/git-dirty":  "true",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("annotations() = %v, want %v", got, want)
+	}
+}
+
+func Test_provenanceAnnotations_disabled(t *testing.T) {
+	got, err := provenanceAnnotations(context.Background(), SopsSecretGenerator{})
+	if err != nil || got != nil {
+		t.Errorf("provenanceAnnotations() = %v, %v, want none", got, err)
+	}
+}
+
+func Test_generatorProvenance(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	commit := func(dir string, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "secrets.env"), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		for _, args := range [][]string{
+			{"init", "-q"},
+			{"add", "secrets.env"},
+			{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", content},
+		} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = dir
+			if output, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("git %v: %v: %s", args, err, output)
+			}
+		}
+	}
+	first, second := t.TempDir(), t.TempDir()
+	commit(first, "first")
+	commit(second, "second")
+	invocationProvenance.reset()
+	t.Cleanup(invocationProvenance.reset)
+
+	ctx := context.Background()
+	a, err := generatorProvenance(ctx, SopsSecretGenerator{EnvSources: []string{filepath.Join(first, "secrets.env")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := generatorProvenance(ctx, SopsSecretGenerator{BaseDir: second, EnvSources: []string{"secrets.env"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Commit == b.Commit {
+		t.Errorf("generatorProvenance() = %s for both repositories, want that of each", a.Commit)
+	}
+
+	commit(first, "again")
+	cached, _ := generatorProvenance(ctx, SopsSecretGenerator{EnvSources: []string{filepath.Join(first, "secrets.env")}})
+	if cached.Commit != a.Commit {
+		t.Errorf("generatorProvenance() = %s within an invocation, want %s", cached.Commit, a.Commit)
+	}
+	invocationProvenance.reset()
+	fresh, _ := generatorProvenance(ctx, SopsSecretGenerator{EnvSources: []string{filepath.Join(first, "secrets.env")}})
+	if fresh.Commit == a.Commit {
+		t.Errorf("generatorProvenance() = %s after a reset, want the new commit", fresh.Commit)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	invocationProvenance.reset()
+	_, err = generatorProvenance(cancelled, SopsSecretGenerator{EnvSources: []string{filepath.Join(first, "secrets.env")}})
+	if err == nil {
+		t.Error("generatorProvenance() with a cancelled context succeeded")
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// defaultRenderTTL is how long render keeps the decrypted files if --ttl is
+// not given
+const defaultRenderTTL = 15 * time.Minute
+
+// runRender implements the render subcommand.
+func runRender(args []string) error {
+	flags := newFlagSet("render")
+	out := flags.String("out", "", "`directory` to write the decrypted source files to, on a tmpfs")
+	force := flags.Bool("force", false, "write to a directory that is not on a tmpfs")
+	name := flags.String("name", "", "only render the generator with this `name`")
+	ttl := flags.Duration("ttl", defaultRenderTTL, "`duration` after which the files are removed, 0 to keep them until interrupted")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *out == "" || flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected --out and a generator manifest")
+	}
+
+	generators, err := readGenerators(flags.Arg(0))
+	if err != nil {
+		return errors.Wrapf(err, "could not read generator %s", flags.Arg(0))
+	}
+	if *name != "" {
+		g, err := selectGenerator(flags.Arg(0), *name)
+		if err != nil {
+			return err
+		}
+		generators = []generatorFile{g}
+	}
+	if len(generators) == 0 {
+		return errors.Errorf("%s contains no generators", flags.Arg(0))
+	}
+
+	created, err := prepareRenderDir(*out, *force)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		removeRendered(*out, created)
+		_, _ = fmt.Fprintf(os.Stderr, "render: removed the decrypted files from %s\n", *out)
+	}()
+	err = renderGenerators(os.Stdout, *out, generators, runtimeSettings)
+	if err != nil {
+		return err
+	}
+	if *ttl > 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "%s: decrypted secrets are in %s. They are removed in %s, or when interrupted. Do not copy them anywhere.\n", colorize(colorYellow, "WARNING"), *out, *ttl)
+	} else {
+		_, _ = fmt.Fprintf(os.Stderr, "%s: decrypted secrets are in %s. They are removed when interrupted. Do not copy them anywhere.\n", colorize(colorYellow, "WARNING"), *out)
+	}
+	waitRender(invocationContext, *ttl)
+	return nil
+}
+
+// prepareRenderDir checks that a directory can receive decrypted files: it
+// must be empty, so that removing the files removes nothing else, and on a
+// file system in memory, unless forced. It creates the directory if it does
+// not exist, and reports whether it did.
+func prepareRenderDir(dir string, force bool) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	if len(entries) > 0 {
+		return false, withCause(errFlags, errors.Errorf("%s is not empty", dir))
+	}
+	existing := dir
+	for {
+		if _, err := os.Stat(existing); err == nil || filepath.Dir(existing) == existing {
+			break
+		}
+		existing = filepath.Dir(existing)
+	}
+	inMemory, err := isMemoryFS(existing)
+	if err != nil {
+		return false, err
+	}
+	if !inMemory {
+		if !force {
+			return false, withCause(errFlags, errors.Errorf("%s is not on a tmpfs, so decrypted files would be written to disk; use a directory such as /dev/shm, or --force", dir))
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "%s: %s is not on a tmpfs, decrypted files are written to disk\n", colorize(colorYellow, "WARNING"), dir)
+	}
+	return existing != dir, os.MkdirAll(dir, 0o700)
+}
+
+// renderGenerators writes the decrypted source files of each generator to
+// a directory of its own, named after the generator, and lists their paths.
+func renderGenerators(w io.Writer, dir string, generators []generatorFile, options Options) error {
+	for _, g := range generators {
+		err := renderGenerator(w, filepath.Join(dir, renderPath(g.Generator.Name)), g, options)
+		if err != nil {
+			return errors.Wrap(err, g.Generator.Name)
+		}
+	}
+	return nil
+}
+
+// renderGenerator writes the decrypted source files of the active variant of
+// a generator to a directory, with their paths relative to the manifest.
+func renderGenerator(w io.Writer, dir string, g generatorFile, options Options) error {
+	generator, err := applyVariant(g.Generator, activeVariant(g.Generator, options))
+	if err != nil {
+		return err
+	}
+	g.Generator = applyBaseDir(generator)
+	opts, err := newDecryptOptions(g.Generator, options)
+	if err != nil {
+		return err
+	}
+	opts.Context = invocationContext
+	files, err := g.sourceFiles()
+	if err != nil {
+		return err
+	}
+	opts.Prefetched = prefetchFiles(files, opts)
+	defer opts.Prefetched.wipe()
+
+	for _, file := range files {
+		decrypted, err := decryptFile(file, opts)
+		if err != nil {
+			return errors.Wrap(err, file)
+		}
+		target := filepath.Join(dir, renderPath(g.relative(file)))
+		err = os.MkdirAll(filepath.Dir(target), 0o700)
+		if err == nil {
+			err = os.WriteFile(target, decrypted, 0o600)
+		}
+		wipe(decrypted)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(w, target)
+	}
+	return nil
+}
+
+// relative returns the path of a source file relative to the directory of
+// the manifest, if it can be.
+func (g generatorFile) relative(filePath string) string {
+	rel, err := filepath.Rel(filepath.Dir(g.Path), filePath)
+	if err != nil {
+		return filepath.Base(filePath)
+	}
+	return rel
+}
+
+// renderPath makes a path safe to join to the render directory, by
+// replacing its ".." elements with "_" and dropping any leading separator.
+func renderPath(p string) string {
+	elements := strings.Split(filepath.ToSlash(p), "/")
+	var kept []string
+	for _, element := range elements {
+		switch element {
+		case "", ".":
+		case "..":
+			kept = append(kept, "_")
+		default:
+			kept = append(kept, element)
+		}
+	}
+	return filepath.Join(kept...)
+}
+
+// waitRender waits until the context is canceled, or the time to live has
+// passed if it is not 0.
+func waitRender(ctx context.Context, ttl time.Duration) {
+	if ttl <= 0 {
+		<-ctx.Done()
+		return
+	}
+	timer := time.NewTimer(ttl)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// removeRendered overwrites and removes the decrypted files in a render
+// directory, and the directory itself if render created it.
+func removeRendered(dir string, created bool) {
+	_ = filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			removeTempFile(p)
+		}
+		return nil
+	})
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		_ = os.RemoveAll(filepath.Join(dir, entry.Name()))
+	}
+	if created {
+		_ = os.Remove(dir)
+	}
+}
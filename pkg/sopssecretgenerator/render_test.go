@@ -0,0 +1,109 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_renderGenerators(t *testing.T) {
+	g := generatorFile{
+		Path: "testdata/generator.yaml",
+		Generator: SopsSecretGenerator{
+			TypeMeta:   TypeMeta{APIVersion: apiVersion, Kind: kind},
+			ObjectMeta: ObjectMeta{Name: "secret"},
+			EnvSources: []string{"vars.env"}, FileSources: []string{"key=file.txt"},
+		},
+	}
+	dir := filepath.Join(t.TempDir(), "out")
+	var out bytes.Buffer
+	err := renderGenerators(&out, dir, []generatorFile{g}, Options{})
+	if err != nil {
+		t.Fatalf("renderGenerators() error = %v", err)
+	}
+	want := filepath.Join(dir, "secret", "vars.env") + "\n" + filepath.Join(dir, "secret", "file.txt") + "\n"
+	if out.String() != want {
+		t.Errorf("renderGenerators() listed %q, want %q", out.String(), want)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "secret", "file.txt"))
+	if err != nil || string(content) != "secret\n" {
+		t.Errorf("renderGenerators() wrote %q, %v, want %q", content, err, "secret\n")
+	}
+	info, err := os.Stat(filepath.Join(dir, "secret", "vars.env"))
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("renderGenerators() wrote %v, %v, want mode 0600", info, err)
+	}
+
+	removeRendered(dir, true)
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("removeRendered() left %s, error = %v", dir, err)
+	}
+}
+
+func Test_prepareRenderDir(t *testing.T) {
+	parent := t.TempDir()
+	inMemory, err := isMemoryFS(parent)
+	if err != nil {
+		t.Fatalf("isMemoryFS() error = %v", err)
+	}
+	if !inMemory {
+		if _, err := prepareRenderDir(filepath.Join(parent, "out"), false); err == nil {
+			t.Error("prepareRenderDir() of a directory on disk succeeded")
+		}
+	}
+
+	created, err := prepareRenderDir(filepath.Join(parent, "out", "nested"), true)
+	if err != nil || !created {
+		t.Errorf("prepareRenderDir() = %v, %v, want a created directory", created, err)
+	}
+	created, err = prepareRenderDir(filepath.Join(parent, "out", "nested"), true)
+	if err != nil || created {
+		t.Errorf("prepareRenderDir() of an empty directory = %v, %v, want it reused", created, err)
+	}
+	writeTestFile(t, filepath.Join(parent, "out", "nested", "file"), "content")
+	if _, err := prepareRenderDir(filepath.Join(parent, "out", "nested"), true); err == nil {
+		t.Error("prepareRenderDir() of a directory that is not empty succeeded")
+	}
+
+	removeRendered(filepath.Join(parent, "out", "nested"), false)
+	entries, err := os.ReadDir(filepath.Join(parent, "out", "nested"))
+	if err != nil || len(entries) != 0 {
+		t.Errorf("removeRendered() left %v, error = %v, want an empty directory", entries, err)
+	}
+}
+
+func Test_renderPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"file.txt", "file.txt"},
+		{"dir/file.txt", filepath.Join("dir", "file.txt")},
+		{"../shared/file.txt", filepath.Join("_", "shared", "file.txt")},
+		{"/etc/passwd", filepath.Join("etc", "passwd")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := renderPath(tt.path); got != tt.want {
+				t.Errorf("renderPath() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_waitRender(t *testing.T) {
+	start := time.Now()
+	waitRender(context.Background(), 10*time.Millisecond)
+	if time.Since(start) < 10*time.Millisecond {
+		t.Error("waitRender() returned before the time to live")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	waitRender(ctx, 0)
+}
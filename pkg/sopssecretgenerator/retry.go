@@ -0,0 +1,142 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/keyservice"
+	"github.com/pkg/errors"
+)
+
+// defaultRetryBackoff is the wait before the first retry if no backoff is set
+const defaultRetryBackoff = 500 * time.Millisecond
+
+// errTransient marks errors of key services and secret stores that may not
+// recur, such as throttling and server errors
+var errTransient = errors.New("transient error")
+
+// transientMessages are parts of the messages of transient errors. sops
+// reports the errors of key services as text, so their types are lost.
+var transientMessages = []string{
+	"ThrottlingException",
+	"TooManyRequestsException",
+	"RequestLimitExceeded",
+	"KMSInternalException",
+	"ServiceUnavailable",
+	"InternalFailure",
+	"code = Unavailable",
+	"code = ResourceExhausted",
+	"429 Too Many Requests",
+	"500 Internal Server Error",
+	"502 Bad Gateway",
+	"503 Service Unavailable",
+	"504 Gateway Timeout",
+	"connection reset by peer",
+}
+
+// retryPolicy is how often transient errors are retried, and how long to
+// wait before the first retry. The wait doubles with every retry.
+type retryPolicy struct {
+	retries int
+	backoff time.Duration
+}
+
+// isTransientError reports whether an error is worth retrying: a throttling
+// or server error of a key service or secret store.
+func isTransientError(err error) bool {
+	if errors.Is(err, errTransient) {
+		return true
+	}
+	var status interface{ HTTPStatusCode() int }
+	if errors.As(err, &status) && isTransientStatus(status.HTTPStatusCode()) {
+		return true
+	}
+	message := err.Error()
+	for _, part := range transientMessages {
+		if strings.Contains(message, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTransientStatus reports whether an HTTP status is a throttling or server
+// error.
+func isTransientStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// do runs f, and runs it again while it fails with a transient error, up to
+// the number of retries. The waits between runs have up to a quarter of
+// random jitter, so that concurrent decryptions do not retry in lockstep.
+// Waiting stops when the context is canceled.
+func (p retryPolicy) do(ctx context.Context, f func() error) error {
+	backoff := p.backoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	for attempt := 0; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= p.retries || !isTransientError(err) {
+			return err
+		}
+		wait := backoff<<attempt + rand.N(backoff<<attempt/4+1)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// hasNetworkKeys reports whether a file is encrypted to any keys of network
+// key services.
+func hasNetworkKeys(metadata sops.Metadata) bool {
+	for _, group := range metadata.KeyGroups {
+		for _, key := range group {
+			if !localKeyTypes[key.TypeToIdentifier()] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// retryServer is a local key service that retries data key decryptions by
+// network key services, such as AWS KMS or Vault, that fail with a transient
+// error. Other keys are passed on unchanged.
+type retryServer struct {
+	next   keyservice.KeyServiceServer
+	policy retryPolicy
+	ctx    context.Context
+}
+
+// Encrypt encrypts a data key.
+func (s retryServer) Encrypt(ctx context.Context, req *keyservice.EncryptRequest) (*keyservice.EncryptResponse, error) {
+	return s.next.Encrypt(ctx, req)
+}
+
+// Decrypt decrypts a data key.
+func (s retryServer) Decrypt(ctx context.Context, req *keyservice.DecryptRequest) (*keyservice.DecryptResponse, error) {
+	switch req.Key.KeyType.(type) {
+	case *keyservice.Key_KmsKey, *keyservice.Key_GcpKmsKey, *keyservice.Key_AzureKeyvaultKey, *keyservice.Key_VaultKey:
+	default:
+		return s.next.Decrypt(ctx, req)
+	}
+	var response *keyservice.DecryptResponse
+	err := s.policy.do(s.ctx, func() error {
+		var err error
+		response, err = s.next.Decrypt(ctx, req)
+		return err
+	})
+	return response, err
+}
@@ -0,0 +1,146 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/getsops/sops/v3/keyservice"
+	"github.com/pkg/errors"
+)
+
+func Test_isTransientError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"Throttling", errors.New("failed to decrypt sops data key with AWS KMS: operation error KMS: Decrypt, api error ThrottlingException: Rate exceeded"), true},
+		{"GRPCUnavailable", errors.New("rpc error: code = Unavailable desc = connection refused"), true},
+		{"Marked", withCause(errTransient, errors.New("vault returned 503 Service Unavailable")), true},
+		{"Status", statusError{code: 502}, true},
+		{"Denied", errors.New("api error AccessDeniedException: not authorized"), false},
+		{"ClientStatus", statusError{code: 404}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransientError(tt.err); got != tt.want {
+				t.Errorf("isTransientError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_retryPolicy_do(t *testing.T) {
+	transient := withCause(errTransient, errors.New("throttled"))
+	tests := []struct {
+		name         string
+		retries      int
+		errs         []error
+		wantAttempts int
+		wantErr      bool
+	}{
+		{"Success", 2, nil, 1, false},
+		{"Recovers", 2, []error{transient, transient}, 3, false},
+		{"GivesUp", 1, []error{transient, transient}, 2, true},
+		{"NoRetries", 0, []error{transient}, 1, true},
+		{"NotTransient", 2, []error{errors.New("denied")}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := retryPolicy{retries: tt.retries, backoff: time.Millisecond}.do(context.Background(), func() error {
+				attempts++
+				if attempts <= len(tt.errs) {
+					return tt.errs[attempts-1]
+				}
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("do() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("do() ran %d times, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func Test_retryPolicy_do_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	attempts := 0
+	start := time.Now()
+	err := retryPolicy{retries: 3, backoff: time.Minute}.do(ctx, func() error {
+		attempts++
+		return withCause(errTransient, errors.New("throttled"))
+	})
+	if err == nil || attempts != 1 || time.Since(start) > time.Second {
+		t.Errorf("do() with a canceled context = %v after %d attempts", err, attempts)
+	}
+}
+
+func Test_retryServer_Decrypt(t *testing.T) {
+	kmsRequest := &keyservice.DecryptRequest{Key: &keyservice.Key{KeyType: &keyservice.Key_KmsKey{KmsKey: &keyservice.KmsKey{Arn: testKMSArnWest}}}}
+	ageRequest := &keyservice.DecryptRequest{Key: &keyservice.Key{KeyType: &keyservice.Key_AgeKey{AgeKey: &keyservice.AgeKey{Recipient: testAgeRecipient}}}}
+	tests := []struct {
+		name         string
+		request      *keyservice.DecryptRequest
+		wantAttempts int
+		wantErr      bool
+	}{
+		{"KMS", kmsRequest, 3, false},
+		{"Age", ageRequest, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &flakyKeyServer{failures: 2}
+			server := retryServer{next: next, policy: retryPolicy{retries: 2, backoff: time.Millisecond}, ctx: context.Background()}
+			got, err := server.Decrypt(context.Background(), tt.request)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decrypt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if next.attempts != tt.wantAttempts {
+				t.Errorf("Decrypt() made %d attempts, want %d", next.attempts, tt.wantAttempts)
+			}
+			if !tt.wantErr && string(got.Plaintext) != "data key" {
+				t.Errorf("Decrypt() = %q, want %q", got.Plaintext, "data key")
+			}
+		})
+	}
+}
+
+// Test util functions
+
+type statusError struct {
+	code int
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("status %d", e.code)
+}
+
+func (e statusError) HTTPStatusCode() int {
+	return e.code
+}
+
+type flakyKeyServer struct {
+	failures int
+	attempts int
+}
+
+func (s *flakyKeyServer) Encrypt(context.Context, *keyservice.EncryptRequest) (*keyservice.EncryptResponse, error) {
+	return &keyservice.EncryptResponse{}, nil
+}
+
+func (s *flakyKeyServer) Decrypt(context.Context, *keyservice.DecryptRequest) (*keyservice.DecryptResponse, error) {
+	s.attempts++
+	if s.attempts <= s.failures {
+		return nil, errors.New("api error ThrottlingException: Rate exceeded")
+	}
+	return &keyservice.DecryptResponse{Plaintext: []byte("data key")}, nil
+}
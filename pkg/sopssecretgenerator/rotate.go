@@ -0,0 +1,178 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/aes"
+	"github.com/getsops/sops/v3/cmd/sops/common"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/getsops/sops/v3/config"
+	"github.com/getsops/sops/v3/keyservice"
+	"github.com/pkg/errors"
+)
+
+// runRotate implements the rotate subcommand.
+func runRotate(args []string) error {
+	flags := newFlagSet("rotate")
+	updateKeys := flags.Bool("update-keys", false, "also apply the key groups of the matching .sops.yaml creation rule")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	root := "."
+	if flags.NArg() > 0 {
+		root = flags.Arg(0)
+	}
+
+	files, err := referencedFiles(root)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, file := range files {
+		err := rotateFile(file, *updateKeys)
+		if err != nil {
+			failed++
+			_, _ = fmt.Fprintf(os.Stderr, "%s  %s: %v\n", colorize(colorRed, "FAILED"), file, err)
+			continue
+		}
+		fmt.Printf("rotated %s\n", file)
+	}
+	if failed > 0 {
+		return errors.Errorf("%d of %d files could not be rotated", failed, len(files))
+	}
+	return nil
+}
+
+// loadEncryptedTree reads a sops-encrypted file into a tree, along with the
+// store that can write it back.
+func loadEncryptedTree(fileName string) (*sops.Tree, common.Store, error) {
+	store := common.StoreForFormat(formats.FormatForPath(fileName), config.NewStoresConfig())
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not read file")
+	}
+	tree, err := store.LoadEncryptedFile(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	tree.FilePath, _ = filepath.Abs(fileName)
+	return &tree, store, nil
+}
+
+// decryptTree decrypts a tree in place with the local keys and returns the
+// data key. The attempt is recorded in the audit log under the command.
+func decryptTree(tree *sops.Tree, command string) ([]byte, error) {
+	keyServices, err := localKeyServices()
+	if err != nil {
+		return nil, err
+	}
+	passGPGTTY(tree.Metadata)
+	dataKey, err := common.DecryptTree(common.DecryptTreeOpts{
+		Tree:        tree,
+		KeyServices: keyServices,
+		Cipher:      aes.NewCipher(),
+	})
+	err = gpgAgentError(err)
+	auditErr := auditCommand(command, tree.FilePath, tree.Metadata, err)
+	if err != nil {
+		return nil, err
+	}
+	if auditErr != nil {
+		wipe(dataKey)
+		return nil, auditErr
+	}
+	return dataKey, nil
+}
+
+// rotateFile re-encrypts a file with a new data key, like `sops rotate`. If
+// updateKeys is set, the file is encrypted to the key groups of its creation
+// rule, like `sops updatekeys`.
+func rotateFile(fileName string, updateKeys bool) error {
+	tree, store, err := loadEncryptedTree(fileName)
+	if err != nil {
+		return err
+	}
+	oldDataKey, err := decryptTree(tree, "rotate")
+	if err != nil {
+		return err
+	}
+	defer wipe(oldDataKey)
+
+	if updateKeys {
+		rule, _, err := loadCreationRule(fileName, "")
+		if err != nil {
+			return err
+		}
+		tree.Metadata.KeyGroups = rule.KeyGroups
+		tree.Metadata.ShamirThreshold = rule.ShamirThreshold
+	}
+
+	err = encryptTree(tree)
+	if err != nil {
+		return err
+	}
+	return writeEncryptedTree(fileName, tree, store)
+}
+
+// encryptTree encrypts a tree in place with a new data key for its key groups.
+func encryptTree(tree *sops.Tree) error {
+	dataKey, errs := tree.GenerateDataKeyWithKeyServices([]keyservice.KeyServiceClient{keyservice.NewLocalClient()})
+	if len(errs) > 0 {
+		return errors.Errorf("could not generate data key: %v", errs)
+	}
+	defer wipe(dataKey)
+	return common.EncryptTree(common.EncryptTreeOpts{Tree: tree, Cipher: aes.NewCipher(), DataKey: dataKey})
+}
+
+// writeEncryptedTree writes the encrypted tree to a file.
+func writeEncryptedTree(fileName string, tree *sops.Tree, store common.Store) error {
+	encrypted, err := store.EmitEncryptedFile(*tree)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(fileName, encrypted)
+}
+
+// writeFileAtomic replaces a file through a temporary file in the same
+// directory, keeping its permissions, so that a failure never leaves a
+// half-written file behind.
+func writeFileAtomic(fileName string, content []byte) error {
+	perm := os.FileMode(0o600)
+	info, err := os.Stat(fileName)
+	if err == nil {
+		perm = info.Mode().Perm()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	return writeFileAtomicPerm(fileName, content, perm)
+}
+
+// writeFileAtomicPerm replaces a file through a temporary file in the same
+// directory, with the given permissions. The temporary file is only readable
+// by the current user until then, and the file is replaced, not written
+// through, if it is a symbolic link.
+func writeFileAtomicPerm(fileName string, content []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), "."+filepath.Base(fileName)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	_, err = tmp.Write(content)
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fileName)
+}
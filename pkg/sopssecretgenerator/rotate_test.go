@@ -0,0 +1,89 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_rotateFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		updateKeys bool
+		sopsConfig string
+		want       string
+		wantErr    bool
+	}{
+		{"Binary", "testdata/file.txt", false, "", "secret\n", false},
+		{"YAML", "testdata/vars.yaml", false, "", "val_yaml", false},
+		{"UpdateKeys", "testdata/file.txt", true, "creation_rules:\n  - pgp: " + testkeyFingerprint + "\n", "secret\n", false},
+		{"UpdateKeysNoRules", "testdata/file.txt", true, "creation_rules: []\n", "", true},
+		{"NotEncrypted", "testdata/notyaml.txt", false, "", "", true},
+	}
+	setupEncryptionKeyring(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.sopsConfig != "" {
+				writeTestFile(t, filepath.Join(dir, ".sops.yaml"), tt.sopsConfig)
+			}
+			fileName := filepath.Join(dir, filepath.Base(tt.source))
+			original := copyTestFile(t, tt.source, fileName)
+
+			err := rotateFile(fileName, tt.updateKeys)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("rotateFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			rotated, readErr := os.ReadFile(fileName)
+			if readErr != nil {
+				t.Fatal(readErr)
+			}
+			if tt.wantErr {
+				if !bytes.Equal(rotated, original) {
+					t.Errorf("rotateFile() modified file on error")
+				}
+				return
+			}
+			if bytes.Equal(rotated, original) {
+				t.Errorf("rotateFile() did not change the file")
+			}
+			decrypted, err := decryptFile(fileName, decryptOptions{})
+			if err != nil {
+				t.Fatalf("decryptFile() error = %v", err)
+			}
+			if !strings.Contains(string(decrypted), tt.want) {
+				t.Errorf("decryptFile() = %q, want it to contain %q", decrypted, tt.want)
+			}
+		})
+	}
+}
+
+// Test util functions
+
+func copyTestFile(t *testing.T, src, dst string) []byte {
+	t.Helper()
+	content, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(dst, content, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+// setupEncryptionKeyring makes the test key available for encryption, which
+// needs the public key that the test keyring only has as part of the secret key.
+func setupEncryptionKeyring(t *testing.T) {
+	t.Helper()
+	gnupgHome := t.TempDir()
+	copyTestFile(t, "testdata/secring.gpg", filepath.Join(gnupgHome, "pubring.gpg"))
+	t.Setenv("GNUPGHOME", gnupgHome)
+}
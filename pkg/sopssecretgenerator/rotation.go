@@ -0,0 +1,176 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/getsops/sops/v3/cmd/sops/common"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/getsops/sops/v3/config"
+	"github.com/pkg/errors"
+)
+
+// lastModifiedAnnotation records when the oldest source of a Secret was last
+// encrypted, if its generator has a rotateAfter
+const lastModifiedAnnotation = "kustomize.freightdog.com/sops-lastmodified"
+
+// sourceAge is when a source file was last encrypted, by its sops metadata
+type sourceAge struct {
+	File         string
+	LastModified time.Time
+}
+
+// staleWarnings collects the warnings about sources that are due for
+// rotation, which the function reports as warning results
+var staleWarnings warningResults
+
+// warningResults are warnings for the results of a ResourceList, which
+// generators add concurrently
+type warningResults struct {
+	mu      sync.Mutex
+	results fn.Results
+}
+
+// add adds a warning.
+func (w *warningResults) add(message string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.results = append(w.results, fn.GeneralResult(message, fn.Warning))
+}
+
+// take returns the warnings and removes them.
+func (w *warningResults) take() fn.Results {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	results := w.results
+	w.results = nil
+	return results
+}
+
+// parseRotateAfter parses a rotation window, in days such as 90d, in weeks
+// such as 12w, or as a Go duration.
+func parseRotateAfter(s string) (time.Duration, error) {
+	invalid := errors.Errorf("invalid rotateAfter \"%s\", expected days such as 90d, weeks such as 12w, or a duration", s)
+	var window time.Duration
+	if n, ok := strings.CutSuffix(s, "d"); ok {
+		days, err := strconv.Atoi(n)
+		if err != nil {
+			return 0, invalid
+		}
+		window = time.Duration(days) * 24 * time.Hour
+	} else if n, ok := strings.CutSuffix(s, "w"); ok {
+		weeks, err := strconv.Atoi(n)
+		if err != nil {
+			return 0, invalid
+		}
+		window = time.Duration(weeks) * 7 * 24 * time.Hour
+	} else {
+		var err error
+		window, err = time.ParseDuration(s)
+		if err != nil {
+			return 0, invalid
+		}
+	}
+	if window <= 0 {
+		return 0, errors.Errorf("invalid rotateAfter \"%s\", it must be positive", s)
+	}
+	return window, nil
+}
+
+// validateRotateAfter checks the rotation window of a generator.
+func validateRotateAfter(input SopsSecretGenerator) error {
+	if input.RotateAfter == "" {
+		if input.RotateStrict {
+			return errors.New("rotateStrict requires rotateAfter")
+		}
+		return nil
+	}
+	_, err := parseRotateAfter(input.RotateAfter)
+	return err
+}
+
+// oldestSource returns the source file of a generator that was encrypted
+// longest ago, by the lastmodified date of its sops metadata, which sops
+// updates whenever the file is edited. Files that cannot be read as
+// encrypted files are skipped, decrypting them reports why. It returns a
+// zero sourceAge if no file has a date.
+func oldestSource(input SopsSecretGenerator) sourceAge {
+	var oldest sourceAge
+	seen := make(map[string]bool)
+	for _, source := range inputFiles(input) {
+		filePath, _, err := splitExtract(source)
+		if err != nil || seen[filePath] {
+			continue
+		}
+		seen[filePath] = true
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			continue
+		}
+		store := common.StoreForFormat(formats.FormatForPath(filePath), config.NewStoresConfig())
+		tree, err := store.LoadEncryptedFile(content)
+		if err != nil || tree.Metadata.LastModified.IsZero() {
+			continue
+		}
+		if oldest.File == "" || tree.Metadata.LastModified.Before(oldest.LastModified) {
+			oldest = sourceAge{File: filePath, LastModified: tree.Metadata.LastModified}
+		}
+	}
+	return oldest
+}
+
+// checkRotation checks the sources of a generator with a rotateAfter against
+// its rotation window. A source that is older than the window is a warning,
+// which is logged and reported as a warning result, or an error if the
+// generator has rotateStrict. It returns the annotation with the date of the
+// oldest source.
+func checkRotation(input SopsSecretGenerator, now time.Time, logger *slog.Logger) (kvMap, error) {
+	if input.RotateAfter == "" {
+		return nil, nil
+	}
+	window, err := parseRotateAfter(input.RotateAfter)
+	if err != nil {
+		return nil, withCause(ErrInvalidGenerator, err)
+	}
+	oldest := oldestSource(input)
+	if oldest.File == "" {
+		return nil, nil
+	}
+	annotations := kvMap{lastModifiedAnnotation: oldest.LastModified.UTC().Format(time.RFC3339)}
+	age := now.Sub(oldest.LastModified)
+	if age <= window {
+		return annotations, nil
+	}
+	message := fmt.Sprintf("%s was last encrypted on %s, %d days ago, rotate its secrets, the generator has rotateAfter %s",
+		oldest.File, oldest.LastModified.UTC().Format(time.DateOnly), int(age.Hours()/24), input.RotateAfter)
+	if input.RotateStrict {
+		return nil, errors.New(message)
+	}
+	logger.Warn("source is due for rotation", "generator", input.Name, "file", oldest.File,
+		"lastModified", oldest.LastModified.UTC().Format(time.RFC3339), "rotateAfter", input.RotateAfter)
+	staleWarnings.add(fmt.Sprintf("SopsSecretGenerator %s: %s", input.Name, message))
+	return annotations, nil
+}
+
+// checkReusedRotation checks the rotation window of a generator whose Secret
+// is reused from the state file, with the sources of its active variant.
+func checkReusedRotation(input SopsSecretGenerator) error {
+	if input.RotateAfter == "" {
+		return nil
+	}
+	input, err := applyVariant(input, activeVariant(input, runtimeSettings))
+	if err != nil {
+		return err
+	}
+	_, err = checkRotation(input, time.Now(), runtimeSettings.logger())
+	return err
+}
@@ -0,0 +1,92 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_parseRotateAfter(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"90d", 90 * 24 * time.Hour, false},
+		{"12w", 12 * 7 * 24 * time.Hour, false},
+		{"720h", 720 * time.Hour, false},
+		{"0d", 0, true},
+		{"-1d", 0, true},
+		{"d", 0, true},
+		{"3 months", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseRotateAfter(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRotateAfter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseRotateAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_oldestSource(t *testing.T) {
+	input := SopsSecretGenerator{
+		EnvSources:  []string{"testdata/vars.env", "testdata/missing.env"},
+		FileSources: []string{"testdata/file.yaml", "other=testdata/file.yaml"},
+	}
+	got := oldestSource(input)
+	want := sourceAge{File: "testdata/vars.env", LastModified: time.Date(2019, 9, 12, 23, 26, 34, 0, time.UTC)}
+	if got.File != want.File || !got.LastModified.Equal(want.LastModified) {
+		t.Errorf("oldestSource() = %+v, want %+v", got, want)
+	}
+}
+
+func Test_checkRotation(t *testing.T) {
+	lastModified := time.Date(2020, 5, 19, 10, 12, 31, 0, time.UTC)
+	annotations := kvMap{"kustomize.freightdog.com/sops-lastmodified": "2020-05-19T10:12:31Z"}
+	tests := []struct {
+		name      string
+		input     SopsSecretGenerator
+		now       time.Time
+		want      kvMap
+		wantErr   string
+		wantStale bool
+	}{
+		{"Disabled", SopsSecretGenerator{FileSources: []string{"testdata/file.yaml"}}, lastModified.AddDate(1, 0, 0), nil, "", false},
+		{"Fresh", SopsSecretGenerator{RotateAfter: "90d", FileSources: []string{"testdata/file.yaml"}}, lastModified.AddDate(0, 0, 30), annotations, "", false},
+		{"Stale", SopsSecretGenerator{RotateAfter: "90d", FileSources: []string{"testdata/file.yaml"}}, lastModified.AddDate(0, 0, 100), annotations, "", true},
+		{"StaleStrict", SopsSecretGenerator{RotateAfter: "90d", RotateStrict: true, FileSources: []string{"testdata/file.yaml"}},
+			lastModified.AddDate(0, 0, 100), nil, "last encrypted on 2020-05-19, 100 days ago", false},
+		{"NoSources", SopsSecretGenerator{RotateAfter: "90d"}, lastModified, nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			staleWarnings.take()
+			got, err := checkRotation(tt.input, tt.now, discardLogger)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("checkRotation() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkRotation() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkRotation() = %v, want %v", got, tt.want)
+			}
+			if warnings := staleWarnings.take(); (len(warnings) > 0) != tt.wantStale {
+				t.Errorf("checkRotation() warned %v, want a warning %v", warnings, tt.wantStale)
+			}
+		})
+	}
+}
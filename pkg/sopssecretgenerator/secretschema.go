@@ -0,0 +1,164 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Limits that the API server enforces on Secrets
+const (
+	maxSecretSize      = 1024 * 1024
+	maxAnnotationsSize = 256 * 1024
+	maxNameLength      = 253
+	maxLabelLength     = 63
+)
+
+// Patterns of the names that the API server accepts
+var (
+	dnsLabelPattern      = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	qualifiedNamePattern = regexp.MustCompile(`^([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]$`)
+	labelValuePattern    = regexp.MustCompile(`^(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?$`)
+	secretKeyPattern     = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+)
+
+// secretTypeKeys are the keys that Secrets of the built-in types must have.
+// Basic auth Secrets need either of their keys.
+var secretTypeKeys = map[string][]string{
+	"kubernetes.io/tls":              {"tls.crt", "tls.key"},
+	"kubernetes.io/ssh-auth":         {"ssh-privatekey"},
+	"kubernetes.io/dockercfg":        {".dockercfg"},
+	"kubernetes.io/dockerconfigjson": {".dockerconfigjson"},
+}
+
+// validateSecret checks a generated Secret like the API server validates
+// v1 Secrets: its name, namespace, labels, annotations and keys, the keys
+// that its type requires, and its size. Values are only checked if asked
+// for, since those of dry runs are redacted. All problems are reported in a
+// single error.
+func validateSecret(secret Secret, checkValues bool) error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if secret.Name == "" {
+		add("metadata.name: required")
+	} else if len(secret.Name) > maxNameLength || !secretNamePattern.MatchString(secret.Name) {
+		add("metadata.name: \"%s\" is not a lowercase DNS subdomain of at most %d characters", secret.Name, maxNameLength)
+	}
+	if secret.Namespace != "" && (len(secret.Namespace) > maxLabelLength || !dnsLabelPattern.MatchString(secret.Namespace)) {
+		add("metadata.namespace: \"%s\" is not a lowercase DNS label of at most %d characters", secret.Namespace, maxLabelLength)
+	}
+	for _, key := range slices.Sorted(maps.Keys(secret.Labels)) {
+		if !isQualifiedName(key) {
+			add("metadata.labels: key \"%s\" is not a qualified name", key)
+		}
+		if value := secret.Labels[key]; len(value) > maxLabelLength || !labelValuePattern.MatchString(value) {
+			add("metadata.labels: value \"%s\" of %s is not a valid label value", value, key)
+		}
+	}
+	annotationsSize := 0
+	for _, key := range slices.Sorted(maps.Keys(secret.Annotations)) {
+		if !isQualifiedName(strings.ToLower(key)) {
+			add("metadata.annotations: key \"%s\" is not a qualified name", key)
+		}
+		annotationsSize += len(key) + len(secret.Annotations[key])
+	}
+	if annotationsSize > maxAnnotationsSize {
+		add("metadata.annotations: %d bytes, at most %d are allowed", annotationsSize, maxAnnotationsSize)
+	}
+
+	values := make(map[string][]byte, len(secret.Data)+len(secret.StringData))
+	for field, data := range map[string]kvMap{"data": secret.Data, "stringData": secret.StringData} {
+		for _, key := range slices.Sorted(maps.Keys(data)) {
+			if !isSecretKey(key) {
+				add("%s: key \"%s\" must consist of alphanumeric characters, '-', '_' or '.'", field, key)
+			}
+			if !checkValues {
+				values[key] = nil
+				continue
+			}
+			value := []byte(data[key])
+			if field == "data" {
+				decoded, err := base64.StdEncoding.DecodeString(data[key])
+				if err != nil {
+					add("data: value of %s is not base64-encoded", key)
+				}
+				value = decoded
+			}
+			values[key] = value
+		}
+	}
+	defer func() {
+		for _, value := range values {
+			wipe(value)
+		}
+	}()
+
+	for _, key := range secretTypeKeys[secret.Type] {
+		if _, ok := values[key]; !ok {
+			add("data: type %s requires key %s", secret.Type, key)
+		}
+	}
+	switch secret.Type {
+	case "kubernetes.io/basic-auth":
+		_, username := values["username"]
+		_, password := values["password"]
+		if !username && !password {
+			add("data: type %s requires key username or password", secret.Type)
+		}
+	case "kubernetes.io/service-account-token":
+		if secret.Annotations["kubernetes.io/service-account.name"] == "" {
+			add("metadata.annotations: type %s requires annotation kubernetes.io/service-account.name", secret.Type)
+		}
+	}
+	if checkValues {
+		if secret.Type == "kubernetes.io/dockercfg" || secret.Type == "kubernetes.io/dockerconfigjson" {
+			key := secretTypeKeys[secret.Type][0]
+			if value, ok := values[key]; ok && !json.Valid(value) {
+				add("data: value of %s is not valid JSON", key)
+			}
+		}
+		size := 0
+		for _, value := range values {
+			size += len(value)
+		}
+		if size > maxSecretSize {
+			add("data: %d bytes, at most %d are allowed", size, maxSecretSize)
+		}
+	}
+
+	if len(problems) > 0 {
+		return withCause(ErrInvalidSecret, errors.Errorf("Secret %s: %s", secret.Name, strings.Join(problems, "; ")))
+	}
+	return nil
+}
+
+// isQualifiedName reports whether a label or annotation key is a qualified
+// name: a name of at most 63 characters with an optional DNS subdomain
+// prefix.
+func isQualifiedName(key string) bool {
+	prefix, name, found := strings.Cut(key, "/")
+	if !found {
+		name, prefix = prefix, ""
+	} else if prefix == "" || len(prefix) > maxNameLength || !secretNamePattern.MatchString(prefix) {
+		return false
+	}
+	return len(name) <= maxLabelLength && qualifiedNamePattern.MatchString(name)
+}
+
+// isSecretKey reports whether a key is valid in the data of a Secret.
+func isSecretKey(key string) bool {
+	return len(key) <= maxNameLength && secretKeyPattern.MatchString(key) &&
+		key != "." && key != ".." && !strings.HasPrefix(key, "..")
+}
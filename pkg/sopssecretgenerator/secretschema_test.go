@@ -0,0 +1,69 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func Test_validateSecret(t *testing.T) {
+	secret := func(name string, data kvMap, secretType string) Secret {
+		return Secret{TypeMeta: TypeMeta{APIVersion: "v1", Kind: "Secret"}, ObjectMeta: ObjectMeta{Name: name}, Data: data, Type: secretType}
+	}
+	withMeta := func(s Secret, namespace string, labels kvMap, annotations kvMap) Secret {
+		s.Namespace, s.Labels, s.Annotations = namespace, labels, annotations
+		return s
+	}
+	tests := []struct {
+		name        string
+		secret      Secret
+		checkValues bool
+		wantErr     string
+	}{
+		{"Valid", withMeta(secret("app.web", kvMap{"KEY": b64("value"), "tls.crt": b64("")}, ""),
+			"apps", kvMap{"app.kubernetes.io/name": "web", "tier": ""}, kvMap{"example.com/Owner": "me"}), true, ""},
+		{"Name", secret("App_Secret", kvMap{}, ""), true, "metadata.name"},
+		{"Namespace", withMeta(secret("app", kvMap{}, ""), "apps.prod", nil, nil), true, "metadata.namespace"},
+		{"LabelKey", withMeta(secret("app", kvMap{}, ""), "", kvMap{"/name": "web"}, nil), true, "metadata.labels: key"},
+		{"LabelValue", withMeta(secret("app", kvMap{}, ""), "", kvMap{"name": "web app"}, nil), true, "metadata.labels: value"},
+		{"AnnotationKey", withMeta(secret("app", kvMap{}, ""), "", nil, kvMap{"owner name": "me"}), true, "metadata.annotations: key"},
+		{"DataKey", secret("app", kvMap{"MY KEY": b64("value")}, ""), true, "data: key \"MY KEY\""},
+		{"DotKey", secret("app", kvMap{"..data": b64("value")}, ""), true, "data: key \"..data\""},
+		{"NotBase64", secret("app", kvMap{"KEY": "<redacted>"}, ""), true, "not base64"},
+		{"Redacted", secret("app", kvMap{"KEY": "<redacted>"}, ""), false, ""},
+		{"TLS", secret("app", kvMap{"tls.crt": b64("cert")}, "kubernetes.io/tls"), true, "requires key tls.key"},
+		{"BasicAuth", secret("app", kvMap{"user": b64("me")}, "kubernetes.io/basic-auth"), true, "username or password"},
+		{"DockerConfig", secret("app", kvMap{".dockerconfigjson": b64("{")}, "kubernetes.io/dockerconfigjson"), true, "not valid JSON"},
+		{"ServiceAccountToken", secret("app", kvMap{}, "kubernetes.io/service-account-token"), true, "kubernetes.io/service-account.name"},
+		{"Size", secret("app", kvMap{"big": b64(strings.Repeat("x", maxSecretSize+1))}, ""), true, "at most 1048576"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSecret(tt.secret, tt.checkValues)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateSecret() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateSecret() error = %v, want %s", err, tt.wantErr)
+			}
+			if !errors.Is(err, ErrInvalidSecret) {
+				t.Errorf("validateSecret() error = %v, want %v", err, ErrInvalidSecret)
+			}
+		})
+	}
+}
+
+func Test_validateSecret_stringData(t *testing.T) {
+	secret := Secret{ObjectMeta: ObjectMeta{Name: "app"}, StringData: kvMap{"username": "me", "bad key": "x"}, Type: "kubernetes.io/basic-auth"}
+	err := validateSecret(secret, true)
+	if err == nil || !strings.Contains(err.Error(), "stringData: key \"bad key\"") || strings.Contains(err.Error(), "username or password") {
+		t.Errorf("validateSecret() error = %v", err)
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// envPrefix is the prefix of environment variables that configure the plugin.
+// Kustomize passes the environment through to exec functions, so this is the
+// way to configure an invocation as a whole.
+const envPrefix = "SOPS_SECRETGEN_"
+
+// Options holds the configuration that applies to all generators. Fields of
+// a generator take precedence. The command reads them from SOPS_SECRETGEN_
+// environment variables and flags.
+type Options struct {
+	// Timeout is the maximum duration to decrypt a file, zero for none
+	Timeout time.Duration
+	// Offline only uses local age and PGP keys
+	Offline bool
+	// AuditLog is a file to record decryptions in, or "syslog"
+	AuditLog string
+	// KMSRegions are the AWS regions whose KMS keys are tried first
+	KMSRegions []string
+	// KMSAttemptTimeout is the maximum duration for each KMS key
+	KMSAttemptTimeout time.Duration
+	// KMSRetries is how often a transient error of a network key service or
+	// Vault is retried, zero for never
+	KMSRetries int
+	// KMSRetryBackoff is the wait before the first retry, 500ms if zero. It
+	// doubles with every retry.
+	KMSRetryBackoff time.Duration
+	// CacheDir enables the decryption cache in the directory
+	CacheDir string
+	// CacheTTL is how long decrypted files are cached, one hour if zero
+	CacheTTL time.Duration
+	// CacheIdentity is the age identity file to encrypt cache entries with
+	CacheIdentity string
+	// NoCache disables the decryption cache and the state file
+	NoCache bool
+	// MaxFileSize is the maximum size of an encrypted file, zero for none
+	MaxFileSize int64
+	// Parallel is the number of generators the command processes at a time
+	Parallel int
+	// MaxConcurrency is the number of files that are decrypted, and remote
+	// sources that are fetched, at a time across all generators, 8 if zero
+	MaxConcurrency int
+	// Timings makes the command report decryption durations
+	Timings bool
+	// NoColor disables colored diagnostics on a terminal
+	NoColor bool
+	// Events is the format of the event stream, "ndjson", or "" for none
+	Events string
+	// EventsFile is where events are written, stderr if empty
+	EventsFile string
+	// NoProgress hides the progress line that standalone runs show on a
+	// terminal
+	NoProgress bool
+	// CPUProfile is a file to write a pprof CPU profile of the command to
+	CPUProfile string
+	// MemProfile is a file to write a pprof heap profile to when the command
+	// ends
+	MemProfile string
+	// StateFile is where the command records generated Secrets for reuse
+	StateFile string
+	// Decryptor decrypts the source files, sops with local keys if nil
+	Decryptor Decryptor
+	// Logger receives warnings and decryption traces, nothing is logged if nil
+	Logger *slog.Logger
+	// DryRun replaces the values of generated Secrets with a hash prefix
+	DryRun bool
+	// Output is the format of standalone runs: "yaml" or "" for a
+	// ResourceList, "json" for a List of the Secrets. It is only set by a
+	// flag, since kustomize needs a ResourceList.
+	Output string
+	// Variant selects the variant of generators that define variants
+	Variant string
+	// Namespace overrides the namespace of all generated Secrets
+	Namespace string
+	// Annotations is the CI format that validate and lint also report their
+	// problems in, and that runs report failed generators in: "github",
+	// "gitlab", or "" for none
+	Annotations string
+	// ErrorFormat is how the command reports the error that failed it:
+	// "text" or "" for a log line, "json" for an error envelope
+	ErrorFormat string
+}
+
+// runtimeSettings are the options of the current invocation of the command
+var runtimeSettings Options
+
+// OptionsFromEnv reads the options from the SOPS_SECRETGEN_ environment
+// variables.
+func OptionsFromEnv() (Options, error) {
+	var s Options
+	var err error
+
+	s.Timeout, err = envDuration("TIMEOUT")
+	if err != nil {
+		return Options{}, err
+	}
+	s.Offline, err = envBool("OFFLINE")
+	if err != nil {
+		return Options{}, err
+	}
+	s.AuditLog = os.Getenv(envPrefix + "AUDIT_LOG")
+	s.KMSRegions = envList("KMS_REGIONS")
+	s.KMSAttemptTimeout, err = envDuration("KMS_ATTEMPT_TIMEOUT")
+	if err != nil {
+		return Options{}, err
+	}
+	s.KMSRetries, err = envInt("KMS_RETRIES")
+	if err != nil {
+		return Options{}, err
+	}
+	s.KMSRetryBackoff, err = envDuration("KMS_RETRY_BACKOFF")
+	if err != nil {
+		return Options{}, err
+	}
+	s.CacheDir = os.Getenv(envPrefix + "CACHE_DIR")
+	s.CacheTTL, err = envDuration("CACHE_TTL")
+	if err != nil {
+		return Options{}, err
+	}
+	s.CacheIdentity = os.Getenv(envPrefix + "CACHE_IDENTITY")
+	s.NoCache, err = envBool("NO_CACHE")
+	if err != nil {
+		return Options{}, err
+	}
+	s.MaxFileSize, err = envSize("MAX_FILE_SIZE")
+	if err != nil {
+		return Options{}, err
+	}
+	s.Parallel, err = envInt("PARALLEL")
+	if err != nil {
+		return Options{}, err
+	}
+	s.MaxConcurrency, err = envInt("CONCURRENCY")
+	if err != nil {
+		return Options{}, err
+	}
+	s.Timings, err = envBool("TIMINGS")
+	if err != nil {
+		return Options{}, err
+	}
+	s.NoColor = os.Getenv("NO_COLOR") != ""
+	s.Events = os.Getenv(envPrefix + "EVENTS")
+	s.EventsFile = os.Getenv(envPrefix + "EVENTS_FILE")
+	s.NoProgress, err = envBool("NO_PROGRESS")
+	if err != nil {
+		return Options{}, err
+	}
+	s.CPUProfile = os.Getenv(envPrefix + "CPU_PROFILE")
+	s.MemProfile = os.Getenv(envPrefix + "MEM_PROFILE")
+	s.StateFile = os.Getenv(envPrefix + "STATE_FILE")
+	s.Variant = os.Getenv(envPrefix + "VARIANT")
+	s.Namespace = os.Getenv(envPrefix + "NAMESPACE")
+	s.ErrorFormat = os.Getenv(envPrefix + "ERROR_FORMAT")
+	s.Annotations = os.Getenv(envPrefix + "ANNOTATIONS")
+	s.DryRun, err = envBool("DRY_RUN")
+	if err != nil {
+		return Options{}, err
+	}
+	fake, err := envBool("FAKE_DECRYPT")
+	if err != nil {
+		return Options{}, err
+	}
+	if fake || fakeDecryptBuild {
+		s.Decryptor = FakeDecryptor{}
+	}
+	level, err := envLogLevel("LOG")
+	if err != nil {
+		return Options{}, err
+	}
+	s.Logger = newLogger(colorWriter{os.Stderr}, level)
+	return s, nil
+}
+
+// parseGlobalFlags applies the flags that precede a command to the options,
+// and returns the remaining arguments. Flags take precedence over the
+// environment. --version is short for the version command.
+func parseGlobalFlags(s *Options, args []string) ([]string, error) {
+	var version bool
+	flags := globalFlagSet(s, &version)
+	err := flags.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	if s.Output != "" && s.Output != "yaml" && s.Output != "json" {
+		return nil, errors.Errorf("invalid --output \"%s\", expected yaml or json", s.Output)
+	}
+	if s.ErrorFormat != "" && s.ErrorFormat != "text" && s.ErrorFormat != "json" {
+		return nil, errors.Errorf("invalid --error-format \"%s\", expected text or json", s.ErrorFormat)
+	}
+	if s.Annotations != "" && s.Annotations != annotationsGitHub && s.Annotations != annotationsGitLab {
+		return nil, errors.Errorf("invalid --annotations \"%s\", expected github or gitlab", s.Annotations)
+	}
+	if s.Events != "" && s.Events != "ndjson" {
+		return nil, errors.Errorf("invalid --events \"%s\", expected ndjson", s.Events)
+	}
+	if version {
+		return []string{"version"}, nil
+	}
+	return flags.Args(), nil
+}
+
+// hiddenFlags are global flags that are left out of the usage and of shell
+// completion, since they are only for performance reports
+var hiddenFlags = map[string]bool{"cpuprofile": true, "memprofile": true}
+
+// globalFlagSet returns the flags that come before a subcommand, which set
+// the options of a run.
+func globalFlagSet(s *Options, version *bool) *flag.FlagSet {
+	flags := flag.NewFlagSet("SopsSecretGenerator", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.BoolVar(&s.NoCache, "no-cache", s.NoCache, "do not use the decryption cache")
+	flags.IntVar(&s.Parallel, "parallel", s.Parallel, "maximum number of generators to process at a time")
+	flags.IntVar(&s.MaxConcurrency, "max-concurrency", s.MaxConcurrency, "maximum number of files to decrypt and remote sources to fetch at a time")
+	flags.BoolVar(&s.Timings, "timings", s.Timings, "report decryption durations and KMS calls on stderr")
+	flags.BoolVar(&s.NoColor, "no-color", s.NoColor, "do not color warnings and errors on a terminal")
+	flags.BoolVar(&s.NoProgress, "no-progress", s.NoProgress, "do not show the progress of decryption on a terminal")
+	flags.StringVar(&s.Events, "events", s.Events, "emit machine-readable events in the format: ndjson")
+	flags.StringVar(&s.EventsFile, "events-file", s.EventsFile, "write events to the file instead of stderr")
+	flags.BoolVar(&s.DryRun, "dry-run", s.DryRun, "replace the values of Secrets with a hash of the value")
+	flags.StringVar(&s.Output, "output", s.Output, "output format of standalone runs: yaml or json")
+	flags.StringVar(&s.Variant, "variant", s.Variant, "variant of the generators to generate")
+	flags.StringVar(&s.Namespace, "namespace", s.Namespace, "namespace of the generated Secrets")
+	flags.StringVar(&s.ErrorFormat, "error-format", s.ErrorFormat, "format of the error that fails the command: text or json")
+	flags.StringVar(&s.Annotations, "annotations", s.Annotations, "also report problems as CI annotations: github or gitlab")
+	flags.StringVar(&s.CPUProfile, "cpuprofile", s.CPUProfile, "write a CPU profile to the file")
+	flags.StringVar(&s.MemProfile, "memprofile", s.MemProfile, "write a heap profile to the file")
+	flags.BoolVar(version, "version", false, "print the version")
+	return flags
+}
+
+// envBool reads a boolean from the environment variable with the given name
+// (without prefix). It returns false if the variable is unset.
+func envBool(name string) (bool, error) {
+	value := os.Getenv(envPrefix + name)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid %s%s", envPrefix, name)
+	}
+	return b, nil
+}
+
+// envInt reads a non-negative integer from the environment variable with the
+// given name (without prefix). It returns zero if the variable is unset.
+func envInt(name string) (int, error) {
+	value := os.Getenv(envPrefix + name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err == nil && n < 0 {
+		err = errors.New("must not be negative")
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid %s%s", envPrefix, name)
+	}
+	return n, nil
+}
+
+// envDuration reads a duration such as "30s" from the environment variable
+// with the given name (without prefix). It returns zero if the variable is unset.
+func envDuration(name string) (time.Duration, error) {
+	value := os.Getenv(envPrefix + name)
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid %s%s", envPrefix, name)
+	}
+	return d, nil
+}
+
+// envSize reads a size such as "1Mi" from the environment variable with the
+// given name (without prefix). It returns zero if the variable is unset.
+func envSize(name string) (int64, error) {
+	value := os.Getenv(envPrefix + name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := parseSize(value)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid %s%s", envPrefix, name)
+	}
+	return n, nil
+}
+
+// envLogLevel reads a log level such as "debug" from the environment variable
+// with the given name (without prefix). It returns the default level if the
+// variable is unset.
+func envLogLevel(name string) (slog.Level, error) {
+	value := os.Getenv(envPrefix + name)
+	if value == "" {
+		return defaultLogLevel, nil
+	}
+	level, err := parseLogLevel(value)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid %s%s", envPrefix, name)
+	}
+	return level, nil
+}
+
+// envList reads a comma-separated list from the environment variable with the
+// given name (without prefix). Empty items are ignored.
+func envList(name string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(envPrefix+name), ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,230 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_envDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"Unset", "", 0, false},
+		{"Seconds", "30s", 30 * time.Second, false},
+		{"Invalid", "thirty", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(envPrefix+"TEST_DURATION", tt.value)
+			got, err := envDuration("TEST_DURATION")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("envDuration() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("envDuration() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_envInt(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"Unset", "", 0, false},
+		{"Number", "4", 4, false},
+		{"Negative", "-1", 0, true},
+		{"Invalid", "four", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(envPrefix+"TEST_INT", tt.value)
+			got, err := envInt("TEST_INT")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("envInt() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("envInt() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_envBool(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{"Unset", "", false, false},
+		{"True", "true", true, false},
+		{"One", "1", true, false},
+		{"Invalid", "yes please", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(envPrefix+"TEST_BOOL", tt.value)
+			got, err := envBool("TEST_BOOL")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("envBool() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("envBool() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_OptionsFromEnv(t *testing.T) {
+	t.Setenv(envPrefix+"TIMEOUT", "1m")
+	t.Setenv(envPrefix+"OFFLINE", "true")
+	t.Setenv(envPrefix+"KMS_REGIONS", "eu-west-1,eu-central-1")
+	t.Setenv(envPrefix+"KMS_ATTEMPT_TIMEOUT", "5s")
+	t.Setenv(envPrefix+"KMS_RETRIES", "3")
+	t.Setenv(envPrefix+"KMS_RETRY_BACKOFF", "1s")
+	t.Setenv(envPrefix+"MAX_FILE_SIZE", "1Mi")
+	t.Setenv(envPrefix+"TIMINGS", "true")
+	t.Setenv(envPrefix+"NO_PROGRESS", "true")
+	t.Setenv(envPrefix+"EVENTS", "ndjson")
+	t.Setenv(envPrefix+"EVENTS_FILE", "/dev/fd/3")
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv(envPrefix+"CONCURRENCY", "4")
+	t.Setenv(envPrefix+"STATE_FILE", "/tmp/secrets.state")
+	t.Setenv(envPrefix+"LOG", "debug")
+	t.Setenv(envPrefix+"DRY_RUN", "true")
+	got, err := OptionsFromEnv()
+	if err != nil {
+		t.Fatalf("OptionsFromEnv() error = %v", err)
+	}
+	if got.Timeout != time.Minute {
+		t.Errorf("OptionsFromEnv() Timeout = %v, want %v", got.Timeout, time.Minute)
+	}
+	if !got.Offline {
+		t.Errorf("OptionsFromEnv() Offline = %v, want true", got.Offline)
+	}
+	if want := []string{"eu-west-1", "eu-central-1"}; !reflect.DeepEqual(got.KMSRegions, want) {
+		t.Errorf("OptionsFromEnv() KMSRegions = %v, want %v", got.KMSRegions, want)
+	}
+	if got.KMSAttemptTimeout != 5*time.Second {
+		t.Errorf("OptionsFromEnv() KMSAttemptTimeout = %v, want %v", got.KMSAttemptTimeout, 5*time.Second)
+	}
+	if got.MaxFileSize != 1<<20 {
+		t.Errorf("OptionsFromEnv() MaxFileSize = %v, want %v", got.MaxFileSize, 1<<20)
+	}
+	if !got.Timings {
+		t.Errorf("OptionsFromEnv() Timings = %v, want true", got.Timings)
+	}
+	if !got.NoColor {
+		t.Errorf("OptionsFromEnv() NoColor = %v, want true", got.NoColor)
+	}
+	if !got.NoProgress {
+		t.Errorf("OptionsFromEnv() NoProgress = %v, want true", got.NoProgress)
+	}
+	if got.Events != "ndjson" || got.EventsFile != "/dev/fd/3" {
+		t.Errorf("OptionsFromEnv() Events = %v, EventsFile = %v, want ndjson, /dev/fd/3", got.Events, got.EventsFile)
+	}
+	if got.KMSRetries != 3 || got.KMSRetryBackoff != time.Second {
+		t.Errorf("OptionsFromEnv() KMSRetries = %v, KMSRetryBackoff = %v, want 3, 1s", got.KMSRetries, got.KMSRetryBackoff)
+	}
+	if got.MaxConcurrency != 4 {
+		t.Errorf("OptionsFromEnv() MaxConcurrency = %v, want 4", got.MaxConcurrency)
+	}
+	if got.StateFile != "/tmp/secrets.state" {
+		t.Errorf("OptionsFromEnv() StateFile = %v, want /tmp/secrets.state", got.StateFile)
+	}
+	if !got.DryRun {
+		t.Errorf("OptionsFromEnv() DryRun = %v, want true", got.DryRun)
+	}
+	if got.Logger == nil || !got.Logger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Errorf("OptionsFromEnv() Logger does not log at debug level")
+	}
+}
+
+func Test_envList(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{"Unset", "", nil},
+		{"Single", "eu-west-1", []string{"eu-west-1"}},
+		{"Multiple", "eu-west-1, eu-central-1,,", []string{"eu-west-1", "eu-central-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(envPrefix+"TEST_LIST", tt.value)
+			if got := envList("TEST_LIST"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("envList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseGlobalFlags(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		want         []string
+		wantNoCache  bool
+		wantParallel int
+		wantOutput   string
+		wantErr      bool
+	}{
+		{"None", []string{}, []string{}, false, 0, "", false},
+		{"Command", []string{"rotate", "--update-keys"}, []string{"rotate", "--update-keys"}, false, 0, "", false},
+		{"NoCache", []string{"--no-cache", "rotate"}, []string{"rotate"}, true, 0, "", false},
+		{"Parallel", []string{"--parallel", "2"}, []string{}, false, 2, "", false},
+		{"MaxConcurrency", []string{"--max-concurrency=2", "lint"}, []string{"lint"}, false, 0, "", false},
+		{"InvalidMaxConcurrency", []string{"--max-concurrency", "many"}, nil, false, 0, "", true},
+		{"Version", []string{"--version"}, []string{"version"}, false, 0, "", false},
+		{"LegacyPlugin", []string{"/tmp/kust-plugin-config-123"}, []string{"/tmp/kust-plugin-config-123"}, false, 0, "", false},
+		{"OutputJSON", []string{"--output=json"}, []string{}, false, 0, "json", false},
+		{"InvalidOutput", []string{"--output", "xml"}, nil, false, 0, "xml", true},
+		{"ErrorFormat", []string{"--error-format=json", "lint"}, []string{"lint"}, false, 0, "", false},
+		{"InvalidErrorFormat", []string{"--error-format", "xml"}, nil, false, 0, "", true},
+		{"Annotations", []string{"--annotations", "github", "validate"}, []string{"validate"}, false, 0, "", false},
+		{"InvalidAnnotations", []string{"--annotations=jenkins"}, nil, false, 0, "", true},
+		{"Events", []string{"--events=ndjson", "--events-file", "/dev/fd/3", "lint"}, []string{"lint"}, false, 0, "", false},
+		{"InvalidEvents", []string{"--events", "json"}, nil, false, 0, "", true},
+		{"Profiles", []string{"--cpuprofile=cpu.pprof", "--memprofile", "mem.pprof", "lint"}, []string{"lint"}, false, 0, "", false},
+		{"Unknown", []string{"--unknown"}, nil, false, 0, "", true},
+		{"InvalidParallel", []string{"--parallel", "many"}, nil, false, 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s Options
+			got, err := parseGlobalFlags(&s, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseGlobalFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseGlobalFlags() = %v, want %v", got, tt.want)
+			}
+			if s.NoCache != tt.wantNoCache {
+				t.Errorf("parseGlobalFlags() NoCache = %v, want %v", s.NoCache, tt.wantNoCache)
+			}
+			if s.Output != tt.wantOutput {
+				t.Errorf("parseGlobalFlags() Output = %v, want %v", s.Output, tt.wantOutput)
+			}
+			if s.Parallel != tt.wantParallel {
+				t.Errorf("parseGlobalFlags() Parallel = %v, want %v", s.Parallel, tt.wantParallel)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// invocationContext is canceled when the process is interrupted, which
+// abandons decryptions in progress
+var invocationContext = context.Background()
+
+// notifyInterrupt returns a context that is canceled on SIGINT or SIGTERM.
+// After the first signal, the default handling is restored, so that a second
+// signal terminates the process immediately.
+func notifyInterrupt(parent context.Context) context.Context {
+	ctx, stop := signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx
+}
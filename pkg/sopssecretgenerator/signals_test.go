@@ -0,0 +1,125 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+)
+
+// blockingDecryptor blocks until it is released, and signals when it starts
+type blockingDecryptor struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+// Decrypt decrypts a file.
+func (d blockingDecryptor) Decrypt(content []byte, format formats.Format) ([]byte, error) {
+	close(d.started)
+	<-d.release
+	return []byte("VAR=value\n"), nil
+}
+
+func Test_decryptWithTimeout(t *testing.T) {
+	done := func() ([]byte, error) { return []byte("plaintext"), nil }
+	block := make(chan struct{})
+	defer close(block)
+	blocked := func() ([]byte, error) {
+		<-block
+		return []byte("plaintext"), nil
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expiring, cancelExpiring := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelExpiring()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		decryptFn func() ([]byte, error)
+		timeout   time.Duration
+		wantErr   string
+	}{
+		{"NoTimeout", context.Background(), done, 0, ""},
+		{"Timeout", context.Background(), done, time.Minute, ""},
+		{"TimedOut", context.Background(), blocked, 10 * time.Millisecond, "timed out"},
+		{"CanceledBefore", canceled, done, 0, "canceled"},
+		{"CanceledDuring", expiring, blocked, 0, "canceled"},
+		{"CanceledBeforeTimeout", expiring, blocked, time.Minute, "canceled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decryptWithTimeout(tt.ctx, tt.decryptFn, tt.timeout)
+			if tt.wantErr == "" {
+				if err != nil || string(got) != "plaintext" {
+					t.Errorf("decryptWithTimeout() = %q, %v, want plaintext", got, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("decryptWithTimeout() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerate_Interrupted(t *testing.T) {
+	decryptor := blockingDecryptor{started: make(chan struct{}), release: make(chan struct{})}
+	defer close(decryptor.release)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-decryptor.started
+		cancel()
+	}()
+
+	spec := SopsSecretGenerator{
+		TypeMeta:   TypeMeta{APIVersion: apiVersion, Kind: kind},
+		ObjectMeta: ObjectMeta{Name: "my-secret"},
+		EnvSources: []string{"testdata/vars.env"},
+	}
+	_, err := Generate(ctx, spec, Options{Decryptor: decryptor})
+	if err == nil || !strings.Contains(err.Error(), "canceled") {
+		t.Errorf("Generate() error = %v, want canceled", err)
+	}
+}
+
+func Test_generateSecretObjects_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := generateSecretObjects(ctx, nil, 0, nil)
+	if err == nil || !strings.Contains(err.Error(), "canceled") {
+		t.Errorf("generateSecretObjects() error = %v, want canceled", err)
+	}
+}
+
+func Test_notifyInterrupt(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	ctx := notifyInterrupt(parent)
+	cancel()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("notifyInterrupt() context was not canceled with its parent")
+	}
+
+	ctx = notifyInterrupt(context.Background())
+	process, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = process.Signal(os.Interrupt)
+	if err != nil {
+		t.Skipf("cannot interrupt the test process: %v", err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Errorf("notifyInterrupt() context was not canceled by SIGINT")
+	}
+}
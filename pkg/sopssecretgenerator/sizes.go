@@ -0,0 +1,108 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// sizeSuffixes are the multipliers of the size suffixes, which follow the
+// Kubernetes quantity notation
+var sizeSuffixes = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"Ki", 1 << 10},
+	{"Mi", 1 << 20},
+	{"Gi", 1 << 30},
+	{"k", 1000},
+	{"M", 1000 * 1000},
+	{"G", 1000 * 1000 * 1000},
+}
+
+// parseSize parses a size in bytes, such as "1048576", "512Ki" or "1M".
+func parseSize(s string) (int64, error) {
+	number, multiplier := s, int64(1)
+	for _, suffix := range sizeSuffixes {
+		if strings.HasSuffix(s, suffix.suffix) {
+			number, multiplier = strings.TrimSuffix(s, suffix.suffix), suffix.multiplier
+			break
+		}
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 {
+		return 0, errors.Errorf("invalid size \"%s\", use a number of bytes with an optional suffix such as Ki, Mi or Gi", s)
+	}
+	return n * multiplier, nil
+}
+
+// formatSize formats a size in bytes for error messages.
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fGi", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMi", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKi", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
+
+// parseMaxFileSizes parses the per-file size limits of a generator. Every
+// file must be a source of the generator, to catch misspelled paths.
+func parseMaxFileSizes(input SopsSecretGenerator) (map[string]int64, error) {
+	if len(input.MaxFileSizes) == 0 {
+		return nil, nil
+	}
+	sources := make(map[string]bool)
+	for _, source := range inputFiles(input) {
+		filePath, _, err := splitExtract(source)
+		if err == nil {
+			sources[filePath] = true
+		}
+	}
+	limits := make(map[string]int64, len(input.MaxFileSizes))
+	for filePath, size := range input.MaxFileSizes {
+		if !sources[filePath] {
+			return nil, errors.Errorf("maxFileSizes: \"%s\" is not a source of the generator", filePath)
+		}
+		limit, err := parseSize(size)
+		if err != nil {
+			return nil, errors.Wrapf(err, "maxFileSizes: \"%s\"", filePath)
+		}
+		limits[filePath] = limit
+	}
+	return limits, nil
+}
+
+// maxFileSize returns the size limit for a file, or zero if it is unlimited.
+func (opts decryptOptions) maxFileSize(filePath string) int64 {
+	if limit, ok := opts.MaxFileSizes[filePath]; ok {
+		return limit
+	}
+	return opts.MaxFileSize
+}
+
+// checkFileSize fails if a file is larger than the limit, before it is read.
+// A zero limit allows any size.
+func checkFileSize(filePath string, limit int64) error {
+	if limit <= 0 {
+		return nil
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return errors.Wrap(err, "could not read file")
+	}
+	if info.Size() > limit {
+		return errors.Errorf("file is %s, larger than the maximum file size of %s", formatSize(info.Size()), formatSize(limit))
+	}
+	return nil
+}
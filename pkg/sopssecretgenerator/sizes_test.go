@@ -0,0 +1,135 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{"Bytes", "1024", 1024, false},
+		{"Kibibytes", "512Ki", 512 << 10, false},
+		{"Mebibytes", "1Mi", 1 << 20, false},
+		{"Gibibytes", "2Gi", 2 << 30, false},
+		{"Kilobytes", "500k", 500000, false},
+		{"Megabytes", "1M", 1000000, false},
+		{"Empty", "", 0, true},
+		{"UnknownSuffix", "1MB", 0, true},
+		{"Negative", "-1Mi", 0, true},
+		{"Fraction", "1.5Mi", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSize(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseSize() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseSize() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_formatSize(t *testing.T) {
+	tests := []struct {
+		size int64
+		want string
+	}{
+		{100, "100 bytes"},
+		{1536, "1.5Ki"},
+		{1 << 20, "1.0Mi"},
+		{3 << 30, "3.0Gi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := formatSize(tt.size); got != tt.want {
+				t.Errorf("formatSize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_checkFileSize(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "large.bin")
+	writeTestFile(t, file, strings.Repeat("x", 2048))
+
+	tests := []struct {
+		name    string
+		file    string
+		limit   int64
+		wantErr string
+	}{
+		{"Unlimited", file, 0, ""},
+		{"WithinLimit", file, 2048, ""},
+		{"TooLarge", file, 1024, "file is 2.0Ki, larger than the maximum file size of 1.0Ki"},
+		{"Missing", file + ".missing", 1024, "could not read file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkFileSize(tt.file, tt.limit)
+			if (err != nil) != (tt.wantErr != "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("checkFileSize() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_parseMaxFileSizes(t *testing.T) {
+	input := SopsSecretGenerator{
+		EnvSources:  []string{"testdata/file.env"},
+		FileSources: []string{"key=testdata/file.bin", "testdata/file.json[\"key\"]"},
+	}
+	tests := []struct {
+		name    string
+		sizes   kvMap
+		want    map[string]int64
+		wantErr bool
+	}{
+		{"None", nil, nil, false},
+		{"EnvSource", kvMap{"testdata/file.env": "1Ki"}, map[string]int64{"testdata/file.env": 1 << 10}, false},
+		{"KeyedSource", kvMap{"testdata/file.bin": "10Mi"}, map[string]int64{"testdata/file.bin": 10 << 20}, false},
+		{"ExtractSource", kvMap{"testdata/file.json": "1k"}, map[string]int64{"testdata/file.json": 1000}, false},
+		{"NotASource", kvMap{"testdata/other.bin": "1Mi"}, nil, true},
+		{"InvalidSize", kvMap{"testdata/file.bin": "large"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input.MaxFileSizes = tt.sizes
+			got, err := parseMaxFileSizes(input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseMaxFileSizes() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMaxFileSizes() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_decryptFile_maxFileSize(t *testing.T) {
+	opts := decryptOptions{
+		MaxFileSize:  1,
+		MaxFileSizes: map[string]int64{"testdata/file.txt": 1 << 20},
+	}
+	_, err := decryptFile("testdata/file.txt", opts)
+	if err != nil {
+		t.Errorf("decryptFile() with a per-file limit error = %v", err)
+	}
+	_, err = decryptFile("testdata/file.env", opts)
+	if err == nil || !strings.Contains(err.Error(), "larger than the maximum file size") {
+		t.Errorf("decryptFile() with the generator limit error = %v, want too large", err)
+	}
+}
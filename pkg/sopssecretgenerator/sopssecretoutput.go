@@ -0,0 +1,164 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/cmd/sops/common"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/getsops/sops/v3/config"
+	"github.com/getsops/sops/v3/version"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Output kinds of a generator
+const (
+	outputKindSecret          = "Secret"
+	outputKindEncryptedSecret = "EncryptedSecret"
+)
+
+// encryptedSecretRegex selects the values of an EncryptedSecret to encrypt,
+// as Flux documents for Secrets that kustomize-controller decrypts
+const encryptedSecretRegex = "^(data|stringData)$"
+
+// validateOutputKind checks the outputKind of a generator. EncryptedSecrets
+// are encrypted, so kustomize cannot merge or hash them.
+func validateOutputKind(input SopsSecretGenerator) error {
+	switch input.OutputKind {
+	case "", outputKindSecret:
+		return nil
+	case outputKindEncryptedSecret:
+		if input.Behavior == "merge" || input.Behavior == "replace" {
+			return errors.Errorf("outputKind %s cannot be combined with behavior %s", input.OutputKind, input.Behavior)
+		}
+		if input.NameSuffixHash.Algorithm != "" {
+			return errors.Errorf("outputKind %s cannot be combined with nameSuffixHash", input.OutputKind)
+		}
+		return nil
+	}
+	return errors.Errorf("outputKind must be %s or %s, not %s",
+		outputKindSecret, outputKindEncryptedSecret, input.OutputKind)
+}
+
+// isEncryptedOutput reports whether a generator emits a Secret with
+// encrypted data.
+func isEncryptedOutput(input SopsSecretGenerator) bool {
+	return input.OutputKind == outputKindEncryptedSecret
+}
+
+// encryptedSecretObject builds the KubeObject of the Secret of a generator
+// with encrypted data. Its sources were decrypted at build time to generate
+// the Secret, which is then encrypted again. Encryption gives new ciphertext
+// every time, so if the state file has a Secret that the generator encrypted
+// from the same plaintext to the same keys, that one is reused, and the
+// output only changes when the secrets do. The digest of the sources is
+// recorded with it, if there is one.
+func encryptedSecretObject(secret Secret, input SopsSecretGenerator, state *stateFile, digest string) (*fn.KubeObject, error) {
+	metadata, err := encryptedSecretMetadata(input)
+	if err != nil {
+		return nil, err
+	}
+	secret.Annotations = withoutKustomizeAnnotations(secret.Annotations)
+	plaintext, err := yaml.Marshal(secret)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(plaintext)
+
+	plainDigest := encryptedSecretDigest(plaintext, metadata)
+	encrypted, ok := state.getEncrypted(stateKey(input), plainDigest)
+	if ok {
+		runtimeSettings.logger().Info("reused encrypted Secret from state file", "generator", stateKey(input))
+	} else {
+		ciphertext, err := encryptSecret(plaintext, metadata)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not encrypt %s", input.OutputKind)
+		}
+		encrypted = string(ciphertext)
+	}
+	obj, err := fn.ParseKubeObject([]byte(encrypted))
+	if err != nil {
+		return nil, err
+	}
+	state.putEncrypted(stateKey(input), digest, plainDigest, obj.String())
+	return obj, nil
+}
+
+// encryptedSecretMetadata returns the sops metadata to encrypt the Secret of
+// a generator with: the key groups of its first sops-encrypted source file,
+// so that it can be decrypted with the same keys as the sources. Its
+// metadata stays readable, and only the encrypted values are covered by the
+// MAC, so that kustomize can still set its name prefix, namespace and labels.
+func encryptedSecretMetadata(input SopsSecretGenerator) (sops.Metadata, error) {
+	files := inputFiles(applyBaseDir(input))
+	if len(files) == 0 {
+		return sops.Metadata{}, errors.Errorf("outputKind %s requires a sops-encrypted source file, whose keys it is encrypted to", input.OutputKind)
+	}
+	keyFile, _, err := splitExtract(files[0])
+	if err != nil {
+		return sops.Metadata{}, err
+	}
+	keyTree, _, err := loadEncryptedTree(keyFile)
+	if err != nil {
+		return sops.Metadata{}, errors.Wrapf(err, "could not read the keys of %s", keyFile)
+	}
+	metadata := sops.Metadata{
+		KeyGroups:        keyTree.Metadata.KeyGroups,
+		ShamirThreshold:  keyTree.Metadata.ShamirThreshold,
+		EncryptedRegex:   encryptedSecretRegex,
+		MACOnlyEncrypted: true,
+		Version:          version.Version,
+	}
+	err = input.Policy.check(metadata)
+	if err != nil {
+		return sops.Metadata{}, err
+	}
+	return metadata, nil
+}
+
+// encryptedSecretDigest returns a digest of the plaintext of a Secret and of
+// the keys it is encrypted to. It is only kept in the state file, which is
+// encrypted itself.
+func encryptedSecretDigest(plaintext []byte, metadata sops.Metadata) string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s\n%d\n", stateFormat, len(plaintext))
+	_, _ = h.Write(plaintext)
+	_, _ = fmt.Fprintf(h, "%s\n%d\n", strings.Join(keyGroupSignatures(metadata.KeyGroups), "; "), metadata.ShamirThreshold)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// withoutKustomizeAnnotations returns the annotations without those of
+// kustomize, which would have kustomize hash or merge the ciphertext.
+func withoutKustomizeAnnotations(annotations kvMap) kvMap {
+	result := make(kvMap)
+	for k, v := range annotations {
+		if !strings.HasPrefix(k, "kustomize.config.k8s.io/") {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// encryptSecret encrypts the plaintext of a Secret with the metadata, for a
+// cluster-side controller such as Flux kustomize-controller to decrypt.
+func encryptSecret(plaintext []byte, metadata sops.Metadata) ([]byte, error) {
+	store := common.StoreForFormat(formats.Yaml, config.NewStoresConfig())
+	branches, err := store.LoadPlainFile(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	tree := &sops.Tree{Branches: branches, Metadata: metadata}
+	err = encryptTree(tree)
+	if err != nil {
+		return nil, err
+	}
+	return store.EmitEncryptedFile(*tree)
+}
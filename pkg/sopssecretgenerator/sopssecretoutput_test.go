@@ -0,0 +1,127 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/getsops/sops/v3/decrypt"
+	"gopkg.in/yaml.v3"
+)
+
+func Test_validateOutputKind(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   SopsSecretGenerator
+		wantErr bool
+	}{
+		{"Default", SopsSecretGenerator{}, false},
+		{"Secret", SopsSecretGenerator{OutputKind: "Secret"}, false},
+		{"EncryptedSecret", SopsSecretGenerator{OutputKind: "EncryptedSecret"}, false},
+		{"Other", SopsSecretGenerator{OutputKind: "ConfigMap"}, true},
+		{"SopsSecret", SopsSecretGenerator{OutputKind: "SopsSecret"}, true},
+		{"EncryptedReplace", SopsSecretGenerator{OutputKind: "EncryptedSecret", Behavior: "replace"}, true},
+		{"Merge", SopsSecretGenerator{OutputKind: "EncryptedSecret", Behavior: "merge"}, true},
+		{"Hash", SopsSecretGenerator{OutputKind: "EncryptedSecret", NameSuffixHash: NameSuffixHash{Algorithm: "sha256"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateOutputKind(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateOutputKind() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_encryptedSecretObject(t *testing.T) {
+	setupEncryptionKeyring(t)
+	secret := Secret{
+		TypeMeta: TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: ObjectMeta{
+			Name:        "app",
+			Labels:      kvMap{"app": "web"},
+			Annotations: kvMap{"kustomize.config.k8s.io/needs-hash": "true"},
+		},
+		Data: kvMap{"VAR_YAML": b64("val_yaml")},
+		Type: "Opaque",
+	}
+	input := SopsSecretGenerator{OutputKind: outputKindEncryptedSecret, EnvSources: []string{"testdata/vars.yaml"}}
+
+	obj, err := encryptedSecretObject(secret, input, nil, "")
+	if err != nil {
+		t.Fatalf("encryptedSecretObject() error = %v", err)
+	}
+	encrypted := []byte(obj.String())
+	var plain Secret
+	if err := yaml.Unmarshal(encrypted, &plain); err != nil {
+		t.Fatal(err)
+	}
+	if plain.Kind != "Secret" || plain.Name != "app" || plain.Type != "Opaque" || plain.Labels["app"] != "web" {
+		t.Errorf("encryptedSecretObject() metadata = %+v, want it unencrypted", plain)
+	}
+	if len(plain.Annotations) != 0 {
+		t.Errorf("encryptedSecretObject() annotations = %v, want none", plain.Annotations)
+	}
+	if !strings.HasPrefix(plain.Data["VAR_YAML"], "ENC[") {
+		t.Errorf("encryptedSecretObject() data = %v, want it encrypted", plain.Data)
+	}
+
+	// kustomize may change the metadata after generation
+	renamed := strings.Replace(string(encrypted), "name: app", "name: prefix-app", 1)
+	decrypted, err := decrypt.DataWithFormat([]byte(renamed), formats.Yaml)
+	if err != nil {
+		t.Fatalf("could not decrypt renamed Secret: %v", err)
+	}
+	var got Secret
+	if err := yaml.Unmarshal(decrypted, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "prefix-app" || got.Data["VAR_YAML"] != b64("val_yaml") {
+		t.Errorf("encryptedSecretObject() decrypts to %+v", got)
+	}
+}
+
+func Test_encryptedSecretObject_state(t *testing.T) {
+	setupEncryptionKeyring(t)
+	s, err := loadStateFile(filepath.Join(t.TempDir(), "secrets.state"), "")
+	if err != nil {
+		t.Fatalf("loadStateFile() error = %v", err)
+	}
+	secret := Secret{
+		TypeMeta:   TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: ObjectMeta{Name: "app"},
+		Data:       kvMap{"VAR_YAML": b64("val_yaml")},
+	}
+	input := SopsSecretGenerator{OutputKind: outputKindEncryptedSecret, EnvSources: []string{"testdata/vars.yaml"}}
+	input.Name = "app"
+
+	first, err := encryptedSecretObject(secret, input, s, "digest")
+	if err != nil {
+		t.Fatalf("encryptedSecretObject() error = %v", err)
+	}
+	// The sources changed, but not the Secret
+	second, err := encryptedSecretObject(secret, input, s, "other")
+	if err != nil {
+		t.Fatalf("encryptedSecretObject() error = %v", err)
+	}
+	if second.String() != first.String() {
+		t.Errorf("encryptedSecretObject() of the same Secret = %s, want %s", second, first)
+	}
+	if recorded, ok := s.get("/app", "other"); !ok || recorded != first.String() {
+		t.Errorf("get() = %q, %v, want the encrypted Secret", recorded, ok)
+	}
+
+	secret.Data = kvMap{"VAR_YAML": b64("changed")}
+	third, err := encryptedSecretObject(secret, input, s, "other")
+	if err != nil {
+		t.Fatalf("encryptedSecretObject() error = %v", err)
+	}
+	if third.String() == first.String() {
+		t.Error("encryptedSecretObject() of a changed Secret reused the old one")
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"filippo.io/age"
+	"github.com/pkg/errors"
+)
+
+// stateFormat is part of every digest, so that a change to how Secrets are
+// generated invalidates the Secrets in existing state files
+const stateFormat = "1"
+
+// stateIdentitySuffix is appended to the state file name for the generated
+// age identity
+const stateIdentitySuffix = ".identity.txt"
+
+// generatorState is the content of a state file, by generator
+type generatorState struct {
+	Generators map[string]stateEntry `json:"generators"`
+}
+
+// stateEntry is the Secret last generated by a generator, with the digest of
+// the generator manifest and source files it was generated from. Secrets
+// with encrypted data also have the digest of what they were encrypted from.
+type stateEntry struct {
+	Digest      string `json:"digest"`
+	Secret      string `json:"secret"`
+	PlainDigest string `json:"plainDigest,omitempty"`
+}
+
+// stateFile reuses previously generated Secrets when neither the generator
+// nor its source files changed. The file contains plaintext Secrets, so it is
+// encrypted with a local age identity, like the decryption cache.
+type stateFile struct {
+	path     string
+	identity *age.X25519Identity
+	mu       sync.Mutex
+	state    generatorState
+	changed  bool
+}
+
+var (
+	stateOnce sync.Once
+	state     *stateFile
+)
+
+// openState returns the state file for this invocation, or nil if it is not
+// enabled. Like the cache, the state file is an optimization, so if it cannot
+// be opened, a warning is printed and all Secrets are generated.
+func openState() *stateFile {
+	stateOnce.Do(func() {
+		if runtimeSettings.StateFile == "" || runtimeSettings.NoCache {
+			return
+		}
+		var err error
+		state, err = loadStateFile(runtimeSettings.StateFile, runtimeSettings.CacheIdentity)
+		if err != nil {
+			runtimeSettings.logger().Warn("state file disabled", "file", runtimeSettings.StateFile, "error", err)
+		}
+	})
+	return state
+}
+
+// loadStateFile reads a state file. A missing or unreadable state file is
+// treated as empty. If no identity file is given, an identity is generated
+// next to the state file on first use.
+func loadStateFile(path string, identityFile string) (*stateFile, error) {
+	if identityFile == "" {
+		identityFile = path + stateIdentitySuffix
+		err := os.MkdirAll(filepath.Dir(identityFile), 0o700)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not create state directory")
+		}
+		err = generateCacheIdentity(identityFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	identity, err := readCacheIdentity(identityFile)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &stateFile{path: path, identity: identity}
+	s.state.Generators = make(map[string]stateEntry)
+	ciphertext, err := os.ReadFile(path)
+	if err != nil {
+		return s, nil
+	}
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), identity)
+	if err != nil {
+		return s, nil
+	}
+	plaintext, err := io.ReadAll(r)
+	defer wipe(plaintext)
+	if err != nil || json.Unmarshal(plaintext, &s.state) != nil || s.state.Generators == nil {
+		s.state.Generators = make(map[string]stateEntry)
+	}
+	return s, nil
+}
+
+// get returns the Secret last generated by a generator, if it was generated
+// from the same inputs.
+func (s *stateFile) get(generator string, digest string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.state.Generators[generator]
+	if !ok || digest == "" || entry.Digest != digest {
+		return "", false
+	}
+	return entry.Secret, true
+}
+
+// getEncrypted returns the Secret with encrypted data last generated by a
+// generator, if it was encrypted from the same plaintext to the same keys,
+// whether or not its sources changed since.
+func (s *stateFile) getEncrypted(generator string, plainDigest string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.state.Generators[generator]
+	if !ok || entry.PlainDigest != plainDigest {
+		return "", false
+	}
+	return entry.Secret, true
+}
+
+// put records the Secret generated by a generator.
+func (s *stateFile) put(generator string, digest string, secret string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.Generators[generator] = stateEntry{Digest: digest, Secret: secret}
+	s.changed = true
+}
+
+// putEncrypted records the Secret with encrypted data generated by a
+// generator, with the digest of its plaintext and keys.
+func (s *stateFile) putEncrypted(generator string, digest string, plainDigest string, secret string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state.Generators[generator] = stateEntry{Digest: digest, Secret: secret, PlainDigest: plainDigest}
+	s.changed = true
+}
+
+// save writes the state file if any Secret was generated. Entries of
+// generators that were not part of this invocation are kept.
+func (s *stateFile) save() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.changed {
+		return nil
+	}
+
+	plaintext, err := json.Marshal(s.state)
+	if err != nil {
+		return err
+	}
+	defer wipe(plaintext)
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, s.identity.Recipient())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(plaintext)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".state-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	_, err = tmp.Write(buf.Bytes())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp.Name(), s.path)
+	if err == nil {
+		s.changed = false
+	}
+	return err
+}
+
+// stateKey identifies a generator in the state file.
+func stateKey(input SopsSecretGenerator) string {
+	return input.Namespace + "/" + input.Name
+}
+
+// generatorDigest returns a digest of a generator manifest and the encrypted
+// content of its source files, and of the git state if the generator
+// annotates its Secret with it.
+func generatorDigest(ctx context.Context, manifest []byte, input SopsSecretGenerator) (string, error) {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s\n%d\n", stateFormat, len(manifest))
+	_, _ = h.Write(manifest)
+	if input.Provenance {
+		provenance, err := generatorProvenance(ctx, input)
+		if err != nil {
+			return "", err
+		}
+		_, _ = fmt.Fprintf(h, "%s\n%t\n", provenance.Commit, provenance.Dirty)
+	}
+	for _, source := range inputFiles(input) {
+		filePath, _, err := splitExtract(source)
+		if err != nil {
+			return "", err
+		}
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return "", err
+		}
+		sum := sha256.Sum256(content)
+		_, _ = fmt.Fprintf(h, "%s\n%s\n", filePath, hex.EncodeToString(sum[:]))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+)
+
+func Test_generatorDigest(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	copyTestFile(t, "testdata/file.txt", file)
+	input := SopsSecretGenerator{FileSources: []string{"key=" + file}}
+	manifest := []byte("name: first")
+
+	digest, err := generatorDigest(context.Background(), manifest, input)
+	if err != nil {
+		t.Fatalf("generatorDigest() error = %v", err)
+	}
+	again, _ := generatorDigest(context.Background(), manifest, input)
+	if again != digest {
+		t.Errorf("generatorDigest() is not stable")
+	}
+	changedManifest, _ := generatorDigest(context.Background(), []byte("name: second"), input)
+	if changedManifest == digest {
+		t.Errorf("generatorDigest() did not change with the manifest")
+	}
+	copyTestFile(t, "testdata/file2.txt", file)
+	changedFile, _ := generatorDigest(context.Background(), manifest, input)
+	if changedFile == digest {
+		t.Errorf("generatorDigest() did not change with a source file")
+	}
+
+	input.EnvSources = []string{filepath.Join(dir, "missing.env")}
+	_, err = generatorDigest(context.Background(), manifest, input)
+	if err == nil {
+		t.Errorf("generatorDigest() with a missing file error = nil")
+	}
+}
+
+func Test_stateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "secrets.state")
+	s, err := loadStateFile(path, "")
+	if err != nil {
+		t.Fatalf("loadStateFile() error = %v", err)
+	}
+	if _, ok := s.get("ns/secret", "digest"); ok {
+		t.Errorf("get() on an empty state file ok = true")
+	}
+	s.put("ns/secret", "digest", "plaintext-secret")
+	err = s.save()
+	if err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(content, []byte("plaintext-secret")) {
+		t.Errorf("save() wrote the Secret in plaintext")
+	}
+
+	loaded, err := loadStateFile(path, "")
+	if err != nil {
+		t.Fatalf("loadStateFile() error = %v", err)
+	}
+	tests := []struct {
+		name      string
+		generator string
+		digest    string
+		want      string
+		wantOk    bool
+	}{
+		{"Unchanged", "ns/secret", "digest", "plaintext-secret", true},
+		{"Changed", "ns/secret", "other", "", false},
+		{"Unknown", "ns/other", "digest", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := loaded.get(tt.generator, tt.digest)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("get() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+
+	// A state file that cannot be decrypted is treated as empty.
+	other, err := loadStateFile(path, path+".other-identity")
+	if err == nil {
+		t.Errorf("loadStateFile() with a missing identity file error = nil, got %v", other)
+	}
+	writeTestFile(t, path, "corrupt")
+	corrupt, err := loadStateFile(path, "")
+	if err != nil {
+		t.Fatalf("loadStateFile() error = %v", err)
+	}
+	if _, ok := corrupt.get("ns/secret", "digest"); ok {
+		t.Errorf("get() on a corrupt state file ok = true")
+	}
+}
+
+func Test_generateSecretObject_state(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	copyTestFile(t, "testdata/file.txt", file)
+	item, err := fn.ParseKubeObject([]byte(fmt.Sprintf("apiVersion: %s\nkind: %s\nmetadata:\n  name: state\nfiles:\n  - %s\n", apiVersion, kind, file)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := loadStateFile(filepath.Join(dir, "secrets.state"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generated, err := generateSecretObject(context.Background(), item, s)
+	if err != nil {
+		t.Fatalf("generateSecretObject() error = %v", err)
+	}
+	digest, _ := generatorDigest(context.Background(), []byte(item.String()), SopsSecretGenerator{FileSources: []string{file}})
+	if _, ok := s.get("/state", digest); !ok {
+		t.Fatalf("generateSecretObject() did not record the Secret")
+	}
+
+	// Replace the recorded Secret, to tell a reused Secret from a generated one.
+	reused := generated.String() + "# reused\n"
+	s.put("/state", digest, reused)
+	got, err := generateSecretObject(context.Background(), item, s)
+	if err != nil {
+		t.Fatalf("generateSecretObject() error = %v", err)
+	}
+	if got.GetName() != "state" || got.String() == generated.String() {
+		t.Errorf("generateSecretObject() did not reuse the recorded Secret:\n%s", got)
+	}
+
+	// A changed source file is decrypted again.
+	copyTestFile(t, "testdata/file2.txt", file)
+	got, err = generateSecretObject(context.Background(), item, s)
+	if err != nil {
+		t.Fatalf("generateSecretObject() error = %v", err)
+	}
+	if value, _, _ := got.NestedString("data", "file.txt"); value != b64("secret2\n") {
+		t.Errorf("generateSecretObject() data = %q, want the changed file", value)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"encoding/base64"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// StringData selects the keys of a generator that go in the stringData of
+// the Secret, in plain text, instead of base64-encoded in its data.
+type StringData struct {
+	// Keys are keys from any source
+	Keys []string `json:"keys,omitempty" yaml:"keys,omitempty"`
+	// Sources are env or file sources, as listed under envs and files, all
+	// of whose keys are strings
+	Sources []string `json:"sources,omitempty" yaml:"sources,omitempty"`
+}
+
+// stringKeys records which keys of a generator are strings while its sources
+// are parsed. A key that a later source sets again is a string only if that
+// source is a string source too, or the key is listed. A nil stringKeys
+// records nothing.
+type stringKeys struct {
+	listed  map[string]bool
+	sources map[string]bool
+	keys    map[string]bool
+}
+
+// newStringKeys returns the stringKeys for a generator, or nil if it has no
+// stringData.
+func newStringKeys(input SopsSecretGenerator) *stringKeys {
+	if len(input.StringData.Keys) == 0 && len(input.StringData.Sources) == 0 {
+		return nil
+	}
+	s := &stringKeys{listed: make(map[string]bool), sources: make(map[string]bool), keys: make(map[string]bool)}
+	for _, key := range input.StringData.Keys {
+		s.listed[key] = true
+		s.keys[key] = true
+	}
+	for _, source := range input.StringData.Sources {
+		s.sources[source] = true
+	}
+	return s
+}
+
+// record records the keys that a source has set.
+func (s *stringKeys) record(source string, parsed kvMap) {
+	if s == nil {
+		return
+	}
+	for key := range parsed {
+		if s.sources[source] {
+			s.keys[key] = true
+		} else if !s.listed[key] {
+			delete(s.keys, key)
+		}
+	}
+}
+
+// validateStringData checks that the sources of stringData are sources of
+// the generator or of its variants.
+func validateStringData(input SopsSecretGenerator) error {
+	envs, files := allVariantSources(input)
+	known := make(map[string]bool, len(envs)+len(files))
+	for _, source := range append(envs, files...) {
+		known[source] = true
+	}
+	for _, source := range input.StringData.Sources {
+		if !known[source] {
+			return errors.Errorf("stringData: \"%s\" is not an env or file source of the generator", source)
+		}
+	}
+	return nil
+}
+
+// splitStringData moves the string keys of the data of a Secret to its
+// stringData, decoded. Values that are not base64-encoded, such as those of
+// dry runs, are moved as they are.
+func splitStringData(data kvMap, strs *stringKeys) (kvMap, error) {
+	if strs == nil {
+		return nil, nil
+	}
+	stringData := make(kvMap)
+	for key := range strs.keys {
+		value, ok := data[key]
+		if !ok {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			stringData[key] = value
+			delete(data, key)
+			continue
+		}
+		if !utf8.Valid(decoded) {
+			wipe(decoded)
+			return nil, errors.Errorf("stringData: key %s is binary, it must be in data", key)
+		}
+		stringData[key] = string(decoded)
+		wipe(decoded)
+		delete(data, key)
+	}
+	return stringData, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func Test_generateSecret_stringData(t *testing.T) {
+	tests := []struct {
+		name           string
+		stringData     StringData
+		files          []string
+		wantData       kvMap
+		wantStringData kvMap
+	}{
+		{"None", StringData{}, []string{"testdata/file.txt"},
+			kvMap{"VAR_ENV": b64("val_env"), "file.txt": b64("secret\n")}, nil},
+		{"Keys", StringData{Keys: []string{"file.txt", "MISSING"}}, []string{"testdata/file.txt"},
+			kvMap{"VAR_ENV": b64("val_env")}, kvMap{"file.txt": "secret\n"}},
+		{"Sources", StringData{Sources: []string{"testdata/vars.env"}}, []string{"testdata/file.txt"},
+			kvMap{"file.txt": b64("secret\n")}, kvMap{"VAR_ENV": "val_env"}},
+		{"Overridden", StringData{Sources: []string{"testdata/vars.env"}}, []string{"VAR_ENV=testdata/file.txt"},
+			kvMap{"VAR_ENV": b64("secret\n")}, kvMap{}},
+		{"FileSource", StringData{Sources: []string{"VAR_ENV=testdata/file.txt"}}, []string{"VAR_ENV=testdata/file.txt"},
+			kvMap{}, kvMap{"VAR_ENV": "secret\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := ssg([]string{"testdata/vars.env"}, tt.files)
+			input.StringData = tt.stringData
+			secret, err := generateSecret(context.Background(), input, Options{})
+			if err != nil {
+				t.Fatalf("generateSecret() error = %v", err)
+			}
+			if !reflect.DeepEqual(secret.Data, tt.wantData) {
+				t.Errorf("generateSecret() data = %v, want %v", secret.Data, tt.wantData)
+			}
+			if len(secret.StringData) != 0 || len(tt.wantStringData) != 0 {
+				if !reflect.DeepEqual(secret.StringData, tt.wantStringData) {
+					t.Errorf("generateSecret() stringData = %v, want %v", secret.StringData, tt.wantStringData)
+				}
+			}
+		})
+	}
+}
+
+func Test_stringKeys_record(t *testing.T) {
+	strs := newStringKeys(SopsSecretGenerator{StringData: StringData{Keys: []string{"A"}, Sources: []string{"a.env"}}})
+	strs.record("a.env", kvMap{"A": "", "B": "", "C": ""})
+	strs.record("b.env", kvMap{"A": "", "B": ""})
+	want := map[string]bool{"A": true, "C": true}
+	if !reflect.DeepEqual(strs.keys, want) {
+		t.Errorf("record() keys = %v, want %v", strs.keys, want)
+	}
+}
+
+func Test_validateStringData(t *testing.T) {
+	input := ssg([]string{"testdata/vars.env"}, []string{"key=testdata/file.txt"})
+	input.StringData.Sources = []string{"testdata/vars.env", "key=testdata/file.txt"}
+	if err := validateStringData(input); err != nil {
+		t.Errorf("validateStringData() error = %v", err)
+	}
+	input.StringData.Sources = []string{"testdata/file.txt"}
+	if err := validateStringData(input); err == nil {
+		t.Error("validateStringData() of an unknown source succeeded")
+	}
+}
+
+func Test_splitStringData_binary(t *testing.T) {
+	strs := newStringKeys(SopsSecretGenerator{StringData: StringData{Keys: []string{"key"}}})
+	_, err := splitStringData(kvMap{"key": b64("\xff\xfe")}, strs)
+	if err == nil {
+		t.Error("splitStringData() of a binary value succeeded")
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/getsops/sops/v3/keyservice"
+)
+
+// timings collects decryption durations and KMS round-trips for the timings
+// report. A nil *timings collects nothing, which is the default.
+type timings struct {
+	mu         sync.Mutex
+	generators map[string]time.Duration
+	files      []fileTiming
+}
+
+// fileTiming is the decryption of a single source file
+type fileTiming struct {
+	generator string
+	file      string
+	duration  time.Duration
+	kmsCalls  int64
+	cached    bool
+}
+
+// invocationTimings collects the timings of the invocation, if enabled with
+// --timings or SOPS_SECRETGEN_TIMINGS
+var invocationTimings *timings
+
+// newTimings returns an empty timings collector.
+func newTimings() *timings {
+	return &timings{generators: make(map[string]time.Duration)}
+}
+
+// addGenerator records the total duration of a generator.
+func (t *timings) addGenerator(name string, duration time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.generators[name] += duration
+}
+
+// addFile records the decryption of a source file.
+func (t *timings) addFile(timing fileTiming) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.files = append(t.files, timing)
+}
+
+// kmsCalls returns the number of KMS calls of all recorded files.
+func (t *timings) kmsCalls() int64 {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var calls int64
+	for _, f := range t.files {
+		calls += f.kmsCalls
+	}
+	return calls
+}
+
+// report writes the timings by generator, with the files of each generator
+// below it, sorted by name.
+func (t *timings) report(w io.Writer) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	files := make(map[string][]fileTiming)
+	names := make(map[string]bool)
+	for name := range t.generators {
+		names[name] = true
+	}
+	for _, f := range t.files {
+		files[f.generator] = append(files[f.generator], f)
+		names[f.generator] = true
+	}
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	_, _ = fmt.Fprintln(w, "Timings:")
+	for _, name := range sorted {
+		_, _ = fmt.Fprintf(w, "  %s: %s\n", name, formatDuration(t.generators[name]))
+		generatorFiles := files[name]
+		sort.SliceStable(generatorFiles, func(i, j int) bool { return generatorFiles[i].file < generatorFiles[j].file })
+		for _, f := range generatorFiles {
+			detail := fmt.Sprintf("%d KMS calls", f.kmsCalls)
+			if f.cached {
+				detail = "cached"
+			}
+			_, _ = fmt.Fprintf(w, "    %s: %s, %s\n", f.file, formatDuration(f.duration), detail)
+		}
+	}
+}
+
+// formatDuration rounds a duration for the timings report.
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
+
+// countingServer is a local key service that counts the data key
+// decryptions that go to a network key service, such as AWS KMS or Vault.
+type countingServer struct {
+	next  keyservice.KeyServiceServer
+	calls *atomic.Int64
+}
+
+// Encrypt encrypts a data key.
+func (s countingServer) Encrypt(ctx context.Context, req *keyservice.EncryptRequest) (*keyservice.EncryptResponse, error) {
+	return s.next.Encrypt(ctx, req)
+}
+
+// Decrypt decrypts a data key.
+func (s countingServer) Decrypt(ctx context.Context, req *keyservice.DecryptRequest) (*keyservice.DecryptResponse, error) {
+	switch req.Key.KeyType.(type) {
+	case *keyservice.Key_KmsKey, *keyservice.Key_GcpKmsKey, *keyservice.Key_AzureKeyvaultKey, *keyservice.Key_VaultKey:
+		s.calls.Add(1)
+	}
+	return s.next.Decrypt(ctx, req)
+}
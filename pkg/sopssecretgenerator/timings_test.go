@@ -0,0 +1,89 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/getsops/sops/v3/keyservice"
+	"github.com/lithammer/dedent"
+)
+
+func Test_timings_report(t *testing.T) {
+	tm := newTimings()
+	tm.addGenerator("second", 1500*time.Millisecond)
+	tm.addGenerator("first", 250*time.Millisecond)
+	tm.addFile(fileTiming{generator: "second", file: "b.env", duration: 1200 * time.Millisecond, kmsCalls: 2})
+	tm.addFile(fileTiming{generator: "second", file: "a.bin", duration: 300 * time.Millisecond, cached: true})
+	tm.addFile(fileTiming{generator: "first", file: "c.json", duration: 249400 * time.Microsecond})
+
+	var out bytes.Buffer
+	tm.report(&out)
+	want := dedent.Dedent(`
+		Timings:
+		  first: 250ms
+		    c.json: 249ms, 0 KMS calls
+		  second: 1.5s
+		    a.bin: 300ms, cached
+		    b.env: 1.2s, 2 KMS calls
+	`)[1:]
+	if out.String() != want {
+		t.Errorf("report()\ngot = %s\nwant = %s", out.String(), want)
+	}
+}
+
+func Test_timings_disabled(t *testing.T) {
+	var tm *timings
+	tm.addGenerator("generator", time.Second)
+	tm.addFile(fileTiming{generator: "generator", file: "file.env"})
+	var out bytes.Buffer
+	tm.report(&out)
+	if out.Len() != 0 {
+		t.Errorf("report() wrote %q, want nothing when disabled", out.String())
+	}
+}
+
+func Test_countingServer_Decrypt(t *testing.T) {
+	var calls atomic.Int64
+	server := countingServer{next: slowKeyServer{}, calls: &calls}
+	keys := []*keyservice.Key{
+		{KeyType: &keyservice.Key_KmsKey{KmsKey: &keyservice.KmsKey{Arn: testKMSArn}}},
+		{KeyType: &keyservice.Key_VaultKey{VaultKey: &keyservice.VaultKey{}}},
+		{KeyType: &keyservice.Key_AgeKey{AgeKey: &keyservice.AgeKey{Recipient: testAgeRecipient}}},
+		{KeyType: &keyservice.Key_PgpKey{PgpKey: &keyservice.PgpKey{Fingerprint: testkeyFingerprint}}},
+	}
+	for _, key := range keys {
+		_, err := server.Decrypt(context.Background(), &keyservice.DecryptRequest{Key: key})
+		if err != nil {
+			t.Fatalf("Decrypt() error = %v", err)
+		}
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("Decrypt() counted %d KMS calls, want 2", got)
+	}
+}
+
+func Test_decryptFile_timings(t *testing.T) {
+	invocationTimings = newTimings()
+	defer func() { invocationTimings = nil }()
+
+	decrypted, err := decryptFile("testdata/file.txt", decryptOptions{Generator: "my-secret"})
+	if err != nil {
+		t.Fatalf("decryptFile() error = %v", err)
+	}
+	if string(decrypted) != "secret\n" {
+		t.Errorf("decryptFile() = %q, want %q", decrypted, "secret\n")
+	}
+	if len(invocationTimings.files) != 1 {
+		t.Fatalf("decryptFile() recorded %d timings, want 1", len(invocationTimings.files))
+	}
+	got := invocationTimings.files[0]
+	if got.generator != "my-secret" || got.file != "testdata/file.txt" || got.kmsCalls != 0 || got.cached {
+		t.Errorf("decryptFile() recorded %+v", got)
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+//go:build linux
+
+package sopssecretgenerator
+
+import (
+	"syscall"
+)
+
+// Magic numbers of the file systems that keep files in memory
+const (
+	tmpfsMagic = 0x01021994
+	ramfsMagic = 0x858458f6
+)
+
+// isMemoryFS reports whether a path is on a tmpfs or ramfs.
+func isMemoryFS(path string) (bool, error) {
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(path, &stat)
+	if err != nil {
+		return false, err
+	}
+	magic := uint32(stat.Type)
+	return magic == tmpfsMagic || magic == ramfsMagic, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/age"
+	"github.com/getsops/sops/v3/azkv"
+	"github.com/getsops/sops/v3/gcpkms"
+	"github.com/getsops/sops/v3/hcvault"
+	"github.com/getsops/sops/v3/keyservice"
+	"github.com/getsops/sops/v3/kms"
+	"github.com/getsops/sops/v3/pgp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans of the generator
+const tracerName = "github.com/freightdog/kustomize-sopssecretgenerator/v2/pkg/sopssecretgenerator"
+
+// defaultServiceName is the service of the spans, unless OTEL_SERVICE_NAME
+// is set
+const defaultServiceName = "sops-secretgen"
+
+// tracer returns the tracer of the global tracer provider. Without a
+// provider, spans are not recorded, so library users get spans by setting
+// their own provider.
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// setupTracing installs a tracer provider that exports spans to the OTLP
+// endpoint in the standard OTEL_EXPORTER_OTLP_ environment variables. It
+// returns a function that flushes the spans, or nil if no endpoint is set.
+func setupTracing() (func(context.Context) error, error) {
+	exporter, err := otlpExporterFromEnv()
+	if err != nil || exporter == nil {
+		return nil, err
+	}
+	attributes := []attribute.KeyValue{}
+	if os.Getenv("OTEL_SERVICE_NAME") == "" {
+		attributes = append(attributes, attribute.String("service.name", defaultServiceName))
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attributes...))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// flushTimeout bounds how long the command waits for spans to be exported
+const flushTimeout = 5 * time.Second
+
+// flushTracing exports the remaining spans and stops the tracer provider.
+// Exporting must not hold up the build for long, so it gives up after the
+// flush timeout.
+func flushTracing(shutdown func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	return shutdown(ctx)
+}
+
+// endSpan records the error of an operation, if any, and ends its span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// keyAttributes returns the keys of a file as span attributes: the key types,
+// to group by backend, and the keys themselves.
+func keyAttributes(metadata sops.Metadata) []attribute.KeyValue {
+	var keys []string
+	seen := make(map[string]bool)
+	var types []string
+	for _, group := range metadata.KeyGroups {
+		for _, key := range group {
+			keys = append(keys, key.TypeToIdentifier()+":"+key.ToString())
+			if !seen[key.TypeToIdentifier()] {
+				seen[key.TypeToIdentifier()] = true
+				types = append(types, key.TypeToIdentifier())
+			}
+		}
+	}
+	sort.Strings(types)
+	return []attribute.KeyValue{
+		attribute.StringSlice("sops.key_types", types),
+		attribute.StringSlice("sops.keys", keys),
+	}
+}
+
+// tracingServer is a local key service that records a span for every data
+// key decryption, as a child of the span of the file. sops does not pass a
+// context to key services, so the parent is fixed when the server is created.
+type tracingServer struct {
+	next keyservice.KeyServiceServer
+	ctx  context.Context
+}
+
+// Encrypt encrypts a data key.
+func (s tracingServer) Encrypt(ctx context.Context, req *keyservice.EncryptRequest) (*keyservice.EncryptResponse, error) {
+	return s.next.Encrypt(ctx, req)
+}
+
+// Decrypt decrypts a data key.
+func (s tracingServer) Decrypt(ctx context.Context, req *keyservice.DecryptRequest) (*keyservice.DecryptResponse, error) {
+	keyType, keyID := describeKey(req.Key)
+	_, span := tracer().Start(s.ctx, "decrypt data key", trace.WithAttributes(
+		attribute.String("sops.key_type", keyType),
+		attribute.String("sops.key", keyID),
+	))
+	response, err := s.next.Decrypt(ctx, req)
+	endSpan(span, err)
+	return response, err
+}
+
+// describeKey returns the type and the identifier of a key service key, in
+// the terms of the sops metadata.
+func describeKey(key *keyservice.Key) (string, string) {
+	switch k := key.KeyType.(type) {
+	case *keyservice.Key_KmsKey:
+		return kms.KeyTypeIdentifier, k.KmsKey.Arn
+	case *keyservice.Key_GcpKmsKey:
+		return gcpkms.KeyTypeIdentifier, k.GcpKmsKey.ResourceId
+	case *keyservice.Key_AzureKeyvaultKey:
+		return azkv.KeyTypeIdentifier, k.AzureKeyvaultKey.VaultUrl + "/keys/" + k.AzureKeyvaultKey.Name + "/" + k.AzureKeyvaultKey.Version
+	case *keyservice.Key_VaultKey:
+		return hcvault.KeyTypeIdentifier, k.VaultKey.VaultAddress + "/v1/" + k.VaultKey.EnginePath + "/keys/" + k.VaultKey.KeyName
+	case *keyservice.Key_PgpKey:
+		return pgp.KeyTypeIdentifier, k.PgpKey.Fingerprint
+	case *keyservice.Key_AgeKey:
+		return age.KeyTypeIdentifier, k.AgeKey.Recipient
+	}
+	return "unknown", ""
+}
@@ -0,0 +1,88 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a tracer provider that records spans for the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+// spanAttribute returns the value of a span attribute.
+func spanAttribute(span sdktrace.ReadOnlySpan, key string) (attribute.Value, bool) {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestGenerate_Tracing(t *testing.T) {
+	recorder := recordSpans(t)
+	spec := SopsSecretGenerator{
+		TypeMeta:    TypeMeta{APIVersion: apiVersion, Kind: kind},
+		ObjectMeta:  ObjectMeta{Name: "my-secret"},
+		FileSources: []string{"testdata/file.txt"},
+	}
+	_, err := Generate(context.Background(), spec, Options{})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	generate, decrypt, dataKey := spans["generate"], spans["decrypt"], spans["decrypt data key"]
+	if generate == nil || decrypt == nil || dataKey == nil {
+		t.Fatalf("Generate() recorded spans %v, want generate, decrypt and decrypt data key", reflect.ValueOf(spans).MapKeys())
+	}
+	if decrypt.Parent().SpanID() != generate.SpanContext().SpanID() || dataKey.Parent().SpanID() != decrypt.SpanContext().SpanID() {
+		t.Errorf("Generate() spans are not nested as generate > decrypt > decrypt data key")
+	}
+	if v, _ := spanAttribute(decrypt, "file"); v.AsString() != "testdata/file.txt" {
+		t.Errorf("decrypt span file = %q", v.AsString())
+	}
+	if v, _ := spanAttribute(decrypt, "sops.key_types"); !reflect.DeepEqual(v.AsStringSlice(), []string{"pgp"}) {
+		t.Errorf("decrypt span sops.key_types = %v, want [pgp]", v.AsStringSlice())
+	}
+	if v, _ := spanAttribute(dataKey, "sops.key"); v.AsString() != testkeyFingerprint {
+		t.Errorf("decrypt data key span sops.key = %q, want %q", v.AsString(), testkeyFingerprint)
+	}
+}
+
+func TestGenerate_TracingError(t *testing.T) {
+	recorder := recordSpans(t)
+	spec := SopsSecretGenerator{
+		TypeMeta:    TypeMeta{APIVersion: apiVersion, Kind: kind},
+		ObjectMeta:  ObjectMeta{Name: "my-secret"},
+		FileSources: []string{"testdata/notyaml.txt"},
+	}
+	_, err := Generate(context.Background(), spec, Options{})
+	if err == nil {
+		t.Fatalf("Generate() error = nil")
+	}
+	for _, span := range recorder.Ended() {
+		if span.Status().Code != codes.Error {
+			t.Errorf("%s span status = %v, want Error", span.Name(), span.Status().Code)
+		}
+	}
+}
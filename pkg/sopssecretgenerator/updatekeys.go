@@ -0,0 +1,104 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// runUpdateKeys implements the updatekeys subcommand.
+func runUpdateKeys(args []string) error {
+	flags := newFlagSet("updatekeys")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	root := "."
+	if flags.NArg() > 0 {
+		root = flags.Arg(0)
+	}
+
+	files, err := referencedFiles(root)
+	if err != nil {
+		return err
+	}
+	failed, updated := 0, 0
+	for _, file := range files {
+		changed, err := updateFileKeys(file)
+		switch {
+		case err != nil:
+			failed++
+			_, _ = fmt.Fprintf(os.Stderr, "%s  %s: %v\n", colorize(colorRed, "FAILED"), file, err)
+		case changed:
+			updated++
+			fmt.Printf("updated %s\n", file)
+		default:
+			fmt.Printf("ok      %s\n", file)
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("%d of %d files could not be updated", failed, len(files))
+	}
+	fmt.Printf("%d of %d files updated\n", updated, len(files))
+	return nil
+}
+
+// updateFileKeys encrypts the data key of a file to the key groups of its
+// creation rule in the nearest .sops.yaml, like `sops updatekeys`: the data
+// key and the encrypted values stay the same, only the master keys change.
+// The Shamir threshold of the rule applies, or else that of the file, as far
+// as the new key groups allow. Files that already match their rule are left
+// as they are. It reports whether the file changed.
+func updateFileKeys(fileName string) (bool, error) {
+	tree, store, err := loadEncryptedTree(fileName)
+	if err != nil {
+		return false, err
+	}
+	rule, _, err := loadCreationRule(fileName, "")
+	if err != nil {
+		return false, err
+	}
+	threshold := tree.Metadata.ShamirThreshold
+	if rule.ShamirThreshold != 0 {
+		threshold = rule.ShamirThreshold
+	}
+	threshold = min(threshold, len(rule.KeyGroups))
+	if strings.Join(keyGroupSignatures(tree.Metadata.KeyGroups), "; ") == strings.Join(keyGroupSignatures(rule.KeyGroups), "; ") &&
+		threshold == tree.Metadata.ShamirThreshold {
+		return false, nil
+	}
+
+	keyServices, err := localKeyServices()
+	if err != nil {
+		return false, err
+	}
+	passGPGTTY(tree.Metadata)
+	dataKey, err := tree.Metadata.GetDataKeyWithKeyServices(keyServices, nil)
+	if err != nil {
+		err = gpgAgentError(decryptError(err))
+	}
+	auditErr := auditCommand("updatekeys", tree.FilePath, tree.Metadata, err)
+	if err != nil {
+		return false, err
+	}
+	defer wipe(dataKey)
+	if auditErr != nil {
+		return false, auditErr
+	}
+	tree.Metadata.KeyGroups = rule.KeyGroups
+	tree.Metadata.ShamirThreshold = threshold
+	errs := tree.Metadata.UpdateMasterKeysWithKeyServices(dataKey, keyServices)
+	if len(errs) > 0 {
+		return false, errors.Errorf("could not encrypt the data key to the new keys: %v", errs)
+	}
+	err = writeEncryptedTree(fileName, tree, store)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
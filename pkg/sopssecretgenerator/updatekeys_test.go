@@ -0,0 +1,71 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_updateFileKeys(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      string
+		sopsConfig  string
+		wantChanged bool
+		wantKeys    int
+		wantErr     bool
+	}{
+		{"UpToDate", "testdata/file.txt", "creation_rules:\n  - pgp: " + testkeyFingerprint + "\n", false, 1, false},
+		{"AddRecipient", "testdata/vars.yaml", "creation_rules:\n  - pgp: " + testkeyFingerprint + "\n    age: " + testAgeRecipient + "\n", true, 2, false},
+		{"NoRules", "testdata/file.txt", "creation_rules: []\n", false, 0, true},
+		{"NotEncrypted", "testdata/notyaml.txt", "creation_rules:\n  - pgp: " + testkeyFingerprint + "\n", false, 0, true},
+	}
+	setupEncryptionKeyring(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFile(t, filepath.Join(dir, ".sops.yaml"), tt.sopsConfig)
+			fileName := filepath.Join(dir, filepath.Base(tt.source))
+			original := copyTestFile(t, tt.source, fileName)
+
+			changed, err := updateFileKeys(fileName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("updateFileKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			updated, readErr := os.ReadFile(fileName)
+			if readErr != nil {
+				t.Fatal(readErr)
+			}
+			if changed != tt.wantChanged || bytes.Equal(updated, original) == tt.wantChanged {
+				t.Fatalf("updateFileKeys() changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if tt.wantErr {
+				return
+			}
+			tree, _, err := loadEncryptedTree(fileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys := 0
+			for _, group := range tree.Metadata.KeyGroups {
+				keys += len(group)
+			}
+			if keys != tt.wantKeys {
+				t.Errorf("updateFileKeys() left %d keys, want %d", keys, tt.wantKeys)
+			}
+			// The values are not encrypted again, so the file still decrypts
+			// to the same content with the old key
+			if _, err := decryptFile(fileName, decryptOptions{}); err != nil {
+				t.Errorf("decryptFile() error = %v", err)
+			}
+			if tt.wantChanged && !strings.Contains(string(updated), testAgeRecipient) {
+				t.Errorf("updateFileKeys() did not add the recipient")
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	sopsage "github.com/getsops/sops/v3/age"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// repoConfigFileName is the repository configuration file, which is searched
+// for in the working directory and its parents
+const repoConfigFileName = ".sopssecretgenerator.yaml"
+
+// userConfigPath is the user configuration file, relative to
+// $XDG_CONFIG_HOME or ~/.config
+const userConfigPath = "sops-secretgenerator/config.yaml"
+
+// fileConfig are the defaults of a configuration file. They apply where the
+// corresponding environment variable is not set, so environment variables
+// and flags take precedence. Relative paths are relative to the file.
+type fileConfig struct {
+	Concurrency       *int           `yaml:"concurrency"`
+	Parallel          *int           `yaml:"parallel"`
+	Timeout           *time.Duration `yaml:"timeout"`
+	Offline           *bool          `yaml:"offline"`
+	KMSRegions        []string       `yaml:"kmsRegions"`
+	KMSAttemptTimeout *time.Duration `yaml:"kmsAttemptTimeout"`
+	KMSRetries        *int           `yaml:"kmsRetries"`
+	KMSRetryBackoff   *time.Duration `yaml:"kmsRetryBackoff"`
+	CacheDir          *string        `yaml:"cacheDir"`
+	CacheTTL          *time.Duration `yaml:"cacheTTL"`
+	CacheIdentity     *string        `yaml:"cacheIdentity"`
+	NoCache           *bool          `yaml:"noCache"`
+	StateFile         *string        `yaml:"stateFile"`
+	MaxFileSize       *string        `yaml:"maxFileSize"`
+	LogLevel          *string        `yaml:"logLevel"`
+	Timings           *bool          `yaml:"timings"`
+	NoProgress        *bool          `yaml:"noProgress"`
+	AgeKeyFile        *string        `yaml:"ageKeyFile"`
+	ExportEnvDirs     []string       `yaml:"exportEnvDirs"`
+}
+
+// applyConfigFiles sets the environment variables that are not set yet to
+// the defaults of the user configuration file and of the nearest repository
+// configuration file, which takes precedence. It returns the files it read.
+func applyConfigFiles() ([]string, error) {
+	var paths []string
+	if configHome, err := userConfigHome(); err == nil {
+		paths = append(paths, filepath.Join(configHome, filepath.FromSlash(userConfigPath)))
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if repoConfig := findRepoConfig(wd); repoConfig != "" {
+			paths = append(paths, repoConfig)
+		}
+	}
+
+	env := make(map[string]string)
+	var read []string
+	for _, path := range paths {
+		cfg, err := readConfigFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return read, errors.Wrapf(err, "configuration file %s", path)
+		}
+		for name, value := range cfg.env(filepath.Dir(path)) {
+			env[name] = value
+		}
+		read = append(read, path)
+	}
+	for name, value := range env {
+		if _, ok := os.LookupEnv(name); !ok {
+			err := os.Setenv(name, value)
+			if err != nil {
+				return read, err
+			}
+		}
+	}
+	return read, nil
+}
+
+// userConfigHome returns $XDG_CONFIG_HOME, or ~/.config if it is not set.
+func userConfigHome() (string, error) {
+	if configHome := os.Getenv("XDG_CONFIG_HOME"); configHome != "" {
+		return configHome, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config"), nil
+}
+
+// findRepoConfig returns the repository configuration file in a directory
+// or the nearest of its parents, or "" if there is none.
+func findRepoConfig(dir string) string {
+	for {
+		path := filepath.Join(dir, repoConfigFileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// readConfigFile reads and checks a configuration file. Unknown fields are
+// errors, so that misspelled defaults do not go unnoticed.
+func readConfigFile(path string) (fileConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fileConfig{}, err
+	}
+	var cfg fileConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&cfg)
+	if err != nil && err != io.EOF {
+		return fileConfig{}, err
+	}
+	if cfg.LogLevel != nil {
+		if _, err := parseLogLevel(*cfg.LogLevel); err != nil {
+			return fileConfig{}, errors.Wrap(err, "logLevel")
+		}
+	}
+	if cfg.MaxFileSize != nil {
+		if _, err := parseSize(*cfg.MaxFileSize); err != nil {
+			return fileConfig{}, errors.Wrap(err, "maxFileSize")
+		}
+	}
+	for name, n := range map[string]*int{"concurrency": cfg.Concurrency, "parallel": cfg.Parallel, "kmsRetries": cfg.KMSRetries} {
+		if n != nil && *n < 0 {
+			return fileConfig{}, errors.Errorf("%s must not be negative", name)
+		}
+	}
+	return cfg, nil
+}
+
+// env returns the environment variables that the defaults correspond to,
+// with relative paths resolved against a directory.
+func (c fileConfig) env(dir string) map[string]string {
+	env := make(map[string]string)
+	setInt := func(name string, n *int) {
+		if n != nil {
+			env[envPrefix+name] = strconv.Itoa(*n)
+		}
+	}
+	setDuration := func(name string, d *time.Duration) {
+		if d != nil {
+			env[envPrefix+name] = d.String()
+		}
+	}
+	setBool := func(name string, b *bool) {
+		if b != nil {
+			env[envPrefix+name] = strconv.FormatBool(*b)
+		}
+	}
+	setPath := func(name string, p *string) {
+		if p != nil {
+			env[name] = configPath(dir, *p)
+		}
+	}
+
+	setInt("CONCURRENCY", c.Concurrency)
+	setInt("PARALLEL", c.Parallel)
+	setDuration("TIMEOUT", c.Timeout)
+	setBool("OFFLINE", c.Offline)
+	if c.KMSRegions != nil {
+		env[envPrefix+"KMS_REGIONS"] = strings.Join(c.KMSRegions, ",")
+	}
+	setDuration("KMS_ATTEMPT_TIMEOUT", c.KMSAttemptTimeout)
+	setInt("KMS_RETRIES", c.KMSRetries)
+	setDuration("KMS_RETRY_BACKOFF", c.KMSRetryBackoff)
+	setPath(envPrefix+"CACHE_DIR", c.CacheDir)
+	setDuration("CACHE_TTL", c.CacheTTL)
+	setPath(envPrefix+"CACHE_IDENTITY", c.CacheIdentity)
+	setBool("NO_CACHE", c.NoCache)
+	setPath(envPrefix+"STATE_FILE", c.StateFile)
+	if c.MaxFileSize != nil {
+		env[envPrefix+"MAX_FILE_SIZE"] = *c.MaxFileSize
+	}
+	if c.LogLevel != nil {
+		env[envPrefix+"LOG"] = *c.LogLevel
+	}
+	setBool("TIMINGS", c.Timings)
+	setBool("NO_PROGRESS", c.NoProgress)
+	setPath(sopsage.SopsAgeKeyFileEnv, c.AgeKeyFile)
+	if c.ExportEnvDirs != nil {
+		dirs := make([]string, len(c.ExportEnvDirs))
+		for i, exportDir := range c.ExportEnvDirs {
+			dirs[i] = configPath(dir, exportDir)
+		}
+		env[exportEnvDirsVariable] = strings.Join(dirs, string(os.PathListSeparator))
+	}
+	return env
+}
+
+// configPath resolves a path of a configuration file: a leading ~/ is the
+// home directory, and relative paths are relative to the directory of the
+// file.
+func configPath(dir string, p string) string {
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
@@ -0,0 +1,104 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_readConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"Empty", "", map[string]string{}, false},
+		{"Defaults", "concurrency: 4\ntimeout: 30s\noffline: false\nkmsRegions: [eu-west-1, eu-central-1]\nlogLevel: debug\nmaxFileSize: 1Mi\n",
+			map[string]string{
+				envPrefix + "CONCURRENCY": "4", envPrefix + "TIMEOUT": "30s", envPrefix + "OFFLINE": "false",
+				envPrefix + "KMS_REGIONS": "eu-west-1,eu-central-1", envPrefix + "LOG": "debug", envPrefix + "MAX_FILE_SIZE": "1Mi",
+			}, false},
+		{"Paths", "cacheDir: cache\nageKeyFile: /keys/age.txt\n",
+			map[string]string{envPrefix + "CACHE_DIR": filepath.Join("DIR", "cache"), "SOPS_AGE_KEY_FILE": "/keys/age.txt"}, false},
+		{"ExportEnvDirs", "exportEnvDirs:\n  - dev\n  - /srv/compose\n",
+			map[string]string{exportEnvDirsVariable: filepath.Join("DIR", "dev") + string(os.PathListSeparator) + "/srv/compose"}, false},
+		{"Unknown", "concurency: 4\n", nil, true},
+		{"InvalidDuration", "timeout: soon\n", nil, true},
+		{"InvalidLogLevel", "logLevel: verbose\n", nil, true},
+		{"InvalidSize", "maxFileSize: big\n", nil, true},
+		{"Negative", "parallel: -1\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			writeTestFile(t, path, tt.content)
+			cfg, err := readConfigFile(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readConfigFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := cfg.env("DIR"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("env() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_findRepoConfig(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "overlays", "prod")
+	err := os.MkdirAll(nested, 0o700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(root, repoConfigFileName), "")
+	if got := findRepoConfig(nested); got != filepath.Join(root, repoConfigFileName) {
+		t.Errorf("findRepoConfig() = %s, want %s", got, filepath.Join(root, repoConfigFileName))
+	}
+}
+
+func Test_applyConfigFiles(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	for _, name := range []string{"CONCURRENCY", "PARALLEL", "TIMINGS"} {
+		t.Setenv(envPrefix+name, "")
+		_ = os.Unsetenv(envPrefix + name)
+	}
+	t.Setenv(envPrefix+"TIMINGS", "false")
+	err := os.MkdirAll(filepath.Join(configHome, "sops-secretgenerator"), 0o700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(configHome, filepath.FromSlash(userConfigPath)), "concurrency: 2\nparallel: 2\ntimings: true\n")
+	repo := t.TempDir()
+	writeTestFile(t, filepath.Join(repo, repoConfigFileName), "parallel: 4\n")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Chdir(wd) }()
+
+	read, err := applyConfigFiles()
+	if err != nil {
+		t.Fatalf("applyConfigFiles() error = %v", err)
+	}
+	if len(read) != 2 {
+		t.Errorf("applyConfigFiles() read %v, want the user and repository files", read)
+	}
+	for name, want := range map[string]string{"CONCURRENCY": "2", "PARALLEL": "4", "TIMINGS": "false"} {
+		if got := os.Getenv(envPrefix + name); got != want {
+			t.Errorf("applyConfigFiles() set %s%s = %s, want %s", envPrefix, name, got, want)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/getsops/sops/v3/cmd/sops/common"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/getsops/sops/v3/config"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// validation is the result of validating a generator manifest
+type validation struct {
+	Path string
+	Name string
+	// Line is where the generator document starts in the file
+	Line     int
+	Problems []error
+}
+
+// runValidate implements the validate subcommand.
+func runValidate(args []string) error {
+	flags := newFlagSet("validate")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	var results []validation
+	for _, p := range paths {
+		found, err := validatePath(p)
+		if err != nil {
+			return err
+		}
+		results = append(results, found...)
+	}
+	if len(results) == 0 {
+		return errors.Errorf("no generators found in %s", strings.Join(paths, ", "))
+	}
+	failed := 0
+	var annotations []annotation
+	for _, result := range results {
+		if len(result.Problems) == 0 {
+			// A GitLab report is the only output on stdout
+			if runtimeSettings.Annotations != annotationsGitLab {
+				fmt.Printf("ok      %s\n", result.describe())
+			}
+			continue
+		}
+		failed++
+		for _, problem := range result.Problems {
+			_, _ = fmt.Fprintf(os.Stderr, "%s  %s: %v\n", colorize(colorRed, "FAILED"), result.describe(), problem)
+		}
+		annotations = append(annotations, result.annotations()...)
+	}
+	err = writeAnnotations(os.Stdout, runtimeSettings.Annotations, annotations)
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return errors.Errorf("%d of %d generators are invalid", failed, len(results))
+	}
+	return nil
+}
+
+// describe names the generator of a validation for the report.
+func (v validation) describe() string {
+	if v.Name == "" {
+		return v.Path
+	}
+	return v.Path + " (" + v.Name + ")"
+}
+
+// annotations returns the problems of a validation as CI annotations on the
+// generator manifest, at the line of the field if known, and otherwise at the
+// start of the generator.
+func (v validation) annotations() []annotation {
+	title := "SopsSecretGenerator"
+	if v.Name != "" {
+		title += " " + v.Name
+	}
+	var annotations []annotation
+	for _, problem := range v.Problems {
+		annotations = append(annotations, annotation{
+			Severity: severityError,
+			File:     v.Path,
+			Line:     problemLine(problem, v.Line),
+			Title:    title,
+			Message:  problem.Error(),
+		})
+	}
+	return annotations
+}
+
+// validatePath validates the generators in a manifest, or in the YAML files
+// under a directory. Files given explicitly must be valid YAML; files found
+// in a directory are skipped if they are not, like findGenerators does.
+func validatePath(root string) ([]validation, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return validateManifest(root)
+	}
+
+	var results []validation
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isYAMLFile(p) {
+			return nil
+		}
+		found, err := validateManifest(p)
+		if err != nil {
+			return nil
+		}
+		results = append(results, found...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// validateManifest validates every generator in a (multi-document) YAML
+// file, including the items of generator lists. Documents of other kinds are
+// ignored; documents of the generator kinds with another apiVersion are
+// reported, since kustomize would not run them.
+func validateManifest(fileName string) ([]validation, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Invalid documents are reported below, so they are left out of the
+	// generators that others may extend.
+	siblings, _ := manifestGenerators(fileName)
+
+	var results []validation
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var node yaml.Node
+		err = decoder.Decode(&node)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, fileName)
+		}
+		var typeMeta TypeMeta
+		if node.Decode(&typeMeta) != nil || (typeMeta.Kind != kind && typeMeta.Kind != listKind) {
+			continue
+		}
+		documents := []*yaml.Node{&node}
+		if typeMeta.Kind == listKind {
+			documents, err = listItems(&node)
+			if err != nil {
+				results = append(results, validation{Path: fileName, Line: documentLine(&node), Problems: []error{err}})
+				continue
+			}
+		}
+		for _, documentNode := range documents {
+			document, err := yaml.Marshal(documentNode)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, validateGenerator(fileName, document, siblings, documentLine(documentNode)))
+		}
+	}
+	return results, nil
+}
+
+// documentLine returns the line of a YAML document in its file, so that
+// errors in the document can be reported by their line in the file.
+func documentLine(node *yaml.Node) int {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		return node.Content[0].Line
+	}
+	return node.Line
+}
+
+// fieldErrorLine matches the line number of a field error of the YAML decoder
+var fieldErrorLine = regexp.MustCompile(`^line ([0-9]+): `)
+
+// strictDecode decodes a generator, failing on unknown fields, and returns
+// every field error with its line in the file that the document starts at.
+func strictDecode(document []byte, line int, out *SopsSecretGenerator) []error {
+	decoder := yaml.NewDecoder(bytes.NewReader(document))
+	decoder.KnownFields(true)
+	err := decoder.Decode(out)
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		if err != nil {
+			return []error{err}
+		}
+		return nil
+	}
+	var errs []error
+	for _, message := range typeErr.Errors {
+		if m := fieldErrorLine.FindStringSubmatch(message); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			message = fmt.Sprintf("line %d: %s", n+line-1, message[len(m[0]):])
+		}
+		errs = append(errs, errors.New(message))
+	}
+	return errs
+}
+
+// validateGenerator checks a generator manifest without decrypting anything:
+// the manifest must match the schema, and every source file must exist, be
+// encrypted with sops and satisfy the policy of the generator. Values to
+// extract can only be checked by decrypting, so they are not. The line is
+// where the document starts in the file. Siblings are the generators in the
+// same file by name, which the generator may extend.
+func validateGenerator(fileName string, document []byte, siblings map[string][]byte, line int) validation {
+	result := validation{Path: fileName, Line: line}
+	var strict SopsSecretGenerator
+	errs := strictDecode(document, line, &strict)
+	if len(errs) > 0 {
+		for _, err := range errs {
+			result.Problems = append(result.Problems, withCause(ErrInvalidGenerator, err))
+		}
+		return result
+	}
+	result.Name = strict.Name
+
+	document, err := resolveExtends(document, siblings, filepath.Clean(fileName), filepath.Dir(fileName))
+	if err != nil {
+		result.Problems = append(result.Problems, err)
+		return result
+	}
+	input, err := readInput(document)
+	if err != nil {
+		result.Problems = append(result.Problems, err)
+		return result
+	}
+	opts, err := newDecryptOptions(input, Options{})
+	if err != nil {
+		result.Problems = append(result.Problems, withCause(ErrInvalidGenerator, err))
+		return result
+	}
+
+	g := generatorFile{Path: fileName, Generator: input}
+	for _, source := range inputFiles(input) {
+		filePath, _, err := splitExtract(source)
+		if err != nil {
+			result.Problems = append(result.Problems, withCause(ErrInvalidGenerator, err))
+			continue
+		}
+		err = validateSourceFile(g.resolve(filePath), opts.maxFileSize(filePath), opts.Policy)
+		if input.AllowEmpty && errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			result.Problems = append(result.Problems, errors.Wrapf(err, "source \"%s\"", source))
+		}
+	}
+	_, fileSources := allVariantSources(input)
+	for _, source := range fileSources {
+		_, _, err := parseFileName(source)
+		if err != nil {
+			result.Problems = append(result.Problems, withCause(ErrInvalidGenerator, errors.Wrapf(err, "file source \"%s\"", source)))
+		}
+	}
+	return result
+}
+
+// validateSourceFile checks that a source file exists and has sops metadata
+// that satisfies the policy, by loading it without decrypting.
+func validateSourceFile(filePath string, maxFileSize int64, policy Policy) error {
+	err := checkFileSize(filePath, maxFileSize)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return errors.Wrap(err, "could not read file")
+	}
+	format := formats.FormatForPath(filePath)
+	store := common.StoreForFormat(format, config.NewStoresConfig())
+	tree, err := store.LoadEncryptedFile(content)
+	if err != nil {
+		return loadError(err, content, format)
+	}
+	err = policy.check(tree.Metadata)
+	if err != nil {
+		return err
+	}
+	if policy.MatchCreationRules {
+		return checkCreationRule(filePath, tree.Metadata)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func Test_validateManifest(t *testing.T) {
+	header := "apiVersion: " + apiVersion + "\nkind: " + kind + "\nmetadata:\n  name: secret\n"
+	tests := []struct {
+		name      string
+		manifest  string
+		wantCount int
+		wantErr   []string
+		wantIs    error
+	}{
+		{"Valid", header + "envs:\n  - vars.env\nfiles:\n  - key=file.txt\n  - yaml=vars.yaml[\"key\"]\n", 1, nil, nil},
+		{"OtherKind", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n", 0, nil, nil},
+		{"MultiDocument", header + "files:\n  - file.txt\n---\n" + header + "files:\n  - missing.txt\n", 2, []string{"missing.txt"}, nil},
+		{"UnknownField", header + "env:\n  - vars.env\n", 1, []string{"line 5: field env not found"}, ErrInvalidGenerator},
+		{"UnknownFieldSecondDocument", header + "---\n" + header + "file: []\nenv: []\n", 2, []string{"line 10: field file not found", "line 11: field env not found"}, ErrInvalidGenerator},
+		{"WrongVersion", "apiVersion: kustomize.freightdog.com/v0\nkind: " + kind + "\nmetadata:\n  name: secret\n", 1, []string{"input must be apiVersion"}, ErrInvalidGenerator},
+		{"NoName", "apiVersion: " + apiVersion + "\nkind: " + kind + "\n", 1, []string{"metadata.name"}, ErrInvalidGenerator},
+		{"InvalidTimeout", header + "timeout: soon\n", 1, []string{"invalid timeout"}, ErrInvalidGenerator},
+		{"MissingFile", header + "files:\n  - missing.txt\n", 1, []string{"source \"missing.txt\"", "could not read file"}, nil},
+		{"NotEncrypted", header + "files:\n  - notyaml.txt\n", 1, []string{"source \"notyaml.txt\""}, ErrNotEncrypted},
+		{"InvalidFileSource", header + "files:\n  - a=b=c\n", 1, []string{"file source \"a=b=c\""}, ErrInvalidGenerator},
+		{"Policy", header + "policy:\n  minKeyGroups: 2\nfiles:\n  - file.txt\n", 1, []string{"key group"}, nil},
+		{"MaxFileSize", header + "maxFileSize: 1\nfiles:\n  - file.txt\n", 1, []string{"larger than the maximum file size"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, source := range []string{"file.txt", "vars.env", "vars.yaml", "notyaml.txt"} {
+				copyTestFile(t, filepath.Join("testdata", source), filepath.Join(dir, source))
+			}
+			fileName := filepath.Join(dir, "generator.yaml")
+			writeTestFile(t, fileName, tt.manifest)
+
+			results, err := validateManifest(fileName)
+			if err != nil {
+				t.Fatalf("validateManifest() error = %v", err)
+			}
+			if len(results) != tt.wantCount {
+				t.Fatalf("validateManifest() returned %d results, want %d", len(results), tt.wantCount)
+			}
+			var problems []string
+			for _, result := range results {
+				for _, problem := range result.Problems {
+					problems = append(problems, problem.Error())
+					if tt.wantIs != nil && !errors.Is(problem, tt.wantIs) {
+						t.Errorf("validateManifest() problem %v is not %v", problem, tt.wantIs)
+					}
+				}
+			}
+			if len(tt.wantErr) == 0 && len(problems) > 0 {
+				t.Errorf("validateManifest() problems = %v, want none", problems)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(strings.Join(problems, "\n"), want) {
+					t.Errorf("validateManifest() problems = %v, want %q", problems, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_validatePath(t *testing.T) {
+	dir := t.TempDir()
+	copyTestFile(t, "testdata/file.txt", filepath.Join(dir, "file.txt"))
+	generator := "apiVersion: " + apiVersion + "\nkind: " + kind + "\nmetadata:\n  name: secret\nfiles:\n  - file.txt\n"
+	writeTestFile(t, filepath.Join(dir, "generator.yaml"), generator)
+	writeTestFile(t, filepath.Join(dir, "template.yaml"), "{{ .Values }}: [\n")
+	err := os.Mkdir(filepath.Join(dir, ".hidden"), 0o700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(dir, ".hidden", "generator.yaml"), generator)
+
+	results, err := validatePath(dir)
+	if err != nil {
+		t.Fatalf("validatePath() error = %v", err)
+	}
+	if len(results) != 1 || results[0].Path != filepath.Join(dir, "generator.yaml") || len(results[0].Problems) != 0 {
+		t.Errorf("validatePath() = %+v, want a single valid generator", results)
+	}
+
+	_, err = validatePath(filepath.Join(dir, "template.yaml"))
+	if err == nil {
+		t.Errorf("validatePath() error = nil for invalid YAML given explicitly")
+	}
+}
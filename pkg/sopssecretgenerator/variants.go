@@ -0,0 +1,84 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Variant holds the sources that a generator adds for one environment
+type Variant struct {
+	EnvSources  []string `json:"envs,omitempty" yaml:"envs,omitempty"`
+	FileSources []string `json:"files,omitempty" yaml:"files,omitempty"`
+}
+
+// activeVariant returns the variant of a generator to generate: the variant
+// of the options, or else the variant field of the generator.
+func activeVariant(input SopsSecretGenerator, options Options) string {
+	if options.Variant != "" {
+		return options.Variant
+	}
+	return input.Variant
+}
+
+// applyVariant adds the sources of a variant to those of a generator, and
+// removes its other variants. A variant that is selected for all generators
+// does not apply to generators without variants; a generator with variants
+// must define it.
+func applyVariant(input SopsSecretGenerator, variant string) (SopsSecretGenerator, error) {
+	if variant == "" || len(input.Variants) == 0 {
+		input.Variants = nil
+		return input, nil
+	}
+	selected, ok := input.Variants[variant]
+	if !ok {
+		return SopsSecretGenerator{}, withCause(ErrInvalidGenerator,
+			errors.Errorf("unknown variant \"%s\", expected one of %s", variant, strings.Join(variantNames(input), ", ")))
+	}
+	input.EnvSources = append(append([]string{}, input.EnvSources...), selected.EnvSources...)
+	input.FileSources = append(append([]string{}, input.FileSources...), selected.FileSources...)
+	input.Variant = variant
+	input.Variants = nil
+	return input, nil
+}
+
+// validateVariant checks that the variant field of a generator names one of
+// its variants.
+func validateVariant(input SopsSecretGenerator) error {
+	if input.Variant == "" {
+		return nil
+	}
+	if _, ok := input.Variants[input.Variant]; !ok {
+		return errors.Errorf("variant \"%s\" is not defined under variants", input.Variant)
+	}
+	return nil
+}
+
+// variantNames returns the names of the variants of a generator, sorted.
+func variantNames(input SopsSecretGenerator) []string {
+	names := make([]string, 0, len(input.Variants))
+	for name := range input.Variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// allVariantSources returns the env and file sources of a generator with
+// those of all its variants, for the commands that check every source that
+// a generator may use. Helm values files and Docker configs are returned as
+// env sources, as they are also decrypted as a whole.
+func allVariantSources(input SopsSecretGenerator) (envs []string, files []string) {
+	envs = append(append([]string{}, input.EnvSources...), input.HelmValues.Files...)
+	envs = append(envs, input.DockerConfigs...)
+	files = append([]string{}, input.FileSources...)
+	for _, name := range variantNames(input) {
+		envs = append(envs, input.Variants[name].EnvSources...)
+		files = append(files, input.Variants[name].FileSources...)
+	}
+	return envs, files
+}
@@ -0,0 +1,156 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt-functions-sdk/go/fn"
+)
+
+func Test_applyVariant(t *testing.T) {
+	variants := map[string]Variant{
+		"prod":    {EnvSources: []string{"prod.env"}, FileSources: []string{"tls.crt=prod.crt"}},
+		"staging": {EnvSources: []string{"staging.env"}},
+	}
+	tests := []struct {
+		name      string
+		input     SopsSecretGenerator
+		variant   string
+		wantEnvs  []string
+		wantFiles []string
+		wantErr   bool
+	}{
+		{"None", SopsSecretGenerator{EnvSources: []string{"common.env"}, Variants: variants}, "", []string{"common.env"}, nil, false},
+		{"Prod", SopsSecretGenerator{EnvSources: []string{"common.env"}, Variants: variants}, "prod",
+			[]string{"common.env", "prod.env"}, []string{"tls.crt=prod.crt"}, false},
+		{"Staging", SopsSecretGenerator{EnvSources: []string{"common.env"}, Variants: variants}, "staging",
+			[]string{"common.env", "staging.env"}, []string{}, false},
+		{"NoVariants", SopsSecretGenerator{EnvSources: []string{"common.env"}}, "prod", []string{"common.env"}, nil, false},
+		{"Unknown", SopsSecretGenerator{Variants: variants}, "dev", nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyVariant(tt.input, tt.variant)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyVariant() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got.EnvSources, tt.wantEnvs) || !reflect.DeepEqual(got.FileSources, tt.wantFiles) {
+				t.Errorf("applyVariant() = %v %v, want %v %v", got.EnvSources, got.FileSources, tt.wantEnvs, tt.wantFiles)
+			}
+			if got.Variants != nil {
+				t.Errorf("applyVariant() kept variants %v", got.Variants)
+			}
+			if again, _ := applyVariant(got, tt.variant); !reflect.DeepEqual(again, got) {
+				t.Errorf("applyVariant() applied the variant twice: %v", again)
+			}
+		})
+	}
+}
+
+func Test_activeVariant(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   SopsSecretGenerator
+		options Options
+		want    string
+	}{
+		{"None", SopsSecretGenerator{}, Options{}, ""},
+		{"Field", SopsSecretGenerator{Variant: "staging"}, Options{}, "staging"},
+		{"Options", SopsSecretGenerator{}, Options{Variant: "prod"}, "prod"},
+		{"OptionsOverField", SopsSecretGenerator{Variant: "staging"}, Options{Variant: "prod"}, "prod"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := activeVariant(tt.input, tt.options); got != tt.want {
+				t.Errorf("activeVariant() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+const testVariantGenerator = `apiVersion: kustomize.freightdog.com/v1
+kind: SopsSecretGenerator
+metadata:
+  name: secret
+files:
+  - testdata/file.txt
+variants:
+  env:
+    envs:
+      - testdata/vars.env
+  yaml:
+    envs:
+      - testdata/vars.yaml
+`
+
+func Test_readInput_variant(t *testing.T) {
+	_, err := readInput([]byte(testVariantGenerator + "variant: env\n"))
+	if err != nil {
+		t.Errorf("readInput() error = %v", err)
+	}
+	_, err = readInput([]byte(testVariantGenerator + "variant: json\n"))
+	if err == nil {
+		t.Errorf("readInput() accepted an undefined variant")
+	}
+}
+
+func Test_generateSecret_variant(t *testing.T) {
+	input, err := readInput([]byte(testVariantGenerator + "variant: env\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := generateSecret(context.Background(), input, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := secret.Data["VAR_ENV"]; !ok {
+		t.Errorf("generateSecret() did not add the variant's sources: %v", secret.Data)
+	}
+	if _, ok := secret.Data["file.txt"]; !ok {
+		t.Errorf("generateSecret() did not keep the common sources: %v", secret.Data)
+	}
+
+	_, err = generateSecret(context.Background(), input, Options{Variant: "json"})
+	if err == nil {
+		t.Errorf("generateSecret() accepted an undefined variant")
+	}
+}
+
+func Test_GenerateKRMManifest_variant(t *testing.T) {
+	item := "  - " + strings.ReplaceAll(strings.TrimSuffix(testVariantGenerator, "\n"), "\n", "\n    ") + "\n"
+	input := "apiVersion: config.kubernetes.io/v1\nkind: ResourceList\nitems:\n" + item +
+		"functionConfig:\n  apiVersion: v1\n  kind: ConfigMap\n  metadata:\n    name: config\n  data:\n    variant: env\n"
+	out, err := fn.Run(fn.ResourceListProcessorFunc(generateKRMManifest), []byte(input))
+	if err != nil {
+		t.Fatalf("generateKRMManifest() error = %v", err)
+	}
+	if !strings.Contains(string(out), "VAR_ENV:") {
+		t.Errorf("generateKRMManifest() did not generate the variant from the functionConfig: %s", out)
+	}
+	if runtimeSettings.Variant != "" {
+		t.Errorf("generateKRMManifest() left the variant %s selected", runtimeSettings.Variant)
+	}
+}
+
+func Test_sourceFiles_variants(t *testing.T) {
+	input, err := readInput([]byte(testVariantGenerator))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := generatorFile{Path: "generator.yaml", Generator: input}.sourceFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"testdata/vars.env", "testdata/vars.yaml", "testdata/file.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sourceFiles() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// VaultSource is a secret in a HashiCorp Vault KV secrets engine, whose
+// key/value pairs are added to the Secret as they are, without sops.
+type VaultSource struct {
+	// Mount is the path the KV engine is mounted at, secret if empty
+	Mount string `json:"mount,omitempty" yaml:"mount,omitempty"`
+	Path  string `json:"path" yaml:"path"`
+	// KVVersion is the version of the KV engine, 1 or 2, the default
+	KVVersion int `json:"kvVersion,omitempty" yaml:"kvVersion,omitempty"`
+	// Version is the version of the secret in a KV 2 engine, the latest if 0
+	Version int `json:"version,omitempty" yaml:"version,omitempty"`
+	// Keys are the keys to add, all keys of the secret if empty
+	Keys []string `json:"keys,omitempty" yaml:"keys,omitempty"`
+}
+
+// String returns the Vault source as vault:mount/path.
+func (s VaultSource) String() string {
+	return "vault:" + s.mount() + "/" + strings.Trim(s.Path, "/")
+}
+
+// mount returns the mount path of the KV engine.
+func (s VaultSource) mount() string {
+	if s.Mount == "" {
+		return "secret"
+	}
+	return strings.Trim(s.Mount, "/")
+}
+
+// kvVersion returns the version of the KV engine.
+func (s VaultSource) kvVersion() int {
+	if s.KVVersion == 0 {
+		return 2
+	}
+	return s.KVVersion
+}
+
+// validate checks the fields of a Vault source.
+func (s VaultSource) validate() error {
+	if strings.Trim(s.Path, "/") == "" {
+		return errors.New("vaultSources: path is required")
+	}
+	if s.kvVersion() != 1 && s.kvVersion() != 2 {
+		return errors.Errorf("vaultSources: kvVersion must be 1 or 2, not %d", s.KVVersion)
+	}
+	if s.Version < 0 {
+		return errors.Errorf("vaultSources: version must be positive, not %d", s.Version)
+	}
+	if s.Version > 0 && s.kvVersion() == 1 {
+		return errors.Errorf("vaultSources: %s: version requires kvVersion 2", s)
+	}
+	return nil
+}
+
+// url returns the API URL that reads the secret.
+func (s VaultSource) url(address string) string {
+	u := strings.TrimRight(address, "/") + "/v1/" + s.mount() + "/"
+	if s.kvVersion() == 2 {
+		u += "data/"
+	}
+	u += strings.Trim(s.Path, "/")
+	if s.Version > 0 {
+		u += "?version=" + strconv.Itoa(s.Version)
+	}
+	return u
+}
+
+// vaultResponse is the response to a KV read. KV 1 engines return the
+// key/value pairs as data, KV 2 engines as data.data.
+type vaultResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []string                   `json:"errors"`
+}
+
+// vaultClient reads secrets with the Vault HTTP API. It is configured like
+// the vault CLI, with VAULT_ADDR, VAULT_TOKEN, VAULT_NAMESPACE and
+// VAULT_CACERT, and the token helper file ~/.vault-token.
+type vaultClient struct {
+	address   string
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+// newVaultClient returns a client configured from the environment.
+func newVaultClient() (*vaultClient, error) {
+	c := &vaultClient{
+		address:   os.Getenv("VAULT_ADDR"),
+		token:     os.Getenv("VAULT_TOKEN"),
+		namespace: os.Getenv("VAULT_NAMESPACE"),
+		client:    http.DefaultClient,
+	}
+	if c.address == "" {
+		return nil, errors.New("VAULT_ADDR is not set")
+	}
+	_, err := url.Parse(c.address)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid VAULT_ADDR")
+	}
+	if c.token == "" {
+		home, err := os.UserHomeDir()
+		if err == nil {
+			token, err := os.ReadFile(filepath.Join(home, ".vault-token"))
+			if err == nil {
+				c.token = strings.TrimSpace(string(token))
+			}
+		}
+	}
+	if c.token == "" {
+		return nil, errors.New("VAULT_TOKEN is not set and there is no ~/.vault-token")
+	}
+	if caCert := os.Getenv("VAULT_CACERT"); caCert != "" {
+		pem, err := os.ReadFile(caCert)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read VAULT_CACERT")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("VAULT_CACERT %s has no certificates", caCert)
+		}
+		c.client = &http.Client{Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		}}
+	}
+	return c, nil
+}
+
+// read returns the key/value pairs of a secret.
+func (c *vaultClient) read(source VaultSource, opts decryptOptions) (map[string]json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(opts.context(), http.MethodGet, source.url(c.address), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", c.token)
+	if c.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var response vaultResponse
+	err = json.Unmarshal(body, &response)
+	if resp.StatusCode != http.StatusOK {
+		statusErr := errors.Errorf("vault returned %s", resp.Status)
+		if err == nil && len(response.Errors) > 0 {
+			statusErr = errors.Errorf("vault returned %s: %s", resp.Status, strings.Join(response.Errors, "; "))
+		}
+		if isTransientStatus(resp.StatusCode) {
+			return nil, withCause(errTransient, statusErr)
+		}
+		return nil, statusErr
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid vault response")
+	}
+	if source.kvVersion() == 1 {
+		return response.Data, nil
+	}
+	var data map[string]json.RawMessage
+	err = json.Unmarshal(response.Data["data"], &data)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid vault response")
+	}
+	if data == nil {
+		return nil, errors.New("secret version is deleted")
+	}
+	return data, nil
+}
+
+// parseVaultSources reads the Vault sources of a generator into the Secret
+// data. Vault is not reachable in offline mode.
+func parseVaultSources(sources []VaultSource, opts decryptOptions, data kvMap) error {
+	if len(sources) == 0 {
+		return nil
+	}
+	if opts.Offline {
+		return errors.New("vault sources cannot be read in offline mode")
+	}
+	client, err := newVaultClient()
+	if err != nil {
+		return errors.Wrap(err, "vault sources")
+	}
+	var errs []error
+	for _, source := range sources {
+		err := withWorker(opts, func() error { return parseVaultSource(client, source, opts, data) })
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "vault source %s", source))
+			if opts.context().Err() != nil {
+				break
+			}
+			continue
+		}
+	}
+	return joinSourceErrors(errs)
+}
+
+func parseVaultSource(client *vaultClient, source VaultSource, opts decryptOptions, data kvMap) error {
+	var values map[string]json.RawMessage
+	err := opts.Retry.do(opts.context(), func() error {
+		var err error
+		values, err = client.read(source, opts)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	keys := source.Keys
+	if len(keys) == 0 {
+		for key := range values {
+			keys = append(keys, key)
+		}
+	}
+	for _, key := range keys {
+		raw, ok := values[key]
+		if !ok {
+			return errors.Errorf("secret has no key %s", key)
+		}
+		var value string
+		err := json.Unmarshal(raw, &value)
+		if err != nil {
+			return errors.Errorf("value of key %s is not a string", key)
+		}
+		data[key] = encodeBase64([]byte(value))
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func Test_VaultSource_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  VaultSource
+		wantErr bool
+	}{
+		{"KV2", VaultSource{Path: "app"}, false},
+		{"KV1", VaultSource{Mount: "kv", Path: "app", KVVersion: 1}, false},
+		{"Version", VaultSource{Path: "app", Version: 3}, false},
+		{"NoPath", VaultSource{Path: "/"}, true},
+		{"KV3", VaultSource{Path: "app", KVVersion: 3}, true},
+		{"NegativeVersion", VaultSource{Path: "app", Version: -1}, true},
+		{"KV1Version", VaultSource{Path: "app", KVVersion: 1, Version: 3}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.source.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_parseVaultSources(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.RequestURI() {
+		case "/v1/secret/data/app":
+			_, _ = w.Write([]byte(`{"data":{"data":{"USER":"admin","PASSWORD":"secret"},"metadata":{"version":2}}}`))
+		case "/v1/secret/data/app?version=1":
+			_, _ = w.Write([]byte(`{"data":{"data":{"USER":"root"},"metadata":{"version":1}}}`))
+		case "/v1/secret/data/deleted":
+			_, _ = w.Write([]byte(`{"data":{"data":null,"metadata":{"version":1}}}`))
+		case "/v1/kv/app":
+			_, _ = w.Write([]byte(`{"data":{"USER":"legacy","PORT":5432}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		token   string
+		source  VaultSource
+		want    map[string]string
+		wantErr bool
+	}{
+		{"KV2", "token", VaultSource{Path: "app"}, map[string]string{"USER": "admin", "PASSWORD": "secret"}, false},
+		{"Version", "token", VaultSource{Path: "/app/", Version: 1}, map[string]string{"USER": "root"}, false},
+		{"Keys", "token", VaultSource{Path: "app", Keys: []string{"PASSWORD"}}, map[string]string{"PASSWORD": "secret"}, false},
+		{"KV1", "token", VaultSource{Mount: "kv", Path: "app", KVVersion: 1, Keys: []string{"USER"}}, map[string]string{"USER": "legacy"}, false},
+		{"NotString", "token", VaultSource{Mount: "kv", Path: "app", KVVersion: 1}, nil, true},
+		{"MissingKey", "token", VaultSource{Path: "app", Keys: []string{"TOKEN"}}, nil, true},
+		{"Deleted", "token", VaultSource{Path: "deleted"}, nil, true},
+		{"NotFound", "token", VaultSource{Path: "missing"}, nil, true},
+		{"Denied", "other", VaultSource{Path: "app"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("VAULT_ADDR", server.URL)
+			t.Setenv("VAULT_TOKEN", tt.token)
+			data := make(kvMap)
+			err := parseVaultSources([]VaultSource{tt.source}, decryptOptions{Context: context.Background()}, data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseVaultSources() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := make(map[string]string)
+			for key, value := range data {
+				decoded, _ := base64.StdEncoding.DecodeString(value)
+				got[key] = string(decoded)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseVaultSources() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseVaultSources_allErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/secret/data/app" {
+			_, _ = w.Write([]byte(`{"data":{"data":{"USER":"admin"},"metadata":{"version":1}}}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"errors":[]}`))
+	}))
+	defer server.Close()
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "token")
+
+	sources := []VaultSource{{Path: "missing"}, {Path: "app"}, {Path: "gone"}}
+	data := make(kvMap)
+	err := parseVaultSources(sources, decryptOptions{Context: context.Background()}, data)
+	var errs sourceErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("parseVaultSources() error = %v, want the errors of both failing sources", err)
+	}
+	for _, want := range []string{"vault:secret/missing", "vault:secret/gone"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("parseVaultSources() error = %v, want %s", err, want)
+		}
+	}
+	if _, ok := data["USER"]; !ok {
+		t.Errorf("parseVaultSources() = %v, want the source between the failing ones read", data)
+	}
+}
+
+func Test_parseVaultSources_retry(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"errors":["Vault is sealed"]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"data":{"USER":"admin"},"metadata":{"version":1}}}`))
+	}))
+	defer server.Close()
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "token")
+
+	source := []VaultSource{{Path: "app"}}
+	err := parseVaultSources(source, decryptOptions{Context: context.Background()}, make(kvMap))
+	if err == nil || requests != 1 {
+		t.Errorf("parseVaultSources() without retries = %v after %d requests", err, requests)
+	}
+	requests = 0
+	opts := decryptOptions{Context: context.Background(), Retry: retryPolicy{retries: 1, backoff: time.Millisecond}}
+	err = parseVaultSources(source, opts, make(kvMap))
+	if err != nil || requests != 2 {
+		t.Errorf("parseVaultSources() with a retry = %v after %d requests", err, requests)
+	}
+}
+
+func Test_parseVaultSources_environment(t *testing.T) {
+	source := []VaultSource{{Path: "app"}}
+	t.Setenv("HOME", t.TempDir())
+
+	t.Setenv("VAULT_ADDR", "")
+	t.Setenv("VAULT_TOKEN", "token")
+	if err := parseVaultSources(source, decryptOptions{}, make(kvMap)); err == nil {
+		t.Error("parseVaultSources() without VAULT_ADDR succeeded")
+	}
+	t.Setenv("VAULT_ADDR", "http://127.0.0.1:8200")
+	t.Setenv("VAULT_TOKEN", "")
+	if err := parseVaultSources(source, decryptOptions{}, make(kvMap)); err == nil {
+		t.Error("parseVaultSources() without a token succeeded")
+	}
+	t.Setenv("VAULT_TOKEN", "token")
+	if err := parseVaultSources(source, decryptOptions{Offline: true}, make(kvMap)); err == nil {
+		t.Error("parseVaultSources() in offline mode succeeded")
+	}
+	if err := parseVaultSources(nil, decryptOptions{Offline: true}, make(kvMap)); err != nil {
+		t.Errorf("parseVaultSources() without sources error = %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// verifyDiff is the external diff that kubectl diff runs on its directories
+// of live and merged objects. It only names the objects that differ, so the
+// data of Secrets is never printed.
+const verifyDiff = "diff -q -r -N"
+
+// diffedSecret matches a Secret that differs in the output of verifyDiff.
+// kubectl names the files of core objects VERSION.KIND.NAMESPACE.NAME.
+var diffedSecret = regexp.MustCompile(`^Files .*/v1\.Secret\.([^./]+)\.([^/]+) and .* differ$`)
+
+// runVerify implements the verify subcommand.
+func runVerify(args []string) error {
+	flags := newFlagSet("verify")
+	kubeconfig := flags.String("kubeconfig", "", "kubeconfig `file` (default: the kubeconfig of kubectl)")
+	kubeContext := flags.String("context", "", "kubeconfig `context` (default: the current context)")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	root := "."
+	if flags.NArg() > 0 {
+		root = flags.Arg(0)
+	}
+
+	changed, total, err := verify(root, kubectlFlags(*kubeconfig, *kubeContext))
+	if err != nil {
+		return err
+	}
+	for _, name := range changed {
+		fmt.Printf("CHANGED %s\n", name)
+	}
+	if len(changed) > 0 {
+		return errors.Errorf("%d of %d Secrets would change", len(changed), total)
+	}
+	fmt.Printf("ok      %d Secrets are up to date\n", total)
+	return nil
+}
+
+// kubectlFlags returns the kubectl flags that select the cluster.
+func kubectlFlags(kubeconfig string, kubeContext string) []string {
+	var flags []string
+	if kubeconfig != "" {
+		flags = append(flags, "--kubeconfig", kubeconfig)
+	}
+	if kubeContext != "" {
+		flags = append(flags, "--context", kubeContext)
+	}
+	return flags
+}
+
+// verify builds a kustomization and compares its Secrets with the cluster by
+// a server-side dry-run apply. It returns the namespaced names of the Secrets
+// that would change, including new ones, and the number of Secrets.
+func verify(root string, kubectlFlags []string) ([]string, int, error) {
+	build, err := kustomizeBuild(root)
+	if err != nil {
+		return nil, 0, err
+	}
+	secrets, total, err := filterSecrets(build)
+	if err != nil {
+		return nil, 0, err
+	}
+	if total == 0 {
+		return nil, 0, errors.Errorf("the kustomization in %s has no Secrets", root)
+	}
+	changed, err := diffSecrets(secrets, kubectlFlags)
+	if err != nil {
+		return nil, 0, err
+	}
+	return changed, total, nil
+}
+
+// kustomizeBuild runs kustomize build with plugins enabled, or kubectl
+// kustomize if kustomize is not installed.
+func kustomizeBuild(root string) ([]byte, error) {
+	command := []string{"kubectl", "kustomize", "--enable-alpha-plugins", "--enable-exec", root}
+	if binary, err := exec.LookPath("kustomize"); err == nil {
+		command = []string{binary, "build", "--enable-alpha-plugins", "--enable-exec", root}
+	}
+	cmd := exec.CommandContext(invocationContext, command[0], command[1:]...)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s", strings.Join(command[:2], " "))
+	}
+	return output, nil
+}
+
+// filterSecrets returns the Secrets among the objects of a multi-document
+// YAML stream, and their number.
+func filterSecrets(content []byte) ([]byte, int, error) {
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	total := 0
+	for {
+		var node yaml.Node
+		err := decoder.Decode(&node)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		var typeMeta TypeMeta
+		if node.Decode(&typeMeta) != nil || typeMeta.APIVersion != "v1" || typeMeta.Kind != "Secret" {
+			continue
+		}
+		err = encoder.Encode(&node)
+		if err != nil {
+			return nil, 0, err
+		}
+		total++
+	}
+	err := encoder.Close()
+	if err != nil {
+		return nil, 0, err
+	}
+	return out.Bytes(), total, nil
+}
+
+// diffSecrets runs kubectl diff on Secrets, and returns the namespaced names
+// of those that differ from the cluster. kubectl diff exits with 1 if any
+// object differs.
+func diffSecrets(secrets []byte, kubectlFlags []string) ([]string, error) {
+	args := append([]string{"diff", "--server-side", "--force-conflicts", "-f", "-"}, kubectlFlags...)
+	cmd := exec.CommandContext(invocationContext, "kubectl", args...)
+	cmd.Env = append(os.Environ(), "KUBECTL_EXTERNAL_DIFF="+verifyDiff)
+	cmd.Stdin = bytes.NewReader(secrets)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		err = nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "kubectl diff")
+	}
+
+	var changed []string
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		if m := diffedSecret.FindStringSubmatch(scanner.Text()); m != nil {
+			changed = append(changed, m[1]+"/"+m[2])
+		}
+	}
+	return changed, scanner.Err()
+}
@@ -0,0 +1,138 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeKubectl prints the build in $TEST_BUILD for kubectl kustomize, and for
+// kubectl diff records its arguments and input and prints $TEST_DIFF
+const fakeKubectl = `#!/bin/sh
+if [ "$1" = kustomize ]; then
+  cat "$TEST_BUILD"
+  exit
+fi
+[ "$KUBECTL_EXTERNAL_DIFF" = "diff -q -r -N" ] || exit 3
+echo "$@" > "$TEST_DIR/args"
+cat > "$TEST_DIR/input"
+printf '%s' "$TEST_DIFF"
+exit "$TEST_EXIT"
+`
+
+const verifyBuild = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db-5g2hk8f6t4
+  namespace: prod
+data:
+  PASSWORD: c2VjcmV0
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: api.example.com
+  namespace: prod
+`
+
+func Test_verify(t *testing.T) {
+	tests := []struct {
+		name      string
+		build     string
+		diff      string
+		exit      string
+		kustomize bool
+		want      []string
+		wantTotal int
+		wantErr   bool
+	}{
+		{"UpToDate", verifyBuild, "", "0", true, nil, 2, false},
+		{"Changed", verifyBuild, "Files /tmp/LIVE-1/v1.Secret.prod.api.example.com and /tmp/MERGED-2/v1.Secret.prod.api.example.com differ\n", "1", true,
+			[]string{"prod/api.example.com"}, 2, false},
+		{"KubectlKustomize", verifyBuild, "Files /tmp/LIVE-1/v1.Secret.prod.db-5g2hk8f6t4 and /tmp/MERGED-2/v1.Secret.prod.db-5g2hk8f6t4 differ\n", "1", false,
+			[]string{"prod/db-5g2hk8f6t4"}, 2, false},
+		{"NoSecrets", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\n", "", "0", true, nil, 0, true},
+		{"DiffFailed", verifyBuild, "", "2", true, nil, 0, true},
+		{"InvalidBuild", "{", "", "0", true, nil, 0, true},
+	}
+	cat, err := exec.LookPath("cat")
+	if err != nil {
+		t.Skip("cat is not installed")
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			bin := filepath.Join(dir, "bin")
+			if err := os.Mkdir(bin, 0o700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink(cat, filepath.Join(bin, "cat")); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(bin, "kubectl"), []byte(fakeKubectl), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if tt.kustomize {
+				if err := os.WriteFile(filepath.Join(bin, "kustomize"), []byte("#!/bin/sh\ncat \"$TEST_BUILD\"\n"), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			writeTestFile(t, filepath.Join(dir, "build.yaml"), tt.build)
+			t.Setenv("PATH", bin)
+			t.Setenv("TEST_DIR", dir)
+			t.Setenv("TEST_BUILD", filepath.Join(dir, "build.yaml"))
+			t.Setenv("TEST_DIFF", tt.diff)
+			t.Setenv("TEST_EXIT", tt.exit)
+
+			got, total, err := verify(dir, kubectlFlags("", "staging"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) || total != tt.wantTotal {
+				t.Errorf("verify() = %v, %d, want %v, %d", got, total, tt.want, tt.wantTotal)
+			}
+			args, _ := os.ReadFile(filepath.Join(dir, "args"))
+			if strings.TrimSpace(string(args)) != "diff --server-side --force-conflicts -f - --context staging" {
+				t.Errorf("kubectl arguments = %q", args)
+			}
+			input, _ := os.ReadFile(filepath.Join(dir, "input"))
+			if strings.Contains(string(input), "ConfigMap") || strings.Count(string(input), "kind: Secret") != 2 {
+				t.Errorf("kubectl input = %q, want only the Secrets", input)
+			}
+		})
+	}
+}
+
+func Test_kubectlFlags(t *testing.T) {
+	tests := []struct {
+		name        string
+		kubeconfig  string
+		kubeContext string
+		want        []string
+	}{
+		{"None", "", "", nil},
+		{"Kubeconfig", "/tmp/config", "", []string{"--kubeconfig", "/tmp/config"}},
+		{"Both", "/tmp/config", "prod", []string{"--kubeconfig", "/tmp/config", "--context", "prod"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := kubectlFlags(tt.kubeconfig, tt.kubeContext); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("kubectlFlags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	sopsversion "github.com/getsops/sops/v3/version"
+)
+
+// Build metadata, set by the release build with
+// -X github.com/freightdog/kustomize-sopssecretgenerator/v2/pkg/sopssecretgenerator.buildVersion=...
+var (
+	buildVersion string
+	buildCommit  string
+	buildDate    string
+)
+
+// versionInfo describes the build of the plugin
+type versionInfo struct {
+	Version  string `json:"version"`
+	Commit   string `json:"commit"`
+	Date     string `json:"date"`
+	Sops     string `json:"sops"`
+	Go       string `json:"go"`
+	Platform string `json:"platform"`
+}
+
+// currentVersion returns the build metadata of the running binary. Builds
+// without release metadata, such as with `go install`, fall back to the
+// module version and the version control information that Go records.
+func currentVersion() versionInfo {
+	info := versionInfo{
+		Version:  buildVersion,
+		Commit:   buildCommit,
+		Date:     buildDate,
+		Sops:     sopsversion.Version,
+		Go:       runtime.Version(),
+		Platform: runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "" && build.Main.Version != "" {
+			info.Version = build.Main.Version
+		}
+		for _, setting := range build.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time" && info.Date == "":
+				info.Date = setting.Value
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "(devel)"
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.Date == "" {
+		info.Date = "unknown"
+	}
+	return info
+}
+
+// runVersion implements the version subcommand.
+func runVersion(args []string) error {
+	flags := newFlagSet("version")
+	asJSON := flags.Bool("json", false, "print the version as JSON")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	return printVersion(os.Stdout, currentVersion(), *asJSON)
+}
+
+// printVersion writes build metadata for humans, or as a JSON object.
+func printVersion(w io.Writer, info versionInfo, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	}
+	_, err := fmt.Fprintf(w, "SopsSecretGenerator %s\n  commit:   %s\n  built:    %s\n  sops:     %s\n  go:       %s\n  platform: %s\n",
+		info.Version, info.Commit, info.Date, info.Sops, info.Go, info.Platform)
+	return err
+}
@@ -0,0 +1,75 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	sopsversion "github.com/getsops/sops/v3/version"
+)
+
+func Test_currentVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     string
+		commit      string
+		date        string
+		wantVersion string
+		wantCommit  string
+		wantDate    string
+	}{
+		{"Release", "2.1.0", "abc123", "2025-01-02T03:04:05Z", "2.1.0", "abc123", "2025-01-02T03:04:05Z"},
+		{"Unset", "", "", "", "(devel)", "unknown", "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := [3]string{buildVersion, buildCommit, buildDate}
+			buildVersion, buildCommit, buildDate = tt.version, tt.commit, tt.date
+			t.Cleanup(func() { buildVersion, buildCommit, buildDate = previous[0], previous[1], previous[2] })
+
+			got := currentVersion()
+			if got.Version != tt.wantVersion || got.Commit != tt.wantCommit || got.Date != tt.wantDate {
+				t.Errorf("currentVersion() = %+v, want version %q, commit %q, date %q", got, tt.wantVersion, tt.wantCommit, tt.wantDate)
+			}
+			if got.Sops != sopsversion.Version {
+				t.Errorf("currentVersion() Sops = %q, want %q", got.Sops, sopsversion.Version)
+			}
+		})
+	}
+}
+
+func Test_printVersion(t *testing.T) {
+	info := versionInfo{Version: "2.1.0", Commit: "abc123", Date: "2025-01-02", Sops: "3.9.0", Go: "go1.22.0", Platform: "linux/amd64"}
+	tests := []struct {
+		name   string
+		asJSON bool
+		want   []string
+	}{
+		{"Human", false, []string{"SopsSecretGenerator 2.1.0\n", "commit:   abc123\n", "sops:     3.9.0\n", "platform: linux/amd64\n"}},
+		{"JSON", true, []string{`"version": "2.1.0"`, `"commit": "abc123"`, `"sops": "3.9.0"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := printVersion(&out, info, tt.asJSON)
+			if err != nil {
+				t.Fatalf("printVersion() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("printVersion() = %q, want %q", out.String(), want)
+				}
+			}
+			if tt.asJSON {
+				var got versionInfo
+				if err := json.Unmarshal(out.Bytes(), &got); err != nil || got != info {
+					t.Errorf("printVersion() JSON = %+v, %v, want %+v", got, err, info)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Defaults of the webhook server
+const (
+	defaultWebhookAddr = ":8443"
+	// maxAdmissionReviewSize bounds the request body: a Secret of at most
+	// 1 MiB, base64-encoded, with its old object on updates
+	maxAdmissionReviewSize = 3 * 1024 * 1024
+	webhookShutdownTimeout = 10 * time.Second
+)
+
+// sopsCiphertext starts every value that sops encrypted, including the MAC
+// of every encrypted file
+const sopsCiphertext = "ENC[AES256_GCM,"
+
+// defaultExemptTypes are the types of Secrets that the cluster and Helm
+// create, which never come from a generator
+var defaultExemptTypes = []string{
+	"kubernetes.io/service-account-token",
+	"bootstrap.kubernetes.io/token",
+	"helm.sh/release.v1",
+}
+
+// admissionReview is an admission.k8s.io/v1 AdmissionReview, with the fields
+// that the webhook uses
+type admissionReview struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Request    *admissionRequest  `json:"request,omitempty"`
+	Response   *admissionResponse `json:"response,omitempty"`
+}
+
+// admissionRequest is the object that the API server asks to admit
+type admissionRequest struct {
+	UID       string           `json:"uid"`
+	Kind      groupVersionKind `json:"kind"`
+	Operation string           `json:"operation"`
+	Namespace string           `json:"namespace"`
+	Name      string           `json:"name"`
+	Object    json.RawMessage  `json:"object"`
+}
+
+// groupVersionKind is the kind of an admitted object
+type groupVersionKind struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// admissionResponse is the verdict of the webhook
+type admissionResponse struct {
+	UID     string           `json:"uid"`
+	Allowed bool             `json:"allowed"`
+	Status  *admissionStatus `json:"status,omitempty"`
+}
+
+// admissionStatus is why an object is rejected
+type admissionStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// webhookPolicy is what the webhook requires of Secrets
+type webhookPolicy struct {
+	// RequiredAnnotations must be set on every Secret, such as the
+	// provenance annotations that generators add
+	RequiredAnnotations []string
+	// ExemptTypes are the types of Secrets that are admitted unchecked
+	ExemptTypes []string
+}
+
+// newWebhookPolicy returns the policy of the serve-webhook flags. Without
+// required annotations, Secrets must have the git commit provenance
+// annotation, as a webhook that required none would admit Secrets written by
+// hand in plaintext.
+func newWebhookPolicy(requiredAnnotations []string, exemptTypes []string) webhookPolicy {
+	if len(requiredAnnotations) == 0 {
+		requiredAnnotations = []string{gitCommitAnnotation}
+	}
+	return webhookPolicy{
+		RequiredAnnotations: requiredAnnotations,
+		ExemptTypes:         append(slices.Clone(defaultExemptTypes), exemptTypes...),
+	}
+}
+
+// runServeWebhook implements the serve-webhook subcommand.
+func runServeWebhook(args []string) error {
+	flags := newFlagSet("serve-webhook")
+	addr := flags.String("addr", defaultWebhookAddr, "`address` to listen on")
+	certFile := flags.String("cert-file", "", "TLS certificate `file`, reloaded when it changes")
+	keyFile := flags.String("key-file", "", "TLS private key `file`, reloaded when it changes")
+	var requiredAnnotations, exemptTypes []string
+	flags.Func("require-annotation", "annotation `key` that every Secret must have (repeatable, default: "+gitCommitAnnotation+")", func(s string) error {
+		requiredAnnotations = append(requiredAnnotations, s)
+		return nil
+	})
+	flags.Func("exempt-type", "Secret `type` to admit unchecked, in addition to those of the cluster and Helm (repeatable)", func(s string) error {
+		exemptTypes = append(exemptTypes, s)
+		return nil
+	})
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	policy := newWebhookPolicy(requiredAnnotations, exemptTypes)
+	if *certFile == "" || *keyFile == "" {
+		return withCause(errFlags, errors.New("--cert-file and --key-file are required, the API server only calls webhooks over TLS"))
+	}
+	certificates := &certificateReloader{certFile: *certFile, keyFile: *keyFile}
+	_, err = certificates.certificate()
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/validate", policy.handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				return certificates.certificate()
+			},
+		},
+	}
+	go func() {
+		<-invocationContext.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	}()
+	runtimeSettings.logger().Info("serving webhook", "addr", *addr)
+	_, _ = fmt.Fprintf(os.Stderr, "serve-webhook: serving on %s\n", *addr)
+	err = server.ListenAndServeTLS("", "")
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// handler returns the HTTP handler of the validating webhook, which answers
+// AdmissionReviews of Secrets.
+func (p webhookPolicy) handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var review admissionReview
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdmissionReviewSize)).Decode(&review)
+		if err != nil || review.Request == nil {
+			http.Error(w, "expected an AdmissionReview", http.StatusBadRequest)
+			return
+		}
+		response := p.review(review.Request)
+		if !response.Allowed {
+			runtimeSettings.logger().Info("rejected Secret", "namespace", review.Request.Namespace, "name", review.Request.Name, "reason", response.Status.Message)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(admissionReview{
+			APIVersion: review.APIVersion,
+			Kind:       review.Kind,
+			Response:   &response,
+		})
+	})
+}
+
+// review decides whether to admit an object. Objects other than Secrets,
+// deletions and Secrets of exempt types are admitted.
+func (p webhookPolicy) review(request *admissionRequest) admissionResponse {
+	allowed := admissionResponse{UID: request.UID, Allowed: true}
+	if request.Kind.Group != "" || request.Kind.Kind != "Secret" || request.Operation == "DELETE" {
+		return allowed
+	}
+	var secret Secret
+	err := json.Unmarshal(request.Object, &secret)
+	if err != nil {
+		return rejected(request.UID, http.StatusBadRequest, fmt.Sprintf("could not read the Secret: %v", err))
+	}
+	if slices.Contains(p.ExemptTypes, secret.Type) {
+		return allowed
+	}
+	problems := p.check(secret)
+	if len(problems) > 0 {
+		return rejected(request.UID, http.StatusForbidden, "Secret rejected by SopsSecretGenerator: "+strings.Join(problems, "; "))
+	}
+	return allowed
+}
+
+// check returns the problems of a Secret: values that are still encrypted
+// with sops, because an encrypted file was applied instead of the Secret
+// generated from it, values that a dry run redacted, missing provenance
+// annotations, which Secrets written by hand in plaintext lack, and what
+// validateSecret finds wrong with it.
+func (p webhookPolicy) check(secret Secret) []string {
+	var problems []string
+	checkValue := func(key string, value string) {
+		switch {
+		case strings.Contains(value, sopsCiphertext):
+			problems = append(problems, fmt.Sprintf("value of %s is encrypted with sops, apply the Secret that a generator generates from the file", key))
+		case strings.HasPrefix(value, redactedPrefix):
+			problems = append(problems, fmt.Sprintf("value of %s is redacted, the Secret comes from a dry run", key))
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(secret.Data)) {
+		decoded, err := base64.StdEncoding.DecodeString(secret.Data[key])
+		if err != nil {
+			checkValue(key, secret.Data[key])
+			continue
+		}
+		checkValue(key, string(decoded))
+		wipe(decoded)
+	}
+	for _, key := range slices.Sorted(maps.Keys(secret.StringData)) {
+		checkValue(key, secret.StringData[key])
+	}
+	for _, annotation := range p.RequiredAnnotations {
+		if secret.Annotations[annotation] == "" {
+			problems = append(problems, fmt.Sprintf("annotation %s is missing, Secrets must be generated by a SopsSecretGenerator", annotation))
+		}
+	}
+	err := validateSecret(secret, true)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	return problems
+}
+
+// rejected returns a response that rejects an object with a reason.
+func rejected(uid string, code int, message string) admissionResponse {
+	return admissionResponse{UID: uid, Allowed: false, Status: &admissionStatus{Code: code, Message: message}}
+}
+
+// certificateReloader loads a TLS certificate, and loads it again when its
+// files change, such as when cert-manager renews it.
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mu          sync.Mutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+// certificate returns the current certificate. If the files cannot be
+// loaded after they changed, the previous certificate is kept.
+func (c *certificateReloader) certificate() (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	certInfo, certErr := os.Stat(c.certFile)
+	keyInfo, keyErr := os.Stat(c.keyFile)
+	if c.cert != nil && (certErr != nil || keyErr != nil || (certInfo.ModTime().Equal(c.certModTime) && keyInfo.ModTime().Equal(c.keyModTime))) {
+		return c.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		if c.cert != nil {
+			return c.cert, nil
+		}
+		return nil, errors.Wrap(err, "could not load the TLS certificate")
+	}
+	c.cert = &cert
+	if certErr == nil && keyErr == nil {
+		c.certModTime = certInfo.ModTime()
+		c.keyModTime = keyInfo.ModTime()
+	}
+	return c.cert, nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_webhookPolicy_review(t *testing.T) {
+	policy := webhookPolicy{RequiredAnnotations: []string{"kustomize.freightdog.com/source"}, ExemptTypes: defaultExemptTypes}
+	secret := func(annotations string, data string) json.RawMessage {
+		return json.RawMessage(`{"apiVersion":"v1","kind":"Secret","metadata":{"name":"db","annotations":{` + annotations + `}},` + data + `}`)
+	}
+	provenance := `"kustomize.freightdog.com/source":"overlays/prod/secrets.yaml"`
+
+	tests := []struct {
+		name        string
+		kind        groupVersionKind
+		operation   string
+		object      json.RawMessage
+		wantAllowed bool
+		wantMessage string
+	}{
+		{"Generated", groupVersionKind{Version: "v1", Kind: "Secret"}, "CREATE", secret(provenance, `"data":{"password":"aHVudGVyMg=="}`), true, ""},
+		{"Plaintext", groupVersionKind{Version: "v1", Kind: "Secret"}, "CREATE", secret("", `"stringData":{"password":"hunter2"}`), false,
+			"annotation kustomize.freightdog.com/source is missing"},
+		{"Encrypted", groupVersionKind{Version: "v1", Kind: "Secret"}, "UPDATE",
+			secret(provenance, `"data":{"vars.env":"VkFSPUVOQ1tBRVMyNTZfR0NNLGRhdGE6eFF6S10="}`), false, "value of vars.env is encrypted with sops"},
+		{"Redacted", groupVersionKind{Version: "v1", Kind: "Secret"}, "CREATE",
+			secret(provenance, `"stringData":{"password":"<redacted:sha256:2bb80d537b1d>"}`), false, "value of password is redacted"},
+		{"ExemptType", groupVersionKind{Version: "v1", Kind: "Secret"}, "CREATE",
+			json.RawMessage(`{"metadata":{"name":"sh.helm.release.v1.app.v1"},"type":"helm.sh/release.v1","data":{"release":"SDRzSQ=="}}`), true, ""},
+		{"Delete", groupVersionKind{Version: "v1", Kind: "Secret"}, "DELETE", nil, true, ""},
+		{"OtherKind", groupVersionKind{Version: "v1", Kind: "ConfigMap"}, "CREATE", json.RawMessage(`{"data":{"a":"b"}}`), true, ""},
+		{"Invalid", groupVersionKind{Version: "v1", Kind: "Secret"}, "CREATE", json.RawMessage(`{"data":[]}`), false, "could not read the Secret"},
+		{"InvalidKey", groupVersionKind{Version: "v1", Kind: "Secret"}, "CREATE", secret(provenance, `"stringData":{"pass word":"hunter2"}`), false,
+			`stringData: key "pass word" must consist of alphanumeric characters`},
+		{"MissingTypeKey", groupVersionKind{Version: "v1", Kind: "Secret"}, "CREATE", secret(provenance, `"type":"kubernetes.io/tls","data":{"tls.crt":"Y2VydA=="}`), false,
+			"type kubernetes.io/tls requires key tls.key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := policy.review(&admissionRequest{UID: "42", Kind: tt.kind, Operation: tt.operation, Object: tt.object})
+			if got.UID != "42" || got.Allowed != tt.wantAllowed {
+				t.Fatalf("review() = %+v, want allowed %v", got, tt.wantAllowed)
+			}
+			if tt.wantMessage != "" && (got.Status == nil || !strings.Contains(got.Status.Message, tt.wantMessage)) {
+				t.Errorf("review() status = %+v, want message with %q", got.Status, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func Test_newWebhookPolicy(t *testing.T) {
+	policy := newWebhookPolicy(nil, []string{"example.com/token"})
+	if !reflect.DeepEqual(policy.RequiredAnnotations, []string{gitCommitAnnotation}) {
+		t.Errorf("newWebhookPolicy() required annotations = %v, want %s", policy.RequiredAnnotations, gitCommitAnnotation)
+	}
+	if len(policy.ExemptTypes) != len(defaultExemptTypes)+1 || !slices.Contains(policy.ExemptTypes, "example.com/token") {
+		t.Errorf("newWebhookPolicy() exempt types = %v", policy.ExemptTypes)
+	}
+	policy = newWebhookPolicy([]string{"kustomize.freightdog.com/source"}, nil)
+	if !reflect.DeepEqual(policy.RequiredAnnotations, []string{"kustomize.freightdog.com/source"}) {
+		t.Errorf("newWebhookPolicy() required annotations = %v", policy.RequiredAnnotations)
+	}
+}
+
+func Test_webhookPolicy_handler(t *testing.T) {
+	handler := webhookPolicy{}.handler()
+	review := `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview","request":{"uid":"42","kind":{"version":"v1","kind":"Secret"},"operation":"CREATE",` +
+		`"object":{"metadata":{"name":"db"},"stringData":{"vars.env":"A=ENC[AES256_GCM,data:xQzK]"}}}}`
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/validate", strings.NewReader(review)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("handler status = %d, body %s", recorder.Code, recorder.Body.String())
+	}
+	var got admissionReview
+	err := json.Unmarshal(recorder.Body.Bytes(), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.APIVersion != "admission.k8s.io/v1" || got.Kind != "AdmissionReview" || got.Response == nil || got.Response.Allowed || got.Response.Status.Code != http.StatusForbidden {
+		t.Errorf("handler response = %s", recorder.Body.String())
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/validate", strings.NewReader(`{"kind":"AdmissionReview"}`)))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("handler status without a request = %d, want %d", recorder.Code, http.StatusBadRequest)
+	}
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/validate", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("handler status of GET = %d, want %d", recorder.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+// writeTestCertificate writes a self-signed certificate and its key.
+func writeTestCertificate(t *testing.T, certFile, keyFile string, name string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, certFile, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	writeTestFile(t, keyFile, string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})))
+}
+
+func Test_certificateReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	reloader := &certificateReloader{certFile: certFile, keyFile: keyFile}
+	_, err := reloader.certificate()
+	if err == nil {
+		t.Fatal("certificate() without files succeeded")
+	}
+
+	writeTestCertificate(t, certFile, keyFile, "first")
+	first, err := reloader.certificate()
+	if err != nil {
+		t.Fatalf("certificate() error = %v", err)
+	}
+	again, _ := reloader.certificate()
+	if again != first {
+		t.Errorf("certificate() reloaded unchanged files")
+	}
+
+	writeTestCertificate(t, certFile, keyFile, "second")
+	later := time.Now().Add(time.Minute)
+	_ = os.Chtimes(certFile, later, later)
+	_ = os.Chtimes(keyFile, later, later)
+	second, err := reloader.certificate()
+	if err != nil || second == first || bytes.Equal(second.Certificate[0], first.Certificate[0]) {
+		t.Errorf("certificate() did not reload changed files, error = %v", err)
+	}
+
+	writeTestFile(t, certFile, "broken")
+	_ = os.Chtimes(certFile, later.Add(time.Minute), later.Add(time.Minute))
+	kept, err := reloader.certificate()
+	if err != nil || kept != second {
+		t.Errorf("certificate() with a broken file = %v, %v, want the previous certificate", kept, err)
+	}
+}
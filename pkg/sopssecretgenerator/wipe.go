@@ -0,0 +1,14 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+// wipe overwrites a plaintext buffer with zeroes once it is no longer needed,
+// shortening the time decrypted secrets sit in process memory (and in core
+// dumps). Go strings are immutable and cannot be wiped, so plaintext should
+// be kept in byte slices for as long as possible.
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
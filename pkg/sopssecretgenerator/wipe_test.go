@@ -0,0 +1,17 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"bytes"
+	"testing"
+)
+
+func Test_wipe(t *testing.T) {
+	buf := b("secret")
+	wipe(buf)
+	if !bytes.Equal(buf, make([]byte, 6)) {
+		t.Errorf("wipe() left %q", buf)
+	}
+}
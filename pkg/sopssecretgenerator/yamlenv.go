@@ -0,0 +1,135 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlMergeKey is the key of a YAML merge. sops loses the merge tag when it
+// decrypts a file and writes the key quoted, as a plain string, which is
+// still taken as a merge: "<<" is not a valid Secret key anyway.
+const yamlMergeKey = "<<"
+
+// yamlEnv turns the mappings of a YAML env source into keys and string
+// values, with aliases and merge keys resolved. The values of a mapping are
+// computed once, however often it is merged, so that nested aliases cannot
+// blow up.
+type yamlEnv struct {
+	mappings map[*yaml.Node]map[string]string
+	// resolving are the mappings whose merges are being resolved, so that a
+	// merge that refers to a mapping it is in is an error, not a loop
+	resolving map[*yaml.Node]bool
+}
+
+// parseYAMLContent parses the decrypted content of a YAML env source: a
+// mapping of keys to strings. Aliases and `<<` merge keys are resolved, the
+// keys of a mapping winning over those it merges, as in YAML 1.1. Every other
+// key becomes a key of the Secret, so a key that holds a mapping is an error,
+// whatever its name.
+func parseYAMLContent(content []byte, data kvMap) error {
+	var document yaml.Node
+	err := yaml.Unmarshal(content, &document)
+	if err != nil {
+		return err
+	}
+	if len(document.Content) == 0 {
+		return nil
+	}
+	env := yamlEnv{mappings: make(map[*yaml.Node]map[string]string), resolving: make(map[*yaml.Node]bool)}
+	values, err := env.mapping(resolveAlias(document.Content[0]))
+	if err != nil {
+		return err
+	}
+	for k, v := range values {
+		data[k] = encodeBase64([]byte(v))
+	}
+	return nil
+}
+
+// mapping returns the keys and values of a mapping node, with its merge keys
+// resolved.
+func (env yamlEnv) mapping(node *yaml.Node) (map[string]string, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, parseError{Line: node.Line, Column: node.Column, Message: "content must be a mapping of keys to strings"}
+	}
+	if values, ok := env.mappings[node]; ok {
+		return values, nil
+	}
+	if env.resolving[node] {
+		return nil, parseError{Line: node.Line, Column: node.Column, Key: yamlMergeKey, Message: "merge refers to itself"}
+	}
+	values := make(map[string]string)
+	keyLines := make(map[string]int)
+	var merges []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], resolveAlias(node.Content[i+1])
+		if key.Value == yamlMergeKey && key.Kind == yaml.ScalarNode {
+			merges = append(merges, value)
+			continue
+		}
+		if key.Kind != yaml.ScalarNode {
+			return nil, parseError{Line: key.Line, Column: key.Column, Message: fmt.Sprintf("key must be a string, not a %s", yamlKindName(key.Kind))}
+		}
+		if first, ok := keyLines[key.Value]; ok {
+			return nil, parseError{Line: key.Line, Column: key.Column, Key: key.Value, Message: fmt.Sprintf("duplicate key, first on line %d", first)}
+		}
+		keyLines[key.Value] = key.Line
+		if value.Kind != yaml.ScalarNode {
+			return nil, parseError{Line: value.Line, Column: value.Column, Key: key.Value,
+				Message: fmt.Sprintf("value must be a string, not a %s", yamlKindName(value.Kind))}
+		}
+		var s string
+		err := value.Decode(&s)
+		if err != nil {
+			return nil, parseError{Line: value.Line, Column: value.Column, Key: key.Value, Message: "value must be a string"}
+		}
+		values[key.Value] = s
+	}
+	env.resolving[node] = true
+	for _, merge := range merges {
+		err := env.merge(merge, values)
+		if err != nil {
+			return nil, err
+		}
+	}
+	delete(env.resolving, node)
+	env.mappings[node] = values
+	return values, nil
+}
+
+// merge adds the keys of a merged mapping, or of a sequence of them, that
+// are not set yet: explicit keys win, then earlier mappings of a sequence.
+func (env yamlEnv) merge(node *yaml.Node, values map[string]string) error {
+	sources := []*yaml.Node{node}
+	if node.Kind == yaml.SequenceNode {
+		sources = node.Content
+	}
+	for _, source := range sources {
+		source = resolveAlias(source)
+		if source.Kind != yaml.MappingNode {
+			return parseError{Line: source.Line, Column: source.Column, Key: yamlMergeKey, Message: "a merge must be a mapping or a sequence of mappings"}
+		}
+		merged, err := env.mapping(source)
+		if err != nil {
+			return err
+		}
+		for k, v := range merged {
+			if _, ok := values[k]; !ok {
+				values[k] = v
+			}
+		}
+	}
+	return nil
+}
+
+// resolveAlias returns the node an alias refers to, or the node itself.
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
@@ -0,0 +1,56 @@
+// Copyright 2024-2025 Freightdog B.V. and contributors
+// Licensed under the Apache License, Version 2.0.
+
+package sopssecretgenerator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseYAMLContent_anchors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    kvMap
+		wantErr string
+	}{
+		{"Alias", "A: &a one\nB: *a\n", kvMap{"A": b64("one"), "B": b64("one")}, ""},
+		{"Merge", "<<: &common\n  A: one\n  B: two\nB: three\n", kvMap{"A": b64("one"), "B": b64("three")}, ""},
+		{"MergeAfterKeys", "A: two\n<<: {A: one}\n", kvMap{"A": b64("two")}, ""},
+		{"MergeSequence", "<<: [{B: b, C: b}, {A: a, B: a}]\n", kvMap{"A": b64("a"), "B": b64("b"), "C": b64("b")}, ""},
+		{"NestedMerge", "A: &a base\n<<:\n  <<: {B: *a}\n  C: prod\n", kvMap{"A": b64("base"), "B": b64("base"), "C": b64("prod")}, ""},
+		// sops decrypts anchors as copies, and writes the merge key quoted
+		{"DecryptedBySops", "\"<<\":\n  A: one\nB: two\n", kvMap{"A": b64("one"), "B": b64("two")}, ""},
+		{"Mapping", "common: &common\n  A: one\n<<: *common\n", nil, "line 2, column 3, key common: value must be a string, not a mapping"},
+		{"HiddenKey", ".common: &common\n  A: one\n<<: *common\n", nil, "key .common: value must be a string, not a mapping"},
+		{"ExtensionKey", "x-common: &common\n  A: one\n<<: *common\n", nil, "key x-common: value must be a string, not a mapping"},
+		{"MergeScalar", "A: &a one\n<<: *a\n", nil, "key <<: a merge must be a mapping"},
+		{"Duplicate", "A: one\nB: two\nA: secret\n", nil, "line 3, column 1, key A: duplicate key, first on line 1"},
+		{"MergeItself", "<<: &x {B: one, <<: *x}\n", nil, "key <<: merge refers to itself"},
+		{"MergeCycle", "<<: &a\n  A: one\n  <<: {B: two, <<: *a}\n", nil, "key <<: merge refers to itself"},
+		{"MergeRootItself", "&root\nA: one\n<<: *root\n", nil, "key <<: merge refers to itself"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(kvMap)
+			err := parseYAMLContent([]byte(tt.content), got)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseYAMLContent() error = %v, want %s", err, tt.wantErr)
+				}
+				if strings.Contains(err.Error(), "secret") {
+					t.Errorf("parseYAMLContent() error = %v, has content", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseYAMLContent() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseYAMLContent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}